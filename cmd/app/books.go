@@ -0,0 +1,245 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ernur-eskermes/crud-app/internal/core"
+	"github.com/go-playground/validator/v10"
+	"github.com/spf13/cobra"
+)
+
+const (
+	formatJSON = "json"
+	formatCSV  = "csv"
+
+	csvDateLayout = "2006-01-02"
+)
+
+var booksCSVHeader = []string{"id", "title", "author", "publish_date", "rating"}
+
+func newBooksCmd(c *cli) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "books",
+		Short: "Import and export books",
+	}
+
+	cmd.AddCommand(newBooksImportCmd(c), newBooksExportCmd(c))
+
+	return cmd
+}
+
+func newBooksImportCmd(c *cli) *cobra.Command {
+	var (
+		author string
+		format string
+	)
+
+	cmd := &cobra.Command{
+		Use:   "import FILE",
+		Short: "Import books from a JSON or CSV file",
+		Long: "Import books from a JSON array or a CSV file with a title,publish_date,rating header.\n" +
+			"All records are validated before any of them is written.",
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			fileFormat, err := detectFormat(format, args[0])
+			if err != nil {
+				return err
+			}
+
+			f, err := os.Open(args[0])
+			if err != nil {
+				return err
+			}
+			defer f.Close()
+
+			books, err := readBooks(f, fileFormat)
+			if err != nil {
+				return err
+			}
+
+			validate := validator.New()
+			for i, book := range books {
+				if err = validate.Struct(book); err != nil {
+					return fmt.Errorf("record %d: %w", i+1, err)
+				}
+			}
+
+			return c.withServices(cmd.Context(), func(d *deps) error {
+				user, err := d.services.Users.GetByUsername(cmd.Context(), author)
+				if err != nil {
+					return fmt.Errorf("author %s: %w", author, err)
+				}
+
+				for i, book := range books {
+					if err = d.services.Books.Create(cmd.Context(), book, user.ID); err != nil {
+						return fmt.Errorf("record %d: %w", i+1, err)
+					}
+				}
+
+				fmt.Fprintf(cmd.OutOrStdout(), "imported %d books\n", len(books))
+
+				return nil
+			})
+		},
+	}
+
+	cmd.Flags().StringVar(&author, "author", "", "username of the books author")
+	cmd.Flags().StringVar(&format, "format", "", "file format: json or csv (default: by file extension)")
+	_ = cmd.MarkFlagRequired("author")
+
+	return cmd
+}
+
+func newBooksExportCmd(c *cli) *cobra.Command {
+	var (
+		output string
+		format string
+	)
+
+	cmd := &cobra.Command{
+		Use:   "export",
+		Short: "Export all books to a JSON or CSV file",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if format == "" && output == "" {
+				format = formatJSON
+			}
+
+			fileFormat, err := detectFormat(format, output)
+			if err != nil {
+				return err
+			}
+
+			return c.withServices(cmd.Context(), func(d *deps) error {
+				books, err := d.services.Books.GetAll(cmd.Context())
+				if err != nil {
+					return err
+				}
+
+				out := cmd.OutOrStdout()
+
+				if output != "" {
+					f, err := os.Create(output)
+					if err != nil {
+						return err
+					}
+					defer f.Close()
+
+					out = f
+				}
+
+				return writeBooks(out, fileFormat, books)
+			})
+		},
+	}
+
+	cmd.Flags().StringVarP(&output, "output", "o", "", "output file (default: stdout)")
+	cmd.Flags().StringVar(&format, "format", "", "file format: json or csv (default: by file extension)")
+
+	return cmd
+}
+
+func detectFormat(format, path string) (string, error) {
+	if format == "" {
+		format = strings.TrimPrefix(filepath.Ext(path), ".")
+	}
+
+	switch format = strings.ToLower(format); format {
+	case formatJSON, formatCSV:
+		return format, nil
+	default:
+		return "", fmt.Errorf("unsupported format %q: use json or csv", format)
+	}
+}
+
+func readBooks(r io.Reader, format string) ([]core.CreateBookInput, error) {
+	var books []core.CreateBookInput
+
+	if format == formatJSON {
+		if err := json.NewDecoder(r).Decode(&books); err != nil {
+			return nil, err
+		}
+
+		return books, nil
+	}
+
+	records, err := csv.NewReader(r).ReadAll()
+	if err != nil {
+		return nil, err
+	}
+
+	if len(records) == 0 {
+		return nil, nil
+	}
+
+	columns := make(map[string]int, len(records[0]))
+	for i, name := range records[0] {
+		columns[strings.TrimSpace(name)] = i
+	}
+
+	for _, name := range []string{"title", "publish_date", "rating"} {
+		if _, ok := columns[name]; !ok {
+			return nil, fmt.Errorf("csv header: missing %s column", name)
+		}
+	}
+
+	for i, record := range records[1:] {
+		publishDate, err := time.Parse(csvDateLayout, record[columns["publish_date"]])
+		if err != nil {
+			return nil, fmt.Errorf("line %d: invalid publish_date: %w", i+2, err)
+		}
+
+		rating, err := strconv.Atoi(record[columns["rating"]])
+		if err != nil {
+			return nil, fmt.Errorf("line %d: invalid rating: %w", i+2, err)
+		}
+
+		books = append(books, core.CreateBookInput{
+			Title:       record[columns["title"]],
+			PublishDate: publishDate,
+			Rating:      rating,
+		})
+	}
+
+	return books, nil
+}
+
+func writeBooks(w io.Writer, format string, books []core.Book) error {
+	if format == formatJSON {
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+
+		return enc.Encode(books)
+	}
+
+	cw := csv.NewWriter(w)
+
+	if err := cw.Write(booksCSVHeader); err != nil {
+		return err
+	}
+
+	for _, book := range books {
+		err := cw.Write([]string{
+			book.ID.String(),
+			book.Title,
+			book.Author.String(),
+			book.PublishDate.Format(csvDateLayout),
+			strconv.Itoa(book.Rating),
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	cw.Flush()
+
+	return cw.Error()
+}
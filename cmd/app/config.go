@@ -0,0 +1,74 @@
+package main
+
+import (
+	"encoding/json"
+	"reflect"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	durationType = reflect.TypeOf(time.Duration(0))
+	timeType     = reflect.TypeOf(time.Time{})
+)
+
+func newConfigCmd(c *cli) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "config",
+		Short: "Inspect the configuration",
+	}
+
+	cmd.AddCommand(&cobra.Command{
+		Use:   "print",
+		Short: "Print the effective configuration with secrets redacted",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg, err := c.config()
+			if err != nil {
+				return err
+			}
+
+			enc := json.NewEncoder(cmd.OutOrStdout())
+			enc.SetIndent("", "  ")
+
+			return enc.Encode(printable(reflect.ValueOf(cfg.Redacted())))
+		},
+	})
+
+	return cmd
+}
+
+// printable converts config structs into maps keyed like the config files,
+// so durations are shown as "10s" instead of nanoseconds.
+func printable(v reflect.Value) interface{} {
+	switch {
+	case v.Type() == durationType:
+		return time.Duration(v.Int()).String()
+	case v.Type() == timeType:
+		return v.Interface()
+	case v.Kind() == reflect.Slice && !v.IsNil():
+		items := make([]interface{}, v.Len())
+		for i := range items {
+			items[i] = printable(v.Index(i))
+		}
+
+		return items
+	case v.Kind() == reflect.Struct:
+		fields := make(map[string]interface{}, v.NumField())
+		for i := 0; i < v.NumField(); i++ {
+			field := v.Type().Field(i)
+
+			name := field.Tag.Get("mapstructure")
+			if name == "" {
+				name = field.Name
+			}
+
+			fields[name] = printable(v.Field(i))
+		}
+
+		return fields
+	default:
+		return v.Interface()
+	}
+}
@@ -0,0 +1,199 @@
+package main
+
+import (
+	"context"
+
+	"github.com/duo-labs/webauthn/webauthn"
+	"github.com/ernur-eskermes/crud-app/internal/config"
+	"github.com/ernur-eskermes/crud-app/internal/repository"
+	"github.com/ernur-eskermes/crud-app/internal/repository/postgres"
+	"github.com/ernur-eskermes/crud-app/internal/service"
+	"github.com/ernur-eskermes/crud-app/pkg/auth"
+	"github.com/ernur-eskermes/crud-app/pkg/database/postgresql"
+	"github.com/ernur-eskermes/crud-app/pkg/hash"
+	"github.com/ernur-eskermes/crud-app/pkg/logging"
+	"github.com/ernur-eskermes/crud-app/pkg/lru"
+	"github.com/ernur-eskermes/crud-app/pkg/oidc"
+	"github.com/ernur-eskermes/crud-app/pkg/otp"
+	cache "github.com/ernur-eskermes/go-homeworks/2-cache-ttl"
+	"github.com/go-playground/validator/v10"
+)
+
+const dbConnectAttempts = 5
+
+// deps is the dependency graph shared by the server and the administration commands.
+type deps struct {
+	db           *postgresql.Cluster
+	tokenManager auth.TokenManager
+	validate     *validator.Validate
+	services     *service.Services
+}
+
+func newDeps(ctx context.Context, cfg *config.Config, logger *logging.Logger) (*deps, error) {
+	tokenManager, err := newTokenManager(cfg.Auth.JWT)
+	if err != nil {
+		return nil, err
+	}
+
+	db, err := postgresql.NewClient(ctx, dbConnectAttempts, postgresql.StorageConfig{
+		ConnStr:           cfg.Postgres.ConnStr,
+		ReplicaConnStrs:   cfg.Postgres.Replicas,
+		HealthCheckPeriod: cfg.Postgres.HealthCheckPeriod,
+		ReadYourWrites:    cfg.Postgres.ReadYourWrites,
+		Logger:            logging.NewPgxLogger(logger),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	relyingParty, err := newWebAuthn(cfg.Auth.WebAuthn)
+	if err != nil {
+		return nil, err
+	}
+
+	var bookCache service.CacheBackend
+	if cfg.Cache.Enabled {
+		bookCache = lru.New(cfg.Cache.Size, cfg.Cache.TTL)
+	}
+
+	repos := repository.NewRepositories(postgresql.NewTracedClient(db))
+	if bookCache != nil {
+		// The cache is filled from the primary, so it never keeps the view of a lagging replica.
+		repos.Books = postgres.NewBooksRepo(postgresql.NewTracedClient(db.PrimaryOnly()))
+	}
+	services := service.NewServices(service.Deps{
+		Repos:          repos,
+		Hasher:         hash.NewSHA256Hasher(cfg.Auth.PasswordSalt),
+		Cache:          cache.New(),
+		OtpGenerator:   otp.NewGOTPGenerator(),
+		TokenManager:   tokenManager,
+		AccessTokenTTL: cfg.Auth.JWT.AccessTokenTTL,
+		Environment:    cfg.Environment,
+		Domain:         cfg.HTTP.Host,
+		Lockout: service.LockoutPolicy{
+			MaxFailures:   cfg.Auth.Lockout.MaxFailures,
+			MaxIPFailures: cfg.Auth.Lockout.MaxIPFailures,
+			Backoff:       cfg.Auth.Lockout.Backoff,
+			Duration:      cfg.Auth.Lockout.Duration,
+			Window:        cfg.Auth.Lockout.Window,
+		},
+		OAuth: service.OAuthPolicy{
+			CodeTTL:         cfg.Auth.OAuth.CodeTTL,
+			RefreshTokenTTL: cfg.Auth.OAuth.RefreshTokenTTL,
+		},
+		IdentityProviders:    newIdentityProviders(cfg.Auth.OIDC),
+		WebAuthn:             relyingParty,
+		WebAuthnChallengeTTL: cfg.Auth.WebAuthn.ChallengeTTL,
+		LogVerificationCodes: cfg.Environment != config.Prod,
+		Events: service.EventsPolicy{
+			PollInterval: cfg.Events.PollInterval,
+			BatchSize:    cfg.Events.BatchSize,
+		},
+		BookStream: service.BookStreamPolicy{
+			ReplaySize: cfg.Stream.ReplaySize,
+			BufferSize: cfg.Stream.BufferSize,
+			Heartbeat:  cfg.Stream.Heartbeat,
+		},
+		Jobs: service.JobsPolicy{
+			Concurrency:   cfg.Jobs.Concurrency,
+			PollInterval:  cfg.Jobs.PollInterval,
+			Timeout:       cfg.Jobs.Timeout,
+			MaxAttempts:   cfg.Jobs.MaxAttempts,
+			RetryDelay:    cfg.Jobs.RetryDelay,
+			MaxRetryDelay: cfg.Jobs.MaxRetryDelay,
+		},
+		Webhooks: service.WebhookPolicy{
+			Timeout:       cfg.Webhooks.Timeout,
+			MaxAttempts:   cfg.Webhooks.MaxAttempts,
+			RetryDelay:    cfg.Webhooks.RetryDelay,
+			MaxRetryDelay: cfg.Webhooks.MaxRetryDelay,
+			DisableAfter:  cfg.Webhooks.DisableAfter,
+			PollInterval:  cfg.Webhooks.PollInterval,
+			BatchSize:     cfg.Webhooks.BatchSize,
+
+			AllowPrivateNetworks: cfg.Webhooks.AllowPrivateNetworks,
+		},
+		BookCache:  bookCache,
+		EventsFeed: postgres.NewOutboxFeed(postgresql.NewTracedClient(db), db.Primary()),
+	})
+
+	return &deps{
+		db:           db,
+		tokenManager: tokenManager,
+		validate:     validator.New(),
+		services:     services,
+	}, nil
+}
+
+// newTokenManager loads the signing keys. The HS256 signing key, when set, is the oldest one,
+// so configured key files take over signing while tokens signed with the secret stay valid.
+func newTokenManager(cfg config.JWTConfig) (*auth.Manager, error) {
+	var keys []auth.Key
+
+	if cfg.SigningKey != "" {
+		key, err := auth.NewSecretKey("", cfg.SigningKey)
+		if err != nil {
+			return nil, err
+		}
+
+		keys = append(keys, key)
+	}
+
+	for _, keyCfg := range cfg.Keys {
+		key, err := auth.LoadKey(keyCfg.ID, keyCfg.File)
+		if err != nil {
+			return nil, err
+		}
+
+		key.ActiveFrom = keyCfg.ActiveFrom
+		key.ExpiresAt = keyCfg.ExpiresAt
+		keys = append(keys, key)
+	}
+
+	return auth.NewManager(auth.ManagerConfig{
+		Issuer:   cfg.Issuer,
+		Audience: cfg.Audience,
+		Keys:     keys,
+		Leeway:   cfg.Leeway,
+	})
+}
+
+func newIdentityProviders(cfg config.OIDCConfig) []service.IdentityProvider {
+	providers := make([]service.IdentityProvider, 0, len(cfg.Providers))
+
+	for _, p := range cfg.Providers {
+		providers = append(providers, oidc.NewProvider(oidc.Config{
+			Name:         p.Name,
+			Issuer:       p.Issuer,
+			ClientID:     p.ClientID,
+			ClientSecret: p.ClientSecret,
+			RedirectURL:  p.RedirectURL,
+			Scopes:       p.Scopes,
+		}))
+	}
+
+	return providers
+}
+
+func newWebAuthn(cfg config.WebAuthnConfig) (*webauthn.WebAuthn, error) {
+	return webauthn.New(&webauthn.Config{
+		RPID:          cfg.RPID,
+		RPDisplayName: cfg.RPDisplayName,
+		RPOrigin:      cfg.RPOrigin,
+		Timeout:       int(cfg.ChallengeTTL.Milliseconds()),
+	})
+}
+
+// deps loads the config and wires the dependencies for a subcommand.
+func (c *cli) deps(ctx context.Context) (*deps, error) {
+	cfg, err := c.config()
+	if err != nil {
+		return nil, err
+	}
+
+	return newDeps(ctx, cfg, c.logger)
+}
+
+func (d *deps) Close() {
+	d.db.Close()
+}
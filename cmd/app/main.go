@@ -1,27 +1,15 @@
 package main
 
 import (
-	"context"
 	"os"
-	"os/signal"
-	"syscall"
-
-	"github.com/ernur-eskermes/crud-app/pkg/otp"
 
 	_ "github.com/ernur-eskermes/crud-app/docs"
 	"github.com/ernur-eskermes/crud-app/internal/config"
-	"github.com/ernur-eskermes/crud-app/internal/repository"
+	"github.com/ernur-eskermes/crud-app/internal/core"
 	"github.com/ernur-eskermes/crud-app/internal/service"
-	"github.com/ernur-eskermes/crud-app/internal/transport/rest"
-	"github.com/ernur-eskermes/crud-app/pkg/auth"
-	"github.com/ernur-eskermes/crud-app/pkg/database/postgresql"
-	"github.com/ernur-eskermes/crud-app/pkg/hash"
 	"github.com/ernur-eskermes/crud-app/pkg/logging"
-	cache "github.com/ernur-eskermes/go-homeworks/2-cache-ttl"
-	"github.com/go-playground/validator/v10"
-	"github.com/gofiber/fiber/v2"
-	"github.com/jackc/pgx/v4/log/logrusadapter"
 	_ "github.com/lib/pq"
+	"github.com/spf13/cobra"
 )
 
 const configsDir = "configs"
@@ -37,82 +25,68 @@ const configsDir = "configs"
 // @in header
 // @name Authorization
 
-// Run initializes whole application.
-func main() {
-	logger := logging.GetLogger()
+// @securityDefinitions.apikey APIKeyAuth
+// @in header
+// @name X-API-Key
 
-	cfg, err := config.Init(configsDir)
-	if err != nil {
-		logger.Fatal(err)
+func main() {
+	if err := newRootCmd(logging.GetLogger()).Execute(); err != nil {
+		os.Exit(1)
 	}
+}
 
-	// Dependencies
-	memCache := cache.New()
-
-	otpGenerator := otp.NewGOTPGenerator()
-
-	hasher := hash.NewSHA256Hasher(cfg.Auth.PasswordSalt)
+// cli holds the state shared by all subcommands.
+type cli struct {
+	logger     *logging.Logger
+	configsDir string
+}
 
-	tokenManager, err := auth.NewManager(cfg.Auth.JWT.SigningKey)
+func (c *cli) config() (*config.Config, error) {
+	cfg, err := config.Init(c.configsDir)
 	if err != nil {
-		logger.Fatal(err)
+		return nil, err
 	}
 
-	validation := validator.New()
-
-	// init db
-	db, err := postgresql.NewClient(context.TODO(), 5, postgresql.StorageConfig{
-		ConnStr: cfg.Postgres.ConnStr,
-		Logger:  logrusadapter.NewLogger(logger),
-	})
-	if err != nil {
-		logger.Fatal(err)
+	if err = logging.SetLevel(cfg.Log.Level); err != nil {
+		return nil, err
 	}
 
-	// init deps
-
-	repos := repository.NewRepositories(db)
-	services := service.NewServices(service.Deps{
-		Repos:          repos,
-		Hasher:         hasher,
-		Cache:          memCache,
-		OtpGenerator:   otpGenerator,
-		TokenManager:   tokenManager,
-		AccessTokenTTL: cfg.Auth.JWT.AccessTokenTTL,
-		Environment:    cfg.Environment,
-		Domain:         cfg.HTTP.Host,
-	})
-	handlers := rest.NewHandler(services, tokenManager, validation, logger)
-
-	// init & run server
+	return cfg, nil
+}
 
-	app := fiber.New(fiber.Config{
-		WriteTimeout: cfg.HTTP.WriteTimeout,
-		ReadTimeout:  cfg.HTTP.ReadTimeout,
-		BodyLimit:    cfg.HTTP.MaxHeaderMegabytes << 20,
-	})
+func newRootCmd(logger *logging.Logger) *cobra.Command {
+	c := &cli{logger: logger}
 
-	handlers.InitRouter(app, cfg)
+	cmd := &cobra.Command{
+		Use:          "app",
+		Short:        "CRUD App server and administration tools",
+		SilenceUsage: true,
+	}
 
-	go func() {
-		if err := app.Listen(":" + cfg.HTTP.Port); err != nil {
-			logger.Errorf("error occurred while running http server: %s\n", err.Error())
-		}
-	}()
+	cmd.PersistentFlags().StringVar(&c.configsDir, "configs", configsDir, "directory with configuration files")
 
-	logger.Info("Server started")
+	cmd.AddCommand(
+		newServeCmd(c),
+		newMigrateCmd(c),
+		newUserCmd(c),
+		newBooksCmd(c),
+		newConfigCmd(c),
+	)
 
-	// Graceful Shutdown
-	quit := make(chan os.Signal, 1)
-	signal.Notify(quit, syscall.SIGTERM, syscall.SIGINT)
+	return cmd
+}
 
-	<-quit
+// registerJobs registers the handlers of the background jobs and the schedules queuing them.
+// Only the types registered here are run by this instance.
+func registerJobs(services *service.Services, cfg config.JobsConfig) error {
+	services.RegisterJob(core.JobPurgeOutbox, services.PurgeOutbox, service.JobOptions{})
+	services.RegisterJob(core.JobPurgeJobs, services.PurgeJobs, service.JobOptions{})
 
-	logger.Info("Shutting down server")
+	purge := core.PurgeJobPayload{Retention: cfg.Retention.String()}
 
-	if err := app.Shutdown(); err != nil {
-		logger.Errorf("failed to stop server: %v", err)
+	if err := services.ScheduleJob("purge-outbox", cfg.PurgeSchedule, core.JobPurgeOutbox, purge); err != nil {
+		return err
 	}
 
-	db.Close()
+	return services.ScheduleJob("purge-jobs", cfg.PurgeSchedule, core.JobPurgeJobs, purge)
 }
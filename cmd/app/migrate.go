@@ -0,0 +1,158 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"text/tabwriter"
+
+	"github.com/ernur-eskermes/crud-app/internal/config"
+	"github.com/ernur-eskermes/crud-app/migrations"
+	"github.com/ernur-eskermes/crud-app/pkg/logging"
+	"github.com/spf13/cobra"
+)
+
+func newMigrateCmd(c *cli) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "migrate",
+		Short: "Manage database migrations",
+	}
+
+	cmd.AddCommand(
+		&cobra.Command{
+			Use:   "up",
+			Short: "Apply all pending migrations",
+			Args:  cobra.NoArgs,
+			RunE: func(cmd *cobra.Command, args []string) error {
+				return c.withMigrator(func(m *migrations.Migrator) error {
+					return m.Up()
+				})
+			},
+		},
+		&cobra.Command{
+			Use:   "down N|all",
+			Short: "Roll back N or all migrations",
+			Args:  cobra.ExactArgs(1),
+			RunE: func(cmd *cobra.Command, args []string) error {
+				if args[0] == "all" {
+					return c.withMigrator(func(m *migrations.Migrator) error {
+						return m.DownAll()
+					})
+				}
+
+				n, err := strconv.Atoi(args[0])
+				if err != nil || n < 1 {
+					return fmt.Errorf("invalid number of migrations: %s", args[0])
+				}
+
+				return c.withMigrator(func(m *migrations.Migrator) error {
+					return m.Down(n)
+				})
+			},
+		},
+		&cobra.Command{
+			Use:   "to V",
+			Short: "Migrate up or down to version V",
+			Args:  cobra.ExactArgs(1),
+			RunE: func(cmd *cobra.Command, args []string) error {
+				version, err := strconv.ParseUint(args[0], 10, 64)
+				if err != nil {
+					return fmt.Errorf("invalid version: %s", args[0])
+				}
+
+				return c.withMigrator(func(m *migrations.Migrator) error {
+					return m.To(uint(version))
+				})
+			},
+		},
+		&cobra.Command{
+			Use:   "force V",
+			Short: "Set version V without running migrations and clear the dirty flag",
+			Args:  cobra.ExactArgs(1),
+			RunE: func(cmd *cobra.Command, args []string) error {
+				version, err := strconv.Atoi(args[0])
+				if err != nil {
+					return fmt.Errorf("invalid version: %s", args[0])
+				}
+
+				return c.withMigrator(func(m *migrations.Migrator) error {
+					return m.Force(version)
+				})
+			},
+		},
+		&cobra.Command{
+			Use:   "status",
+			Short: "Show applied and pending migrations",
+			Args:  cobra.NoArgs,
+			RunE: func(cmd *cobra.Command, args []string) error {
+				return c.withMigrator(func(m *migrations.Migrator) error {
+					return printMigrationStatus(cmd.OutOrStdout(), m)
+				})
+			},
+		},
+	)
+
+	return cmd
+}
+
+func (c *cli) withMigrator(fn func(m *migrations.Migrator) error) error {
+	cfg, err := c.config()
+	if err != nil {
+		return err
+	}
+
+	m, err := migrations.NewMigrator(cfg.Postgres.ConnStr, c.logger)
+	if err != nil {
+		return err
+	}
+	defer m.Close()
+
+	return fn(m)
+}
+
+func printMigrationStatus(out io.Writer, m *migrations.Migrator) error {
+	version, dirty, err := m.Version()
+	if err != nil {
+		return err
+	}
+
+	list, err := migrations.List()
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "VERSION\tNAME\tSTATUS")
+
+	for _, migration := range list {
+		status := "pending"
+
+		switch {
+		case migration.Version == version && dirty:
+			status = "dirty"
+		case migration.Version <= version:
+			status = "applied"
+		}
+
+		fmt.Fprintf(w, "%d\t%s\t%s\n", migration.Version, migration.Name, status)
+	}
+
+	return w.Flush()
+}
+
+// autoMigrate applies pending migrations on start when enabled in the config.
+func autoMigrate(cfg *config.Config, logger *logging.Logger) error {
+	if !cfg.Postgres.AutoMigrate {
+		return nil
+	}
+
+	m, err := migrations.NewMigrator(cfg.Postgres.ConnStr, logger)
+	if err != nil {
+		return err
+	}
+	defer m.Close()
+
+	logger.Info("Applying migrations")
+
+	return m.Up()
+}
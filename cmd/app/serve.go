@@ -0,0 +1,182 @@
+package main
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/ernur-eskermes/crud-app/internal/config"
+	"github.com/ernur-eskermes/crud-app/internal/transport/grpc"
+	"github.com/ernur-eskermes/crud-app/internal/transport/rest"
+	"github.com/ernur-eskermes/crud-app/migrations"
+	"github.com/ernur-eskermes/crud-app/pkg/database/postgresql"
+	"github.com/ernur-eskermes/crud-app/pkg/health"
+	"github.com/ernur-eskermes/crud-app/pkg/logging"
+	"github.com/ernur-eskermes/crud-app/pkg/ratelimit"
+	"github.com/ernur-eskermes/crud-app/pkg/tracing"
+	"github.com/gofiber/fiber/v2"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/spf13/cobra"
+)
+
+const healthCheckTimeout = 3 * time.Second
+
+func newServeCmd(c *cli) *cobra.Command {
+	return &cobra.Command{
+		Use:   "serve",
+		Short: "Run the HTTP and gRPC servers",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return c.serve()
+		},
+	}
+}
+
+// serve initializes whole application and blocks until it is stopped.
+func (c *cli) serve() error {
+	logger := c.logger
+
+	cfg, err := c.config()
+	if err != nil {
+		return err
+	}
+
+	if err = autoMigrate(cfg, logger); err != nil {
+		return err
+	}
+
+	schemaVersion, err := migrations.Latest()
+	if err != nil {
+		return err
+	}
+
+	shutdownTracing, err := tracing.Init(context.Background(), tracing.Config{
+		Exporter:    cfg.Tracing.Exporter,
+		Endpoint:    cfg.Tracing.Endpoint,
+		Insecure:    cfg.Tracing.Insecure,
+		ServiceName: cfg.Tracing.ServiceName,
+		SampleRatio: cfg.Tracing.SampleRatio,
+	})
+	if err != nil {
+		return err
+	}
+
+	d, err := newDeps(context.TODO(), cfg, logger)
+	if err != nil {
+		return err
+	}
+
+	if err = registerJobs(d.services, cfg.Jobs); err != nil {
+		return err
+	}
+
+	prometheus.MustRegister(postgresql.NewStatsCollector(d.db))
+
+	checker := health.NewChecker(healthCheckTimeout)
+	checker.Add("postgres", postgresql.PingCheck(d.db.Primary()))
+	checker.Add("migrations", postgresql.SchemaVersionCheck(d.db, schemaVersion))
+
+	var limitStore ratelimit.Store = ratelimit.NewMemoryStore(cfg.Limiter.TTL)
+	if cfg.Limiter.Store == config.LimiterStorePostgres {
+		limitStore = ratelimit.NewPostgresStore(postgresql.NewTracedClient(d.db), cfg.Limiter.TTL)
+	}
+
+	handlers := rest.NewHandler(d.services, d.tokenManager, d.validate, checker, limitStore, logger)
+
+	// init & run server
+
+	app := fiber.New(fiber.Config{
+		WriteTimeout: cfg.HTTP.WriteTimeout,
+		ReadTimeout:  cfg.HTTP.ReadTimeout,
+		BodyLimit:    cfg.HTTP.MaxHeaderMegabytes << 20,
+		ErrorHandler: handlers.ErrorHandler,
+	})
+
+	handlers.InitRouter(app, cfg)
+
+	grpcServer := grpc.NewServer(d.services, d.validate, logger)
+
+	watcher := config.NewWatcher(c.configsDir, cfg, logger)
+	watcher.Subscribe(handlers.Reconfigure)
+	watcher.Subscribe(func(cfg *config.Config) {
+		d.services.SetAccessTokenTTL(cfg.Auth.JWT.AccessTokenTTL)
+	})
+	watcher.Subscribe(func(cfg *config.Config) {
+		if err := logging.SetLevel(cfg.Log.Level); err != nil {
+			logger.Errorf("failed to set log level: %v", err)
+		}
+	})
+
+	watchCtx, stopWatching := context.WithCancel(context.Background())
+	defer stopWatching()
+
+	go func() {
+		if err := watcher.Run(watchCtx); err != nil {
+			logger.Errorf("error occurred while watching config: %s\n", err.Error())
+		}
+	}()
+
+	workersCtx, stopWorkers := context.WithCancel(context.Background())
+	defer stopWorkers()
+
+	workersDone := make(chan struct{})
+
+	go func() {
+		defer close(workersDone)
+
+		if err := d.services.Run(workersCtx); err != nil {
+			logger.Errorf("error occurred while running background workers: %s\n", err.Error())
+		}
+	}()
+
+	go func() {
+		if err := app.Listen(":" + cfg.HTTP.Port); err != nil {
+			logger.Errorf("error occurred while running http server: %s\n", err.Error())
+		}
+	}()
+
+	go func() {
+		if err := grpcServer.ListenAndServe(cfg.GRPC.Port); err != nil {
+			logger.Errorf("error occurred while running grpc server: %s\n", err.Error())
+		}
+	}()
+
+	logger.Info("Server started")
+
+	// Graceful Shutdown
+	quit := make(chan os.Signal, 1)
+	signal.Notify(quit, syscall.SIGTERM, syscall.SIGINT)
+
+	<-quit
+
+	logger.Info("Shutting down server")
+
+	stopWatching()
+
+	// Fail readiness first and give load balancers time to stop routing traffic here.
+	checker.Shutdown()
+	time.Sleep(cfg.HTTP.ShutdownDelay)
+
+	// Streams never finish by themselves, the server would wait for them forever.
+	d.services.CloseStreams()
+
+	if err := app.Shutdown(); err != nil {
+		logger.Errorf("failed to stop server: %v", err)
+	}
+
+	grpcServer.Stop()
+
+	// Let the events and deliveries in flight be recorded before the pool is closed.
+	stopWorkers()
+	<-workersDone
+
+	d.Close()
+
+	if err := shutdownTracing(context.Background()); err != nil {
+		logger.Errorf("failed to flush traces: %v", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/ernur-eskermes/crud-app/internal/core"
+	"github.com/ernur-eskermes/crud-app/internal/service"
+	"github.com/go-playground/validator/v10"
+	"github.com/spf13/cobra"
+)
+
+type userCreateInput struct {
+	Username string `validate:"required,max=64"`
+	Password string `validate:"required,min=8,max=64"`
+}
+
+func newUserCmd(c *cli) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "user",
+		Short: "Administer users",
+	}
+
+	cmd.AddCommand(
+		newUserCreateCmd(c),
+		&cobra.Command{
+			Use:   "promote USERNAME",
+			Short: "Grant the admin role to a user",
+			Args:  cobra.ExactArgs(1),
+			RunE: func(cmd *cobra.Command, args []string) error {
+				return c.withServices(cmd.Context(), func(d *deps) error {
+					if err := d.services.Users.Promote(cmd.Context(), args[0]); err != nil {
+						return err
+					}
+
+					fmt.Fprintf(cmd.OutOrStdout(), "user %s promoted to %s\n", args[0], core.RoleAdmin)
+
+					return nil
+				})
+			},
+		},
+		&cobra.Command{
+			Use:   "verify USERNAME",
+			Short: "Activate a user without a verification code",
+			Args:  cobra.ExactArgs(1),
+			RunE: func(cmd *cobra.Command, args []string) error {
+				return c.withServices(cmd.Context(), func(d *deps) error {
+					if err := d.services.Users.MarkVerified(cmd.Context(), args[0]); err != nil {
+						return err
+					}
+
+					fmt.Fprintf(cmd.OutOrStdout(), "user %s verified\n", args[0])
+
+					return nil
+				})
+			},
+		},
+		&cobra.Command{
+			Use:   "unlock USERNAME",
+			Short: "Lift a lockout after failed sign in attempts",
+			Args:  cobra.ExactArgs(1),
+			RunE: func(cmd *cobra.Command, args []string) error {
+				return c.withServices(cmd.Context(), func(d *deps) error {
+					if err := d.services.Users.Unlock(cmd.Context(), args[0]); err != nil {
+						return err
+					}
+
+					fmt.Fprintf(cmd.OutOrStdout(), "user %s unlocked\n", args[0])
+
+					return nil
+				})
+			},
+		},
+	)
+
+	return cmd
+}
+
+func newUserCreateCmd(c *cli) *cobra.Command {
+	var (
+		password string
+		admin    bool
+		verified bool
+	)
+
+	cmd := &cobra.Command{
+		Use:   "create USERNAME",
+		Short: "Create a user",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			inp := service.UserCreateInput{
+				Username: args[0],
+				Password: password,
+				Role:     core.RoleUser,
+				Verified: verified,
+			}
+			if admin {
+				inp.Role = core.RoleAdmin
+			}
+
+			if err := validator.New().Struct(userCreateInput{Username: inp.Username, Password: inp.Password}); err != nil {
+				return err
+			}
+
+			return c.withServices(cmd.Context(), func(d *deps) error {
+				user, err := d.services.Users.Create(cmd.Context(), inp)
+				if err != nil {
+					return err
+				}
+
+				fmt.Fprintf(cmd.OutOrStdout(), "user %s created with id %s\n", user.Username, user.ID)
+
+				return nil
+			})
+		},
+	}
+
+	cmd.Flags().StringVarP(&password, "password", "p", "", "user password")
+	cmd.Flags().BoolVar(&admin, "admin", false, "grant the admin role")
+	cmd.Flags().BoolVar(&verified, "verified", true, "create the user already verified")
+	_ = cmd.MarkFlagRequired("password")
+
+	return cmd
+}
+
+// withServices wires the dependencies, runs fn and releases them afterwards.
+func (c *cli) withServices(ctx context.Context, fn func(d *deps) error) error {
+	d, err := c.deps(ctx)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+
+	return fn(d)
+}
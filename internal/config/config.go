@@ -1,131 +1,515 @@
 package config
 
 import (
+	"net/url"
 	"os"
+	"reflect"
+	"strings"
 	"time"
+	"unicode"
 
+	"github.com/mitchellh/mapstructure"
 	"github.com/spf13/viper"
 )
 
 const (
 	defaultHTTPPort               = "8000"
+	defaultGRPCPort               = "9000"
 	defaultHTTPRWTimeout          = 10 * time.Second
 	defaultHTTPMaxHeaderMegabytes = 1
+	defaultHTTPShutdownDelay      = 5 * time.Second
 	defaultAccessTokenTTL         = 15 * time.Minute
+	defaultJWTIssuer              = "crud-app"
+	defaultJWTLeeway              = 30 * time.Second
 	defaultLimiterRPS             = 10
 	defaultLimiterBurst           = 2
 	defaultLimiterTTL             = 10 * time.Minute
+	defaultLimiterStore           = LimiterStoreMemory
+	defaultLockoutMaxFailures     = 5
+	defaultLockoutMaxIPFailures   = 20
+	defaultLockoutBackoff         = time.Second
+	defaultLockoutDuration        = 15 * time.Minute
+	defaultLockoutWindow          = time.Hour
+	defaultOAuthCodeTTL           = time.Minute
+	defaultOAuthRefreshTokenTTL   = 30 * 24 * time.Hour
+	defaultWebAuthnRPID           = "localhost"
+	defaultWebAuthnRPOrigin       = "http://localhost:8000"
+	defaultWebAuthnChallengeTTL   = 5 * time.Minute
+	defaultWebhooksTimeout        = 10 * time.Second
+	defaultWebhooksMaxAttempts    = 8
+	defaultWebhooksRetryDelay     = 30 * time.Second
+	defaultWebhooksMaxRetryDelay  = time.Hour
+	defaultWebhooksDisableAfter   = 20
+	defaultWebhooksPollInterval   = time.Second
+	defaultWebhooksBatchSize      = 50
+	defaultEventsPollInterval     = 500 * time.Millisecond
+	defaultEventsBatchSize        = 100
+	defaultJobsConcurrency        = 4
+	defaultJobsPollInterval       = time.Second
+	defaultJobsTimeout            = 5 * time.Minute
+	defaultJobsMaxAttempts        = 10
+	defaultJobsRetryDelay         = 10 * time.Second
+	defaultJobsMaxRetryDelay      = time.Hour
+	defaultJobsPurgeSchedule      = "0 3 * * *"
+	defaultJobsRetention          = 7 * 24 * time.Hour
+	defaultStreamReplaySize       = 1000
+	defaultPostgresHealthCheck    = 5 * time.Second
+	defaultPostgresReadYourWrites = 5 * time.Second
+	defaultCacheEnabled           = true
+	defaultCacheSize              = 1000
+	defaultCacheTTL               = time.Minute
+	defaultStreamBufferSize       = 64
+	defaultStreamHeartbeat        = 15 * time.Second
+	defaultTracingExporter        = "none"
+	defaultTracingServiceName     = "crud-app"
+	defaultTracingSampleRatio     = 1
+	defaultLogLevel               = "trace"
+
+	// EnvPrefix is prepended to the environment variable of every config key,
+	// e.g. auth.jwt.signingKey is overridden by APP_AUTH_JWT_SIGNING_KEY.
+	EnvPrefix = "APP"
+
+	LimiterStoreMemory   = "memory"
+	LimiterStorePostgres = "postgres"
+
+	redactedValue = "REDACTED"
 
 	EnvLocal = "local"
+	Stage    = "stage"
 	Prod     = "prod"
 )
 
 type (
 	Config struct {
-		Environment string
-		Postgres    PostgresConfig
-		HTTP        HTTPConfig
-		Auth        AuthConfig
-		Limiter     LimiterConfig
+		Environment string         `mapstructure:"env" validate:"required,oneof=local stage prod"`
+		Postgres    PostgresConfig `mapstructure:"postgres"`
+		HTTP        HTTPConfig     `mapstructure:"http"`
+		GRPC        GRPCConfig     `mapstructure:"grpc"`
+		Auth        AuthConfig     `mapstructure:"auth"`
+		Limiter     LimiterConfig  `mapstructure:"limiter"`
+		Events      EventsConfig   `mapstructure:"events"`
+		Stream      StreamConfig   `mapstructure:"stream"`
+		Cache       CacheConfig    `mapstructure:"cache"`
+		Jobs        JobsConfig     `mapstructure:"jobs"`
+		Webhooks    WebhooksConfig `mapstructure:"webhooks"`
+		Tracing     TracingConfig  `mapstructure:"tracing"`
+		Log         LogConfig      `mapstructure:"log"`
 	}
 
+	// PostgresConfig describes the primary and the optional read replicas. The replicas are pinged every
+	// HealthCheckPeriod, and a user reads from the primary for ReadYourWrites after their own writes.
+	// The writes are tracked by the instance that served them, other instances may still read from a replica.
 	PostgresConfig struct {
-		ConnStr string
+		ConnStr           string        `mapstructure:"uri" validate:"required"`
+		Replicas          []string      `mapstructure:"replicas" validate:"dive,required"`
+		HealthCheckPeriod time.Duration `mapstructure:"healthCheckPeriod" validate:"min=1s,max=1m"`
+		ReadYourWrites    time.Duration `mapstructure:"readYourWrites" validate:"min=0,max=1m"`
+		AutoMigrate       bool          `mapstructure:"autoMigrate"`
 	}
 
 	AuthConfig struct {
-		JWT           JWTConfig
-		SessionSecret string
-		PasswordSalt  string
+		JWT           JWTConfig      `mapstructure:"jwt"`
+		Lockout       LockoutConfig  `mapstructure:"lockout"`
+		OIDC          OIDCConfig     `mapstructure:"oidc"`
+		OAuth         OAuthConfig    `mapstructure:"oauth"`
+		WebAuthn      WebAuthnConfig `mapstructure:"webauthn"`
+		SessionSecret string         `mapstructure:"sessionSecret"`
+		PasswordSalt  string         `mapstructure:"passwordSalt" validate:"required"`
 	}
 
+	// JWTConfig describes token signing. SigningKey is an HS256 secret, Keys are RS256 or EdDSA
+	// private keys for rotation; at least one of them is required.
 	JWTConfig struct {
-		AccessTokenTTL time.Duration `mapstructure:"accessTokenTTL"`
-		SigningKey     string
+		AccessTokenTTL time.Duration  `mapstructure:"accessTokenTTL" validate:"min=1m,max=24h"`
+		SigningKey     string         `mapstructure:"signingKey" validate:"required_without=Keys"`
+		Issuer         string         `mapstructure:"issuer" validate:"required"`
+		Audience       []string       `mapstructure:"audience" validate:"min=1,dive,required"`
+		Keys           []JWTKeyConfig `mapstructure:"keys" validate:"dive"`
+		Leeway         time.Duration  `mapstructure:"leeway" validate:"min=0,max=5m"`
+	}
+
+	// JWTKeyConfig is a PEM private key file. The key signs tokens from ActiveFrom
+	// and is accepted until ExpiresAt (forever when empty).
+	JWTKeyConfig struct {
+		ID         string    `mapstructure:"id" validate:"required"`
+		File       string    `mapstructure:"file" validate:"required"`
+		ActiveFrom time.Time `mapstructure:"activeFrom"`
+		ExpiresAt  time.Time `mapstructure:"expiresAt"`
+	}
+
+	// LockoutConfig limits failed sign in and verification attempts, see service.LockoutPolicy.
+	LockoutConfig struct {
+		MaxFailures   int           `mapstructure:"maxFailures" validate:"min=1"`
+		MaxIPFailures int           `mapstructure:"maxIPFailures" validate:"min=1"`
+		Backoff       time.Duration `mapstructure:"backoff" validate:"min=0"`
+		Duration      time.Duration `mapstructure:"duration" validate:"min=1s"`
+		Window        time.Duration `mapstructure:"window" validate:"min=1m"`
+	}
+
+	// OIDCConfig lists the OpenID Connect providers users can sign in with.
+	OIDCConfig struct {
+		Providers []OIDCProviderConfig `mapstructure:"providers" validate:"unique=Name,dive"`
+	}
+
+	// OIDCProviderConfig is a client registered at the provider. RedirectURL must point to
+	// /api/v1/auth/oidc/{name}/callback. When ClientSecret is empty it is read from
+	// APP_AUTH_OIDC_{NAME}_CLIENT_SECRET, see ClientSecretEnv.
+	OIDCProviderConfig struct {
+		Name         string   `mapstructure:"name" validate:"required,alphanum"`
+		Issuer       string   `mapstructure:"issuer" validate:"required,url"`
+		ClientID     string   `mapstructure:"clientID" validate:"required"`
+		ClientSecret string   `mapstructure:"clientSecret"`
+		RedirectURL  string   `mapstructure:"redirectURL" validate:"required,url"`
+		Scopes       []string `mapstructure:"scopes"`
+	}
+
+	// OAuthConfig sets the lifetimes of the authorization server tokens, see service.OAuthPolicy.
+	OAuthConfig struct {
+		CodeTTL         time.Duration `mapstructure:"codeTTL" validate:"min=10s,max=10m"`
+		RefreshTokenTTL time.Duration `mapstructure:"refreshTokenTTL" validate:"min=1h"`
+	}
+
+	// WebAuthnConfig describes the relying party of passkeys. RPID is the domain passkeys are bound to,
+	// RPOrigin the origin of the pages calling the WebAuthn API; it must be on RPID or its subdomain.
+	WebAuthnConfig struct {
+		RPID          string        `mapstructure:"rpID" validate:"required,hostname"`
+		RPDisplayName string        `mapstructure:"rpDisplayName" validate:"required"`
+		RPOrigin      string        `mapstructure:"rpOrigin" validate:"required,url"`
+		ChallengeTTL  time.Duration `mapstructure:"challengeTTL" validate:"min=30s,max=10m"`
 	}
 
 	HTTPConfig struct {
 		Host               string        `mapstructure:"host"`
-		Port               string        `mapstructure:"port"`
-		ReadTimeout        time.Duration `mapstructure:"readTimeout"`
-		WriteTimeout       time.Duration `mapstructure:"writeTimeout"`
-		MaxHeaderMegabytes int           `mapstructure:"maxHeaderBytes"`
+		Port               string        `mapstructure:"port" validate:"required,numeric"`
+		ReadTimeout        time.Duration `mapstructure:"readTimeout" validate:"min=1s"`
+		WriteTimeout       time.Duration `mapstructure:"writeTimeout" validate:"min=1s"`
+		MaxHeaderMegabytes int           `mapstructure:"maxHeaderBytes" validate:"min=1,max=64"`
+		ShutdownDelay      time.Duration `mapstructure:"shutdownDelay" validate:"min=0,max=1m"`
+		CORSOrigins        []string      `mapstructure:"corsOrigins" validate:"min=1,dive,required"`
+	}
+
+	GRPCConfig struct {
+		Port string `mapstructure:"port" validate:"required,numeric"`
 	}
 
+	// LimiterConfig describes token bucket rate limits: RPS and Burst are the default limit,
+	// Rules override it for matching routes.
 	LimiterConfig struct {
-		RPS   int
-		Burst int
-		TTL   time.Duration
+		Store string        `mapstructure:"store" validate:"oneof=memory postgres"`
+		RPS   float64       `mapstructure:"rps" validate:"gt=0"`
+		Burst int           `mapstructure:"burst" validate:"min=1"`
+		TTL   time.Duration `mapstructure:"ttl" validate:"min=1s"`
+		Rules []LimiterRule `mapstructure:"rules" validate:"dive"`
+	}
+
+	// LimiterRule applies its own limit to requests with the Method (any when empty)
+	// whose path is Path or is under it.
+	LimiterRule struct {
+		Name   string  `mapstructure:"name" validate:"required"`
+		Method string  `mapstructure:"method" validate:"omitempty,oneof=GET POST PUT PATCH DELETE"`
+		Path   string  `mapstructure:"path" validate:"required,startswith=/"`
+		RPS    float64 `mapstructure:"rps" validate:"gt=0"`
+		Burst  int     `mapstructure:"burst" validate:"min=1"`
+	}
+
+	// EventsConfig describes the publishing of the domain events recorded in the outbox.
+	EventsConfig struct {
+		PollInterval time.Duration `mapstructure:"pollInterval" validate:"min=100ms,max=1m"`
+		BatchSize    int           `mapstructure:"batchSize" validate:"min=1,max=1000"`
+	}
+
+	// StreamConfig describes the stream of book events: the last ReplaySize events are kept for resuming
+	// clients, a client more than BufferSize events behind is disconnected, idle streams are pinged every Heartbeat.
+	StreamConfig struct {
+		ReplaySize int           `mapstructure:"replaySize" validate:"min=1,max=100000"`
+		BufferSize int           `mapstructure:"bufferSize" validate:"min=1,max=10000"`
+		Heartbeat  time.Duration `mapstructure:"heartbeat" validate:"min=1s,max=1m"`
+	}
+
+	// CacheConfig describes the cache of the book reads: up to Size books and lists are kept for TTL.
+	// Every instance has its own cache, invalidated by the book events of the others as well.
+	// TTL bounds the staleness when the events don't get through.
+	CacheConfig struct {
+		Enabled bool          `mapstructure:"enabled"`
+		Size    int           `mapstructure:"size" validate:"min=1,max=1000000"`
+		TTL     time.Duration `mapstructure:"ttl" validate:"min=1s,max=1h"`
+	}
+
+	// JobsConfig describes the background jobs: Concurrency jobs run at once per instance, an attempt
+	// times out after Timeout, failed jobs are retried MaxAttempts times with a backoff growing from RetryDelay
+	// to MaxRetryDelay. Published events and succeeded jobs older than Retention are purged on PurgeSchedule.
+	JobsConfig struct {
+		Concurrency   int           `mapstructure:"concurrency" validate:"min=1,max=100"`
+		PollInterval  time.Duration `mapstructure:"pollInterval" validate:"min=100ms,max=1m"`
+		Timeout       time.Duration `mapstructure:"timeout" validate:"min=1s"`
+		MaxAttempts   int           `mapstructure:"maxAttempts" validate:"min=1,max=100"`
+		RetryDelay    time.Duration `mapstructure:"retryDelay" validate:"min=1s"`
+		MaxRetryDelay time.Duration `mapstructure:"maxRetryDelay" validate:"gtefield=RetryDelay"`
+		PurgeSchedule string        `mapstructure:"purgeSchedule" validate:"required"`
+		Retention     time.Duration `mapstructure:"retention" validate:"min=1h"`
+	}
+
+	// WebhooksConfig describes the delivery of book events to webhooks: failed deliveries are retried
+	// MaxAttempts times with a backoff growing from RetryDelay to MaxRetryDelay, and a webhook is disabled
+	// after DisableAfter failed attempts in a row. Webhooks reach only public addresses unless AllowPrivateNetworks.
+	WebhooksConfig struct {
+		Timeout       time.Duration `mapstructure:"timeout" validate:"min=1s,max=1m"`
+		MaxAttempts   int           `mapstructure:"maxAttempts" validate:"min=1,max=30"`
+		RetryDelay    time.Duration `mapstructure:"retryDelay" validate:"min=1s"`
+		MaxRetryDelay time.Duration `mapstructure:"maxRetryDelay" validate:"gtefield=RetryDelay"`
+		DisableAfter  int           `mapstructure:"disableAfter" validate:"min=1"`
+		PollInterval  time.Duration `mapstructure:"pollInterval" validate:"min=100ms,max=1m"`
+		BatchSize     int           `mapstructure:"batchSize" validate:"min=1,max=1000"`
+
+		AllowPrivateNetworks bool `mapstructure:"allowPrivateNetworks"`
+	}
+
+	TracingConfig struct {
+		Exporter    string  `mapstructure:"exporter" validate:"oneof=none stdout otlp"`
+		Endpoint    string  `mapstructure:"endpoint" validate:"required_if=Exporter otlp"`
+		Insecure    bool    `mapstructure:"insecure"`
+		ServiceName string  `mapstructure:"serviceName" validate:"required"`
+		SampleRatio float64 `mapstructure:"sampleRatio" validate:"min=0,max=1"`
+	}
+
+	LogConfig struct {
+		Level string `mapstructure:"level" validate:"oneof=trace debug info warn error"`
 	}
 )
 
+// legacyEnv maps config keys to the environment variables used before EnvPrefix was introduced.
+// They are still honoured when the prefixed variable is not set.
+var legacyEnv = map[string]string{
+	"postgres.uri":        "POSTGRES_URI",
+	"auth.passwordSalt":   "PASSWORD_SALT",
+	"auth.sessionSecret":  "SESSION_SECRET",
+	"auth.jwt.signingKey": "JWT_SIGNING_KEY",
+	"http.host":           "HTTP_HOST",
+	"tracing.exporter":    "TRACING_EXPORTER",
+	"tracing.endpoint":    "TRACING_ENDPOINT",
+}
+
 // Init populates Config struct with values from config file
-// located at filepath and environment variables.
+// located at filepath and environment variables, and validates the result.
 func Init(configsDir string) (*Config, error) {
-	populateDefaults()
+	v := viper.New()
+
+	populateDefaults(v)
+
+	if err := bindEnv(v, reflect.TypeOf(Config{}), ""); err != nil {
+		return nil, err
+	}
 
-	if err := parseConfigFile(configsDir, os.Getenv("APP_ENV")); err != nil {
+	if err := parseConfigFile(v, configsDir, v.GetString("env")); err != nil {
 		return nil, err
 	}
 
 	var cfg Config
-	if err := unmarshal(&cfg); err != nil {
+	decodeHook := viper.DecodeHook(mapstructure.ComposeDecodeHookFunc(
+		mapstructure.StringToTimeDurationHookFunc(),
+		mapstructure.StringToSliceHookFunc(","),
+		mapstructure.StringToTimeHookFunc(time.RFC3339),
+	))
+
+	if err := v.Unmarshal(&cfg, decodeHook); err != nil {
 		return nil, err
 	}
 
-	setFromEnv(&cfg)
+	for i, p := range cfg.Auth.OIDC.Providers {
+		if p.ClientSecret == "" {
+			cfg.Auth.OIDC.Providers[i].ClientSecret = os.Getenv(p.ClientSecretEnv())
+		}
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
 
 	return &cfg, nil
 }
 
-func unmarshal(cfg *Config) error {
-	if err := viper.UnmarshalKey("http", &cfg.HTTP); err != nil {
-		return err
+// ClientSecretEnv is the environment variable holding the client secret of the provider,
+// e.g. APP_AUTH_OIDC_GOOGLE_CLIENT_SECRET. Providers are listed in the files only,
+// so their secrets can't be bound like the other keys.
+func (p OIDCProviderConfig) ClientSecretEnv() string {
+	return envName("auth.oidc." + strings.ToLower(p.Name) + ".clientSecret")
+}
+
+// Redacted returns a copy of the config that is safe to print:
+// secrets are masked and the password is removed from the database URI.
+func (c Config) Redacted() Config {
+	c.Postgres.ConnStr = redactConnStr(c.Postgres.ConnStr)
+
+	if len(c.Postgres.Replicas) > 0 {
+		replicas := make([]string, len(c.Postgres.Replicas))
+		for i, connStr := range c.Postgres.Replicas {
+			replicas[i] = redactConnStr(connStr)
+		}
+
+		c.Postgres.Replicas = replicas
 	}
+	c.Auth.PasswordSalt = redact(c.Auth.PasswordSalt)
+	c.Auth.JWT.SigningKey = redact(c.Auth.JWT.SigningKey)
+	c.Auth.SessionSecret = redact(c.Auth.SessionSecret)
 
-	if err := viper.UnmarshalKey("auth", &cfg.Auth.JWT); err != nil {
-		return err
+	if len(c.Auth.OIDC.Providers) > 0 {
+		providers := make([]OIDCProviderConfig, len(c.Auth.OIDC.Providers))
+		for i, p := range c.Auth.OIDC.Providers {
+			p.ClientSecret = redact(p.ClientSecret)
+			providers[i] = p
+		}
+
+		c.Auth.OIDC.Providers = providers
 	}
 
-	return viper.UnmarshalKey("limiter", &cfg.Limiter)
+	return c
 }
 
-func setFromEnv(cfg *Config) {
-	cfg.Postgres.ConnStr = os.Getenv("POSTGRES_URI")
+func redact(secret string) string {
+	if secret == "" {
+		return ""
+	}
 
-	cfg.Auth.PasswordSalt = os.Getenv("PASSWORD_SALT")
-	cfg.Auth.JWT.SigningKey = os.Getenv("JWT_SIGNING_KEY")
-	cfg.Auth.SessionSecret = os.Getenv("SESSION_SECRET")
+	return redactedValue
+}
+
+// redactConnStr masks the password of a URI connection string.
+// Key/value connection strings are masked entirely.
+func redactConnStr(connStr string) string {
+	u, err := url.Parse(connStr)
+	if err != nil || u.Scheme == "" {
+		return redact(connStr)
+	}
+
+	return u.Redacted()
+}
+
+// bindEnv registers an environment variable for every leaf key of the config struct,
+// so the keys can be set from the environment even when they are absent in the files.
+func bindEnv(v *viper.Viper, t reflect.Type, prefix string) error {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		key := prefix + field.Tag.Get("mapstructure")
+
+		if field.Type.Kind() == reflect.Struct {
+			if err := bindEnv(v, field.Type, key+"."); err != nil {
+				return err
+			}
+
+			continue
+		}
+
+		// Lists of sections can only be set in the files.
+		if field.Type.Kind() == reflect.Slice && field.Type.Elem().Kind() == reflect.Struct {
+			continue
+		}
 
-	cfg.HTTP.Host = os.Getenv("HTTP_HOST")
+		names := []string{envName(key)}
+		if legacy, ok := legacyEnv[key]; ok {
+			names = append(names, legacy)
+		}
+
+		if err := v.BindEnv(append([]string{key}, names...)...); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// envName converts a config key to its environment variable: http.readTimeout -> APP_HTTP_READ_TIMEOUT.
+func envName(key string) string {
+	var b strings.Builder
+
+	b.WriteString(EnvPrefix)
+	b.WriteByte('_')
+
+	for i, r := range key {
+		switch {
+		case r == '.':
+			b.WriteByte('_')
+		case unicode.IsUpper(r) && i > 0 && key[i-1] != '.':
+			b.WriteByte('_')
+			b.WriteRune(r)
+		default:
+			b.WriteRune(unicode.ToUpper(r))
+		}
+	}
 
-	cfg.Environment = os.Getenv("APP_ENV")
+	return b.String()
 }
 
-func parseConfigFile(folder, env string) error {
-	viper.AddConfigPath(folder)
-	viper.SetConfigName("main")
+func parseConfigFile(v *viper.Viper, folder, env string) error {
+	v.AddConfigPath(folder)
+	v.SetConfigName("main")
 
-	if err := viper.ReadInConfig(); err != nil {
+	if err := v.ReadInConfig(); err != nil {
 		return err
 	}
 
-	if env == EnvLocal {
+	if env == "" || env == EnvLocal {
 		return nil
 	}
 
-	viper.SetConfigName(env)
+	v.SetConfigName(env)
 
-	return viper.MergeInConfig()
+	return v.MergeInConfig()
 }
 
-func populateDefaults() {
-	viper.SetDefault("http.port", defaultHTTPPort)
-	viper.SetDefault("http.max_header_megabytes", defaultHTTPMaxHeaderMegabytes)
-	viper.SetDefault("http.timeouts.read", defaultHTTPRWTimeout)
-	viper.SetDefault("http.timeouts.write", defaultHTTPRWTimeout)
-	viper.SetDefault("auth.accessTokenTTL", defaultAccessTokenTTL)
-	viper.SetDefault("limiter.rps", defaultLimiterRPS)
-	viper.SetDefault("limiter.burst", defaultLimiterBurst)
-	viper.SetDefault("limiter.ttl", defaultLimiterTTL)
+func populateDefaults(v *viper.Viper) {
+	v.SetDefault("http.port", defaultHTTPPort)
+	v.SetDefault("http.maxHeaderBytes", defaultHTTPMaxHeaderMegabytes)
+	v.SetDefault("http.readTimeout", defaultHTTPRWTimeout)
+	v.SetDefault("http.writeTimeout", defaultHTTPRWTimeout)
+	v.SetDefault("http.shutdownDelay", defaultHTTPShutdownDelay)
+	v.SetDefault("grpc.port", defaultGRPCPort)
+	v.SetDefault("auth.jwt.accessTokenTTL", defaultAccessTokenTTL)
+	v.SetDefault("auth.jwt.issuer", defaultJWTIssuer)
+	v.SetDefault("auth.jwt.leeway", defaultJWTLeeway)
+	v.SetDefault("auth.jwt.audience", []string{defaultJWTIssuer})
+	v.SetDefault("auth.lockout.maxFailures", defaultLockoutMaxFailures)
+	v.SetDefault("auth.lockout.maxIPFailures", defaultLockoutMaxIPFailures)
+	v.SetDefault("auth.lockout.backoff", defaultLockoutBackoff)
+	v.SetDefault("auth.lockout.duration", defaultLockoutDuration)
+	v.SetDefault("auth.lockout.window", defaultLockoutWindow)
+	v.SetDefault("auth.oauth.codeTTL", defaultOAuthCodeTTL)
+	v.SetDefault("auth.oauth.refreshTokenTTL", defaultOAuthRefreshTokenTTL)
+	v.SetDefault("auth.webauthn.rpID", defaultWebAuthnRPID)
+	v.SetDefault("auth.webauthn.rpDisplayName", defaultJWTIssuer)
+	v.SetDefault("auth.webauthn.rpOrigin", defaultWebAuthnRPOrigin)
+	v.SetDefault("auth.webauthn.challengeTTL", defaultWebAuthnChallengeTTL)
+	v.SetDefault("limiter.rps", defaultLimiterRPS)
+	v.SetDefault("limiter.burst", defaultLimiterBurst)
+	v.SetDefault("limiter.ttl", defaultLimiterTTL)
+	v.SetDefault("limiter.store", defaultLimiterStore)
+	v.SetDefault("events.pollInterval", defaultEventsPollInterval)
+	v.SetDefault("events.batchSize", defaultEventsBatchSize)
+	v.SetDefault("jobs.concurrency", defaultJobsConcurrency)
+	v.SetDefault("jobs.pollInterval", defaultJobsPollInterval)
+	v.SetDefault("jobs.timeout", defaultJobsTimeout)
+	v.SetDefault("jobs.maxAttempts", defaultJobsMaxAttempts)
+	v.SetDefault("jobs.retryDelay", defaultJobsRetryDelay)
+	v.SetDefault("jobs.maxRetryDelay", defaultJobsMaxRetryDelay)
+	v.SetDefault("jobs.purgeSchedule", defaultJobsPurgeSchedule)
+	v.SetDefault("jobs.retention", defaultJobsRetention)
+	v.SetDefault("stream.replaySize", defaultStreamReplaySize)
+	v.SetDefault("stream.bufferSize", defaultStreamBufferSize)
+	v.SetDefault("stream.heartbeat", defaultStreamHeartbeat)
+	v.SetDefault("postgres.healthCheckPeriod", defaultPostgresHealthCheck)
+	v.SetDefault("postgres.readYourWrites", defaultPostgresReadYourWrites)
+	v.SetDefault("cache.enabled", defaultCacheEnabled)
+	v.SetDefault("cache.size", defaultCacheSize)
+	v.SetDefault("cache.ttl", defaultCacheTTL)
+	v.SetDefault("webhooks.timeout", defaultWebhooksTimeout)
+	v.SetDefault("webhooks.maxAttempts", defaultWebhooksMaxAttempts)
+	v.SetDefault("webhooks.retryDelay", defaultWebhooksRetryDelay)
+	v.SetDefault("webhooks.maxRetryDelay", defaultWebhooksMaxRetryDelay)
+	v.SetDefault("webhooks.disableAfter", defaultWebhooksDisableAfter)
+	v.SetDefault("webhooks.pollInterval", defaultWebhooksPollInterval)
+	v.SetDefault("webhooks.batchSize", defaultWebhooksBatchSize)
+	v.SetDefault("tracing.exporter", defaultTracingExporter)
+	v.SetDefault("tracing.serviceName", defaultTracingServiceName)
+	v.SetDefault("tracing.sampleRatio", defaultTracingSampleRatio)
+	v.SetDefault("http.corsOrigins", []string{"*"})
+	v.SetDefault("log.level", defaultLogLevel)
 }
@@ -0,0 +1,144 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/go-playground/validator/v10"
+)
+
+const minProdSecretLength = 32
+
+// secretKeys are never echoed back in validation errors.
+var secretKeys = map[string]bool{
+	"postgres.uri":        true,
+	"postgres.replicas":   true,
+	"auth.passwordSalt":   true,
+	"auth.sessionSecret":  true,
+	"auth.jwt.signingKey": true,
+}
+
+// ValidationError lists every invalid config key, so all problems are reported at once.
+type ValidationError struct {
+	Problems []string
+}
+
+func (e *ValidationError) Error() string {
+	return "invalid configuration:\n  - " + strings.Join(e.Problems, "\n  - ")
+}
+
+// Validate checks the config against the schema and the rules of its environment.
+func (c *Config) Validate() error {
+	var problems []string
+
+	validate := validator.New()
+	validate.RegisterTagNameFunc(func(field reflect.StructField) string {
+		return field.Tag.Get("mapstructure")
+	})
+
+	var validationErrs validator.ValidationErrors
+
+	err := validate.Struct(c)
+
+	switch {
+	case errors.As(err, &validationErrs):
+		for _, fieldErr := range validationErrs {
+			problems = append(problems, describe(fieldErr))
+		}
+	case err != nil:
+		return err
+	}
+
+	if c.Environment == Prod {
+		problems = append(problems, c.prodProblems()...)
+	}
+
+	if len(problems) > 0 {
+		return &ValidationError{Problems: problems}
+	}
+
+	return nil
+}
+
+// prodProblems enforces stricter rules for production deployments.
+func (c *Config) prodProblems() []string {
+	var problems []string
+
+	secrets := []struct {
+		key   string
+		value string
+	}{
+		{"auth.jwt.signingKey", c.Auth.JWT.SigningKey},
+		{"auth.passwordSalt", c.Auth.PasswordSalt},
+	}
+
+	for _, secret := range secrets {
+		if secret.value != "" && len(secret.value) < minProdSecretLength {
+			problems = append(problems, fmt.Sprintf("%s (%s): must be at least %d characters in %s",
+				secret.key, envName(secret.key), minProdSecretLength, Prod))
+		}
+	}
+
+	if c.Tracing.Exporter == "stdout" {
+		problems = append(problems, fmt.Sprintf("tracing.exporter: stdout is not allowed in %s", Prod))
+	}
+
+	return problems
+}
+
+func describe(fieldErr validator.FieldError) string {
+	// Namespace is "Config.section.key", drop the struct name.
+	key := fieldErr.Namespace()
+	if i := strings.IndexByte(key, '.'); i >= 0 {
+		key = key[i+1:]
+	}
+
+	var rule string
+
+	switch fieldErr.Tag() {
+	case "required", "required_if":
+		rule = "is required"
+	case "oneof":
+		rule = "must be one of: " + fieldErr.Param()
+	case "min":
+		rule = "must be at least " + fieldErr.Param()
+	case "gt":
+		rule = "must be greater than " + fieldErr.Param()
+	case "gtefield":
+		rule = "must not be less than " + strings.ToLower(fieldErr.Param()[:1]) + fieldErr.Param()[1:]
+	case "startswith":
+		rule = "must start with " + fieldErr.Param()
+	case "max":
+		rule = "must be at most " + fieldErr.Param()
+	case "numeric":
+		rule = "must be a number"
+	case "url":
+		rule = "must be a URL"
+	case "hostname":
+		rule = "must be a host name"
+	case "alphanum":
+		rule = "must contain only letters and digits"
+	case "unique":
+		rule = "must have unique " + strings.ToLower(fieldErr.Param()) + "s"
+	default:
+		rule = "failed " + fieldErr.Tag() + " check"
+	}
+
+	if secretKeys[key] {
+		return fmt.Sprintf("%s (%s): %s", key, envName(key), rule)
+	}
+
+	// Lists of sections are set in the files only and may hold secrets, don't echo them.
+	if v := reflect.ValueOf(fieldErr.Value()); v.Kind() == reflect.Slice && v.Type().Elem().Kind() == reflect.Struct {
+		return fmt.Sprintf("%s: %s", key, rule)
+	}
+
+	// Keys inside lists have no environment variable.
+	if strings.ContainsRune(key, '[') {
+		return fmt.Sprintf("%s: %s, got %q", key, rule, fmt.Sprint(fieldErr.Value()))
+	}
+
+	return fmt.Sprintf("%s (%s): %s, got %q", key, envName(key), rule, fmt.Sprint(fieldErr.Value()))
+}
@@ -0,0 +1,170 @@
+package config
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"reflect"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/ernur-eskermes/crud-app/pkg/logging"
+	"github.com/fsnotify/fsnotify"
+)
+
+// reloadDebounce collapses the burst of file events produced by a single save.
+const reloadDebounce = 200 * time.Millisecond
+
+// reloadableKeys are the settings that can change without a restart.
+var reloadableKeys = map[string]bool{
+	"limiter.rps":             true,
+	"limiter.burst":           true,
+	"limiter.rules":           true,
+	"log.level":               true,
+	"auth.jwt.accessTokenTTL": true,
+	"http.corsOrigins":        true,
+}
+
+// Watcher reloads the configuration when a file in the configs directory changes
+// or the process receives SIGHUP. Only reloadable settings are applied,
+// subscribers are notified with the resulting config.
+type Watcher struct {
+	configsDir string
+	logger     *logging.Logger
+	current    atomic.Value
+
+	mu          sync.Mutex
+	subscribers []func(cfg *Config)
+}
+
+func NewWatcher(configsDir string, cfg *Config, logger *logging.Logger) *Watcher {
+	w := &Watcher{
+		configsDir: configsDir,
+		logger:     logger,
+	}
+	w.current.Store(cfg)
+
+	return w
+}
+
+// Current returns the config with the last applied reload.
+func (w *Watcher) Current() *Config {
+	return w.current.Load().(*Config)
+}
+
+// Subscribe registers fn to be called after every reload that changed reloadable settings.
+func (w *Watcher) Subscribe(fn func(cfg *Config)) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.subscribers = append(w.subscribers, fn)
+}
+
+// Run watches for changes until ctx is done.
+func (w *Watcher) Run(ctx context.Context) error {
+	fsWatcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+	defer fsWatcher.Close()
+
+	if err = fsWatcher.Add(w.configsDir); err != nil {
+		return err
+	}
+
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	defer signal.Stop(hup)
+
+	debounce := time.NewTimer(reloadDebounce)
+	debounce.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			debounce.Stop()
+
+			return nil
+		case <-hup:
+			w.logger.Info("SIGHUP received, reloading config")
+			w.Reload()
+		case event := <-fsWatcher.Events:
+			if event.Op&(fsnotify.Write|fsnotify.Create|fsnotify.Rename|fsnotify.Remove) != 0 {
+				debounce.Reset(reloadDebounce)
+			}
+		case <-debounce.C:
+			w.logger.Info("config files changed, reloading config")
+			w.Reload()
+		case err := <-fsWatcher.Errors:
+			w.logger.Errorf("config watcher: %v", err)
+		}
+	}
+}
+
+// Reload reads and validates the config again and applies the reloadable settings.
+// An invalid config is logged and ignored, the current one stays in effect.
+func (w *Watcher) Reload() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	next, err := Init(w.configsDir)
+	if err != nil {
+		w.logger.Errorf("config reload rejected: %v", err)
+
+		return
+	}
+
+	current := w.Current()
+	applied := *current
+	applied.Limiter.RPS = next.Limiter.RPS
+	applied.Limiter.Burst = next.Limiter.Burst
+	applied.Limiter.Rules = next.Limiter.Rules
+	applied.Log = next.Log
+	applied.Auth.JWT.AccessTokenTTL = next.Auth.JWT.AccessTokenTTL
+	applied.HTTP.CORSOrigins = next.HTTP.CORSOrigins
+
+	var reloaded []string
+
+	for _, key := range changedKeys(reflect.ValueOf(*current), reflect.ValueOf(*next), "") {
+		if reloadableKeys[key] {
+			reloaded = append(reloaded, key)
+		} else {
+			w.logger.Warnf("config reload: %s cannot be changed at runtime, restart to apply it", key)
+		}
+	}
+
+	if len(reloaded) == 0 {
+		return
+	}
+
+	w.current.Store(&applied)
+	w.logger.WithField("keys", reloaded).Info("config reloaded")
+
+	for _, fn := range w.subscribers {
+		fn(&applied)
+	}
+}
+
+// changedKeys returns the config keys whose values differ between a and b.
+func changedKeys(a, b reflect.Value, prefix string) []string {
+	var keys []string
+
+	for i := 0; i < a.NumField(); i++ {
+		field := a.Type().Field(i)
+		key := prefix + field.Tag.Get("mapstructure")
+
+		if field.Type.Kind() == reflect.Struct {
+			keys = append(keys, changedKeys(a.Field(i), b.Field(i), key+".")...)
+
+			continue
+		}
+
+		if !reflect.DeepEqual(a.Field(i).Interface(), b.Field(i).Interface()) {
+			keys = append(keys, key)
+		}
+	}
+
+	return keys
+}
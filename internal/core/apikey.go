@@ -0,0 +1,56 @@
+package core
+
+import (
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	ScopeBooksRead  = "books:read"
+	ScopeBooksWrite = "books:write"
+	ScopeAdmin      = "admin"
+)
+
+var (
+	ErrAPIKeyNotFound = errors.New("api key not found")
+	ErrAPIKeyInvalid  = errors.New("api key is invalid or expired")
+	ErrScopeForbidden = errors.New("scope is not allowed for the user")
+	// ErrInsufficientScope is returned when an API key or an OAuth client lacks the scope of the request.
+	ErrInsufficientScope = errors.New("token lacks the scope required by the request")
+)
+
+// RoleScopes returns the scopes a user with the role may use.
+func RoleScopes(role string) []string {
+	scopes := []string{ScopeBooksRead, ScopeBooksWrite}
+	if role == RoleAdmin {
+		scopes = append(scopes, ScopeAdmin)
+	}
+
+	return scopes
+}
+
+// APIKey is a personal key of a user for machine clients. Only the hash of the key is stored,
+// Prefix identifies the key and is shown to the user to tell the keys apart.
+type APIKey struct {
+	ID         uuid.UUID  `json:"id"`
+	UserID     uuid.UUID  `json:"-"`
+	Name       string     `json:"name"`
+	Prefix     string     `json:"prefix"`
+	Hash       string     `json:"-"`
+	Scopes     []string   `json:"scopes"`
+	ExpiresAt  *time.Time `json:"expires_at,omitempty"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
+func (k APIKey) Expired(now time.Time) bool {
+	return k.ExpiresAt != nil && !now.Before(*k.ExpiresAt)
+}
+
+type CreateAPIKeyInput struct {
+	Name      string     `json:"name" validate:"required,max=64"`
+	Scopes    []string   `json:"scopes" validate:"required,min=1,dive,oneof=books:read books:write admin"`
+	ExpiresAt *time.Time `json:"expires_at" validate:"omitempty,gt"`
+}
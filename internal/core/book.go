@@ -15,6 +15,7 @@ type Book struct {
 	Author      uuid.UUID `json:"author"`
 	PublishDate time.Time `json:"publish_date"`
 	Rating      int       `json:"rating"`
+	UpdatedAt   time.Time `json:"updated_at"`
 }
 
 type CreateBookInput struct {
@@ -28,3 +29,20 @@ type UpdateBookInput struct {
 	PublishDate time.Time `json:"publish_date" validate:"required"`
 	Rating      int       `json:"rating" validate:"required,number,max=5,min=0"`
 }
+
+type SearchBooksQuery struct {
+	Title  string
+	Author *uuid.UUID
+	Limit  int `validate:"min=1,max=100"`
+	Offset int `validate:"min=0"`
+}
+
+// BookStreamFilter narrows a stream of book events to the books of an author or to a single book.
+type BookStreamFilter struct {
+	Author *uuid.UUID
+	BookID *uuid.UUID
+}
+
+func (f BookStreamFilter) Match(book Book) bool {
+	return (f.Author == nil || *f.Author == book.Author) && (f.BookID == nil || *f.BookID == book.ID)
+}
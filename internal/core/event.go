@@ -0,0 +1,34 @@
+package core
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Domain events, recorded in the outbox by the writes they describe.
+const (
+	EventBookCreated  = "book.created"
+	EventBookUpdated  = "book.updated"
+	EventBookDeleted  = "book.deleted"
+	EventUserSignedUp = "user.signed_up"
+	EventUserVerified = "user.verified"
+)
+
+// Event is a domain event. AggregateID is the id of the book or the user the event is about,
+// Data is a Book for book events, the book as it was for book.deleted, and a UserEvent for user events.
+type Event struct {
+	ID          int64           `json:"id"`
+	Type        string          `json:"type"`
+	AggregateID uuid.UUID       `json:"aggregate_id"`
+	Data        json.RawMessage `json:"data"`
+	CreatedAt   time.Time       `json:"created_at"`
+}
+
+// UserEvent is the data of user events, the user without the password.
+type UserEvent struct {
+	ID       uuid.UUID `json:"id"`
+	Username string    `json:"username"`
+	Role     string    `json:"role"`
+}
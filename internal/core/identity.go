@@ -0,0 +1,39 @@
+package core
+
+import (
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+var (
+	ErrProviderNotFound      = errors.New("identity provider not found")
+	ErrIdentityNotFound      = errors.New("identity not found")
+	ErrIdentityAlreadyLinked = errors.New("identity is already linked to another user")
+	ErrIdentityUsernameTaken = errors.New("username is taken, sign in and link the identity to your account")
+	ErrLoginStateInvalid     = errors.New("login request is invalid or expired, start again")
+	ErrLoginFailed           = errors.New("identity provider login failed")
+)
+
+// Identity links a user to an account of an external OpenID Connect provider.
+type Identity struct {
+	Provider  string    `json:"provider"`
+	Subject   string    `json:"subject"`
+	UserID    uuid.UUID `json:"-"`
+	Email     string    `json:"email,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// LoginState is kept between the redirect to the provider and the callback. It is found by the hash of
+// the state sent to the provider and bound to the browser that started the login by the hash of BrowserKey.
+type LoginState struct {
+	StateHash   string
+	BrowserHash string
+	Provider    string
+	Nonce       string
+	Verifier    string
+	// LinkTo is the user to link the identity to, uuid.Nil to sign in.
+	LinkTo    uuid.UUID
+	ExpiresAt time.Time
+}
@@ -0,0 +1,61 @@
+package core
+
+import (
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Statuses of a job. A failed job is queued again until it runs out of attempts and is dead.
+const (
+	JobQueued    = "queued"
+	JobRunning   = "running"
+	JobSucceeded = "succeeded"
+	JobDead      = "dead"
+)
+
+// Types of the background jobs.
+const (
+	JobPurgeOutbox = "outbox.purge"
+	JobPurgeJobs   = "jobs.purge"
+)
+
+var (
+	ErrJobNotFound     = errors.New("job not found")
+	ErrJobNotRetryable = errors.New("only dead jobs can be retried")
+)
+
+// Job is a unit of work run in the background by a handler registered for its type.
+// Key, when set, makes the job unique, e.g. a run of a schedule.
+type Job struct {
+	ID          uuid.UUID       `json:"id"`
+	Type        string          `json:"type"`
+	Payload     json.RawMessage `json:"payload" swaggertype:"object"`
+	Status      string          `json:"status"`
+	Attempts    int             `json:"attempts"`
+	MaxAttempts int             `json:"max_attempts"`
+	RunAt       time.Time       `json:"run_at"`
+	LastError   string          `json:"last_error,omitempty"`
+	Key         *string         `json:"key,omitempty"`
+	CreatedAt   time.Time       `json:"created_at"`
+	FinishedAt  *time.Time      `json:"finished_at,omitempty"`
+}
+
+// Decode unmarshals the payload of the job into v.
+func (j Job) Decode(v interface{}) error {
+	return json.Unmarshal(j.Payload, v)
+}
+
+type JobsQuery struct {
+	Status string `query:"status" validate:"omitempty,oneof=queued running succeeded dead"`
+	Type   string `query:"type" validate:"max=64"`
+	Limit  int    `query:"limit" validate:"min=1,max=100"`
+	Offset int    `query:"offset" validate:"min=0"`
+}
+
+// PurgeJobPayload is the payload of the purge jobs: the records finished longer than Retention ago are deleted.
+type PurgeJobPayload struct {
+	Retention string `json:"retention"`
+}
@@ -0,0 +1,110 @@
+package core
+
+import (
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+var (
+	ErrOAuthClientNotFound = errors.New("oauth client not found")
+	ErrRedirectURIMismatch = errors.New("redirect_uri is not registered for the client")
+	ErrConsentRequired     = errors.New("user has not granted the requested scopes to the client")
+	ErrConsentNotFound     = errors.New("consent not found")
+	ErrCodeNotFound        = errors.New("authorization code not found")
+	ErrGrantNotFound       = errors.New("grant not found")
+	ErrGrantRevoked        = errors.New("token has been revoked")
+	ErrTokenNotUser        = errors.New("token is issued to a client, not on behalf of a user")
+)
+
+// Error codes of RFC 6749 section 5.2 and 4.1.2.1.
+const (
+	OAuthInvalidRequest       = "invalid_request"
+	OAuthInvalidClient        = "invalid_client"
+	OAuthInvalidGrant         = "invalid_grant"
+	OAuthInvalidScope         = "invalid_scope"
+	OAuthUnauthorizedClient   = "unauthorized_client"
+	OAuthUnsupportedGrantType = "unsupported_grant_type"
+	OAuthUnsupportedResponse  = "unsupported_response_type"
+	OAuthAccessDenied         = "access_denied"
+)
+
+// OAuthError is an error of the OAuth 2.0 endpoints, rendered as {"error": Code, "error_description": Description}.
+type OAuthError struct {
+	Code        string
+	Description string
+}
+
+func NewOAuthError(code, description string) *OAuthError {
+	return &OAuthError{
+		Code:        code,
+		Description: description,
+	}
+}
+
+func (e *OAuthError) Error() string {
+	return e.Code + ": " + e.Description
+}
+
+// OAuthClient is an application registered to obtain tokens. Confidential clients authenticate
+// with a secret, public ones (SPAs, mobile apps) rely on PKCE alone.
+type OAuthClient struct {
+	ID           uuid.UUID `json:"client_id"`
+	Name         string    `json:"name"`
+	SecretHash   string    `json:"-"`
+	Confidential bool      `json:"confidential"`
+	RedirectURIs []string  `json:"redirect_uris"`
+	Scopes       []string  `json:"scopes"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+func (c OAuthClient) HasRedirectURI(uri string) bool {
+	for _, u := range c.RedirectURIs {
+		if u == uri {
+			return true
+		}
+	}
+
+	return false
+}
+
+type CreateOAuthClientInput struct {
+	Name         string   `json:"name" validate:"required,max=64"`
+	Confidential bool     `json:"confidential"`
+	RedirectURIs []string `json:"redirect_uris" validate:"required_without=Confidential,dive,url"`
+	Scopes       []string `json:"scopes" validate:"required,min=1,dive,oneof=books:read books:write admin"`
+}
+
+// OAuthConsent records the scopes a user has granted to a client.
+type OAuthConsent struct {
+	UserID     uuid.UUID `json:"-"`
+	ClientID   uuid.UUID `json:"client_id"`
+	ClientName string    `json:"client_name"`
+	Scopes     []string  `json:"scopes"`
+	GrantedAt  time.Time `json:"granted_at"`
+}
+
+// AuthorizationCode is issued by the authorization endpoint and exchanged once for tokens.
+// Only the hash of the code is stored.
+type AuthorizationCode struct {
+	Hash          string
+	ClientID      uuid.UUID
+	UserID        uuid.UUID
+	RedirectURI   string
+	Scopes        []string
+	CodeChallenge string
+	ExpiresAt     time.Time
+}
+
+// OAuthGrant is the authorization behind the tokens issued to a client. Tokens carry
+// the grant id as their session id, revoking the grant revokes all of them.
+// UserID is uuid.Nil for the client credentials grant.
+type OAuthGrant struct {
+	ID        uuid.UUID
+	ClientID  uuid.UUID
+	UserID    uuid.UUID
+	Scopes    []string
+	ExpiresAt time.Time
+	RevokedAt *time.Time
+}
@@ -2,6 +2,7 @@ package core
 
 import (
 	"errors"
+	"time"
 
 	"github.com/google/uuid"
 )
@@ -13,10 +14,39 @@ var (
 	ErrUserCodeExpired     = errors.New("code has expired. repeat again")
 	ErrUserCodeUnknownType = errors.New("code of unknown type")
 	ErrUserCodeIncorrect   = errors.New("code is incorrect")
+
+	ErrTooManyAttempts = errors.New("too many failed attempts, try again later")
+)
+
+// TooManyAttemptsError is returned while an account or a client is locked out
+// after failed sign in or verification attempts. It unwraps to ErrTooManyAttempts.
+type TooManyAttemptsError struct {
+	RetryAfter time.Duration
+}
+
+func (e *TooManyAttemptsError) Error() string {
+	return ErrTooManyAttempts.Error()
+}
+
+func (e *TooManyAttemptsError) Unwrap() error {
+	return ErrTooManyAttempts
+}
+
+// Attempts is the failure counter of an account or a client address.
+type Attempts struct {
+	Failures    int
+	LockedUntil time.Time
+}
+
+const (
+	RoleUser  = "user"
+	RoleAdmin = "admin"
 )
 
 type User struct {
 	ID       uuid.UUID `json:"id"`
 	Username string    `json:"username"`
 	Password string    `json:"-"`
+	Role     string    `json:"role"`
+	IsActive bool      `json:"is_active"`
 }
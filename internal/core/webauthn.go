@@ -0,0 +1,54 @@
+package core
+
+import (
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+var (
+	ErrCredentialNotFound          = errors.New("passkey not found")
+	ErrCredentialAlreadyRegistered = errors.New("passkey is already registered")
+	ErrWebAuthnSessionInvalid      = errors.New("passkey ceremony is invalid or expired, start again")
+	ErrWebAuthnFailed              = errors.New("passkey verification failed")
+	ErrSecondFactorRequired        = errors.New("sign in must be confirmed with a passkey")
+)
+
+// SecondFactorRequiredError is returned by a password sign in of a user with passkeys.
+// Token starts the passkey login that completes the sign in. It unwraps to ErrSecondFactorRequired.
+type SecondFactorRequiredError struct {
+	Token string
+}
+
+func (e *SecondFactorRequiredError) Error() string {
+	return ErrSecondFactorRequired.Error()
+}
+
+func (e *SecondFactorRequiredError) Unwrap() error {
+	return ErrSecondFactorRequired
+}
+
+// WebAuthnSession is kept between the start and the end of a passkey ceremony, or from a password sign in
+// to the passkey login confirming it. It is found by the hash of its key, Data is the encoded challenge if any.
+type WebAuthnSession struct {
+	KeyHash   string
+	UserID    uuid.UUID
+	Data      []byte
+	ExpiresAt time.Time
+}
+
+// WebAuthnCredential is a passkey registered by a user. CredentialID is the id assigned
+// by the authenticator, ID is ours.
+type WebAuthnCredential struct {
+	ID              uuid.UUID  `json:"id"`
+	UserID          uuid.UUID  `json:"-"`
+	Name            string     `json:"name"`
+	CredentialID    []byte     `json:"credential_id"`
+	PublicKey       []byte     `json:"-"`
+	AttestationType string     `json:"-"`
+	AAGUID          []byte     `json:"-"`
+	SignCount       uint32     `json:"-"`
+	LastUsedAt      *time.Time `json:"last_used_at"`
+	CreatedAt       time.Time  `json:"created_at"`
+}
@@ -0,0 +1,70 @@
+package core
+
+import (
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Statuses of a webhook delivery.
+const (
+	DeliveryPending   = "pending"
+	DeliverySucceeded = "succeeded"
+	DeliveryFailed    = "failed"
+)
+
+var (
+	ErrWebhookNotFound         = errors.New("webhook not found")
+	ErrDeliveryNotFound        = errors.New("webhook delivery not found")
+	ErrWebhookURLInvalid       = errors.New("webhook url must be an http or https url of a public host")
+	ErrWebhookAddressForbidden = errors.New("webhook address is not public")
+)
+
+// Webhook is an endpoint notified of the events of the books of its owner, or of all books
+// when the owner is an admin. Payloads are signed with Secret. A webhook failing
+// too many deliveries in a row is disabled until it is enabled again.
+type Webhook struct {
+	ID         uuid.UUID  `json:"id"`
+	UserID     uuid.UUID  `json:"-"`
+	URL        string     `json:"url"`
+	Events     []string   `json:"events"`
+	Secret     string     `json:"-"`
+	Failures   int        `json:"failures"`
+	DisabledAt *time.Time `json:"disabled_at,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
+type CreateWebhookInput struct {
+	URL    string   `json:"url" validate:"required,url,max=2048"`
+	Events []string `json:"events" validate:"required,min=1,unique,dive,oneof=book.created book.updated book.deleted"`
+	// Secret signs the payloads, a random one is generated when it is empty.
+	Secret string `json:"secret" validate:"omitempty,min=16,max=128"`
+}
+
+type UpdateWebhookInput struct {
+	URL    string   `json:"url" validate:"required,url,max=2048"`
+	Events []string `json:"events" validate:"required,min=1,unique,dive,oneof=book.created book.updated book.deleted"`
+	// Enabled re-enables a disabled webhook and resets its failures, or disables it.
+	Enabled bool `json:"enabled"`
+}
+
+// WebhookDelivery is an event sent, or to be sent, to a webhook, kept as the delivery log.
+type WebhookDelivery struct {
+	ID             uuid.UUID       `json:"id"`
+	WebhookID      uuid.UUID       `json:"webhook_id"`
+	Event          string          `json:"event"`
+	Payload        json.RawMessage `json:"payload" swaggertype:"object"`
+	Status         string          `json:"status"`
+	Attempts       int             `json:"attempts"`
+	ResponseStatus *int            `json:"response_status,omitempty"`
+	LastError      string          `json:"last_error,omitempty"`
+	NextAttemptAt  time.Time       `json:"next_attempt_at"`
+	DeliveredAt    *time.Time      `json:"delivered_at,omitempty"`
+	CreatedAt      time.Time       `json:"created_at"`
+
+	// URL and Secret are those of the webhook when the delivery is claimed for sending.
+	URL    string `json:"-"`
+	Secret string `json:"-"`
+}
@@ -0,0 +1,99 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+
+	"github.com/google/uuid"
+
+	"github.com/ernur-eskermes/crud-app/internal/core"
+	"github.com/ernur-eskermes/crud-app/pkg/database/postgresql"
+	"github.com/jackc/pgx/v4"
+)
+
+const apiKeyColumns = "id, user_id, name, prefix, key_hash, scopes, expires_at, last_used_at, created_at"
+
+type APIKeysRepo struct {
+	db postgresql.Client
+}
+
+func NewAPIKeysRepo(db postgresql.Client) *APIKeysRepo {
+	return &APIKeysRepo{
+		db: db,
+	}
+}
+
+func (r *APIKeysRepo) Create(ctx context.Context, key *core.APIKey) error {
+	q := `INSERT INTO api_keys (user_id, name, prefix, key_hash, scopes, expires_at)
+		VALUES ($1, $2, $3, $4, $5, $6) RETURNING id, created_at`
+
+	return r.db.QueryRow(ctx, q, key.UserID, key.Name, key.Prefix, key.Hash, key.Scopes, key.ExpiresAt).
+		Scan(&key.ID, &key.CreatedAt)
+}
+
+func (r *APIKeysRepo) GetByPrefix(ctx context.Context, prefix string) (core.APIKey, error) {
+	q := "SELECT " + apiKeyColumns + " FROM api_keys WHERE prefix=$1"
+
+	key, err := scanAPIKey(r.db.QueryRow(ctx, q, prefix))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return core.APIKey{}, core.ErrAPIKeyNotFound
+	}
+
+	return key, err
+}
+
+func (r *APIKeysRepo) GetByUser(ctx context.Context, userID uuid.UUID) ([]core.APIKey, error) {
+	q := "SELECT " + apiKeyColumns + " FROM api_keys WHERE user_id=$1 ORDER BY created_at"
+
+	rows, err := r.db.Query(ctx, q, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	keys := make([]core.APIKey, 0)
+
+	for rows.Next() {
+		key, err := scanAPIKey(rows)
+		if err != nil {
+			return nil, err
+		}
+
+		keys = append(keys, key)
+	}
+
+	return keys, rows.Err()
+}
+
+// Touch records the use of the key. It is written at most once a minute
+// to keep authentication from updating the row on every request.
+func (r *APIKeysRepo) Touch(ctx context.Context, id uuid.UUID) error {
+	q := `UPDATE api_keys SET last_used_at=now()
+		WHERE id=$1 AND (last_used_at IS NULL OR last_used_at < now() - interval '1 minute')`
+
+	_, err := r.db.Exec(ctx, q, id)
+
+	return err
+}
+
+func (r *APIKeysRepo) Delete(ctx context.Context, id, userID uuid.UUID) error {
+	res, err := r.db.Exec(ctx, "DELETE FROM api_keys WHERE id=$1 AND user_id=$2", id, userID)
+	if err != nil {
+		return err
+	}
+
+	if res.RowsAffected() == 0 {
+		return core.ErrAPIKeyNotFound
+	}
+
+	return nil
+}
+
+func scanAPIKey(row pgx.Row) (core.APIKey, error) {
+	var key core.APIKey
+
+	err := row.Scan(&key.ID, &key.UserID, &key.Name, &key.Prefix, &key.Hash, &key.Scopes,
+		&key.ExpiresAt, &key.LastUsedAt, &key.CreatedAt)
+
+	return key, err
+}
@@ -0,0 +1,63 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/ernur-eskermes/crud-app/internal/core"
+	"github.com/ernur-eskermes/crud-app/pkg/database/postgresql"
+	"github.com/jackc/pgx/v4"
+)
+
+type AttemptsRepo struct {
+	db postgresql.Client
+}
+
+func NewAttemptsRepo(db postgresql.Client) *AttemptsRepo {
+	return &AttemptsRepo{
+		db: db,
+	}
+}
+
+// Get returns the counter for key, a zero counter if there were no failures.
+func (r *AttemptsRepo) Get(ctx context.Context, key string) (core.Attempts, error) {
+	q := "SELECT failures, locked_until FROM auth_failures WHERE key=$1"
+
+	var attempts core.Attempts
+
+	err := r.db.QueryRow(ctx, q, key).Scan(&attempts.Failures, &attempts.LockedUntil)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return core.Attempts{}, nil
+	}
+
+	return attempts, err
+}
+
+// Fail counts a failure for key and returns the number of failures. Failures older
+// than forgetBefore are forgotten, so the counter starts over.
+func (r *AttemptsRepo) Fail(ctx context.Context, key string, now, forgetBefore time.Time) (int, error) {
+	q := `INSERT INTO auth_failures AS f (key, failures, locked_until, failed_at) VALUES ($1, 1, $2, $2)
+		ON CONFLICT (key) DO UPDATE SET
+			failures  = CASE WHEN f.failed_at < $3 THEN 1 ELSE f.failures + 1 END,
+			failed_at = $2
+		RETURNING failures`
+
+	var failures int
+
+	err := r.db.QueryRow(ctx, q, key, now, forgetBefore).Scan(&failures)
+
+	return failures, err
+}
+
+func (r *AttemptsRepo) Lock(ctx context.Context, key string, until time.Time) error {
+	_, err := r.db.Exec(ctx, "UPDATE auth_failures SET locked_until=$2 WHERE key=$1", key, until)
+
+	return err
+}
+
+func (r *AttemptsRepo) Reset(ctx context.Context, key string) error {
+	_, err := r.db.Exec(ctx, "DELETE FROM auth_failures WHERE key=$1", key)
+
+	return err
+}
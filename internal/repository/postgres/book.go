@@ -3,6 +3,7 @@ package postgres
 import (
 	"context"
 	"errors"
+	"time"
 
 	"github.com/google/uuid"
 
@@ -20,16 +21,17 @@ func NewBooksRepo(db postgresql.Client) *BooksRepo {
 }
 
 func (b *BooksRepo) GetByID(ctx context.Context, id uuid.UUID) (core.Book, error) {
-	q := "SELECT id, title, author_id, publish_date, rating FROM book WHERE id=$1"
+	q := "SELECT id, title, author_id, publish_date, rating, updated_at FROM book WHERE id=$1"
 
 	var book core.Book
 
-	if err := b.db.QueryRow(ctx, q, id).Scan(
+	if err := b.db.QueryRow(postgresql.ReadOnly(ctx), q, id).Scan(
 		&book.ID,
 		&book.Title,
 		&book.Author,
 		&book.PublishDate,
 		&book.Rating,
+		&book.UpdatedAt,
 	); err != nil {
 		if errors.Is(err, pgx.ErrNoRows) {
 			return core.Book{}, core.ErrBookNotFound
@@ -42,22 +44,30 @@ func (b *BooksRepo) GetByID(ctx context.Context, id uuid.UUID) (core.Book, error
 }
 
 func (b *BooksRepo) Update(ctx context.Context, book core.Book) error {
-	q := "UPDATE book SET title=$1, publish_date=$2, rating=$3 WHERE id=$4 and author_id=$5"
-	res, err := b.db.Exec(ctx, q, book.Title, book.PublishDate, book.Rating, book.ID, book.Author)
+	q := `UPDATE book SET title=$1, publish_date=$2, rating=$3, updated_at=clock_timestamp() WHERE id=$4 and author_id=$5
+		RETURNING id, title, author_id, publish_date, rating, updated_at`
 
-	if res.RowsAffected() == 0 {
-		return core.ErrBookNotFound
-	}
+	return postgresql.WithTx(ctx, b.db, func(tx pgx.Tx) error {
+		err := tx.QueryRow(ctx, q, book.Title, book.PublishDate, book.Rating, book.ID, book.Author).
+			Scan(&book.ID, &book.Title, &book.Author, &book.PublishDate, &book.Rating, &book.UpdatedAt)
+		if err != nil {
+			if errors.Is(err, pgx.ErrNoRows) {
+				return core.ErrBookNotFound
+			}
 
-	return err
+			return err
+		}
+
+		return touchBooks(ctx, tx, core.EventBookUpdated, book)
+	})
 }
 
 func (b *BooksRepo) GetAll(ctx context.Context) ([]core.Book, error) {
-	q := "SELECT id, title, author_id, publish_date, rating FROM book"
+	q := "SELECT id, title, author_id, publish_date, rating, updated_at FROM book"
 
 	var books []core.Book
 
-	rows, err := b.db.Query(ctx, q)
+	rows, err := b.db.Query(postgresql.ReadOnly(ctx), q)
 	if err != nil {
 		return nil, err
 	}
@@ -65,7 +75,7 @@ func (b *BooksRepo) GetAll(ctx context.Context) ([]core.Book, error) {
 	for rows.Next() {
 		var book core.Book
 
-		err = rows.Scan(&book.ID, &book.Title, &book.Author, &book.PublishDate, &book.Rating)
+		err = rows.Scan(&book.ID, &book.Title, &book.Author, &book.PublishDate, &book.Rating, &book.UpdatedAt)
 		if err != nil {
 			return nil, err
 		}
@@ -76,23 +86,83 @@ func (b *BooksRepo) GetAll(ctx context.Context) ([]core.Book, error) {
 	return books, nil
 }
 
+func (b *BooksRepo) Search(ctx context.Context, query core.SearchBooksQuery) ([]core.Book, error) {
+	q := `SELECT id, title, author_id, publish_date, rating, updated_at FROM book
+		WHERE ($1::text = '' OR title ILIKE '%' || $1 || '%') AND ($2::uuid IS NULL OR author_id = $2)
+		ORDER BY publish_date DESC, id LIMIT $3 OFFSET $4`
+
+	rows, err := b.db.Query(postgresql.ReadOnly(ctx), q, query.Title, query.Author, query.Limit, query.Offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	books := make([]core.Book, 0, query.Limit)
+
+	for rows.Next() {
+		var book core.Book
+
+		if err = rows.Scan(&book.ID, &book.Title, &book.Author, &book.PublishDate, &book.Rating, &book.UpdatedAt); err != nil {
+			return nil, err
+		}
+
+		books = append(books, book)
+	}
+
+	return books, rows.Err()
+}
+
 func (b *BooksRepo) Create(ctx context.Context, book core.Book) error {
-	q := "INSERT INTO book (title, author_id, publish_date, rating) VALUES ($1, $2, $3, $4) RETURNING id"
+	q := `INSERT INTO book (title, author_id, publish_date, rating, updated_at) VALUES ($1, $2, $3, $4, clock_timestamp())
+		RETURNING id, updated_at`
+
+	return postgresql.WithTx(ctx, b.db, func(tx pgx.Tx) error {
+		err := tx.QueryRow(ctx, q, book.Title, book.Author, book.PublishDate, book.Rating).Scan(&book.ID, &book.UpdatedAt)
+		if err != nil {
+			return err
+		}
 
-	return b.db.QueryRow(ctx, q, book.Title, book.Author, book.PublishDate, book.Rating).Scan(&book.ID)
+		return touchBooks(ctx, tx, core.EventBookCreated, book)
+	})
 }
 
 func (b *BooksRepo) Delete(ctx context.Context, id, userID uuid.UUID) error {
-	q := "DELETE FROM book WHERE id=$1 and author_id=$2"
+	q := "DELETE FROM book WHERE id=$1 and author_id=$2 RETURNING id, title, author_id, publish_date, rating, updated_at"
 
-	res, err := b.db.Exec(ctx, q, id, userID)
-	if err != nil {
+	return postgresql.WithTx(ctx, b.db, func(tx pgx.Tx) error {
+		var book core.Book
+
+		err := tx.QueryRow(ctx, q, id, userID).
+			Scan(&book.ID, &book.Title, &book.Author, &book.PublishDate, &book.Rating, &book.UpdatedAt)
+		if err != nil {
+			if errors.Is(err, pgx.ErrNoRows) {
+				return core.ErrBookNotFound
+			}
+
+			return err
+		}
+
+		return touchBooks(ctx, tx, core.EventBookDeleted, book)
+	})
+}
+
+// LastModified returns the time of the latest change of the books, deletions included.
+func (b *BooksRepo) LastModified(ctx context.Context) (time.Time, error) {
+	var modifiedAt time.Time
+
+	err := b.db.QueryRow(postgresql.ReadOnly(ctx), "SELECT modified_at FROM books_modified").Scan(&modifiedAt)
+
+	return modifiedAt, err
+}
+
+// touchBooks records the event of a book change and the time of the latest change, last in the transaction
+// so that the time is close to the commit and the row is locked briefly.
+func touchBooks(ctx context.Context, tx pgx.Tx, eventType string, book core.Book) error {
+	if err := insertEvent(ctx, tx, eventType, book.ID, book); err != nil {
 		return err
 	}
 
-	if res.RowsAffected() == 0 {
-		return core.ErrBookNotFound
-	}
+	_, err := tx.Exec(ctx, "UPDATE books_modified SET modified_at = clock_timestamp()")
 
-	return nil
+	return err
 }
@@ -0,0 +1,88 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgconn"
+	"github.com/jackc/pgx/v4"
+
+	"github.com/ernur-eskermes/crud-app/internal/core"
+	"github.com/ernur-eskermes/crud-app/pkg/database/postgresql"
+)
+
+type IdentitiesRepo struct {
+	db postgresql.Client
+}
+
+func NewIdentitiesRepo(db postgresql.Client) *IdentitiesRepo {
+	return &IdentitiesRepo{
+		db: db,
+	}
+}
+
+func (r *IdentitiesRepo) Create(ctx context.Context, identity *core.Identity) error {
+	q := `INSERT INTO user_identities (provider, subject, user_id, email)
+		VALUES ($1, $2, $3, $4) RETURNING created_at`
+
+	err := r.db.QueryRow(ctx, q, identity.Provider, identity.Subject, identity.UserID, identity.Email).
+		Scan(&identity.CreatedAt)
+
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+		return core.ErrIdentityAlreadyLinked
+	}
+
+	return err
+}
+
+// CreateLoginState stores the state, dropping the expired states of the logins that were never completed.
+func (r *IdentitiesRepo) CreateLoginState(ctx context.Context, state core.LoginState) error {
+	if _, err := r.db.Exec(ctx, "DELETE FROM oidc_login_states WHERE expires_at < now()"); err != nil {
+		return err
+	}
+
+	q := `INSERT INTO oidc_login_states (state_hash, browser_hash, provider, nonce, verifier, link_to, expires_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)`
+
+	_, err := r.db.Exec(ctx, q, state.StateHash, state.BrowserHash, state.Provider, state.Nonce, state.Verifier,
+		nullUUID(state.LinkTo), state.ExpiresAt)
+
+	return err
+}
+
+// ConsumeLoginState deletes the state and returns it, so a login can be completed only once.
+func (r *IdentitiesRepo) ConsumeLoginState(ctx context.Context, stateHash string) (core.LoginState, error) {
+	q := `DELETE FROM oidc_login_states WHERE state_hash=$1
+		RETURNING state_hash, browser_hash, provider, nonce, verifier, link_to, expires_at`
+
+	var (
+		state  core.LoginState
+		linkTo uuid.NullUUID
+	)
+
+	err := r.db.QueryRow(ctx, q, stateHash).Scan(&state.StateHash, &state.BrowserHash, &state.Provider,
+		&state.Nonce, &state.Verifier, &linkTo, &state.ExpiresAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return core.LoginState{}, core.ErrLoginStateInvalid
+	}
+
+	state.LinkTo = linkTo.UUID
+
+	return state, err
+}
+
+func (r *IdentitiesRepo) Get(ctx context.Context, provider, subject string) (core.Identity, error) {
+	q := "SELECT provider, subject, user_id, email, created_at FROM user_identities WHERE provider=$1 AND subject=$2"
+
+	var identity core.Identity
+
+	err := r.db.QueryRow(ctx, q, provider, subject).
+		Scan(&identity.Provider, &identity.Subject, &identity.UserID, &identity.Email, &identity.CreatedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return core.Identity{}, core.ErrIdentityNotFound
+	}
+
+	return identity, err
+}
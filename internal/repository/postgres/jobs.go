@@ -0,0 +1,162 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v4"
+
+	"github.com/ernur-eskermes/crud-app/internal/core"
+	"github.com/ernur-eskermes/crud-app/pkg/database/postgresql"
+)
+
+const jobColumns = "id, type, payload, status, attempts, max_attempts, run_at, last_error, key, created_at, finished_at"
+
+type JobsRepo struct {
+	db postgresql.Client
+}
+
+func NewJobsRepo(db postgresql.Client) *JobsRepo {
+	return &JobsRepo{
+		db: db,
+	}
+}
+
+// Create queues the job. A job with the key of an existing one is not queued, Create then reports false.
+func (r *JobsRepo) Create(ctx context.Context, job *core.Job) (bool, error) {
+	q := `INSERT INTO jobs (type, payload, max_attempts, run_at, key) VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (key) WHERE key IS NOT NULL DO NOTHING
+		RETURNING id, status, attempts, created_at`
+
+	err := r.db.QueryRow(ctx, q, job.Type, job.Payload, job.MaxAttempts, job.RunAt, job.Key).
+		Scan(&job.ID, &job.Status, &job.Attempts, &job.CreatedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return false, nil
+	}
+
+	return err == nil, err
+}
+
+// Claim marks up to limit due jobs of the types as running, counting the attempt, and locks them for lease.
+// Running jobs whose lease has expired, e.g. because their worker died, are due again.
+func (r *JobsRepo) Claim(ctx context.Context, types []string, limit int, lease time.Duration) ([]core.Job, error) {
+	q := `WITH due AS (
+			SELECT id FROM jobs WHERE type = ANY ($1) AND run_at <= now()
+				AND (status = $2 OR (status = $3 AND locked_until < now()))
+			ORDER BY run_at LIMIT $4 FOR UPDATE SKIP LOCKED
+		)
+		UPDATE jobs j SET status = $3, attempts = attempts + 1, locked_until = now() + $5::interval
+		FROM due WHERE j.id = due.id
+		RETURNING j.id, j.type, j.payload, j.status, j.attempts, j.max_attempts, j.run_at, j.last_error, j.key,
+			j.created_at, j.finished_at`
+
+	rows, err := r.db.Query(ctx, q, types, core.JobQueued, core.JobRunning, limit, lease)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	jobs := make([]core.Job, 0, limit)
+
+	for rows.Next() {
+		job, err := scanJob(rows)
+		if err != nil {
+			return nil, err
+		}
+
+		jobs = append(jobs, job)
+	}
+
+	return jobs, rows.Err()
+}
+
+// Finish stores the outcome of the attempt of the job: succeeded or dead, or queued again at RunAt.
+// The outcome is dropped if the lease of the attempt expired and the job was claimed again.
+func (r *JobsRepo) Finish(ctx context.Context, job core.Job) error {
+	q := `UPDATE jobs SET status=$3, run_at=$4, last_error=$5, locked_until=NULL,
+		finished_at=CASE WHEN $3 IN ($6, $7) THEN now() END
+		WHERE id=$1 AND attempts=$2 AND status=$8`
+
+	_, err := r.db.Exec(ctx, q, job.ID, job.Attempts, job.Status, job.RunAt, job.LastError,
+		core.JobSucceeded, core.JobDead, core.JobRunning)
+
+	return err
+}
+
+// Release queues a running job again without counting the attempt, e.g. when it is interrupted by a shutdown.
+func (r *JobsRepo) Release(ctx context.Context, job core.Job) error {
+	q := `UPDATE jobs SET status=$3, attempts=attempts-1, run_at=now(), locked_until=NULL
+		WHERE id=$1 AND attempts=$2 AND status=$4`
+
+	_, err := r.db.Exec(ctx, q, job.ID, job.Attempts, core.JobQueued, core.JobRunning)
+
+	return err
+}
+
+func (r *JobsRepo) Get(ctx context.Context, id uuid.UUID) (core.Job, error) {
+	job, err := scanJob(r.db.QueryRow(ctx, "SELECT "+jobColumns+" FROM jobs WHERE id=$1", id))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return core.Job{}, core.ErrJobNotFound
+	}
+
+	return job, err
+}
+
+// GetAll returns the jobs matching the query, newest first.
+func (r *JobsRepo) GetAll(ctx context.Context, query core.JobsQuery) ([]core.Job, error) {
+	q := "SELECT " + jobColumns + ` FROM jobs WHERE ($1 = '' OR status = $1) AND ($2 = '' OR type = $2)
+		ORDER BY created_at DESC LIMIT $3 OFFSET $4`
+
+	rows, err := r.db.Query(ctx, q, query.Status, query.Type, query.Limit, query.Offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	jobs := make([]core.Job, 0)
+
+	for rows.Next() {
+		job, err := scanJob(rows)
+		if err != nil {
+			return nil, err
+		}
+
+		jobs = append(jobs, job)
+	}
+
+	return jobs, rows.Err()
+}
+
+// Retry queues a dead job again with all of its attempts.
+func (r *JobsRepo) Retry(ctx context.Context, id uuid.UUID) (core.Job, error) {
+	q := `UPDATE jobs SET status=$2, attempts=0, run_at=now(), last_error='', finished_at=NULL
+		WHERE id=$1 AND status=$3 RETURNING ` + jobColumns
+
+	job, err := scanJob(r.db.QueryRow(ctx, q, id, core.JobQueued, core.JobDead))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return core.Job{}, core.ErrJobNotRetryable
+	}
+
+	return job, err
+}
+
+// Purge deletes the jobs that succeeded before the time. Dead jobs are kept until they are retried.
+func (r *JobsRepo) Purge(ctx context.Context, before time.Time) (int64, error) {
+	res, err := r.db.Exec(ctx, "DELETE FROM jobs WHERE status=$1 AND finished_at < $2", core.JobSucceeded, before)
+	if err != nil {
+		return 0, err
+	}
+
+	return res.RowsAffected(), nil
+}
+
+func scanJob(row pgx.Row) (core.Job, error) {
+	var job core.Job
+
+	err := row.Scan(&job.ID, &job.Type, &job.Payload, &job.Status, &job.Attempts, &job.MaxAttempts, &job.RunAt,
+		&job.LastError, &job.Key, &job.CreatedAt, &job.FinishedAt)
+
+	return job, err
+}
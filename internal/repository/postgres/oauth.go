@@ -0,0 +1,244 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v4"
+
+	"github.com/ernur-eskermes/crud-app/internal/core"
+	"github.com/ernur-eskermes/crud-app/pkg/database/postgresql"
+)
+
+const oauthClientColumns = "id, name, secret_hash, confidential, redirect_uris, scopes, created_at"
+
+// OAuthRepo stores the clients, consents, authorization codes and grants of the OAuth server.
+type OAuthRepo struct {
+	db postgresql.Client
+}
+
+func NewOAuthRepo(db postgresql.Client) *OAuthRepo {
+	return &OAuthRepo{
+		db: db,
+	}
+}
+
+func (r *OAuthRepo) CreateClient(ctx context.Context, client *core.OAuthClient) error {
+	q := `INSERT INTO oauth_clients (name, secret_hash, confidential, redirect_uris, scopes)
+		VALUES ($1, $2, $3, $4, $5) RETURNING id, created_at`
+
+	return r.db.QueryRow(ctx, q, client.Name, client.SecretHash, client.Confidential, client.RedirectURIs, client.Scopes).
+		Scan(&client.ID, &client.CreatedAt)
+}
+
+func (r *OAuthRepo) GetClient(ctx context.Context, id uuid.UUID) (core.OAuthClient, error) {
+	q := "SELECT " + oauthClientColumns + " FROM oauth_clients WHERE id=$1"
+
+	client, err := scanOAuthClient(r.db.QueryRow(ctx, q, id))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return core.OAuthClient{}, core.ErrOAuthClientNotFound
+	}
+
+	return client, err
+}
+
+func (r *OAuthRepo) GetClients(ctx context.Context) ([]core.OAuthClient, error) {
+	rows, err := r.db.Query(ctx, "SELECT "+oauthClientColumns+" FROM oauth_clients ORDER BY created_at")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	clients := make([]core.OAuthClient, 0)
+
+	for rows.Next() {
+		client, err := scanOAuthClient(rows)
+		if err != nil {
+			return nil, err
+		}
+
+		clients = append(clients, client)
+	}
+
+	return clients, rows.Err()
+}
+
+func (r *OAuthRepo) DeleteClient(ctx context.Context, id uuid.UUID) error {
+	res, err := r.db.Exec(ctx, "DELETE FROM oauth_clients WHERE id=$1", id)
+	if err != nil {
+		return err
+	}
+
+	if res.RowsAffected() == 0 {
+		return core.ErrOAuthClientNotFound
+	}
+
+	return nil
+}
+
+func (r *OAuthRepo) GetConsent(ctx context.Context, userID, clientID uuid.UUID) (core.OAuthConsent, error) {
+	q := `SELECT c.user_id, c.client_id, cl.name, c.scopes, c.granted_at
+		FROM oauth_consents c JOIN oauth_clients cl ON cl.id = c.client_id
+		WHERE c.user_id=$1 AND c.client_id=$2`
+
+	consent, err := scanOAuthConsent(r.db.QueryRow(ctx, q, userID, clientID))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return core.OAuthConsent{}, core.ErrConsentNotFound
+	}
+
+	return consent, err
+}
+
+func (r *OAuthRepo) GetConsents(ctx context.Context, userID uuid.UUID) ([]core.OAuthConsent, error) {
+	q := `SELECT c.user_id, c.client_id, cl.name, c.scopes, c.granted_at
+		FROM oauth_consents c JOIN oauth_clients cl ON cl.id = c.client_id
+		WHERE c.user_id=$1 ORDER BY c.granted_at`
+
+	rows, err := r.db.Query(ctx, q, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	consents := make([]core.OAuthConsent, 0)
+
+	for rows.Next() {
+		consent, err := scanOAuthConsent(rows)
+		if err != nil {
+			return nil, err
+		}
+
+		consents = append(consents, consent)
+	}
+
+	return consents, rows.Err()
+}
+
+// SaveConsent creates the consent or replaces its scopes.
+func (r *OAuthRepo) SaveConsent(ctx context.Context, consent core.OAuthConsent) error {
+	q := `INSERT INTO oauth_consents (user_id, client_id, scopes) VALUES ($1, $2, $3)
+		ON CONFLICT (user_id, client_id) DO UPDATE SET scopes=EXCLUDED.scopes, granted_at=now()`
+
+	_, err := r.db.Exec(ctx, q, consent.UserID, consent.ClientID, consent.Scopes)
+
+	return err
+}
+
+func (r *OAuthRepo) DeleteConsent(ctx context.Context, userID, clientID uuid.UUID) error {
+	res, err := r.db.Exec(ctx, "DELETE FROM oauth_consents WHERE user_id=$1 AND client_id=$2", userID, clientID)
+	if err != nil {
+		return err
+	}
+
+	if res.RowsAffected() == 0 {
+		return core.ErrConsentNotFound
+	}
+
+	return nil
+}
+
+// CreateCode stores the code, dropping the expired codes that were never exchanged.
+func (r *OAuthRepo) CreateCode(ctx context.Context, code core.AuthorizationCode) error {
+	if _, err := r.db.Exec(ctx, "DELETE FROM oauth_codes WHERE expires_at < now()"); err != nil {
+		return err
+	}
+
+	q := `INSERT INTO oauth_codes (code_hash, client_id, user_id, redirect_uri, scopes, code_challenge, expires_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)`
+
+	_, err := r.db.Exec(ctx, q, code.Hash, code.ClientID, code.UserID, code.RedirectURI, code.Scopes,
+		code.CodeChallenge, code.ExpiresAt)
+
+	return err
+}
+
+// ConsumeCode deletes the code and returns it, so a code can be exchanged only once.
+func (r *OAuthRepo) ConsumeCode(ctx context.Context, hash string) (core.AuthorizationCode, error) {
+	q := `DELETE FROM oauth_codes WHERE code_hash=$1
+		RETURNING code_hash, client_id, user_id, redirect_uri, scopes, code_challenge, expires_at`
+
+	var code core.AuthorizationCode
+
+	err := r.db.QueryRow(ctx, q, hash).Scan(&code.Hash, &code.ClientID, &code.UserID, &code.RedirectURI,
+		&code.Scopes, &code.CodeChallenge, &code.ExpiresAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return core.AuthorizationCode{}, core.ErrCodeNotFound
+	}
+
+	return code, err
+}
+
+// CreateGrant stores the grant, dropping the grants expired for more than a day.
+func (r *OAuthRepo) CreateGrant(ctx context.Context, grant *core.OAuthGrant) error {
+	if _, err := r.db.Exec(ctx, "DELETE FROM oauth_grants WHERE expires_at < now() - interval '1 day'"); err != nil {
+		return err
+	}
+
+	q := `INSERT INTO oauth_grants (client_id, user_id, scopes, expires_at) VALUES ($1, $2, $3, $4) RETURNING id`
+
+	return r.db.QueryRow(ctx, q, grant.ClientID, nullUUID(grant.UserID), grant.Scopes, grant.ExpiresAt).
+		Scan(&grant.ID)
+}
+
+func (r *OAuthRepo) GetGrant(ctx context.Context, id uuid.UUID) (core.OAuthGrant, error) {
+	q := "SELECT id, client_id, user_id, scopes, expires_at, revoked_at FROM oauth_grants WHERE id=$1"
+
+	var (
+		grant  core.OAuthGrant
+		userID uuid.NullUUID
+	)
+
+	err := r.db.QueryRow(ctx, q, id).Scan(&grant.ID, &grant.ClientID, &userID, &grant.Scopes,
+		&grant.ExpiresAt, &grant.RevokedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return core.OAuthGrant{}, core.ErrGrantNotFound
+	}
+
+	grant.UserID = userID.UUID
+
+	return grant, err
+}
+
+func (r *OAuthRepo) ExtendGrant(ctx context.Context, id uuid.UUID, expiresAt time.Time) error {
+	_, err := r.db.Exec(ctx, "UPDATE oauth_grants SET expires_at=$2 WHERE id=$1", id, expiresAt)
+
+	return err
+}
+
+func (r *OAuthRepo) RevokeGrant(ctx context.Context, id uuid.UUID) error {
+	_, err := r.db.Exec(ctx, "UPDATE oauth_grants SET revoked_at=now() WHERE id=$1 AND revoked_at IS NULL", id)
+
+	return err
+}
+
+// RevokeGrants revokes every grant of the user to the client.
+func (r *OAuthRepo) RevokeGrants(ctx context.Context, userID, clientID uuid.UUID) error {
+	q := "UPDATE oauth_grants SET revoked_at=now() WHERE user_id=$1 AND client_id=$2 AND revoked_at IS NULL"
+
+	_, err := r.db.Exec(ctx, q, userID, clientID)
+
+	return err
+}
+
+func scanOAuthClient(row pgx.Row) (core.OAuthClient, error) {
+	var client core.OAuthClient
+
+	err := row.Scan(&client.ID, &client.Name, &client.SecretHash, &client.Confidential,
+		&client.RedirectURIs, &client.Scopes, &client.CreatedAt)
+
+	return client, err
+}
+
+func scanOAuthConsent(row pgx.Row) (core.OAuthConsent, error) {
+	var consent core.OAuthConsent
+
+	err := row.Scan(&consent.UserID, &consent.ClientID, &consent.ClientName, &consent.Scopes, &consent.GrantedAt)
+
+	return consent, err
+}
+
+func nullUUID(id uuid.UUID) uuid.NullUUID {
+	return uuid.NullUUID{UUID: id, Valid: id != uuid.Nil}
+}
@@ -0,0 +1,210 @@
+package postgres
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v4"
+	"github.com/jackc/pgx/v4/pgxpool"
+
+	"github.com/ernur-eskermes/crud-app/internal/core"
+	"github.com/ernur-eskermes/crud-app/pkg/database/postgresql"
+)
+
+// outboxChannel is notified with the id of every event inserted in the outbox.
+const outboxChannel = "outbox"
+
+type OutboxRepo struct {
+	db postgresql.Client
+}
+
+func NewOutboxRepo(db postgresql.Client) *OutboxRepo {
+	return &OutboxRepo{
+		db: db,
+	}
+}
+
+// Dispatch passes up to limit unpublished events, oldest first, to publish and marks the published ones.
+// It stops at the first event publish fails for, which is passed again by the next call.
+// The events are locked meanwhile, other replicas skip them.
+func (r *OutboxRepo) Dispatch(ctx context.Context, limit int, publish func(ctx context.Context, event core.Event) error,
+) (int, error) {
+	var (
+		published  int
+		publishErr error
+	)
+
+	err := postgresql.WithTx(ctx, r.db, func(tx pgx.Tx) error {
+		q := `SELECT id, type, aggregate_id, data, created_at FROM outbox WHERE published_at IS NULL
+			ORDER BY id LIMIT $1 FOR UPDATE SKIP LOCKED`
+
+		rows, err := tx.Query(ctx, q, limit)
+		if err != nil {
+			return err
+		}
+
+		events := make([]core.Event, 0, limit)
+
+		for rows.Next() {
+			var event core.Event
+
+			if err = rows.Scan(&event.ID, &event.Type, &event.AggregateID, &event.Data, &event.CreatedAt); err != nil {
+				rows.Close()
+
+				return err
+			}
+
+			events = append(events, event)
+		}
+
+		rows.Close()
+
+		if err = rows.Err(); err != nil {
+			return err
+		}
+
+		ids := make([]int64, 0, len(events))
+
+		for _, event := range events {
+			if publishErr = publish(ctx, event); publishErr != nil {
+				break
+			}
+
+			ids = append(ids, event.ID)
+		}
+
+		if len(ids) > 0 {
+			if _, err = tx.Exec(ctx, "UPDATE outbox SET published_at=now() WHERE id = ANY($1)", ids); err != nil {
+				return err
+			}
+		}
+
+		published = len(ids)
+
+		// The events published before a failure are committed as published all the same.
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return published, publishErr
+}
+
+// Purge deletes the events published before the time.
+func (r *OutboxRepo) Purge(ctx context.Context, before time.Time) (int64, error) {
+	res, err := r.db.Exec(ctx, "DELETE FROM outbox WHERE published_at < $1", before)
+	if err != nil {
+		return 0, err
+	}
+
+	return res.RowsAffected(), nil
+}
+
+// OutboxFeed passes the events of the outbox to every instance listening to it, as they are committed,
+// unlike Dispatch passing each event to one instance. The events are not marked as published.
+type OutboxFeed struct {
+	db   postgresql.Client
+	pool *pgxpool.Pool
+}
+
+// NewOutboxFeed returns a feed reading the events with db and listening with a connection of its own to pool,
+// which must be the primary.
+func NewOutboxFeed(db postgresql.Client, pool *pgxpool.Pool) *OutboxFeed {
+	return &OutboxFeed{
+		db:   db,
+		pool: pool,
+	}
+}
+
+// Listen passes the latest recent events of the types to handle, oldest first, then those committed after,
+// until ctx is done or the connection fails. The events committed while no instance listens are missed,
+// the recent ones passed on the next call make up for a short interruption.
+func (f *OutboxFeed) Listen(ctx context.Context, types []string, recent int,
+	handle func(ctx context.Context, event core.Event) error,
+) error {
+	ready := func(ctx context.Context) error {
+		q := `SELECT id, type, aggregate_id, data, created_at FROM (
+				SELECT * FROM outbox WHERE type = ANY($1) ORDER BY id DESC LIMIT $2
+			) latest ORDER BY id`
+
+		rows, err := f.db.Query(ctx, q, types, recent)
+		if err != nil {
+			return err
+		}
+
+		events := make([]core.Event, 0, recent)
+
+		for rows.Next() {
+			var event core.Event
+
+			if err = rows.Scan(&event.ID, &event.Type, &event.AggregateID, &event.Data, &event.CreatedAt); err != nil {
+				rows.Close()
+
+				return err
+			}
+
+			events = append(events, event)
+		}
+
+		rows.Close()
+
+		if err = rows.Err(); err != nil {
+			return err
+		}
+
+		for _, event := range events {
+			if err = handle(ctx, event); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	}
+
+	notify := func(ctx context.Context, payload string) error {
+		id, err := strconv.ParseInt(payload, 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid outbox notification %q: %w", payload, err)
+		}
+
+		var event core.Event
+
+		err = f.db.QueryRow(ctx, "SELECT id, type, aggregate_id, data, created_at FROM outbox WHERE id=$1 AND type = ANY($2)",
+			id, types).Scan(&event.ID, &event.Type, &event.AggregateID, &event.Data, &event.CreatedAt)
+		if errors.Is(err, pgx.ErrNoRows) {
+			// An event of another type, or purged meanwhile.
+			return nil
+		}
+
+		if err != nil {
+			return err
+		}
+
+		return handle(ctx, event)
+	}
+
+	return postgresql.Listen(ctx, f.pool, outboxChannel, ready, notify)
+}
+
+// insertEvent records a domain event in the outbox, in the transaction of the change it describes,
+// so the event is published if and only if the change is committed.
+func insertEvent(ctx context.Context, tx pgx.Tx, eventType string, aggregateID uuid.UUID, data interface{}) error {
+	raw, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+
+	// The notification is delivered to the listeners when the transaction commits, not at all otherwise.
+	q := `WITH event AS (INSERT INTO outbox (type, aggregate_id, data) VALUES ($1, $2, $3) RETURNING id)
+		SELECT pg_notify($4, id::text) FROM event`
+
+	_, err = tx.Exec(ctx, q, eventType, aggregateID, raw, outboxChannel)
+
+	return err
+}
@@ -13,6 +13,8 @@ import (
 	"github.com/jackc/pgx/v4"
 )
 
+const userColumns = "id, username, password, role, is_active"
+
 type UsersRepo struct {
 	db postgresql.Client
 }
@@ -24,39 +26,101 @@ func NewUsersRepo(db postgresql.Client) *UsersRepo {
 }
 
 func (r *UsersRepo) GetByID(ctx context.Context, id uuid.UUID) (core.User, error) {
-	q := "SELECT id, username, password FROM users WHERE id=$1"
+	q := "SELECT " + userColumns + " FROM users WHERE id=$1"
 
-	var user core.User
+	return r.getOne(ctx, q, id)
+}
 
-	if err := r.db.QueryRow(ctx, q, id).Scan(&user.ID, &user.Username, &user.Password); err != nil {
-		if errors.Is(err, pgx.ErrNoRows) {
-			return core.User{}, core.ErrUserNotFound
+func (r *UsersRepo) GetByUsername(ctx context.Context, username string) (core.User, error) {
+	q := "SELECT " + userColumns + " FROM users WHERE username=$1"
+
+	return r.getOne(ctx, q, username)
+}
+
+func (r *UsersRepo) GetByIDs(ctx context.Context, ids []uuid.UUID) ([]core.User, error) {
+	q := "SELECT " + userColumns + " FROM users WHERE id = ANY($1)"
+
+	rows, err := r.db.Query(ctx, q, ids)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	users := make([]core.User, 0, len(ids))
+
+	for rows.Next() {
+		var user core.User
+
+		if err = rows.Scan(&user.ID, &user.Username, &user.Password, &user.Role, &user.IsActive); err != nil {
+			return nil, err
 		}
 
-		return core.User{}, err
+		users = append(users, user)
 	}
 
-	return user, nil
+	return users, rows.Err()
 }
 
+// Verify activates the user, recording user.verified unless the user was already active.
 func (r *UsersRepo) Verify(ctx context.Context, username string) error {
-	q := "UPDATE users SET is_active=true WHERE username=$1"
+	q := `UPDATE users u SET is_active=true FROM users old WHERE u.id = old.id AND u.username=$1
+		RETURNING u.id, u.username, u.role, old.is_active`
+
+	return postgresql.WithTx(ctx, r.db, func(tx pgx.Tx) error {
+		var (
+			user      core.UserEvent
+			wasActive bool
+		)
 
-	if res, err := r.db.Exec(ctx, q, username); err != nil {
-		if res.RowsAffected() == 0 {
-			return core.ErrUserNotFound
+		if err := tx.QueryRow(ctx, q, username).Scan(&user.ID, &user.Username, &user.Role, &wasActive); err != nil {
+			if errors.Is(err, pgx.ErrNoRows) {
+				return core.ErrUserNotFound
+			}
+
+			return err
+		}
+
+		if wasActive {
+			return nil
 		}
 
+		return insertEvent(ctx, tx, core.EventUserVerified, user.ID, user)
+	})
+}
+
+func (r *UsersRepo) SetRole(ctx context.Context, username, role string) error {
+	q := "UPDATE users SET role=$1 WHERE username=$2"
+
+	res, err := r.db.Exec(ctx, q, role, username)
+	if err != nil {
 		return err
 	}
 
+	if res.RowsAffected() == 0 {
+		return core.ErrUserNotFound
+	}
+
 	return nil
 }
 
 func (r *UsersRepo) Create(ctx context.Context, user *core.User) error {
-	q := "INSERT INTO users (username, password) VALUES ($1, $2) RETURNING id"
+	q := "INSERT INTO users (username, password, role, is_active) VALUES ($1, $2, $3, $4) RETURNING id"
 
-	err := r.db.QueryRow(ctx, q, user.Username, user.Password).Scan(&user.ID)
+	if user.Role == "" {
+		user.Role = core.RoleUser
+	}
+
+	err := postgresql.WithTx(ctx, r.db, func(tx pgx.Tx) error {
+		if err := tx.QueryRow(ctx, q, user.Username, user.Password, user.Role, user.IsActive).Scan(&user.ID); err != nil {
+			return err
+		}
+
+		return insertEvent(ctx, tx, core.EventUserSignedUp, user.ID, core.UserEvent{
+			ID:       user.ID,
+			Username: user.Username,
+			Role:     user.Role,
+		})
+	})
 
 	var pgErr *pgconn.PgError
 	if errors.As(err, &pgErr) {
@@ -69,10 +133,21 @@ func (r *UsersRepo) Create(ctx context.Context, user *core.User) error {
 }
 
 func (r *UsersRepo) GetByCredentials(ctx context.Context, username, password string) (core.User, error) {
-	q := "SELECT id, username, password FROM users WHERE username=$1 and password=$2"
+	q := "SELECT " + userColumns + " FROM users WHERE username=$1 and password=$2"
 
+	return r.getOne(ctx, q, username, password)
+}
+
+func (r *UsersRepo) getOne(ctx context.Context, q string, args ...interface{}) (core.User, error) {
 	var user core.User
-	if err := r.db.QueryRow(ctx, q, username, password).Scan(&user.ID, &user.Username, &user.Password); err != nil {
+
+	if err := r.db.QueryRow(ctx, q, args...).Scan(
+		&user.ID,
+		&user.Username,
+		&user.Password,
+		&user.Role,
+		&user.IsActive,
+	); err != nil {
 		if errors.Is(err, pgx.ErrNoRows) {
 			return core.User{}, core.ErrUserNotFound
 		}
@@ -0,0 +1,133 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgconn"
+	"github.com/jackc/pgx/v4"
+
+	"github.com/ernur-eskermes/crud-app/internal/core"
+	"github.com/ernur-eskermes/crud-app/pkg/database/postgresql"
+)
+
+const webAuthnCredentialColumns = `id, user_id, name, credential_id, public_key, attestation_type, aaguid,
+	sign_count, last_used_at, created_at`
+
+type WebAuthnRepo struct {
+	db postgresql.Client
+}
+
+func NewWebAuthnRepo(db postgresql.Client) *WebAuthnRepo {
+	return &WebAuthnRepo{
+		db: db,
+	}
+}
+
+func (r *WebAuthnRepo) Create(ctx context.Context, credential *core.WebAuthnCredential) error {
+	q := `INSERT INTO webauthn_credentials (user_id, name, credential_id, public_key, attestation_type, aaguid, sign_count)
+		VALUES ($1, $2, $3, $4, $5, $6, $7) RETURNING id, created_at`
+
+	err := r.db.QueryRow(ctx, q, credential.UserID, credential.Name, credential.CredentialID, credential.PublicKey,
+		credential.AttestationType, credential.AAGUID, int64(credential.SignCount)).
+		Scan(&credential.ID, &credential.CreatedAt)
+
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+		return core.ErrCredentialAlreadyRegistered
+	}
+
+	return err
+}
+
+func (r *WebAuthnRepo) GetByUser(ctx context.Context, userID uuid.UUID) ([]core.WebAuthnCredential, error) {
+	q := "SELECT " + webAuthnCredentialColumns + " FROM webauthn_credentials WHERE user_id=$1 ORDER BY created_at"
+
+	rows, err := r.db.Query(ctx, q, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	credentials := make([]core.WebAuthnCredential, 0)
+
+	for rows.Next() {
+		credential, err := scanWebAuthnCredential(rows)
+		if err != nil {
+			return nil, err
+		}
+
+		credentials = append(credentials, credential)
+	}
+
+	return credentials, rows.Err()
+}
+
+// Touch stores the signature counter reported by the authenticator and the time of the login.
+func (r *WebAuthnRepo) Touch(ctx context.Context, id uuid.UUID, signCount uint32) error {
+	_, err := r.db.Exec(ctx, "UPDATE webauthn_credentials SET sign_count=$2, last_used_at=now() WHERE id=$1",
+		id, int64(signCount))
+
+	return err
+}
+
+func (r *WebAuthnRepo) Delete(ctx context.Context, id, userID uuid.UUID) error {
+	res, err := r.db.Exec(ctx, "DELETE FROM webauthn_credentials WHERE id=$1 AND user_id=$2", id, userID)
+	if err != nil {
+		return err
+	}
+
+	if res.RowsAffected() == 0 {
+		return core.ErrCredentialNotFound
+	}
+
+	return nil
+}
+
+func scanWebAuthnCredential(row pgx.Row) (core.WebAuthnCredential, error) {
+	var (
+		credential core.WebAuthnCredential
+		signCount  int64
+	)
+
+	err := row.Scan(&credential.ID, &credential.UserID, &credential.Name, &credential.CredentialID,
+		&credential.PublicKey, &credential.AttestationType, &credential.AAGUID, &signCount,
+		&credential.LastUsedAt, &credential.CreatedAt)
+	credential.SignCount = uint32(signCount)
+
+	return credential, err
+}
+
+// CreateSession stores the session, replacing the one with the same key and dropping the expired ones.
+func (r *WebAuthnRepo) CreateSession(ctx context.Context, session core.WebAuthnSession) error {
+	if _, err := r.db.Exec(ctx, "DELETE FROM webauthn_sessions WHERE expires_at < now()"); err != nil {
+		return err
+	}
+
+	q := `INSERT INTO webauthn_sessions (key_hash, user_id, data, expires_at) VALUES ($1, $2, $3, $4)
+		ON CONFLICT (key_hash) DO UPDATE SET user_id=excluded.user_id, data=excluded.data, expires_at=excluded.expires_at`
+
+	var data interface{}
+	if session.Data != nil {
+		data = session.Data
+	}
+
+	_, err := r.db.Exec(ctx, q, session.KeyHash, session.UserID, data, session.ExpiresAt)
+
+	return err
+}
+
+// ConsumeSession deletes the session and returns it, so a ceremony can be completed only once.
+func (r *WebAuthnRepo) ConsumeSession(ctx context.Context, keyHash string) (core.WebAuthnSession, error) {
+	q := "DELETE FROM webauthn_sessions WHERE key_hash=$1 RETURNING key_hash, user_id, data, expires_at"
+
+	var session core.WebAuthnSession
+
+	err := r.db.QueryRow(ctx, q, keyHash).Scan(&session.KeyHash, &session.UserID, &session.Data, &session.ExpiresAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return core.WebAuthnSession{}, core.ErrWebAuthnSessionInvalid
+	}
+
+	return session, err
+}
@@ -0,0 +1,250 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v4"
+
+	"github.com/ernur-eskermes/crud-app/internal/core"
+	"github.com/ernur-eskermes/crud-app/pkg/database/postgresql"
+)
+
+const (
+	webhookColumns  = "id, user_id, url, events, secret, failures, disabled_at, created_at"
+	deliveryColumns = `d.id, d.webhook_id, d.event, d.payload, d.status, d.attempts, d.response_status, d.last_error,
+	d.next_attempt_at, d.delivered_at, d.created_at`
+)
+
+type WebhooksRepo struct {
+	db postgresql.Client
+}
+
+func NewWebhooksRepo(db postgresql.Client) *WebhooksRepo {
+	return &WebhooksRepo{
+		db: db,
+	}
+}
+
+func (r *WebhooksRepo) Create(ctx context.Context, webhook *core.Webhook) error {
+	q := `INSERT INTO webhooks (user_id, url, events, secret) VALUES ($1, $2, $3, $4) RETURNING id, created_at`
+
+	return r.db.QueryRow(ctx, q, webhook.UserID, webhook.URL, webhook.Events, webhook.Secret).
+		Scan(&webhook.ID, &webhook.CreatedAt)
+}
+
+func (r *WebhooksRepo) Get(ctx context.Context, id, userID uuid.UUID) (core.Webhook, error) {
+	q := "SELECT " + webhookColumns + " FROM webhooks WHERE id=$1 AND user_id=$2"
+
+	webhook, err := scanWebhook(r.db.QueryRow(ctx, q, id, userID))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return core.Webhook{}, core.ErrWebhookNotFound
+	}
+
+	return webhook, err
+}
+
+func (r *WebhooksRepo) GetByUser(ctx context.Context, userID uuid.UUID) ([]core.Webhook, error) {
+	q := "SELECT " + webhookColumns + " FROM webhooks WHERE user_id=$1 ORDER BY created_at"
+
+	rows, err := r.db.Query(ctx, q, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	webhooks := make([]core.Webhook, 0)
+
+	for rows.Next() {
+		webhook, err := scanWebhook(rows)
+		if err != nil {
+			return nil, err
+		}
+
+		webhooks = append(webhooks, webhook)
+	}
+
+	return webhooks, rows.Err()
+}
+
+func (r *WebhooksRepo) Update(ctx context.Context, webhook core.Webhook) error {
+	q := "UPDATE webhooks SET url=$3, events=$4, failures=$5, disabled_at=$6 WHERE id=$1 AND user_id=$2"
+
+	res, err := r.db.Exec(ctx, q, webhook.ID, webhook.UserID, webhook.URL, webhook.Events, webhook.Failures,
+		webhook.DisabledAt)
+	if err != nil {
+		return err
+	}
+
+	if res.RowsAffected() == 0 {
+		return core.ErrWebhookNotFound
+	}
+
+	return nil
+}
+
+func (r *WebhooksRepo) Delete(ctx context.Context, id, userID uuid.UUID) error {
+	res, err := r.db.Exec(ctx, "DELETE FROM webhooks WHERE id=$1 AND user_id=$2", id, userID)
+	if err != nil {
+		return err
+	}
+
+	if res.RowsAffected() == 0 {
+		return core.ErrWebhookNotFound
+	}
+
+	return nil
+}
+
+// CreateDeliveries creates the deliveries of a book event with the payload for every enabled webhook
+// subscribed to it, of the author of the book or of an admin. A webhook gets one delivery per event
+// however many times the event is passed.
+func (r *WebhooksRepo) CreateDeliveries(ctx context.Context, event core.Event, authorID uuid.UUID, payload []byte,
+) (int, error) {
+	q := `INSERT INTO webhook_deliveries (webhook_id, event_id, event, payload)
+		SELECT w.id, $1, $2, $3 FROM webhooks w JOIN users u ON u.id = w.user_id
+		WHERE w.disabled_at IS NULL AND $2 = ANY (w.events) AND (w.user_id = $4 OR u.role = $5)
+		ON CONFLICT (webhook_id, event_id) DO NOTHING`
+
+	res, err := r.db.Exec(ctx, q, event.ID, event.Type, payload, authorID, core.RoleAdmin)
+	if err != nil {
+		return 0, err
+	}
+
+	return int(res.RowsAffected()), nil
+}
+
+// ClaimDeliveries returns up to limit pending deliveries that are due, of enabled webhooks,
+// and postpones their next attempt by lease so other replicas don't send them meanwhile.
+func (r *WebhooksRepo) ClaimDeliveries(ctx context.Context, limit int, lease time.Duration) ([]core.WebhookDelivery, error) {
+	q := `WITH due AS (
+			SELECT d.id FROM webhook_deliveries d JOIN webhooks w ON w.id = d.webhook_id
+			WHERE d.status = $1 AND d.next_attempt_at <= now() AND w.disabled_at IS NULL
+			ORDER BY d.next_attempt_at LIMIT $2 FOR UPDATE OF d SKIP LOCKED
+		)
+		UPDATE webhook_deliveries d SET next_attempt_at = now() + $3::interval
+		FROM due, webhooks w WHERE d.id = due.id AND w.id = d.webhook_id
+		RETURNING ` + deliveryColumns + `, w.url, w.secret`
+
+	rows, err := r.db.Query(ctx, q, core.DeliveryPending, limit, lease)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	deliveries := make([]core.WebhookDelivery, 0, limit)
+
+	for rows.Next() {
+		var url, secret string
+
+		delivery, err := scanDelivery(rows, &url, &secret)
+		if err != nil {
+			return nil, err
+		}
+
+		delivery.URL = url
+		delivery.Secret = secret
+		deliveries = append(deliveries, delivery)
+	}
+
+	return deliveries, rows.Err()
+}
+
+// SaveAttempt stores the outcome of a delivery attempt and counts it towards the consecutive failures
+// of the webhook, which is disabled when they reach disableAfter. Reports whether this attempt disabled it.
+func (r *WebhooksRepo) SaveAttempt(ctx context.Context, delivery core.WebhookDelivery, disableAfter int) (bool, error) {
+	var disabled bool
+
+	err := postgresql.WithTx(ctx, r.db, func(tx pgx.Tx) error {
+		q := `UPDATE webhook_deliveries SET status=$2, attempts=$3, response_status=$4, last_error=$5,
+			next_attempt_at=$6, delivered_at=$7 WHERE id=$1`
+
+		_, err := tx.Exec(ctx, q, delivery.ID, delivery.Status, delivery.Attempts, delivery.ResponseStatus,
+			delivery.LastError, delivery.NextAttemptAt, delivery.DeliveredAt)
+		if err != nil {
+			return err
+		}
+
+		if delivery.Status == core.DeliverySucceeded {
+			_, err = tx.Exec(ctx, "UPDATE webhooks SET failures=0 WHERE id=$1", delivery.WebhookID)
+
+			return err
+		}
+
+		q = `UPDATE webhooks SET failures=failures+1,
+			disabled_at=CASE WHEN failures+1 >= $2 AND disabled_at IS NULL THEN now() ELSE disabled_at END
+			WHERE id=$1 RETURNING failures = $2`
+
+		return tx.QueryRow(ctx, q, delivery.WebhookID, disableAfter).Scan(&disabled)
+	})
+
+	return disabled, err
+}
+
+func (r *WebhooksRepo) CreateDelivery(ctx context.Context, delivery *core.WebhookDelivery) error {
+	q := `INSERT INTO webhook_deliveries (webhook_id, event, payload) VALUES ($1, $2, $3)
+		RETURNING id, status, attempts, next_attempt_at, created_at`
+
+	return r.db.QueryRow(ctx, q, delivery.WebhookID, delivery.Event, delivery.Payload).
+		Scan(&delivery.ID, &delivery.Status, &delivery.Attempts, &delivery.NextAttemptAt, &delivery.CreatedAt)
+}
+
+func (r *WebhooksRepo) GetDelivery(ctx context.Context, id, webhookID uuid.UUID) (core.WebhookDelivery, error) {
+	q := "SELECT " + deliveryColumns + " FROM webhook_deliveries d WHERE d.id=$1 AND d.webhook_id=$2"
+
+	delivery, err := scanDelivery(r.db.QueryRow(ctx, q, id, webhookID))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return core.WebhookDelivery{}, core.ErrDeliveryNotFound
+	}
+
+	return delivery, err
+}
+
+// GetDeliveries returns the latest deliveries of the webhook, newest first.
+func (r *WebhooksRepo) GetDeliveries(ctx context.Context, webhookID uuid.UUID, limit int) ([]core.WebhookDelivery, error) {
+	q := "SELECT " + deliveryColumns + ` FROM webhook_deliveries d WHERE d.webhook_id=$1
+		ORDER BY d.created_at DESC LIMIT $2`
+
+	rows, err := r.db.Query(ctx, q, webhookID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	deliveries := make([]core.WebhookDelivery, 0)
+
+	for rows.Next() {
+		delivery, err := scanDelivery(rows)
+		if err != nil {
+			return nil, err
+		}
+
+		deliveries = append(deliveries, delivery)
+	}
+
+	return deliveries, rows.Err()
+}
+
+func scanWebhook(row pgx.Row) (core.Webhook, error) {
+	var webhook core.Webhook
+
+	err := row.Scan(&webhook.ID, &webhook.UserID, &webhook.URL, &webhook.Events, &webhook.Secret,
+		&webhook.Failures, &webhook.DisabledAt, &webhook.CreatedAt)
+
+	return webhook, err
+}
+
+// scanDelivery scans deliveryColumns followed by the extra destinations.
+func scanDelivery(row pgx.Row, extra ...interface{}) (core.WebhookDelivery, error) {
+	var delivery core.WebhookDelivery
+
+	dest := append([]interface{}{&delivery.ID, &delivery.WebhookID, &delivery.Event, &delivery.Payload,
+		&delivery.Status, &delivery.Attempts, &delivery.ResponseStatus, &delivery.LastError,
+		&delivery.NextAttemptAt, &delivery.DeliveredAt, &delivery.CreatedAt}, extra...)
+
+	err := row.Scan(dest...)
+
+	return delivery, err
+}
@@ -2,6 +2,7 @@ package repository
 
 import (
 	"context"
+	"time"
 
 	"github.com/google/uuid"
 
@@ -16,25 +17,125 @@ type Users interface {
 	Create(ctx context.Context, user *core.User) error
 	GetByCredentials(ctx context.Context, email, password string) (core.User, error)
 	GetByID(ctx context.Context, id uuid.UUID) (core.User, error)
+	GetByIDs(ctx context.Context, ids []uuid.UUID) ([]core.User, error)
+	GetByUsername(ctx context.Context, username string) (core.User, error)
 	Verify(ctx context.Context, username string) error
+	SetRole(ctx context.Context, username, role string) error
 }
 
 type Books interface {
 	Create(ctx context.Context, book core.Book) error
 	GetByID(ctx context.Context, id uuid.UUID) (core.Book, error)
 	GetAll(ctx context.Context) ([]core.Book, error)
+	Search(ctx context.Context, query core.SearchBooksQuery) ([]core.Book, error)
 	Delete(ctx context.Context, id, userID uuid.UUID) error
 	Update(ctx context.Context, inp core.Book) error
+	LastModified(ctx context.Context) (time.Time, error)
+}
+
+type Attempts interface {
+	Get(ctx context.Context, key string) (core.Attempts, error)
+	Fail(ctx context.Context, key string, now, forgetBefore time.Time) (int, error)
+	Lock(ctx context.Context, key string, until time.Time) error
+	Reset(ctx context.Context, key string) error
+}
+
+type APIKeys interface {
+	Create(ctx context.Context, key *core.APIKey) error
+	GetByPrefix(ctx context.Context, prefix string) (core.APIKey, error)
+	GetByUser(ctx context.Context, userID uuid.UUID) ([]core.APIKey, error)
+	Touch(ctx context.Context, id uuid.UUID) error
+	Delete(ctx context.Context, id, userID uuid.UUID) error
+}
+
+type Identities interface {
+	Create(ctx context.Context, identity *core.Identity) error
+	Get(ctx context.Context, provider, subject string) (core.Identity, error)
+	CreateLoginState(ctx context.Context, state core.LoginState) error
+	ConsumeLoginState(ctx context.Context, stateHash string) (core.LoginState, error)
+}
+
+type WebAuthn interface {
+	Create(ctx context.Context, credential *core.WebAuthnCredential) error
+	GetByUser(ctx context.Context, userID uuid.UUID) ([]core.WebAuthnCredential, error)
+	Touch(ctx context.Context, id uuid.UUID, signCount uint32) error
+	Delete(ctx context.Context, id, userID uuid.UUID) error
+	CreateSession(ctx context.Context, session core.WebAuthnSession) error
+	ConsumeSession(ctx context.Context, keyHash string) (core.WebAuthnSession, error)
+}
+
+type Webhooks interface {
+	Create(ctx context.Context, webhook *core.Webhook) error
+	Get(ctx context.Context, id, userID uuid.UUID) (core.Webhook, error)
+	GetByUser(ctx context.Context, userID uuid.UUID) ([]core.Webhook, error)
+	Update(ctx context.Context, webhook core.Webhook) error
+	Delete(ctx context.Context, id, userID uuid.UUID) error
+	CreateDeliveries(ctx context.Context, event core.Event, authorID uuid.UUID, payload []byte) (int, error)
+	ClaimDeliveries(ctx context.Context, limit int, lease time.Duration) ([]core.WebhookDelivery, error)
+	SaveAttempt(ctx context.Context, delivery core.WebhookDelivery, disableAfter int) (bool, error)
+	CreateDelivery(ctx context.Context, delivery *core.WebhookDelivery) error
+	GetDelivery(ctx context.Context, id, webhookID uuid.UUID) (core.WebhookDelivery, error)
+	GetDeliveries(ctx context.Context, webhookID uuid.UUID, limit int) ([]core.WebhookDelivery, error)
+}
+
+type Outbox interface {
+	Dispatch(ctx context.Context, limit int, publish func(ctx context.Context, event core.Event) error) (int, error)
+	Purge(ctx context.Context, before time.Time) (int64, error)
+}
+
+type Jobs interface {
+	Create(ctx context.Context, job *core.Job) (bool, error)
+	Claim(ctx context.Context, types []string, limit int, lease time.Duration) ([]core.Job, error)
+	Finish(ctx context.Context, job core.Job) error
+	Release(ctx context.Context, job core.Job) error
+	Get(ctx context.Context, id uuid.UUID) (core.Job, error)
+	GetAll(ctx context.Context, query core.JobsQuery) ([]core.Job, error)
+	Retry(ctx context.Context, id uuid.UUID) (core.Job, error)
+	Purge(ctx context.Context, before time.Time) (int64, error)
+}
+
+type OAuth interface {
+	CreateClient(ctx context.Context, client *core.OAuthClient) error
+	GetClient(ctx context.Context, id uuid.UUID) (core.OAuthClient, error)
+	GetClients(ctx context.Context) ([]core.OAuthClient, error)
+	DeleteClient(ctx context.Context, id uuid.UUID) error
+	GetConsent(ctx context.Context, userID, clientID uuid.UUID) (core.OAuthConsent, error)
+	GetConsents(ctx context.Context, userID uuid.UUID) ([]core.OAuthConsent, error)
+	SaveConsent(ctx context.Context, consent core.OAuthConsent) error
+	DeleteConsent(ctx context.Context, userID, clientID uuid.UUID) error
+	CreateCode(ctx context.Context, code core.AuthorizationCode) error
+	ConsumeCode(ctx context.Context, hash string) (core.AuthorizationCode, error)
+	CreateGrant(ctx context.Context, grant *core.OAuthGrant) error
+	GetGrant(ctx context.Context, id uuid.UUID) (core.OAuthGrant, error)
+	ExtendGrant(ctx context.Context, id uuid.UUID, expiresAt time.Time) error
+	RevokeGrant(ctx context.Context, id uuid.UUID) error
+	RevokeGrants(ctx context.Context, userID, clientID uuid.UUID) error
 }
 
 type Repositories struct {
-	Users Users
-	Books Books
+	Users      Users
+	Books      Books
+	Attempts   Attempts
+	APIKeys    APIKeys
+	Identities Identities
+	OAuth      OAuth
+	WebAuthn   WebAuthn
+	Webhooks   Webhooks
+	Outbox     Outbox
+	Jobs       Jobs
 }
 
 func NewRepositories(db postgresql.Client) *Repositories {
 	return &Repositories{
-		Users: postgres.NewUsersRepo(db),
-		Books: postgres.NewBooksRepo(db),
+		Users:      postgres.NewUsersRepo(db),
+		Books:      postgres.NewBooksRepo(db),
+		Attempts:   postgres.NewAttemptsRepo(db),
+		APIKeys:    postgres.NewAPIKeysRepo(db),
+		Identities: postgres.NewIdentitiesRepo(db),
+		OAuth:      postgres.NewOAuthRepo(db),
+		WebAuthn:   postgres.NewWebAuthnRepo(db),
+		Webhooks:   postgres.NewWebhooksRepo(db),
+		Outbox:     postgres.NewOutboxRepo(db),
+		Jobs:       postgres.NewJobsRepo(db),
 	}
 }
@@ -0,0 +1,189 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/ernur-eskermes/crud-app/internal/core"
+	"github.com/ernur-eskermes/crud-app/pkg/auth"
+	"github.com/ernur-eskermes/crud-app/pkg/hash"
+	"github.com/ernur-eskermes/crud-app/pkg/logging"
+)
+
+const (
+	// APIKeyPrefix starts every API key, keys look like "crud_<prefix>_<secret>".
+	APIKeyPrefix = "crud_"
+
+	apiKeyPrefixBytes = 4
+	apiKeySecretBytes = 24
+)
+
+type APIKeysRepository interface {
+	Create(ctx context.Context, key *core.APIKey) error
+	GetByPrefix(ctx context.Context, prefix string) (core.APIKey, error)
+	GetByUser(ctx context.Context, userID uuid.UUID) ([]core.APIKey, error)
+	Touch(ctx context.Context, id uuid.UUID) error
+	Delete(ctx context.Context, id, userID uuid.UUID) error
+}
+
+type APIKeysService struct {
+	repo   APIKeysRepository
+	users  UsersRepository
+	hasher hash.PasswordHasher
+}
+
+func NewAPIKeysService(repo APIKeysRepository, users UsersRepository, hasher hash.PasswordHasher) *APIKeysService {
+	return &APIKeysService{
+		repo:   repo,
+		users:  users,
+		hasher: hasher,
+	}
+}
+
+// IsAPIKey reports whether the token looks like an API key rather than a JWT.
+func IsAPIKey(token string) bool {
+	return strings.HasPrefix(token, APIKeyPrefix)
+}
+
+// Create generates a key for the user. The returned secret is the full key,
+// it is not stored and can't be shown again.
+func (s *APIKeysService) Create(ctx context.Context, userID uuid.UUID, input core.CreateAPIKeyInput) (core.APIKey, string, error) {
+	user, err := s.users.GetByID(ctx, userID)
+	if err != nil {
+		return core.APIKey{}, "", err
+	}
+
+	allowed := core.RoleScopes(user.Role)
+	for _, scope := range input.Scopes {
+		if !contains(allowed, scope) {
+			return core.APIKey{}, "", core.ErrScopeForbidden
+		}
+	}
+
+	prefix, err := randomHex(apiKeyPrefixBytes)
+	if err != nil {
+		return core.APIKey{}, "", err
+	}
+
+	secret, err := randomHex(apiKeySecretBytes)
+	if err != nil {
+		return core.APIKey{}, "", err
+	}
+
+	rawKey := APIKeyPrefix + prefix + "_" + secret
+
+	keyHash, err := s.hasher.Hash(rawKey)
+	if err != nil {
+		return core.APIKey{}, "", err
+	}
+
+	key := core.APIKey{
+		UserID:    userID,
+		Name:      input.Name,
+		Prefix:    prefix,
+		Hash:      keyHash,
+		Scopes:    input.Scopes,
+		ExpiresAt: input.ExpiresAt,
+	}
+
+	if err = s.repo.Create(ctx, &key); err != nil {
+		return core.APIKey{}, "", err
+	}
+
+	return key, rawKey, nil
+}
+
+func (s *APIKeysService) List(ctx context.Context, userID uuid.UUID) ([]core.APIKey, error) {
+	return s.repo.GetByUser(ctx, userID)
+}
+
+func (s *APIKeysService) Revoke(ctx context.Context, userID, id uuid.UUID) error {
+	return s.repo.Delete(ctx, id, userID)
+}
+
+// Authenticate checks the key and returns the claims of its owner, limited to the key scopes.
+// Scopes the owner lost since the key was created are dropped.
+func (s *APIKeysService) Authenticate(ctx context.Context, rawKey string) (auth.Claims, error) {
+	parts := strings.Split(strings.TrimPrefix(rawKey, APIKeyPrefix), "_")
+	if !IsAPIKey(rawKey) || len(parts) != 2 {
+		return auth.Claims{}, core.ErrAPIKeyInvalid
+	}
+
+	key, err := s.repo.GetByPrefix(ctx, parts[0])
+	if err != nil {
+		if errors.Is(err, core.ErrAPIKeyNotFound) {
+			return auth.Claims{}, core.ErrAPIKeyInvalid
+		}
+
+		return auth.Claims{}, err
+	}
+
+	keyHash, err := s.hasher.Hash(rawKey)
+	if err != nil {
+		return auth.Claims{}, err
+	}
+
+	if subtle.ConstantTimeCompare([]byte(keyHash), []byte(key.Hash)) != 1 || key.Expired(time.Now()) {
+		return auth.Claims{}, core.ErrAPIKeyInvalid
+	}
+
+	user, err := s.users.GetByID(ctx, key.UserID)
+	if err != nil {
+		if errors.Is(err, core.ErrUserNotFound) {
+			return auth.Claims{}, core.ErrAPIKeyInvalid
+		}
+
+		return auth.Claims{}, err
+	}
+
+	if err = s.repo.Touch(ctx, key.ID); err != nil {
+		logging.FromContext(ctx).Errorf("failed to record api key use: %v", err)
+	}
+
+	allowed := core.RoleScopes(user.Role)
+	scopes := make([]string, 0, len(key.Scopes))
+
+	for _, scope := range key.Scopes {
+		if contains(allowed, scope) {
+			scopes = append(scopes, scope)
+		}
+	}
+
+	claims := auth.Claims{
+		Subject:   user.ID.String(),
+		Roles:     []string{user.Role},
+		Scopes:    scopes,
+		TokenType: auth.TokenTypeAPIKey,
+	}
+	if key.ExpiresAt != nil {
+		claims.ExpiresAt = *key.ExpiresAt
+	}
+
+	return claims, nil
+}
+
+func randomHex(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(b), nil
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,86 @@
+package service
+
+import (
+	"context"
+	"errors"
+
+	"github.com/google/uuid"
+
+	"github.com/ernur-eskermes/crud-app/internal/core"
+	"github.com/ernur-eskermes/crud-app/pkg/auth"
+)
+
+// AuthenticationError is returned by Authenticate when the credentials are rejected,
+// as opposed to the errors of checking them. It wraps the reason, e.g. auth.ErrTokenExpired.
+type AuthenticationError struct {
+	Err error
+}
+
+func (e *AuthenticationError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *AuthenticationError) Unwrap() error {
+	return e.Err
+}
+
+// Authenticate checks the bearer credentials of a request, an API key or an access token, the same way
+// for every transport. The tokens issued to OAuth clients must belong to a grant that is not revoked
+// and act on behalf of a user, the tokens of clients acting on their own (client_credentials) are rejected.
+func (s *Services) Authenticate(ctx context.Context, token string) (auth.Claims, error) {
+	if IsAPIKey(token) {
+		claims, err := s.APIKeys.Authenticate(ctx, token)
+		if errors.Is(err, core.ErrAPIKeyInvalid) {
+			return auth.Claims{}, &AuthenticationError{Err: err}
+		}
+
+		return claims, err
+	}
+
+	claims, err := s.tokenManager.Parse(token)
+	if err != nil {
+		return auth.Claims{}, &AuthenticationError{Err: err}
+	}
+
+	// The subject of the tokens of client_credentials grants is the client.
+	if claims.ClientID != "" && claims.Subject == claims.ClientID {
+		return auth.Claims{}, &AuthenticationError{Err: core.ErrTokenNotUser}
+	}
+
+	if _, err = uuid.Parse(claims.Subject); err != nil {
+		return auth.Claims{}, &AuthenticationError{Err: auth.ErrTokenInvalidClaims}
+	}
+
+	if err = s.OAuth.CheckGrant(ctx, claims); err != nil {
+		if errors.Is(err, core.ErrGrantRevoked) {
+			return auth.Claims{}, &AuthenticationError{Err: err}
+		}
+
+		return auth.Claims{}, err
+	}
+
+	return claims, nil
+}
+
+// Authorize returns core.ErrInsufficientScope if the claims don't allow the scope.
+// Only delegated claims are limited by scopes, access tokens of user sessions are not.
+func Authorize(claims auth.Claims, scope string) error {
+	if Delegated(claims) && !claims.HasScope(scope) {
+		return core.ErrInsufficientScope
+	}
+
+	return nil
+}
+
+// Delegated reports whether the claims come from an API key or an OAuth client
+// rather than the user signing in.
+func Delegated(claims auth.Claims) bool {
+	return claims.TokenType == auth.TokenTypeAPIKey || claims.ClientID != ""
+}
+
+// UserID returns the user the claims returned by Authenticate act for.
+func UserID(claims auth.Claims) uuid.UUID {
+	id, _ := uuid.Parse(claims.Subject)
+
+	return id
+}
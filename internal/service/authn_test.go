@@ -0,0 +1,154 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/google/uuid"
+
+	"github.com/ernur-eskermes/crud-app/internal/core"
+	"github.com/ernur-eskermes/crud-app/pkg/auth"
+)
+
+type fakeTokenManager struct {
+	auth.TokenManager
+	tokens map[string]auth.Claims
+}
+
+func (m fakeTokenManager) Parse(token string) (auth.Claims, error) {
+	claims, ok := m.tokens[token]
+	if !ok {
+		return auth.Claims{}, auth.ErrTokenMalformed
+	}
+
+	return claims, nil
+}
+
+type fakeAPIKeys struct {
+	APIKeys
+	claims auth.Claims
+}
+
+func (k fakeAPIKeys) Authenticate(_ context.Context, rawKey string) (auth.Claims, error) {
+	if rawKey != APIKeyPrefix+"valid_secret" {
+		return auth.Claims{}, core.ErrAPIKeyInvalid
+	}
+
+	return k.claims, nil
+}
+
+type fakeOAuth struct {
+	OAuth
+	revoked map[string]bool
+}
+
+func (o fakeOAuth) CheckGrant(_ context.Context, claims auth.Claims) error {
+	if o.revoked[claims.SessionID] {
+		return core.ErrGrantRevoked
+	}
+
+	return nil
+}
+
+func TestServicesAuthenticate(t *testing.T) {
+	userID := uuid.NewString()
+	clientID := uuid.NewString()
+
+	session := auth.Claims{Subject: userID, TokenType: auth.TokenTypeAccess}
+	delegated := auth.Claims{Subject: userID, ClientID: clientID, SessionID: "grant", TokenType: auth.TokenTypeAccess}
+	revoked := auth.Claims{Subject: userID, ClientID: clientID, SessionID: "revoked", TokenType: auth.TokenTypeAccess}
+	client := auth.Claims{Subject: clientID, ClientID: clientID, SessionID: "grant", TokenType: auth.TokenTypeAccess}
+	apiKey := auth.Claims{Subject: userID, TokenType: auth.TokenTypeAPIKey, Scopes: []string{core.ScopeBooksRead}}
+
+	s := &Services{
+		APIKeys: fakeAPIKeys{claims: apiKey},
+		OAuth:   fakeOAuth{revoked: map[string]bool{"revoked": true}},
+		tokenManager: fakeTokenManager{tokens: map[string]auth.Claims{
+			"session":   session,
+			"delegated": delegated,
+			"revoked":   revoked,
+			"client":    client,
+			"subject":   {Subject: "not-a-uuid", TokenType: auth.TokenTypeAccess},
+		}},
+	}
+
+	tests := []struct {
+		name    string
+		token   string
+		want    auth.Claims
+		wantErr error
+	}{
+		{name: "session token", token: "session", want: session},
+		{name: "token of a client acting for a user", token: "delegated", want: delegated},
+		{name: "api key", token: APIKeyPrefix + "valid_secret", want: apiKey},
+		{name: "invalid api key", token: APIKeyPrefix + "other_secret", wantErr: core.ErrAPIKeyInvalid},
+		{name: "malformed token", token: "garbage", wantErr: auth.ErrTokenMalformed},
+		{name: "revoked grant", token: "revoked", wantErr: core.ErrGrantRevoked},
+		{name: "client credentials token", token: "client", wantErr: core.ErrTokenNotUser},
+		{name: "subject is not a user id", token: "subject", wantErr: auth.ErrTokenInvalidClaims},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			claims, err := s.Authenticate(context.Background(), tt.token)
+
+			if tt.wantErr != nil {
+				var authErr *AuthenticationError
+				if !errors.As(err, &authErr) || !errors.Is(err, tt.wantErr) {
+					t.Fatalf("Authenticate() error = %v, want authentication error %v", err, tt.wantErr)
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("Authenticate() error = %v", err)
+			}
+
+			if claims.Subject != tt.want.Subject || claims.ClientID != tt.want.ClientID {
+				t.Errorf("Authenticate() = %+v, want %+v", claims, tt.want)
+			}
+		})
+	}
+}
+
+func TestAuthorize(t *testing.T) {
+	tests := []struct {
+		name   string
+		claims auth.Claims
+		scope  string
+		want   error
+	}{
+		{
+			name:   "session tokens are not limited by scopes",
+			claims: auth.Claims{TokenType: auth.TokenTypeAccess},
+			scope:  core.ScopeBooksWrite,
+		},
+		{
+			name:   "api key with the scope",
+			claims: auth.Claims{TokenType: auth.TokenTypeAPIKey, Scopes: []string{core.ScopeBooksWrite}},
+			scope:  core.ScopeBooksWrite,
+		},
+		{
+			name:   "read only api key",
+			claims: auth.Claims{TokenType: auth.TokenTypeAPIKey, Scopes: []string{core.ScopeBooksRead}},
+			scope:  core.ScopeBooksWrite,
+			want:   core.ErrInsufficientScope,
+		},
+		{
+			name:   "read only oauth token",
+			claims: auth.Claims{TokenType: auth.TokenTypeAccess, ClientID: "client", Scopes: []string{core.ScopeBooksRead}},
+			scope:  core.ScopeBooksWrite,
+			want:   core.ErrInsufficientScope,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := Authorize(tt.claims, tt.scope); !errors.Is(err, tt.want) {
+				t.Errorf("Authorize() error = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
@@ -14,8 +14,10 @@ type BooksRepository interface {
 	Create(ctx context.Context, book core.Book) error
 	GetByID(ctx context.Context, id uuid.UUID) (core.Book, error)
 	GetAll(ctx context.Context) ([]core.Book, error)
+	Search(ctx context.Context, query core.SearchBooksQuery) ([]core.Book, error)
 	Delete(ctx context.Context, id, userID uuid.UUID) error
 	Update(ctx context.Context, inp core.Book) error
+	LastModified(ctx context.Context) (time.Time, error)
 }
 
 type BooksService struct {
@@ -51,10 +53,19 @@ func (b *BooksService) GetAll(ctx context.Context) ([]core.Book, error) {
 	return b.repo.GetAll(ctx)
 }
 
+func (b *BooksService) Search(ctx context.Context, query core.SearchBooksQuery) ([]core.Book, error) {
+	return b.repo.Search(ctx, query)
+}
+
 func (b *BooksService) Delete(ctx context.Context, id, userID uuid.UUID) error {
 	return b.repo.Delete(ctx, id, userID)
 }
 
+// LastModified returns when a book was last created, updated or deleted.
+func (b *BooksService) LastModified(ctx context.Context) (time.Time, error) {
+	return b.repo.LastModified(ctx)
+}
+
 func (b *BooksService) Update(ctx context.Context, id, userID uuid.UUID, inp core.UpdateBookInput) error {
 	return b.repo.Update(ctx, core.Book{
 		ID:          id,
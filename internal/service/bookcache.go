@@ -0,0 +1,178 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/ernur-eskermes/crud-app/internal/core"
+	"github.com/ernur-eskermes/crud-app/pkg/singleflight"
+)
+
+// CacheBackend stores cached values by key, e.g. lru.Cache. It must be safe for concurrent use
+// and bound the lifetime of the values, in case the writes of other instances aren't notified.
+type CacheBackend interface {
+	Get(key string) (interface{}, bool)
+	Set(key string, value interface{})
+	Delete(key string)
+}
+
+// booksCache is a read-through cache of a BooksRepository by book id and by list query.
+// Concurrent misses of a key are loaded once. Writes drop the cached book and all cached lists,
+// those of the other instances once their events are handled by HandleBookEvent.
+type booksCache struct {
+	BooksRepository
+
+	backend CacheBackend
+	loads   singleflight.Group
+	// mu orders the writes of loads to the backend with the invalidations.
+	mu sync.Mutex
+	// generation is part of the keys of the lists and is increased by every write, so the lists
+	// cached before are never read again. Loads started before a write aren't cached either.
+	generation uint64
+}
+
+func newBooksCache(repo BooksRepository, backend CacheBackend) *booksCache {
+	return &booksCache{
+		BooksRepository: repo,
+		backend:         backend,
+	}
+}
+
+func (c *booksCache) GetByID(ctx context.Context, id uuid.UUID) (core.Book, error) {
+	v, err := c.load("book:"+id.String(), func() (interface{}, error) {
+		return c.BooksRepository.GetByID(ctx, id)
+	})
+	if err != nil {
+		return core.Book{}, err
+	}
+
+	return v.(core.Book), nil //nolint:forcetypeassert
+}
+
+func (c *booksCache) GetAll(ctx context.Context) ([]core.Book, error) {
+	v, err := c.load(c.listKey("all"), func() (interface{}, error) {
+		return c.BooksRepository.GetAll(ctx)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return v.([]core.Book), nil //nolint:forcetypeassert
+}
+
+func (c *booksCache) Search(ctx context.Context, query core.SearchBooksQuery) ([]core.Book, error) {
+	author := ""
+	if query.Author != nil {
+		author = query.Author.String()
+	}
+
+	key := c.listKey(fmt.Sprintf("search:%q:%s:%d:%d", query.Title, author, query.Limit, query.Offset))
+
+	v, err := c.load(key, func() (interface{}, error) {
+		return c.BooksRepository.Search(ctx, query)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return v.([]core.Book), nil //nolint:forcetypeassert
+}
+
+func (c *booksCache) LastModified(ctx context.Context) (time.Time, error) {
+	v, err := c.load(c.listKey("modified"), func() (interface{}, error) {
+		return c.BooksRepository.LastModified(ctx)
+	})
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	return v.(time.Time), nil //nolint:forcetypeassert
+}
+
+func (c *booksCache) Create(ctx context.Context, book core.Book) error {
+	if err := c.BooksRepository.Create(ctx, book); err != nil {
+		return err
+	}
+
+	c.invalidate(uuid.Nil)
+
+	return nil
+}
+
+func (c *booksCache) Update(ctx context.Context, book core.Book) error {
+	if err := c.BooksRepository.Update(ctx, book); err != nil {
+		return err
+	}
+
+	c.invalidate(book.ID)
+
+	return nil
+}
+
+func (c *booksCache) Delete(ctx context.Context, id, userID uuid.UUID) error {
+	if err := c.BooksRepository.Delete(ctx, id, userID); err != nil {
+		return err
+	}
+
+	c.invalidate(id)
+
+	return nil
+}
+
+// HandleBookEvent drops what a book event changes, the writes of every instance included.
+func (c *booksCache) HandleBookEvent(_ context.Context, event core.Event) error {
+	c.invalidate(event.AggregateID)
+
+	return nil
+}
+
+// load returns the cached value of the key or loads it with fn, caching it unless it fails
+// or a write happens meanwhile. Errors are not cached.
+func (c *booksCache) load(key string, fn func() (interface{}, error)) (interface{}, error) {
+	if v, ok := c.backend.Get(key); ok {
+		bookCacheRequestsTotal.WithLabelValues("hit").Inc()
+
+		return v, nil
+	}
+
+	bookCacheRequestsTotal.WithLabelValues("miss").Inc()
+
+	// A load started before a write is not shared with the reads after it.
+	generation := atomic.LoadUint64(&c.generation)
+
+	v, err, _ := c.loads.Do(fmt.Sprintf("%d:%s", generation, key), func() (interface{}, error) {
+		v, err := fn()
+		if err == nil {
+			c.mu.Lock()
+			if atomic.LoadUint64(&c.generation) == generation {
+				c.backend.Set(key, v)
+			}
+			c.mu.Unlock()
+		}
+
+		return v, err
+	})
+
+	return v, err
+}
+
+func (c *booksCache) listKey(query string) string {
+	return fmt.Sprintf("books:%d:%s", atomic.LoadUint64(&c.generation), query)
+}
+
+// invalidate drops the cached book with the id, if any, and all cached lists.
+func (c *booksCache) invalidate(id uuid.UUID) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	atomic.AddUint64(&c.generation, 1)
+
+	if id != uuid.Nil {
+		c.backend.Delete("book:" + id.String())
+	}
+}
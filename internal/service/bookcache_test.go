@@ -0,0 +1,221 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/ernur-eskermes/crud-app/internal/core"
+	"github.com/ernur-eskermes/crud-app/pkg/lru"
+)
+
+// fakeBooksRepo serves one book and counts the reads reaching it.
+type fakeBooksRepo struct {
+	BooksRepository
+
+	book  core.Book
+	err   error
+	reads int
+	// duringRead runs in the middle of a read, e.g. to write concurrently.
+	duringRead func()
+}
+
+func (r *fakeBooksRepo) GetByID(_ context.Context, id uuid.UUID) (core.Book, error) {
+	r.reads++
+
+	if r.duringRead != nil {
+		r.duringRead()
+	}
+
+	if r.err != nil {
+		return core.Book{}, r.err
+	}
+
+	if id != r.book.ID {
+		return core.Book{}, core.ErrBookNotFound
+	}
+
+	return r.book, nil
+}
+
+func (r *fakeBooksRepo) GetAll(context.Context) ([]core.Book, error) {
+	r.reads++
+
+	return []core.Book{r.book}, nil
+}
+
+func (r *fakeBooksRepo) Update(_ context.Context, book core.Book) error {
+	r.book = book
+
+	return nil
+}
+
+func TestBooksCacheLoad(t *testing.T) {
+	book := core.Book{ID: uuid.New(), Title: "Dune"}
+	errDB := errors.New("connection reset")
+
+	tests := []struct {
+		name string
+		// prepare runs against the cache before the reads, it may change the repo.
+		prepare   func(c *booksCache, repo *fakeBooksRepo)
+		reads     int
+		wantReads int
+		wantErr   error
+	}{
+		{
+			name:      "miss then hits",
+			reads:     3,
+			wantReads: 1,
+		},
+		{
+			name: "errors are not cached",
+			prepare: func(_ *booksCache, repo *fakeBooksRepo) {
+				repo.err = errDB
+			},
+			reads:     2,
+			wantReads: 2,
+			wantErr:   errDB,
+		},
+		{
+			name: "not found is not cached",
+			prepare: func(_ *booksCache, repo *fakeBooksRepo) {
+				repo.book.ID = uuid.New()
+			},
+			reads:     2,
+			wantReads: 2,
+			wantErr:   core.ErrBookNotFound,
+		},
+		{
+			name: "a load overtaken by a write is not cached",
+			prepare: func(c *booksCache, repo *fakeBooksRepo) {
+				repo.duringRead = func() {
+					repo.duringRead = nil
+					c.invalidate(book.ID)
+				}
+			},
+			reads:     3,
+			wantReads: 2,
+		},
+		{
+			name: "the events of other instances drop the book",
+			prepare: func(c *booksCache, _ *fakeBooksRepo) {
+				if _, err := c.GetByID(context.Background(), book.ID); err != nil {
+					t.Fatal(err)
+				}
+
+				if err := c.HandleBookEvent(context.Background(), core.Event{AggregateID: book.ID}); err != nil {
+					t.Fatal(err)
+				}
+			},
+			reads:     2,
+			wantReads: 2,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := &fakeBooksRepo{book: book}
+			c := newBooksCache(repo, lru.New(10, time.Minute))
+
+			if tt.prepare != nil {
+				tt.prepare(c, repo)
+			}
+
+			for i := 0; i < tt.reads; i++ {
+				got, err := c.GetByID(context.Background(), book.ID)
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("GetByID() error = %v, want %v", err, tt.wantErr)
+				}
+
+				if err == nil && got != book {
+					t.Fatalf("GetByID() = %+v, want %+v", got, book)
+				}
+			}
+
+			if repo.reads != tt.wantReads {
+				t.Errorf("repository reads = %d, want %d", repo.reads, tt.wantReads)
+			}
+		})
+	}
+}
+
+// racingBackend runs a write once a load is about to be cached.
+type racingBackend struct {
+	CacheBackend
+	write func()
+}
+
+func (b *racingBackend) Set(key string, value interface{}) {
+	if b.write != nil {
+		write := b.write
+		b.write = nil
+
+		write()
+	}
+
+	b.CacheBackend.Set(key, value)
+}
+
+func TestBooksCacheLoadRacingWrite(t *testing.T) {
+	book := core.Book{ID: uuid.New(), Title: "Dune"}
+	repo := &fakeBooksRepo{book: book}
+	backend := &racingBackend{CacheBackend: lru.New(10, time.Minute)}
+	c := newBooksCache(repo, backend)
+	ctx := context.Background()
+
+	written := make(chan struct{})
+	backend.write = func() {
+		go func() {
+			defer close(written)
+
+			c.invalidate(book.ID)
+		}()
+
+		// Give the write the time to overtake the load if nothing orders them.
+		time.Sleep(20 * time.Millisecond)
+	}
+
+	if _, err := c.GetByID(ctx, book.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	<-written
+
+	if _, err := c.GetByID(ctx, book.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	if repo.reads != 2 {
+		t.Errorf("repository reads = %d, want 2: the book loaded before the write was served from the cache", repo.reads)
+	}
+}
+
+func TestBooksCacheWriteDropsLists(t *testing.T) {
+	book := core.Book{ID: uuid.New(), Title: "Dune"}
+	repo := &fakeBooksRepo{book: book}
+	c := newBooksCache(repo, lru.New(10, time.Minute))
+	ctx := context.Background()
+
+	if _, err := c.GetAll(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	updated := book
+	updated.Title = "Dune Messiah"
+
+	if err := c.Update(ctx, updated); err != nil {
+		t.Fatal(err)
+	}
+
+	books, err := c.GetAll(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(books) != 1 || books[0].Title != updated.Title {
+		t.Errorf("GetAll() after Update = %+v, want %+v", books, []core.Book{updated})
+	}
+}
@@ -0,0 +1,235 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/ernur-eskermes/crud-app/internal/core"
+	"github.com/ernur-eskermes/crud-app/pkg/logging"
+	"github.com/ernur-eskermes/crud-app/pkg/repeatable"
+)
+
+// maxDispatchDelay caps the delay between the attempts to publish an event that keeps failing.
+const maxDispatchDelay = time.Minute
+
+// Publisher delivers the events of the outbox, to the in-process subscribers or to a message broker.
+// Delivery is at least once: an event is published again when it or a publisher after it fails,
+// so Publish must tolerate duplicates, e.g. by the event id.
+type Publisher interface {
+	Publish(ctx context.Context, event core.Event) error
+}
+
+type EventHandler func(ctx context.Context, event core.Event) error
+
+// EventBus publishes events to the in-process handlers subscribed to their type.
+type EventBus struct {
+	mu       sync.RWMutex
+	handlers map[string][]EventHandler
+}
+
+func NewEventBus() *EventBus {
+	return &EventBus{
+		handlers: make(map[string][]EventHandler),
+	}
+}
+
+// Subscribe registers handler for the events of the types.
+func (b *EventBus) Subscribe(handler EventHandler, eventTypes ...string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for _, eventType := range eventTypes {
+		b.handlers[eventType] = append(b.handlers[eventType], handler)
+	}
+}
+
+// Publish passes the event to every handler subscribed to it and fails if any of them fails.
+func (b *EventBus) Publish(ctx context.Context, event core.Event) error {
+	b.mu.RLock()
+	handlers := b.handlers[event.Type]
+	b.mu.RUnlock()
+
+	var firstErr error
+
+	for _, handler := range handlers {
+		if err := handler(ctx, event); err != nil && firstErr == nil {
+			firstErr = fmt.Errorf("handle %s event %d: %w", event.Type, event.ID, err)
+		}
+	}
+
+	return firstErr
+}
+
+type OutboxRepository interface {
+	Dispatch(ctx context.Context, limit int, publish func(ctx context.Context, event core.Event) error) (int, error)
+	Purge(ctx context.Context, before time.Time) (int64, error)
+}
+
+// EventsFeed passes the events of the outbox to every instance, see postgres.OutboxFeed.
+type EventsFeed interface {
+	Listen(ctx context.Context, types []string, recent int, handle func(ctx context.Context, event core.Event) error) error
+}
+
+// EventBroadcaster passes the events to the handlers of every instance, e.g. to update what the instance
+// keeps in memory, while EventDispatcher passes each event to one instance only. The handlers get the
+// latest recent events again whenever the feed reconnects, so they must tolerate duplicates.
+type EventBroadcaster struct {
+	feed   EventsFeed
+	recent int
+
+	bus   *EventBus
+	mu    sync.Mutex
+	types []string
+}
+
+func NewEventBroadcaster(feed EventsFeed, recent int) *EventBroadcaster {
+	return &EventBroadcaster{
+		feed:   feed,
+		recent: recent,
+		bus:    NewEventBus(),
+	}
+}
+
+// Subscribe registers handler for the events of the types, before Run.
+func (b *EventBroadcaster) Subscribe(handler EventHandler, eventTypes ...string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for _, eventType := range eventTypes {
+		if _, ok := b.bus.handlers[eventType]; !ok {
+			b.types = append(b.types, eventType)
+		}
+	}
+
+	b.bus.Subscribe(handler, eventTypes...)
+}
+
+// Run listens to the feed until ctx is done, reconnecting after a failure up to a maxDispatchDelay later.
+func (b *EventBroadcaster) Run(ctx context.Context) error {
+	b.mu.Lock()
+	types := b.types
+	b.mu.Unlock()
+
+	if len(types) == 0 {
+		return nil
+	}
+
+	failures := 0
+
+	for {
+		started := time.Now()
+
+		err := b.feed.Listen(ctx, types, b.recent, b.handle)
+		if ctx.Err() != nil {
+			return nil
+		}
+
+		if time.Since(started) > maxDispatchDelay {
+			failures = 0
+		}
+
+		failures++
+		logging.FromContext(ctx).Errorf("failed to listen to events: %v", err)
+
+		timer := time.NewTimer(repeatable.Backoff(time.Second, maxDispatchDelay, failures))
+
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+
+			return nil
+		case <-timer.C:
+		}
+	}
+}
+
+// handle logs the failures of the handlers, the event isn't passed again.
+func (b *EventBroadcaster) handle(ctx context.Context, event core.Event) error {
+	if err := b.bus.Publish(ctx, event); err != nil {
+		logging.FromContext(ctx).Errorf("failed to handle broadcast event: %v", err)
+	}
+
+	return nil
+}
+
+// EventsPolicy sets how often the outbox is polled and how many events are published at once.
+type EventsPolicy struct {
+	PollInterval time.Duration
+	BatchSize    int
+}
+
+// EventDispatcher publishes the events recorded in the outbox, in order, to the publishers.
+type EventDispatcher struct {
+	repo       OutboxRepository
+	publishers []Publisher
+	policy     EventsPolicy
+}
+
+func NewEventDispatcher(repo OutboxRepository, policy EventsPolicy, publishers ...Publisher) *EventDispatcher {
+	return &EventDispatcher{
+		repo:       repo,
+		publishers: publishers,
+		policy:     policy,
+	}
+}
+
+// Run publishes the events of the outbox until ctx is done. While publishing fails
+// the outbox is polled less often, up to once a maxDispatchDelay.
+func (d *EventDispatcher) Run(ctx context.Context) error {
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+
+	failures := 0
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-timer.C:
+		}
+
+		if err := d.dispatch(ctx); err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+
+			failures++
+			logging.FromContext(ctx).Errorf("failed to publish events: %v", err)
+			timer.Reset(repeatable.Backoff(d.policy.PollInterval, maxDispatchDelay, failures))
+
+			continue
+		}
+
+		failures = 0
+		timer.Reset(d.policy.PollInterval)
+	}
+}
+
+// Purge is the handler of core.JobPurgeOutbox.
+func (d *EventDispatcher) Purge(ctx context.Context, job core.Job) error {
+	return purge(ctx, job, d.repo.Purge)
+}
+
+// dispatch publishes the pending events, batch after batch.
+func (d *EventDispatcher) dispatch(ctx context.Context) error {
+	for {
+		published, err := d.repo.Dispatch(ctx, d.policy.BatchSize, d.publish)
+		if err != nil || published < d.policy.BatchSize {
+			return err
+		}
+	}
+}
+
+func (d *EventDispatcher) publish(ctx context.Context, event core.Event) error {
+	for _, publisher := range d.publishers {
+		if err := publisher.Publish(ctx, event); err != nil {
+			return err
+		}
+	}
+
+	eventsPublishedTotal.WithLabelValues(event.Type).Inc()
+
+	return nil
+}
@@ -0,0 +1,421 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/ernur-eskermes/crud-app/internal/core"
+	"github.com/ernur-eskermes/crud-app/pkg/cron"
+	"github.com/ernur-eskermes/crud-app/pkg/logging"
+	"github.com/ernur-eskermes/crud-app/pkg/repeatable"
+)
+
+// jobSaveTimeout bounds recording the outcome of a job, which is done even while shutting down.
+const jobSaveTimeout = 5 * time.Second
+
+type JobsRepository interface {
+	Create(ctx context.Context, job *core.Job) (bool, error)
+	Claim(ctx context.Context, types []string, limit int, lease time.Duration) ([]core.Job, error)
+	Finish(ctx context.Context, job core.Job) error
+	Release(ctx context.Context, job core.Job) error
+	Get(ctx context.Context, id uuid.UUID) (core.Job, error)
+	GetAll(ctx context.Context, query core.JobsQuery) ([]core.Job, error)
+	Retry(ctx context.Context, id uuid.UUID) (core.Job, error)
+	Purge(ctx context.Context, before time.Time) (int64, error)
+}
+
+// JobHandler runs a job, decoding its payload with job.Decode. A returned error fails the attempt.
+// Handlers must stop when ctx is done, the job is then run again by the next worker.
+type JobHandler func(ctx context.Context, job core.Job) error
+
+// JobOptions override the JobsPolicy defaults for a type of jobs.
+type JobOptions struct {
+	MaxAttempts int
+	Timeout     time.Duration
+}
+
+// JobsPolicy sets how jobs are run: Concurrency jobs at once, polled every PollInterval, each attempt
+// limited to Timeout. A failed job is retried up to MaxAttempts times, RetryDelay after the first failure
+// doubling up to MaxRetryDelay, and is dead afterwards.
+type JobsPolicy struct {
+	Concurrency   int
+	PollInterval  time.Duration
+	Timeout       time.Duration
+	MaxAttempts   int
+	RetryDelay    time.Duration
+	MaxRetryDelay time.Duration
+}
+
+type registeredJob struct {
+	handler JobHandler
+	options JobOptions
+}
+
+type jobSchedule struct {
+	name     string
+	schedule cron.Schedule
+	job      core.Job
+}
+
+// JobsService runs the jobs queued in Postgres by the handlers registered for their types.
+// Every instance runs the jobs of the types it has handlers for, each job by one instance at a time.
+type JobsService struct {
+	repo   JobsRepository
+	policy JobsPolicy
+
+	mu        sync.RWMutex
+	types     map[string]registeredJob
+	schedules []jobSchedule
+}
+
+func NewJobsService(repo JobsRepository, policy JobsPolicy) *JobsService {
+	return &JobsService{
+		repo:   repo,
+		policy: policy,
+		types:  make(map[string]registeredJob),
+	}
+}
+
+// Register sets the handler of the jobs of the type. It must be called before Run.
+func (s *JobsService) Register(jobType string, handler JobHandler, options JobOptions) {
+	if options.MaxAttempts == 0 {
+		options.MaxAttempts = s.policy.MaxAttempts
+	}
+
+	if options.Timeout == 0 {
+		options.Timeout = s.policy.Timeout
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.types[jobType] = registeredJob{handler: handler, options: options}
+}
+
+// Schedule queues a job of the type with the payload at every time of the cron spec, once across all instances.
+// It must be called before Run.
+func (s *JobsService) Schedule(name, spec, jobType string, payload interface{}) error {
+	schedule, err := cron.Parse(spec)
+	if err != nil {
+		return err
+	}
+
+	raw, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.schedules = append(s.schedules, jobSchedule{
+		name:     name,
+		schedule: schedule,
+		job:      core.Job{Type: jobType, Payload: raw},
+	})
+
+	return nil
+}
+
+// Enqueue queues a job of the type with the payload, to run at runAt or as soon as possible when it is zero.
+func (s *JobsService) Enqueue(ctx context.Context, jobType string, payload interface{}, runAt time.Time) (core.Job, error) {
+	raw, err := json.Marshal(payload)
+	if err != nil {
+		return core.Job{}, err
+	}
+
+	job := core.Job{Type: jobType, Payload: raw, RunAt: runAt}
+	if _, err = s.create(ctx, &job); err != nil {
+		return core.Job{}, err
+	}
+
+	return job, nil
+}
+
+func (s *JobsService) create(ctx context.Context, job *core.Job) (bool, error) {
+	job.MaxAttempts = s.policy.MaxAttempts
+	if t, ok := s.registered(job.Type); ok {
+		job.MaxAttempts = t.options.MaxAttempts
+	}
+
+	if job.RunAt.IsZero() {
+		job.RunAt = time.Now()
+	}
+
+	return s.repo.Create(ctx, job)
+}
+
+func (s *JobsService) Get(ctx context.Context, id uuid.UUID) (core.Job, error) {
+	return s.repo.Get(ctx, id)
+}
+
+func (s *JobsService) List(ctx context.Context, query core.JobsQuery) ([]core.Job, error) {
+	return s.repo.GetAll(ctx, query)
+}
+
+// Retry queues a dead job again.
+func (s *JobsService) Retry(ctx context.Context, id uuid.UUID) (core.Job, error) {
+	if _, err := s.repo.Get(ctx, id); err != nil {
+		return core.Job{}, err
+	}
+
+	return s.repo.Retry(ctx, id)
+}
+
+// Purge is the handler of core.JobPurgeJobs.
+func (s *JobsService) Purge(ctx context.Context, job core.Job) error {
+	return purge(ctx, job, s.repo.Purge)
+}
+
+// Run runs the due jobs and queues the scheduled ones until ctx is done. The jobs running
+// meanwhile are interrupted and queued again before it returns.
+func (s *JobsService) Run(ctx context.Context) error {
+	s.mu.RLock()
+	types := make([]string, 0, len(s.types))
+
+	for t := range s.types {
+		types = append(types, t)
+	}
+	s.mu.RUnlock()
+
+	if len(types) == 0 {
+		<-ctx.Done()
+
+		return nil
+	}
+
+	var wg sync.WaitGroup
+
+	wg.Add(1)
+
+	go func() {
+		defer wg.Done()
+
+		s.runSchedules(ctx)
+	}()
+
+	slots := make(chan struct{}, s.policy.Concurrency)
+
+	ticker := time.NewTicker(s.policy.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		s.poll(ctx, types, slots, &wg)
+
+		select {
+		case <-ctx.Done():
+			wg.Wait()
+
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// poll claims as many due jobs as there are free slots and runs them.
+func (s *JobsService) poll(ctx context.Context, types []string, slots chan struct{}, wg *sync.WaitGroup) {
+	free := cap(slots) - len(slots)
+	if free == 0 {
+		return
+	}
+
+	// A claimed job is not run by anyone else before its attempt times out.
+	jobs, err := s.repo.Claim(ctx, types, free, 2*s.maxTimeout())
+	if err != nil {
+		if ctx.Err() == nil {
+			logging.FromContext(ctx).Errorf("failed to claim jobs: %v", err)
+		}
+
+		return
+	}
+
+	for _, job := range jobs {
+		slots <- struct{}{}
+
+		wg.Add(1)
+
+		go func(job core.Job) {
+			defer func() {
+				<-slots
+				wg.Done()
+			}()
+
+			s.run(ctx, job)
+		}(job)
+	}
+}
+
+// run runs one attempt of the job and records the outcome, scheduling the next attempt on failure.
+func (s *JobsService) run(ctx context.Context, job core.Job) {
+	logger := logging.FromContext(ctx).WithFields(map[string]interface{}{
+		"job_id":   job.ID,
+		"job_type": job.Type,
+	})
+
+	t, _ := s.registered(job.Type)
+
+	var err error
+
+	// A job claimed again after its lease expired, e.g. because its worker died, has used its attempts already.
+	if job.Attempts > job.MaxAttempts {
+		err = fmt.Errorf("the last attempt did not finish within %s", 2*s.maxTimeout())
+	} else {
+		err = s.handle(ctx, t, job)
+	}
+
+	saveCtx, cancel := context.WithTimeout(context.Background(), jobSaveTimeout)
+	defer cancel()
+
+	if err != nil && ctx.Err() != nil {
+		// Interrupted by the shutdown, not a failure of the job.
+		if err = s.repo.Release(saveCtx, job); err != nil {
+			logger.Errorf("failed to release job: %v", err)
+		}
+
+		return
+	}
+
+	job.RunAt = time.Now()
+	job.LastError = ""
+
+	switch {
+	case err == nil:
+		job.Status = core.JobSucceeded
+	case job.Attempts >= job.MaxAttempts:
+		job.Status = core.JobDead
+		job.LastError = err.Error()
+
+		logger.Errorf("job is dead after %d attempts: %v", job.Attempts, err)
+	default:
+		job.Status = core.JobQueued
+		job.LastError = err.Error()
+		job.RunAt = job.RunAt.Add(repeatable.Backoff(s.policy.RetryDelay, s.policy.MaxRetryDelay, job.Attempts))
+	}
+
+	jobsProcessedTotal.WithLabelValues(job.Type, job.Status).Inc()
+
+	if err = s.repo.Finish(saveCtx, job); err != nil {
+		logger.Errorf("failed to save job outcome: %v", err)
+	}
+}
+
+// handle calls the handler of the job, turning a panic into an error.
+func (s *JobsService) handle(ctx context.Context, t registeredJob, job core.Job) (err error) {
+	ctx, cancel := context.WithTimeout(ctx, t.options.Timeout)
+	defer cancel()
+
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+
+	return t.handler(ctx, job)
+}
+
+// runSchedules queues the scheduled jobs at their times until ctx is done. The instances all try
+// to queue a run, the key of the run lets only one of them succeed.
+func (s *JobsService) runSchedules(ctx context.Context) {
+	s.mu.RLock()
+	schedules := s.schedules
+	s.mu.RUnlock()
+
+	if len(schedules) == 0 {
+		return
+	}
+
+	next := make([]time.Time, len(schedules))
+
+	for i, sch := range schedules {
+		next[i] = sch.schedule.Next(time.Now())
+	}
+
+	for {
+		soonest := -1
+
+		for i, at := range next {
+			if !at.IsZero() && (soonest < 0 || at.Before(next[soonest])) {
+				soonest = i
+			}
+		}
+
+		if soonest < 0 {
+			return
+		}
+
+		timer := time.NewTimer(time.Until(next[soonest]))
+
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+
+			return
+		case <-timer.C:
+		}
+
+		sch := schedules[soonest]
+		key := fmt.Sprintf("schedule:%s:%d", sch.name, next[soonest].Unix())
+
+		job := sch.job
+		job.Key = &key
+		job.RunAt = next[soonest]
+
+		if _, err := s.create(ctx, &job); err != nil && ctx.Err() == nil {
+			logging.FromContext(ctx).Errorf("failed to queue scheduled job %s: %v", sch.name, err)
+		}
+
+		next[soonest] = sch.schedule.Next(next[soonest])
+	}
+}
+
+func (s *JobsService) registered(name string) (registeredJob, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	t, ok := s.types[name]
+
+	return t, ok
+}
+
+// maxTimeout returns the longest timeout of the registered types.
+func (s *JobsService) maxTimeout() time.Duration {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	timeout := s.policy.Timeout
+
+	for _, t := range s.types {
+		if t.options.Timeout > timeout {
+			timeout = t.options.Timeout
+		}
+	}
+
+	return timeout
+}
+
+// purge deletes with del the records finished longer than the retention of the payload of the purge job ago.
+func purge(ctx context.Context, job core.Job, del func(ctx context.Context, before time.Time) (int64, error)) error {
+	var payload core.PurgeJobPayload
+	if err := job.Decode(&payload); err != nil {
+		return err
+	}
+
+	retention, err := time.ParseDuration(payload.Retention)
+	if err != nil {
+		return err
+	}
+
+	deleted, err := del(ctx, time.Now().Add(-retention))
+	if err != nil {
+		return err
+	}
+
+	logging.FromContext(ctx).Infof("%s deleted %d records", job.Type, deleted)
+
+	return nil
+}
@@ -0,0 +1,195 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/ernur-eskermes/crud-app/internal/core"
+)
+
+// fakeJobsRepo records the outcome of the attempts.
+type fakeJobsRepo struct {
+	JobsRepository
+
+	finished []core.Job
+	released []core.Job
+}
+
+func (r *fakeJobsRepo) Finish(_ context.Context, job core.Job) error {
+	r.finished = append(r.finished, job)
+
+	return nil
+}
+
+func (r *fakeJobsRepo) Release(_ context.Context, job core.Job) error {
+	r.released = append(r.released, job)
+
+	return nil
+}
+
+func TestJobsServiceRun(t *testing.T) {
+	policy := JobsPolicy{
+		Timeout:       time.Second,
+		MaxAttempts:   3,
+		RetryDelay:    time.Minute,
+		MaxRetryDelay: time.Hour,
+	}
+
+	errFailed := errors.New("failed")
+
+	tests := []struct {
+		name string
+		// attempts is the attempt being run, counted by the claim.
+		attempts int
+		handler  JobHandler
+		// cancel interrupts the attempt as a shutdown does.
+		cancel bool
+
+		wantStatus   string
+		wantError    string
+		wantDelay    time.Duration
+		wantReleased bool
+		wantCalled   bool
+	}{
+		{
+			name:       "succeeded",
+			attempts:   1,
+			handler:    func(context.Context, core.Job) error { return nil },
+			wantStatus: core.JobSucceeded,
+			wantCalled: true,
+		},
+		{
+			name:       "first failure is retried after the retry delay",
+			attempts:   1,
+			handler:    func(context.Context, core.Job) error { return errFailed },
+			wantStatus: core.JobQueued,
+			wantError:  "failed",
+			wantDelay:  time.Minute,
+			wantCalled: true,
+		},
+		{
+			name:       "retry delay doubles",
+			attempts:   2,
+			handler:    func(context.Context, core.Job) error { return errFailed },
+			wantStatus: core.JobQueued,
+			wantError:  "failed",
+			wantDelay:  2 * time.Minute,
+			wantCalled: true,
+		},
+		{
+			name:       "dead after the last attempt",
+			attempts:   3,
+			handler:    func(context.Context, core.Job) error { return errFailed },
+			wantStatus: core.JobDead,
+			wantError:  "failed",
+			wantCalled: true,
+		},
+		{
+			name:       "the last attempt outlived its lease",
+			attempts:   4,
+			handler:    func(context.Context, core.Job) error { return nil },
+			wantStatus: core.JobDead,
+			wantError:  "the last attempt did not finish",
+		},
+		{
+			name:       "panic fails the attempt",
+			attempts:   1,
+			handler:    func(context.Context, core.Job) error { panic("boom") },
+			wantStatus: core.JobQueued,
+			wantError:  "panic: boom",
+			wantDelay:  time.Minute,
+			wantCalled: true,
+		},
+		{
+			name:     "timeout fails the attempt",
+			attempts: 1,
+			handler: func(ctx context.Context, _ core.Job) error {
+				<-ctx.Done()
+
+				return ctx.Err()
+			},
+			wantStatus: core.JobQueued,
+			wantError:  context.DeadlineExceeded.Error(),
+			wantDelay:  time.Minute,
+			wantCalled: true,
+		},
+		{
+			name:     "interrupted by the shutdown",
+			attempts: 1,
+			handler: func(ctx context.Context, _ core.Job) error {
+				<-ctx.Done()
+
+				return ctx.Err()
+			},
+			cancel:       true,
+			wantReleased: true,
+			wantCalled:   true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := &fakeJobsRepo{}
+			s := NewJobsService(repo, policy)
+
+			called := false
+
+			s.Register("test", func(ctx context.Context, job core.Job) error {
+				called = true
+
+				return tt.handler(ctx, job)
+			}, JobOptions{Timeout: 50 * time.Millisecond})
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			if tt.cancel {
+				time.AfterFunc(10*time.Millisecond, cancel)
+			}
+
+			job := core.Job{ID: uuid.New(), Type: "test", Attempts: tt.attempts, MaxAttempts: policy.MaxAttempts}
+			start := time.Now()
+
+			s.run(ctx, job)
+
+			if called != tt.wantCalled {
+				t.Errorf("handler called = %v, want %v", called, tt.wantCalled)
+			}
+
+			if tt.wantReleased {
+				if len(repo.released) != 1 || len(repo.finished) != 0 {
+					t.Fatalf("released %d, finished %d jobs, want the job released", len(repo.released), len(repo.finished))
+				}
+
+				return
+			}
+
+			if len(repo.finished) != 1 || len(repo.released) != 0 {
+				t.Fatalf("finished %d, released %d jobs, want the job finished", len(repo.finished), len(repo.released))
+			}
+
+			got := repo.finished[0]
+
+			if got.Status != tt.wantStatus {
+				t.Errorf("status = %s, want %s", got.Status, tt.wantStatus)
+			}
+
+			if !strings.Contains(got.LastError, tt.wantError) || (tt.wantError == "") != (got.LastError == "") {
+				t.Errorf("last error = %q, want %q", got.LastError, tt.wantError)
+			}
+
+			if got.Attempts != tt.attempts {
+				t.Errorf("attempts = %d, want %d", got.Attempts, tt.attempts)
+			}
+
+			if delay := got.RunAt.Sub(start); delay < tt.wantDelay || delay > tt.wantDelay+time.Second {
+				t.Errorf("next attempt in %s, want %s", delay, tt.wantDelay)
+			}
+		})
+	}
+}
@@ -0,0 +1,171 @@
+package service
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/ernur-eskermes/crud-app/internal/core"
+	"github.com/ernur-eskermes/crud-app/pkg/logging"
+)
+
+const (
+	accountKeyPrefix = "account:"
+	ipKeyPrefix      = "ip:"
+)
+
+type AttemptsRepository interface {
+	Get(ctx context.Context, key string) (core.Attempts, error)
+	Fail(ctx context.Context, key string, now, forgetBefore time.Time) (int, error)
+	Lock(ctx context.Context, key string, until time.Time) error
+	Reset(ctx context.Context, key string) error
+}
+
+// LockoutNotifier is told when an account gets locked out.
+type LockoutNotifier interface {
+	AccountLocked(ctx context.Context, username string, until time.Time)
+}
+
+// LockoutPolicy limits failed sign in and verification attempts.
+// Every failure delays the next attempt by Backoff doubled with each consecutive failure,
+// after MaxFailures for an account or MaxIPFailures for a client address it is locked for Duration.
+// Failures are forgotten after Window without a new one.
+type LockoutPolicy struct {
+	MaxFailures   int
+	MaxIPFailures int
+	Backoff       time.Duration
+	Duration      time.Duration
+	Window        time.Duration
+}
+
+// Lockout tracks failed attempts per account and per client address.
+type Lockout struct {
+	repo     AttemptsRepository
+	policy   LockoutPolicy
+	notifier LockoutNotifier
+	now      func() time.Time
+}
+
+func NewLockout(repo AttemptsRepository, policy LockoutPolicy, notifier LockoutNotifier) *Lockout {
+	return &Lockout{
+		repo:     repo,
+		policy:   policy,
+		notifier: notifier,
+		now:      time.Now,
+	}
+}
+
+// Check returns a *core.TooManyAttemptsError if the account or the client address is locked.
+func (l *Lockout) Check(ctx context.Context, username string) error {
+	now := l.now()
+
+	var lockedUntil time.Time
+
+	for _, key := range l.keys(ctx, username) {
+		attempts, err := l.repo.Get(ctx, key.key)
+		if err != nil {
+			return err
+		}
+
+		if attempts.LockedUntil.After(lockedUntil) {
+			lockedUntil = attempts.LockedUntil
+		}
+	}
+
+	if lockedUntil.After(now) {
+		return &core.TooManyAttemptsError{RetryAfter: lockedUntil.Sub(now)}
+	}
+
+	return nil
+}
+
+// Fail counts a failed attempt and locks the account and the client address according to the policy.
+func (l *Lockout) Fail(ctx context.Context, username string) error {
+	now := l.now()
+
+	for _, key := range l.keys(ctx, username) {
+		failures, err := l.repo.Fail(ctx, key.key, now, now.Add(-l.policy.Window))
+		if err != nil {
+			return err
+		}
+
+		until := now.Add(l.delay(failures, key.maxFailures))
+		if err = l.repo.Lock(ctx, key.key, until); err != nil {
+			return err
+		}
+
+		if failures != key.maxFailures {
+			continue
+		}
+
+		if key.account {
+			l.notifier.AccountLocked(ctx, username, until)
+		} else {
+			logging.FromContext(ctx).Warnf("client %s is locked out until %s after repeated failed attempts",
+				strings.TrimPrefix(key.key, ipKeyPrefix), until.Format(time.RFC3339))
+		}
+	}
+
+	return nil
+}
+
+// Succeed resets the failures of the account. The client address counter is kept,
+// so signing in to an own account does not reset guessing others.
+func (l *Lockout) Succeed(ctx context.Context, username string) error {
+	return l.repo.Reset(ctx, accountKeyPrefix+username)
+}
+
+// Unlock resets the failures of the account.
+func (l *Lockout) Unlock(ctx context.Context, username string) error {
+	return l.repo.Reset(ctx, accountKeyPrefix+username)
+}
+
+func (l *Lockout) delay(failures, maxFailures int) time.Duration {
+	if failures >= maxFailures {
+		return l.policy.Duration
+	}
+
+	delay := l.policy.Backoff << (failures - 1)
+	if delay <= 0 || delay > l.policy.Duration {
+		return l.policy.Duration
+	}
+
+	return delay
+}
+
+type lockoutKey struct {
+	key         string
+	maxFailures int
+	account     bool
+}
+
+func (l *Lockout) keys(ctx context.Context, username string) []lockoutKey {
+	keys := []lockoutKey{{key: accountKeyPrefix + username, maxFailures: l.policy.MaxFailures, account: true}}
+
+	if ip := clientIP(ctx); ip != "" {
+		keys = append(keys, lockoutKey{key: ipKeyPrefix + ip, maxFailures: l.policy.MaxIPFailures})
+	}
+
+	return keys
+}
+
+// logNotifier reports lockouts to the log.
+type logNotifier struct{}
+
+func (logNotifier) AccountLocked(ctx context.Context, username string, until time.Time) {
+	logging.FromContext(ctx).Warnf("account %s is locked out until %s after repeated failed attempts",
+		username, until.Format(time.RFC3339))
+}
+
+type clientIPCtxKey struct{}
+
+// WithClientIP stores the address of the client making the request, used for per-client lockouts.
+func WithClientIP(ctx context.Context, ip string) context.Context {
+	return context.WithValue(ctx, clientIPCtxKey{}, ip)
+}
+
+func clientIP(ctx context.Context) string {
+	ip, _ := ctx.Value(clientIPCtxKey{}).(string)
+
+	return ip
+}
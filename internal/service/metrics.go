@@ -0,0 +1,103 @@
+package service
+
+import (
+	"context"
+	"errors"
+
+	"github.com/ernur-eskermes/crud-app/internal/core"
+	"github.com/google/uuid"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var (
+	signUpsTotal = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "users_sign_ups_total",
+		Help: "Number of users signed up.",
+	})
+	verificationsTotal = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "users_verifications_total",
+		Help: "Number of users verified.",
+	})
+	signInFailuresTotal = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "users_sign_in_failures_total",
+		Help: "Number of failed sign in attempts.",
+	})
+	booksCreatedTotal = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "books_created_total",
+		Help: "Number of books created.",
+	})
+	eventsPublishedTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "events_published_total",
+		Help: "Number of domain events published from the outbox by type.",
+	}, []string{"type"})
+	bookStreamSubscribers = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "book_stream_subscribers",
+		Help: "Number of clients subscribed to the stream of book events.",
+	})
+	bookStreamDroppedTotal = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "book_stream_dropped_total",
+		Help: "Number of stream subscribers dropped for falling behind.",
+	})
+	bookCacheRequestsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "book_cache_requests_total",
+		Help: "Number of book cache lookups by result, hit or miss.",
+	}, []string{"result"})
+	jobsProcessedTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "jobs_processed_total",
+		Help: "Number of job attempts by type and resulting status.",
+	}, []string{"type", "status"})
+	webhookDeliveriesTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "webhook_deliveries_total",
+		Help: "Number of webhook delivery attempts by event and outcome.",
+	}, []string{"event", "outcome"})
+)
+
+// usersMetrics counts sign ups, verifications and sign in failures.
+type usersMetrics struct {
+	Users
+}
+
+func (u usersMetrics) SignUp(ctx context.Context, input UserSignUpInput) error {
+	if err := u.Users.SignUp(ctx, input); err != nil {
+		return err
+	}
+
+	signUpsTotal.Inc()
+
+	return nil
+}
+
+func (u usersMetrics) SignIn(ctx context.Context, input UserSignInInput) (Tokens, error) {
+	tokens, err := u.Users.SignIn(ctx, input)
+	if err != nil && !errors.Is(err, core.ErrSecondFactorRequired) {
+		signInFailuresTotal.Inc()
+	}
+
+	return tokens, err
+}
+
+func (u usersMetrics) Verify(ctx context.Context, username, code string) error {
+	if err := u.Users.Verify(ctx, username, code); err != nil {
+		return err
+	}
+
+	verificationsTotal.Inc()
+
+	return nil
+}
+
+// booksMetrics counts created books.
+type booksMetrics struct {
+	Books
+}
+
+func (b booksMetrics) Create(ctx context.Context, book core.CreateBookInput, userID uuid.UUID) error {
+	if err := b.Books.Create(ctx, book, userID); err != nil {
+		return err
+	}
+
+	booksCreatedTotal.Inc()
+
+	return nil
+}
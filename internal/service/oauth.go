@@ -0,0 +1,620 @@
+package service
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/ernur-eskermes/crud-app/internal/core"
+	"github.com/ernur-eskermes/crud-app/pkg/auth"
+	"github.com/ernur-eskermes/crud-app/pkg/hash"
+)
+
+const (
+	responseTypeCode       = "code"
+	codeChallengeS256      = "S256"
+	tokenTypeBearer        = "Bearer"
+	grantAuthorizationCode = "authorization_code"
+	grantRefreshToken      = "refresh_token"
+	grantClientCredentials = "client_credentials"
+
+	oauthSecretBytes       = 32
+	authorizationCodeBytes = 32
+)
+
+type OAuthRepository interface {
+	CreateClient(ctx context.Context, client *core.OAuthClient) error
+	GetClient(ctx context.Context, id uuid.UUID) (core.OAuthClient, error)
+	GetClients(ctx context.Context) ([]core.OAuthClient, error)
+	DeleteClient(ctx context.Context, id uuid.UUID) error
+	GetConsent(ctx context.Context, userID, clientID uuid.UUID) (core.OAuthConsent, error)
+	GetConsents(ctx context.Context, userID uuid.UUID) ([]core.OAuthConsent, error)
+	SaveConsent(ctx context.Context, consent core.OAuthConsent) error
+	DeleteConsent(ctx context.Context, userID, clientID uuid.UUID) error
+	CreateCode(ctx context.Context, code core.AuthorizationCode) error
+	ConsumeCode(ctx context.Context, hash string) (core.AuthorizationCode, error)
+	CreateGrant(ctx context.Context, grant *core.OAuthGrant) error
+	GetGrant(ctx context.Context, id uuid.UUID) (core.OAuthGrant, error)
+	ExtendGrant(ctx context.Context, id uuid.UUID, expiresAt time.Time) error
+	RevokeGrant(ctx context.Context, id uuid.UUID) error
+	RevokeGrants(ctx context.Context, userID, clientID uuid.UUID) error
+}
+
+// OAuthPolicy sets the lifetimes of authorization codes and refresh tokens,
+// access tokens live as long as the ones of user sessions.
+type OAuthPolicy struct {
+	CodeTTL         time.Duration
+	RefreshTokenTTL time.Duration
+}
+
+// AuthorizeRequest is the authorization request of RFC 6749 section 4.1.1 with PKCE.
+type AuthorizeRequest struct {
+	ResponseType        string
+	ClientID            string
+	RedirectURI         string
+	Scope               string
+	State               string
+	CodeChallenge       string
+	CodeChallengeMethod string
+	// Approve records the consent of the user when true and denies the request when false.
+	// When nil, the request succeeds only if the user has already consented to the scopes.
+	Approve *bool
+}
+
+// ClientCredentials authenticate a client at the token, introspection and revocation endpoints.
+// Public clients send only the ID.
+type ClientCredentials struct {
+	ID     string
+	Secret string
+}
+
+type TokenRequest struct {
+	GrantType    string
+	Code         string
+	RedirectURI  string
+	CodeVerifier string
+	RefreshToken string
+	Scope        string
+}
+
+type TokenResponse struct {
+	AccessToken  string `json:"access_token"`
+	TokenType    string `json:"token_type"`
+	ExpiresIn    int64  `json:"expires_in"`
+	RefreshToken string `json:"refresh_token,omitempty"`
+	Scope        string `json:"scope,omitempty"`
+}
+
+// Introspection is the response of RFC 7662.
+type Introspection struct {
+	Active    bool   `json:"active"`
+	Scope     string `json:"scope,omitempty"`
+	ClientID  string `json:"client_id,omitempty"`
+	Subject   string `json:"sub,omitempty"`
+	TokenType string `json:"token_type,omitempty"`
+	ExpiresAt int64  `json:"exp,omitempty"`
+	IssuedAt  int64  `json:"iat,omitempty"`
+}
+
+type OAuthService struct {
+	repo         OAuthRepository
+	users        *UsersService
+	hasher       hash.PasswordHasher
+	tokenManager auth.TokenManager
+	policy       OAuthPolicy
+}
+
+func NewOAuthService(repo OAuthRepository, users *UsersService, hasher hash.PasswordHasher,
+	tokenManager auth.TokenManager, policy OAuthPolicy,
+) *OAuthService {
+	return &OAuthService{
+		repo:         repo,
+		users:        users,
+		hasher:       hasher,
+		tokenManager: tokenManager,
+		policy:       policy,
+	}
+}
+
+// CreateClient registers a client. The secret of a confidential client is returned once, only its hash is stored.
+func (s *OAuthService) CreateClient(ctx context.Context, input core.CreateOAuthClientInput) (core.OAuthClient, string, error) {
+	client := core.OAuthClient{
+		Name:         input.Name,
+		Confidential: input.Confidential,
+		RedirectURIs: input.RedirectURIs,
+		Scopes:       input.Scopes,
+	}
+
+	if client.RedirectURIs == nil {
+		client.RedirectURIs = []string{}
+	}
+
+	var secret string
+
+	if client.Confidential {
+		var err error
+		if secret, err = randomHex(oauthSecretBytes); err != nil {
+			return core.OAuthClient{}, "", err
+		}
+
+		if client.SecretHash, err = s.hasher.Hash(secret); err != nil {
+			return core.OAuthClient{}, "", err
+		}
+	}
+
+	if err := s.repo.CreateClient(ctx, &client); err != nil {
+		return core.OAuthClient{}, "", err
+	}
+
+	return client, secret, nil
+}
+
+func (s *OAuthService) GetClients(ctx context.Context) ([]core.OAuthClient, error) {
+	return s.repo.GetClients(ctx)
+}
+
+// DeleteClient removes the client along with its consents and grants, invalidating its tokens.
+func (s *OAuthService) DeleteClient(ctx context.Context, id uuid.UUID) error {
+	return s.repo.DeleteClient(ctx, id)
+}
+
+// Authorize handles the authorization request of the signed in user and returns the URL to redirect
+// the user agent to, carrying either the code or the error. Errors about the client or the redirect URI
+// are returned instead, as the user must not be redirected to an unverified URI.
+func (s *OAuthService) Authorize(ctx context.Context, userID uuid.UUID, req AuthorizeRequest) (string, error) {
+	client, err := s.getClient(ctx, req.ClientID)
+	if err != nil {
+		return "", err
+	}
+
+	redirectURI := req.RedirectURI
+	if redirectURI == "" && len(client.RedirectURIs) == 1 {
+		redirectURI = client.RedirectURIs[0]
+	}
+
+	if !client.HasRedirectURI(redirectURI) {
+		return "", core.ErrRedirectURIMismatch
+	}
+
+	redirectError := func(code, description string) (string, error) {
+		return redirectURL(redirectURI, url.Values{
+			"error":             {code},
+			"error_description": {description},
+			"state":             {req.State},
+		}), nil
+	}
+
+	switch {
+	case req.ResponseType != responseTypeCode:
+		return redirectError(core.OAuthUnsupportedResponse, "only the code response type is supported")
+	case req.CodeChallenge == "" || req.CodeChallengeMethod != codeChallengeS256:
+		return redirectError(core.OAuthInvalidRequest, "code_challenge with the S256 method is required")
+	case req.Approve != nil && !*req.Approve:
+		return redirectError(core.OAuthAccessDenied, "the user denied the request")
+	}
+
+	user, err := s.users.GetByID(ctx, userID)
+	if err != nil {
+		return "", err
+	}
+
+	scopes, ok := grantedScopes(strings.Fields(req.Scope), client.Scopes, core.RoleScopes(user.Role))
+	if !ok {
+		return redirectError(core.OAuthInvalidScope, "scope is not allowed for the client")
+	}
+
+	if err = s.consent(ctx, userID, client.ID, scopes, req.Approve != nil); err != nil {
+		return "", err
+	}
+
+	code, err := randomHex(authorizationCodeBytes)
+	if err != nil {
+		return "", err
+	}
+
+	codeHash, err := s.hasher.Hash(code)
+	if err != nil {
+		return "", err
+	}
+
+	if err = s.repo.CreateCode(ctx, core.AuthorizationCode{
+		Hash:          codeHash,
+		ClientID:      client.ID,
+		UserID:        userID,
+		RedirectURI:   redirectURI,
+		Scopes:        scopes,
+		CodeChallenge: req.CodeChallenge,
+		ExpiresAt:     time.Now().Add(s.policy.CodeTTL),
+	}); err != nil {
+		return "", err
+	}
+
+	return redirectURL(redirectURI, url.Values{
+		"code":  {code},
+		"state": {req.State},
+	}), nil
+}
+
+// consent checks the user has granted the scopes to the client, or records the grant when approved.
+func (s *OAuthService) consent(ctx context.Context, userID, clientID uuid.UUID, scopes []string, approved bool) error {
+	consent, err := s.repo.GetConsent(ctx, userID, clientID)
+	if err != nil && !errors.Is(err, core.ErrConsentNotFound) {
+		return err
+	}
+
+	if containsAll(consent.Scopes, scopes) {
+		return nil
+	}
+
+	if !approved {
+		return core.ErrConsentRequired
+	}
+
+	for _, scope := range scopes {
+		if !contains(consent.Scopes, scope) {
+			consent.Scopes = append(consent.Scopes, scope)
+		}
+	}
+
+	return s.repo.SaveConsent(ctx, core.OAuthConsent{
+		UserID:   userID,
+		ClientID: clientID,
+		Scopes:   consent.Scopes,
+	})
+}
+
+// Token handles the token request of RFC 6749 section 3.2. Protocol errors are *core.OAuthError.
+func (s *OAuthService) Token(ctx context.Context, creds ClientCredentials, req TokenRequest) (TokenResponse, error) {
+	client, err := s.authenticateClient(ctx, creds)
+	if err != nil {
+		return TokenResponse{}, err
+	}
+
+	switch req.GrantType {
+	case grantAuthorizationCode:
+		return s.exchangeCode(ctx, client, req)
+	case grantRefreshToken:
+		return s.refresh(ctx, client, req.RefreshToken)
+	case grantClientCredentials:
+		if !client.Confidential {
+			return TokenResponse{}, core.NewOAuthError(core.OAuthUnauthorizedClient, "public clients can't use client_credentials")
+		}
+
+		scopes, ok := grantedScopes(strings.Fields(req.Scope), client.Scopes, client.Scopes)
+		if !ok {
+			return TokenResponse{}, core.NewOAuthError(core.OAuthInvalidScope, "scope is not allowed for the client")
+		}
+
+		grant := core.OAuthGrant{
+			ClientID:  client.ID,
+			Scopes:    scopes,
+			ExpiresAt: time.Now().Add(s.users.accessTTL()),
+		}
+		if err = s.repo.CreateGrant(ctx, &grant); err != nil {
+			return TokenResponse{}, err
+		}
+
+		return s.issue(client.ID.String(), grant, false)
+	default:
+		return TokenResponse{}, core.NewOAuthError(core.OAuthUnsupportedGrantType, "grant_type is not supported")
+	}
+}
+
+func (s *OAuthService) exchangeCode(ctx context.Context, client core.OAuthClient, req TokenRequest) (TokenResponse, error) {
+	invalidGrant := core.NewOAuthError(core.OAuthInvalidGrant, "code is invalid or expired")
+
+	codeHash, err := s.hasher.Hash(req.Code)
+	if err != nil {
+		return TokenResponse{}, err
+	}
+
+	code, err := s.repo.ConsumeCode(ctx, codeHash)
+	if err != nil {
+		if errors.Is(err, core.ErrCodeNotFound) {
+			return TokenResponse{}, invalidGrant
+		}
+
+		return TokenResponse{}, err
+	}
+
+	switch {
+	case code.ClientID != client.ID, time.Now().After(code.ExpiresAt):
+		return TokenResponse{}, invalidGrant
+	case req.RedirectURI != "" && req.RedirectURI != code.RedirectURI:
+		return TokenResponse{}, core.NewOAuthError(core.OAuthInvalidGrant, "redirect_uri does not match the authorization request")
+	case subtle.ConstantTimeCompare([]byte(codeChallenge(req.CodeVerifier)), []byte(code.CodeChallenge)) != 1:
+		return TokenResponse{}, core.NewOAuthError(core.OAuthInvalidGrant, "code_verifier does not match the code_challenge")
+	}
+
+	grant := core.OAuthGrant{
+		ClientID:  client.ID,
+		UserID:    code.UserID,
+		Scopes:    code.Scopes,
+		ExpiresAt: time.Now().Add(s.policy.RefreshTokenTTL),
+	}
+	if err = s.repo.CreateGrant(ctx, &grant); err != nil {
+		return TokenResponse{}, err
+	}
+
+	return s.issue(code.UserID.String(), grant, true)
+}
+
+func (s *OAuthService) refresh(ctx context.Context, client core.OAuthClient, refreshToken string) (TokenResponse, error) {
+	invalidGrant := core.NewOAuthError(core.OAuthInvalidGrant, "refresh_token is invalid, expired or revoked")
+
+	claims, err := s.tokenManager.ParseRefreshToken(refreshToken)
+	if err != nil || claims.ClientID != client.ID.String() {
+		return TokenResponse{}, invalidGrant
+	}
+
+	grant, err := s.activeGrant(ctx, claims)
+	if err != nil {
+		if errors.Is(err, core.ErrGrantRevoked) {
+			return TokenResponse{}, invalidGrant
+		}
+
+		return TokenResponse{}, err
+	}
+
+	grant.ExpiresAt = time.Now().Add(s.policy.RefreshTokenTTL)
+	if err = s.repo.ExtendGrant(ctx, grant.ID, grant.ExpiresAt); err != nil {
+		return TokenResponse{}, err
+	}
+
+	return s.issue(claims.Subject, grant, true)
+}
+
+// issue signs the tokens of the grant. The grant id is the session id of the tokens.
+func (s *OAuthService) issue(subject string, grant core.OAuthGrant, withRefreshToken bool) (TokenResponse, error) {
+	claims := auth.Claims{
+		Subject:   subject,
+		SessionID: grant.ID.String(),
+		Scopes:    grant.Scopes,
+		ClientID:  grant.ClientID.String(),
+	}
+
+	ttl := s.users.accessTTL()
+
+	accessToken, err := s.tokenManager.NewJWT(claims, ttl)
+	if err != nil {
+		return TokenResponse{}, err
+	}
+
+	res := TokenResponse{
+		AccessToken: accessToken,
+		TokenType:   tokenTypeBearer,
+		ExpiresIn:   int64(ttl.Seconds()),
+		Scope:       strings.Join(grant.Scopes, " "),
+	}
+
+	if withRefreshToken {
+		if res.RefreshToken, err = s.tokenManager.NewRefreshToken(claims, s.policy.RefreshTokenTTL); err != nil {
+			return TokenResponse{}, err
+		}
+	}
+
+	return res, nil
+}
+
+// Introspect describes the token to a confidential client (RFC 7662).
+// Invalid, expired and revoked tokens are reported as inactive.
+func (s *OAuthService) Introspect(ctx context.Context, creds ClientCredentials, token string) (Introspection, error) {
+	client, err := s.authenticateClient(ctx, creds)
+	if err != nil {
+		return Introspection{}, err
+	}
+
+	if !client.Confidential {
+		return Introspection{}, core.NewOAuthError(core.OAuthUnauthorizedClient, "public clients can't introspect tokens")
+	}
+
+	claims, ok := s.parseAnyToken(token)
+	if !ok {
+		return Introspection{}, nil
+	}
+
+	if err = s.CheckGrant(ctx, claims); err != nil {
+		if errors.Is(err, core.ErrGrantRevoked) {
+			return Introspection{}, nil
+		}
+
+		return Introspection{}, err
+	}
+
+	return Introspection{
+		Active:    true,
+		Scope:     strings.Join(claims.Scopes, " "),
+		ClientID:  claims.ClientID,
+		Subject:   claims.Subject,
+		TokenType: tokenTypeBearer,
+		ExpiresAt: claims.ExpiresAt.Unix(),
+		IssuedAt:  claims.IssuedAt.Unix(),
+	}, nil
+}
+
+// Revoke revokes the grant of an access or refresh token issued to the client (RFC 7009),
+// so all the tokens of the grant stop working. Invalid tokens are ignored.
+func (s *OAuthService) Revoke(ctx context.Context, creds ClientCredentials, token string) error {
+	client, err := s.authenticateClient(ctx, creds)
+	if err != nil {
+		return err
+	}
+
+	claims, ok := s.parseAnyToken(token)
+	if !ok || claims.ClientID == "" {
+		return nil
+	}
+
+	if claims.ClientID != client.ID.String() {
+		return core.NewOAuthError(core.OAuthUnauthorizedClient, "token was issued to another client")
+	}
+
+	grantID, err := uuid.Parse(claims.SessionID)
+	if err != nil {
+		return nil
+	}
+
+	return s.repo.RevokeGrant(ctx, grantID)
+}
+
+// CheckGrant returns core.ErrGrantRevoked if the claims belong to a token issued to a client
+// whose grant was revoked. Tokens of user sessions are not checked.
+func (s *OAuthService) CheckGrant(ctx context.Context, claims auth.Claims) error {
+	if claims.ClientID == "" {
+		return nil
+	}
+
+	_, err := s.activeGrant(ctx, claims)
+
+	return err
+}
+
+func (s *OAuthService) GetConsents(ctx context.Context, userID uuid.UUID) ([]core.OAuthConsent, error) {
+	return s.repo.GetConsents(ctx, userID)
+}
+
+// RevokeConsent withdraws the consent of the user and revokes the tokens issued to the client on their behalf.
+func (s *OAuthService) RevokeConsent(ctx context.Context, userID, clientID uuid.UUID) error {
+	if err := s.repo.DeleteConsent(ctx, userID, clientID); err != nil {
+		return err
+	}
+
+	return s.repo.RevokeGrants(ctx, userID, clientID)
+}
+
+func (s *OAuthService) activeGrant(ctx context.Context, claims auth.Claims) (core.OAuthGrant, error) {
+	grantID, err := uuid.Parse(claims.SessionID)
+	if err != nil {
+		return core.OAuthGrant{}, core.ErrGrantRevoked
+	}
+
+	grant, err := s.repo.GetGrant(ctx, grantID)
+	if err != nil {
+		if errors.Is(err, core.ErrGrantNotFound) {
+			return core.OAuthGrant{}, core.ErrGrantRevoked
+		}
+
+		return core.OAuthGrant{}, err
+	}
+
+	if grant.RevokedAt != nil || grant.ClientID.String() != claims.ClientID {
+		return core.OAuthGrant{}, core.ErrGrantRevoked
+	}
+
+	return grant, nil
+}
+
+func (s *OAuthService) authenticateClient(ctx context.Context, creds ClientCredentials) (core.OAuthClient, error) {
+	invalidClient := core.NewOAuthError(core.OAuthInvalidClient, "client authentication failed")
+
+	client, err := s.getClient(ctx, creds.ID)
+	if err != nil {
+		if errors.Is(err, core.ErrOAuthClientNotFound) {
+			return core.OAuthClient{}, invalidClient
+		}
+
+		return core.OAuthClient{}, err
+	}
+
+	if !client.Confidential {
+		return client, nil
+	}
+
+	secretHash, err := s.hasher.Hash(creds.Secret)
+	if err != nil {
+		return core.OAuthClient{}, err
+	}
+
+	if creds.Secret == "" || subtle.ConstantTimeCompare([]byte(secretHash), []byte(client.SecretHash)) != 1 {
+		return core.OAuthClient{}, invalidClient
+	}
+
+	return client, nil
+}
+
+func (s *OAuthService) getClient(ctx context.Context, clientID string) (core.OAuthClient, error) {
+	id, err := uuid.Parse(clientID)
+	if err != nil {
+		return core.OAuthClient{}, core.ErrOAuthClientNotFound
+	}
+
+	return s.repo.GetClient(ctx, id)
+}
+
+func (s *OAuthService) parseAnyToken(token string) (auth.Claims, bool) {
+	if claims, err := s.tokenManager.Parse(token); err == nil {
+		return claims, true
+	}
+
+	claims, err := s.tokenManager.ParseRefreshToken(token)
+
+	return claims, err == nil
+}
+
+// grantedScopes checks the requested scopes are allowed for the client and limits them to the
+// ones the subject has. Requesting no scopes means all the allowed ones.
+func grantedScopes(requested, clientScopes, subjectScopes []string) ([]string, bool) {
+	if len(requested) == 0 {
+		requested = clientScopes
+	}
+
+	scopes := make([]string, 0, len(requested))
+
+	for _, scope := range requested {
+		if !contains(clientScopes, scope) {
+			return nil, false
+		}
+
+		if contains(subjectScopes, scope) && !contains(scopes, scope) {
+			scopes = append(scopes, scope)
+		}
+	}
+
+	return scopes, true
+}
+
+func containsAll(values, subset []string) bool {
+	for _, v := range subset {
+		if !contains(values, v) {
+			return false
+		}
+	}
+
+	return true
+}
+
+func redirectURL(redirectURI string, params url.Values) string {
+	for key, values := range params {
+		if len(values) == 0 || values[0] == "" {
+			params.Del(key)
+		}
+	}
+
+	u, err := url.Parse(redirectURI)
+	if err != nil {
+		return redirectURI
+	}
+
+	query := u.Query()
+	for key, values := range params {
+		query[key] = values
+	}
+
+	u.RawQuery = query.Encode()
+
+	return u.String()
+}
+
+// codeChallenge is the S256 PKCE challenge of the verifier (RFC 7636).
+func codeChallenge(verifier string) string {
+	sum := sha256.Sum256([]byte(verifier))
+
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
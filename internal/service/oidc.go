@@ -0,0 +1,253 @@
+package service
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/ernur-eskermes/crud-app/internal/core"
+	"github.com/ernur-eskermes/crud-app/pkg/hash"
+	"github.com/ernur-eskermes/crud-app/pkg/logging"
+	"github.com/ernur-eskermes/crud-app/pkg/oidc"
+)
+
+// loginStateTTL is how long a user has to complete the login at the provider.
+const loginStateTTL = 10 * time.Minute
+
+type IdentitiesRepository interface {
+	Create(ctx context.Context, identity *core.Identity) error
+	Get(ctx context.Context, provider, subject string) (core.Identity, error)
+	CreateLoginState(ctx context.Context, state core.LoginState) error
+	ConsumeLoginState(ctx context.Context, stateHash string) (core.LoginState, error)
+}
+
+// IdentityProvider is an OpenID Connect provider, implemented by oidc.Provider.
+type IdentityProvider interface {
+	Name() string
+	AuthCodeURL(ctx context.Context, state, nonce, verifier string) (string, error)
+	Exchange(ctx context.Context, code, verifier, nonce string) (oidc.Identity, error)
+}
+
+// OIDCLogin is a login started with a provider.
+type OIDCLogin struct {
+	// URL is the login page of the provider to redirect the user to.
+	URL string
+	// BrowserKey must be kept by the browser starting the login, e.g. in a cookie, and passed to Callback,
+	// so that a callback URL forged by someone else is rejected.
+	BrowserKey string
+	ExpiresAt  time.Time
+}
+
+// OIDCService signs users in with OpenID Connect providers. The states of the logins in progress are stored
+// in the database, so the callback may reach any instance, and hashed like the authorization codes.
+type OIDCService struct {
+	users      *UsersService
+	identities IdentitiesRepository
+	providers  map[string]IdentityProvider
+	hasher     hash.PasswordHasher
+}
+
+func NewOIDCService(users *UsersService, identities IdentitiesRepository, providers []IdentityProvider,
+	hasher hash.PasswordHasher,
+) *OIDCService {
+	byName := make(map[string]IdentityProvider, len(providers))
+	for _, p := range providers {
+		byName[p.Name()] = p
+	}
+
+	return &OIDCService{
+		users:      users,
+		identities: identities,
+		providers:  byName,
+		hasher:     hasher,
+	}
+}
+
+func (s *OIDCService) Providers() []string {
+	names := make([]string, 0, len(s.providers))
+	for name := range s.providers {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	return names
+}
+
+// AuthURL starts a login with the provider and returns the URL to redirect the user to.
+// Unless linkTo is uuid.Nil, the identity is linked to that user instead of signing in.
+func (s *OIDCService) AuthURL(ctx context.Context, provider string, linkTo uuid.UUID) (OIDCLogin, error) {
+	p, ok := s.providers[provider]
+	if !ok {
+		return OIDCLogin{}, core.ErrProviderNotFound
+	}
+
+	st := core.LoginState{
+		Provider:  provider,
+		LinkTo:    linkTo,
+		ExpiresAt: time.Now().Add(loginStateTTL),
+	}
+
+	var (
+		state string
+		login = OIDCLogin{ExpiresAt: st.ExpiresAt}
+		err   error
+	)
+
+	for _, v := range []*string{&state, &login.BrowserKey, &st.Nonce, &st.Verifier} {
+		if *v, err = oidc.RandomString(); err != nil {
+			return OIDCLogin{}, err
+		}
+	}
+
+	if st.StateHash, err = s.hasher.Hash(state); err != nil {
+		return OIDCLogin{}, err
+	}
+
+	if st.BrowserHash, err = s.hasher.Hash(login.BrowserKey); err != nil {
+		return OIDCLogin{}, err
+	}
+
+	if login.URL, err = p.AuthCodeURL(ctx, state, st.Nonce, st.Verifier); err != nil {
+		return OIDCLogin{}, err
+	}
+
+	if err = s.identities.CreateLoginState(ctx, st); err != nil {
+		return OIDCLogin{}, err
+	}
+
+	return login, nil
+}
+
+// Callback completes the login started by AuthURL in the same browser, identified by the BrowserKey
+// of the login. Users signing in with an unknown identity are created, named after the preferred username
+// or the verified email of the identity.
+func (s *OIDCService) Callback(ctx context.Context, provider, state, browserKey, code string) (Tokens, error) {
+	p, ok := s.providers[provider]
+	if !ok {
+		return Tokens{}, core.ErrProviderNotFound
+	}
+
+	st, err := s.consumeLoginState(ctx, provider, state, browserKey)
+	if err != nil {
+		return Tokens{}, err
+	}
+
+	identity, err := p.Exchange(ctx, code, st.Verifier, st.Nonce)
+	if err != nil {
+		logging.FromContext(ctx).WithField("provider", provider).Warnf("oidc login failed: %v", err)
+
+		return Tokens{}, core.ErrLoginFailed
+	}
+
+	user, err := s.resolveUser(ctx, identity, st.LinkTo)
+	if err != nil {
+		return Tokens{}, err
+	}
+
+	return s.users.createSession(user)
+}
+
+// consumeLoginState returns the state of the login started by the browser with the provider. The state
+// is single use, a replayed callback is rejected.
+func (s *OIDCService) consumeLoginState(ctx context.Context, provider, state, browserKey string,
+) (core.LoginState, error) {
+	if state == "" || browserKey == "" {
+		return core.LoginState{}, core.ErrLoginStateInvalid
+	}
+
+	stateHash, err := s.hasher.Hash(state)
+	if err != nil {
+		return core.LoginState{}, err
+	}
+
+	browserHash, err := s.hasher.Hash(browserKey)
+	if err != nil {
+		return core.LoginState{}, err
+	}
+
+	st, err := s.identities.ConsumeLoginState(ctx, stateHash)
+	if err != nil {
+		return core.LoginState{}, err
+	}
+
+	if st.Provider != provider || time.Now().After(st.ExpiresAt) ||
+		subtle.ConstantTimeCompare([]byte(st.BrowserHash), []byte(browserHash)) != 1 {
+		return core.LoginState{}, core.ErrLoginStateInvalid
+	}
+
+	return st, nil
+}
+
+// resolveUser returns the user the identity is linked to, linking it first when it is new.
+func (s *OIDCService) resolveUser(ctx context.Context, identity oidc.Identity, linkTo uuid.UUID) (core.User, error) {
+	linked, err := s.identities.Get(ctx, identity.Provider, identity.Subject)
+
+	switch {
+	case err == nil:
+		if linkTo != uuid.Nil && linked.UserID != linkTo {
+			return core.User{}, core.ErrIdentityAlreadyLinked
+		}
+
+		return s.users.GetByID(ctx, linked.UserID)
+	case !errors.Is(err, core.ErrIdentityNotFound):
+		return core.User{}, err
+	}
+
+	var user core.User
+
+	if linkTo != uuid.Nil {
+		user, err = s.users.GetByID(ctx, linkTo)
+	} else {
+		user, err = s.createUser(ctx, identity)
+	}
+
+	if err != nil {
+		return core.User{}, err
+	}
+
+	if err = s.identities.Create(ctx, &core.Identity{
+		Provider: identity.Provider,
+		Subject:  identity.Subject,
+		UserID:   user.ID,
+		Email:    identity.Email,
+	}); err != nil {
+		return core.User{}, err
+	}
+
+	return user, nil
+}
+
+// createUser creates an active user for the identity. The user has a random password,
+// so it can only sign in with the provider.
+func (s *OIDCService) createUser(ctx context.Context, identity oidc.Identity) (core.User, error) {
+	username := identity.PreferredUsername
+	if username == "" && identity.EmailVerified {
+		username = identity.Email
+	}
+
+	if username == "" {
+		username = identity.Provider + "_" + identity.Subject
+	}
+
+	password, err := oidc.RandomString()
+	if err != nil {
+		return core.User{}, err
+	}
+
+	user, err := s.users.Create(ctx, UserCreateInput{
+		Username: username,
+		Password: password,
+		Role:     core.RoleUser,
+		Verified: true,
+	})
+	if errors.Is(err, core.ErrUserAlreadyExists) {
+		return core.User{}, core.ErrIdentityUsernameTaken
+	}
+
+	return user, err
+}
@@ -0,0 +1,154 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/ernur-eskermes/crud-app/internal/core"
+	"github.com/ernur-eskermes/crud-app/pkg/hash"
+	"github.com/ernur-eskermes/crud-app/pkg/oidc"
+)
+
+type fakeIdentities struct {
+	IdentitiesRepository
+	states map[string]core.LoginState
+}
+
+func (r *fakeIdentities) CreateLoginState(_ context.Context, state core.LoginState) error {
+	r.states[state.StateHash] = state
+
+	return nil
+}
+
+func (r *fakeIdentities) ConsumeLoginState(_ context.Context, stateHash string) (core.LoginState, error) {
+	state, ok := r.states[stateHash]
+	if !ok {
+		return core.LoginState{}, core.ErrLoginStateInvalid
+	}
+
+	delete(r.states, stateHash)
+
+	return state, nil
+}
+
+// fakeProvider puts the state in the URL of its login page.
+type fakeProvider struct {
+	IdentityProvider
+	name string
+}
+
+func (p fakeProvider) Name() string {
+	return p.name
+}
+
+func (p fakeProvider) AuthCodeURL(_ context.Context, state, _, _ string) (string, error) {
+	return "https://idp.example.com/auth?state=" + url.QueryEscape(state), nil
+}
+
+func (p fakeProvider) Exchange(context.Context, string, string, string) (oidc.Identity, error) {
+	return oidc.Identity{}, errors.New("not implemented")
+}
+
+func TestOIDCServiceConsumeLoginState(t *testing.T) {
+	ctx := context.Background()
+
+	tests := []struct {
+		name string
+		// callback returns the provider, state and browser key of the callback of the login.
+		callback func(t *testing.T, state string, login OIDCLogin, repo *fakeIdentities) (string, string, string)
+		wantErr  error
+	}{
+		{
+			name: "same browser",
+			callback: func(_ *testing.T, state string, login OIDCLogin, _ *fakeIdentities) (string, string, string) {
+				return "mock", state, login.BrowserKey
+			},
+		},
+		{
+			name: "another browser",
+			callback: func(_ *testing.T, state string, _ OIDCLogin, _ *fakeIdentities) (string, string, string) {
+				return "mock", state, "attacker"
+			},
+			wantErr: core.ErrLoginStateInvalid,
+		},
+		{
+			name: "no cookie",
+			callback: func(_ *testing.T, state string, _ OIDCLogin, _ *fakeIdentities) (string, string, string) {
+				return "mock", state, ""
+			},
+			wantErr: core.ErrLoginStateInvalid,
+		},
+		{
+			name: "another provider",
+			callback: func(_ *testing.T, state string, login OIDCLogin, _ *fakeIdentities) (string, string, string) {
+				return "other", state, login.BrowserKey
+			},
+			wantErr: core.ErrLoginStateInvalid,
+		},
+		{
+			name: "unknown state",
+			callback: func(_ *testing.T, _ string, login OIDCLogin, _ *fakeIdentities) (string, string, string) {
+				return "mock", "forged", login.BrowserKey
+			},
+			wantErr: core.ErrLoginStateInvalid,
+		},
+		{
+			name: "expired",
+			callback: func(_ *testing.T, state string, login OIDCLogin, repo *fakeIdentities) (string, string, string) {
+				for hash, st := range repo.states {
+					st.ExpiresAt = time.Now().Add(-time.Second)
+					repo.states[hash] = st
+				}
+
+				return "mock", state, login.BrowserKey
+			},
+			wantErr: core.ErrLoginStateInvalid,
+		},
+		{
+			name: "replayed",
+			callback: func(t *testing.T, state string, login OIDCLogin, repo *fakeIdentities) (string, string, string) {
+				s := &OIDCService{identities: repo, hasher: hash.NewSHA256Hasher("salt")}
+				if _, err := s.consumeLoginState(context.Background(), "mock", state, login.BrowserKey); err != nil {
+					t.Fatal(err)
+				}
+
+				return "mock", state, login.BrowserKey
+			},
+			wantErr: core.ErrLoginStateInvalid,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := &fakeIdentities{states: make(map[string]core.LoginState)}
+			s := NewOIDCService(nil, repo, []IdentityProvider{fakeProvider{name: "mock"}}, hash.NewSHA256Hasher("salt"))
+			linkTo := uuid.New()
+
+			login, err := s.AuthURL(ctx, "mock", linkTo)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			u, err := url.Parse(login.URL)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			provider, state, browserKey := tt.callback(t, u.Query().Get("state"), login, repo)
+
+			st, err := s.consumeLoginState(ctx, provider, state, browserKey)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("consumeLoginState() error = %v, want %v", err, tt.wantErr)
+			}
+
+			if err == nil && st.LinkTo != linkTo {
+				t.Errorf("consumeLoginState() linkTo = %s, want %s", st.LinkTo, linkTo)
+			}
+		})
+	}
+}
@@ -2,8 +2,11 @@ package service
 
 import (
 	"context"
+	"io"
 	"time"
 
+	"github.com/duo-labs/webauthn/protocol"
+	"github.com/duo-labs/webauthn/webauthn"
 	"github.com/ernur-eskermes/crud-app/pkg/otp"
 	cache "github.com/ernur-eskermes/go-homeworks/2-cache-ttl"
 
@@ -22,20 +25,101 @@ type Books interface {
 	Create(ctx context.Context, book core.CreateBookInput, userID uuid.UUID) error
 	GetByID(ctx context.Context, id uuid.UUID) (core.Book, error)
 	GetAll(ctx context.Context) ([]core.Book, error)
+	Search(ctx context.Context, query core.SearchBooksQuery) ([]core.Book, error)
 	Delete(ctx context.Context, id, userID uuid.UUID) error
 	Update(ctx context.Context, id, userID uuid.UUID, inp core.UpdateBookInput) error
+	LastModified(ctx context.Context) (time.Time, error)
 }
 
 type Users interface {
 	SignUp(ctx context.Context, input UserSignUpInput) error
 	SignIn(ctx context.Context, input UserSignInInput) (Tokens, error)
 	GetByID(ctx context.Context, id uuid.UUID) (core.User, error)
+	GetByIDs(ctx context.Context, ids []uuid.UUID) ([]core.User, error)
+	GetByUsername(ctx context.Context, username string) (core.User, error)
 	Verify(ctx context.Context, username, code string) error
+
+	Create(ctx context.Context, input UserCreateInput) (core.User, error)
+	MarkVerified(ctx context.Context, username string) error
+	Promote(ctx context.Context, username string) error
+	Unlock(ctx context.Context, username string) error
+}
+
+type APIKeys interface {
+	Create(ctx context.Context, userID uuid.UUID, input core.CreateAPIKeyInput) (core.APIKey, string, error)
+	List(ctx context.Context, userID uuid.UUID) ([]core.APIKey, error)
+	Revoke(ctx context.Context, userID, id uuid.UUID) error
+	Authenticate(ctx context.Context, rawKey string) (auth.Claims, error)
+}
+
+type OIDC interface {
+	Providers() []string
+	AuthURL(ctx context.Context, provider string, linkTo uuid.UUID) (OIDCLogin, error)
+	Callback(ctx context.Context, provider, state, browserKey, code string) (Tokens, error)
+}
+
+type WebAuthn interface {
+	BeginRegistration(ctx context.Context, userID uuid.UUID) (*protocol.CredentialCreation, error)
+	FinishRegistration(ctx context.Context, userID uuid.UUID, name string, response io.Reader) (core.WebAuthnCredential, error)
+	BeginLogin(ctx context.Context, username, secondFactorToken string) (string, *protocol.CredentialAssertion, error)
+	FinishLogin(ctx context.Context, loginID string, response io.Reader) (Tokens, error)
+	GetCredentials(ctx context.Context, userID uuid.UUID) ([]core.WebAuthnCredential, error)
+	DeleteCredential(ctx context.Context, userID, id uuid.UUID) error
+}
+
+type Webhooks interface {
+	Create(ctx context.Context, userID uuid.UUID, input core.CreateWebhookInput) (core.Webhook, string, error)
+	List(ctx context.Context, userID uuid.UUID) ([]core.Webhook, error)
+	Update(ctx context.Context, userID, id uuid.UUID, input core.UpdateWebhookInput) (core.Webhook, error)
+	Delete(ctx context.Context, userID, id uuid.UUID) error
+	GetDeliveries(ctx context.Context, userID, id uuid.UUID) ([]core.WebhookDelivery, error)
+	Replay(ctx context.Context, userID, id, deliveryID uuid.UUID) (core.WebhookDelivery, error)
+}
+
+type Jobs interface {
+	Enqueue(ctx context.Context, jobType string, payload interface{}, runAt time.Time) (core.Job, error)
+	Get(ctx context.Context, id uuid.UUID) (core.Job, error)
+	List(ctx context.Context, query core.JobsQuery) ([]core.Job, error)
+	Retry(ctx context.Context, id uuid.UUID) (core.Job, error)
+}
+
+type BookStream interface {
+	Subscribe(filter core.BookStreamFilter, lastEventID *int64) *BookSubscription
+}
+
+type OAuth interface {
+	CreateClient(ctx context.Context, input core.CreateOAuthClientInput) (core.OAuthClient, string, error)
+	GetClients(ctx context.Context) ([]core.OAuthClient, error)
+	DeleteClient(ctx context.Context, id uuid.UUID) error
+	Authorize(ctx context.Context, userID uuid.UUID, req AuthorizeRequest) (string, error)
+	Token(ctx context.Context, creds ClientCredentials, req TokenRequest) (TokenResponse, error)
+	Introspect(ctx context.Context, creds ClientCredentials, token string) (Introspection, error)
+	Revoke(ctx context.Context, creds ClientCredentials, token string) error
+	CheckGrant(ctx context.Context, claims auth.Claims) error
+	GetConsents(ctx context.Context, userID uuid.UUID) ([]core.OAuthConsent, error)
+	RevokeConsent(ctx context.Context, userID, clientID uuid.UUID) error
 }
 
 type Services struct {
-	Users Users
-	Books Books
+	Users    Users
+	Books    Books
+	APIKeys  APIKeys
+	OIDC     OIDC
+	WebAuthn WebAuthn
+	OAuth    OAuth
+	Webhooks Webhooks
+	Jobs     Jobs
+	// BookStream is not traced, a subscription lasts as long as the client stays connected.
+	BookStream BookStream
+
+	tokenManager auth.TokenManager
+	users        *UsersService
+	webhooks     *WebhooksService
+	bookStream   *BookStreamService
+	jobs         *JobsService
+	events       *EventBus
+	dispatcher   *EventDispatcher
+	broadcaster  *EventBroadcaster
 }
 
 type Deps struct {
@@ -47,17 +131,146 @@ type Deps struct {
 	Cache          cache.Cache
 	Environment    string
 	Domain         string
+	Lockout        LockoutPolicy
+	OAuth          OAuthPolicy
+	// LogVerificationCodes logs the sign up verification codes at debug level, never set it in production.
+	LogVerificationCodes bool
+	// IdentityProviders are the OpenID Connect providers users can sign in with.
+	IdentityProviders []IdentityProvider
+	// WebAuthn is the relying party of passkeys, their challenges expire after WebAuthnChallengeTTL.
+	WebAuthn             *webauthn.WebAuthn
+	WebAuthnChallengeTTL time.Duration
+	Webhooks             WebhookPolicy
+	Events               EventsPolicy
+	BookStream           BookStreamPolicy
+	Jobs                 JobsPolicy
+	// BookCache caches the reads of books when set.
+	BookCache CacheBackend
+	// EventsFeed passes the domain events to every instance, e.g. for the streams of book events.
+	EventsFeed EventsFeed
+	// Publishers receive the domain events after the in-process subscribers, e.g. to forward them to a broker.
+	Publishers []Publisher
 }
 
 func NewServices(deps Deps) *Services {
 	usersService := NewUsersService(deps.Repos.Users, deps.Hasher, deps.TokenManager,
-		deps.AccessTokenTTL, deps.Domain, deps.Cache, deps.OtpGenerator)
-	booksService := NewBooksService(deps.Repos.Books, deps.TokenManager)
+		deps.AccessTokenTTL, deps.Domain, deps.Cache, deps.OtpGenerator,
+		NewLockout(deps.Repos.Attempts, deps.Lockout, logNotifier{}))
+	var (
+		booksRepo  BooksRepository = deps.Repos.Books
+		booksCache *booksCache
+	)
+
+	if deps.BookCache != nil {
+		booksCache = newBooksCache(booksRepo, deps.BookCache)
+		booksRepo = booksCache
+	}
+
+	booksService := NewBooksService(booksRepo, deps.TokenManager)
+	apiKeysService := NewAPIKeysService(deps.Repos.APIKeys, deps.Repos.Users, deps.Hasher)
+	oidcService := NewOIDCService(usersService, deps.Repos.Identities, deps.IdentityProviders, deps.Hasher)
+	webAuthnService := NewWebAuthnService(deps.WebAuthn, deps.Repos.WebAuthn, usersService, deps.Hasher,
+		deps.WebAuthnChallengeTTL)
+	usersService.secondFactor = webAuthnService
+	usersService.logCodes = deps.LogVerificationCodes
+	oauthService := NewOAuthService(deps.Repos.OAuth, usersService, deps.Hasher, deps.TokenManager, deps.OAuth)
+	webhooksService := NewWebhooksService(deps.Repos.Webhooks, deps.Webhooks)
+
+	bookStreamService := NewBookStreamService(deps.BookStream)
+	jobsService := NewJobsService(deps.Repos.Jobs, deps.Jobs)
+
+	events := NewEventBus()
+	events.Subscribe(webhooksService.HandleBookEvent, core.EventBookCreated, core.EventBookUpdated, core.EventBookDeleted)
+	dispatcher := NewEventDispatcher(deps.Repos.Outbox, deps.Events, append([]Publisher{events}, deps.Publishers...)...)
+
+	// Every instance streams the book events to its own subscribers and keeps the same latest ones,
+	// so a stream resumes on any instance.
+	broadcaster := NewEventBroadcaster(deps.EventsFeed, deps.BookStream.ReplaySize)
+	broadcaster.Subscribe(bookStreamService.HandleBookEvent,
+		core.EventBookCreated, core.EventBookUpdated, core.EventBookDeleted)
+
+	if booksCache != nil {
+		broadcaster.Subscribe(booksCache.HandleBookEvent, core.EventBookCreated, core.EventBookUpdated, core.EventBookDeleted)
+	}
 
 	return &Services{
-		Users: usersService,
-		Books: booksService,
+		Users:        newUsersTracing(usersMetrics{usersService}),
+		Books:        newBooksTracing(booksMetrics{booksService}),
+		APIKeys:      newAPIKeysTracing(apiKeysService),
+		OIDC:         newOIDCTracing(oidcService),
+		WebAuthn:     newWebAuthnTracing(webAuthnService),
+		OAuth:        newOAuthTracing(oauthService),
+		Webhooks:     newWebhooksTracing(webhooksService),
+		Jobs:         newJobsTracing(jobsService),
+		BookStream:   bookStreamService,
+		tokenManager: deps.TokenManager,
+		users:        usersService,
+		webhooks:     webhooksService,
+		bookStream:   bookStreamService,
+		jobs:         jobsService,
+		events:       events,
+		dispatcher:   dispatcher,
+		broadcaster:  broadcaster,
+	}
+}
+
+// Subscribe registers handler for the domain events of the types. Handlers are called
+// at least once per event, after the change is committed, while Run is running.
+func (s *Services) Subscribe(handler EventHandler, eventTypes ...string) {
+	s.events.Subscribe(handler, eventTypes...)
+}
+
+// CloseStreams ends the streams of book events, so that the HTTP server can shut down.
+func (s *Services) CloseStreams() {
+	s.bookStream.Close()
+}
+
+// RegisterJob sets the handler of the jobs of the type, zero options take the JobsPolicy defaults.
+// Handlers are registered before Run.
+func (s *Services) RegisterJob(jobType string, handler JobHandler, options JobOptions) {
+	s.jobs.Register(jobType, handler, options)
+}
+
+// ScheduleJob queues a job at every time of the cron spec, see JobsService.Schedule.
+func (s *Services) ScheduleJob(name, spec, jobType string, payload interface{}) error {
+	return s.jobs.Schedule(name, spec, jobType, payload)
+}
+
+// PurgeOutbox is the handler of core.JobPurgeOutbox, deleting the published events.
+func (s *Services) PurgeOutbox(ctx context.Context, job core.Job) error {
+	return s.dispatcher.Purge(ctx, job)
+}
+
+// PurgeJobs is the handler of core.JobPurgeJobs, deleting the succeeded jobs.
+func (s *Services) PurgeJobs(ctx context.Context, job core.Job) error {
+	return s.jobs.Purge(ctx, job)
+}
+
+// Run publishes and broadcasts the domain events, delivers the webhooks and runs the jobs until ctx is done.
+func (s *Services) Run(ctx context.Context) error {
+	workers := []func(ctx context.Context) error{s.dispatcher.Run, s.broadcaster.Run, s.webhooks.Run, s.jobs.Run}
+	errs := make(chan error, len(workers))
+
+	for _, run := range workers {
+		go func(run func(ctx context.Context) error) {
+			errs <- run(ctx)
+		}(run)
+	}
+
+	var err error
+
+	for range workers {
+		if workerErr := <-errs; workerErr != nil && err == nil {
+			err = workerErr
+		}
 	}
+
+	return err
+}
+
+// SetAccessTokenTTL changes the lifetime of access tokens issued from now on.
+func (s *Services) SetAccessTokenTTL(ttl time.Duration) {
+	s.users.SetAccessTokenTTL(ttl)
 }
 
 type UserSignUpInput struct {
@@ -65,6 +278,15 @@ type UserSignUpInput struct {
 	Password string
 }
 
+// UserCreateInput describes a user created by an administrator,
+// bypassing the sign up verification.
+type UserCreateInput struct {
+	Username string
+	Password string
+	Role     string
+	Verified bool
+}
+
 type UserSignInInput struct {
 	Username string
 	Password string
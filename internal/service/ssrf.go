@@ -0,0 +1,81 @@
+package service
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"syscall"
+
+	"github.com/ernur-eskermes/crud-app/internal/core"
+)
+
+// nonPublicNetworks are the special purpose ranges not covered by the net.IP predicates used in publicIP.
+var nonPublicNetworks = parseCIDRs(
+	"0.0.0.0/8",     // "this" network
+	"100.64.0.0/10", // carrier-grade NAT
+	"192.0.0.0/24",  // IETF protocol assignments
+	"198.18.0.0/15", // benchmarking
+	"64:ff9b::/96",  // NAT64, maps to any IPv4 address
+)
+
+// publicIP reports whether the address is a public unicast one. Loopback, private, link-local (including
+// the cloud metadata endpoints at 169.254.169.254 and fd00:ec2::254), multicast and unspecified addresses are not.
+func publicIP(ip net.IP) bool {
+	if ip == nil || ip.IsLoopback() || ip.IsPrivate() || ip.IsUnspecified() || ip.IsMulticast() ||
+		ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() || ip.IsInterfaceLocalMulticast() {
+		return false
+	}
+
+	for _, network := range nonPublicNetworks {
+		if network.Contains(ip) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// checkWebhookURL returns core.ErrWebhookURLInvalid unless the URL is an absolute http(s) URL
+// without credentials, whose host, when an IP address, is public. Host names are checked once resolved.
+func checkWebhookURL(rawURL string, allowPrivateNetworks bool) error {
+	u, err := url.Parse(rawURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Hostname() == "" || u.User != nil {
+		return core.ErrWebhookURLInvalid
+	}
+
+	if ip := net.ParseIP(u.Hostname()); ip != nil && !allowPrivateNetworks && !publicIP(ip) {
+		return core.ErrWebhookURLInvalid
+	}
+
+	return nil
+}
+
+// dialPublicOnly is the net.Dialer Control of the webhook requests: it runs after the host name is resolved,
+// before connecting, so a name resolving to an internal address is refused as well.
+func dialPublicOnly(network, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+
+	if !publicIP(net.ParseIP(host)) {
+		return fmt.Errorf("%s %s: %w", network, address, core.ErrWebhookAddressForbidden)
+	}
+
+	return nil
+}
+
+func parseCIDRs(cidrs ...string) []*net.IPNet {
+	networks := make([]*net.IPNet, 0, len(cidrs))
+
+	for _, cidr := range cidrs {
+		_, network, err := net.ParseCIDR(cidr)
+		if err != nil {
+			panic(err)
+		}
+
+		networks = append(networks, network)
+	}
+
+	return networks
+}
@@ -0,0 +1,198 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"time"
+
+	"github.com/ernur-eskermes/crud-app/internal/core"
+)
+
+// BookEvent is a book event as streamed to the subscribers, Data is the encoded core.Event.
+type BookEvent struct {
+	ID   int64
+	Type string
+	Book core.Book
+	Data []byte
+}
+
+// BookStreamPolicy sets how many of the latest events are kept for the subscribers resuming a stream,
+// how many events may wait for a subscriber before it is dropped, and how often idle streams are pinged.
+type BookStreamPolicy struct {
+	ReplaySize int
+	BufferSize int
+	Heartbeat  time.Duration
+}
+
+// BookSubscription is a stream of book events. Events is closed when the subscriber falls
+// BufferSize events behind, or the stream is closed; the subscriber then resumes after the last event it got.
+type BookSubscription struct {
+	// Missed is set when the events after the last event id are no longer kept, the subscriber
+	// has to reload the books instead.
+	Missed    bool
+	Heartbeat time.Duration
+
+	filter  core.BookStreamFilter
+	events  chan BookEvent
+	service *BookStreamService
+}
+
+func (s *BookSubscription) Events() <-chan BookEvent {
+	return s.events
+}
+
+// Close unsubscribes, it may be called more than once.
+func (s *BookSubscription) Close() {
+	s.service.unsubscribe(s)
+}
+
+// BookStreamService fans the book events out to the subscribers of the instance and keeps
+// the latest ones so that reconnecting subscribers resume where they stopped. Every instance
+// gets all book events from the EventBroadcaster, the event ids are those of the outbox.
+type BookStreamService struct {
+	policy BookStreamPolicy
+
+	mu          sync.Mutex
+	replay      []BookEvent
+	subscribers map[*BookSubscription]struct{}
+	closed      bool
+}
+
+func NewBookStreamService(policy BookStreamPolicy) *BookStreamService {
+	return &BookStreamService{
+		policy:      policy,
+		replay:      make([]BookEvent, 0, policy.ReplaySize),
+		subscribers: make(map[*BookSubscription]struct{}),
+	}
+}
+
+// Subscribe streams the book events matching the filter. When lastEventID is set, the kept events
+// after it are sent first.
+func (s *BookStreamService) Subscribe(filter core.BookStreamFilter, lastEventID *int64) *BookSubscription {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var replay []BookEvent
+
+	missed := false
+
+	if lastEventID != nil {
+		// Events are kept in the order they are published, which is not always the order of their ids.
+		missed = true
+
+		for i := len(s.replay) - 1; i >= 0; i-- {
+			if s.replay[i].ID == *lastEventID {
+				replay = s.replay[i+1:]
+				missed = false
+
+				break
+			}
+		}
+	}
+
+	sub := &BookSubscription{
+		Missed:    missed,
+		Heartbeat: s.policy.Heartbeat,
+		filter:    filter,
+		events:    make(chan BookEvent, len(replay)+s.policy.BufferSize),
+		service:   s,
+	}
+
+	for _, event := range replay {
+		if filter.Match(event.Book) {
+			sub.events <- event
+		}
+	}
+
+	if s.closed {
+		close(sub.events)
+
+		return sub
+	}
+
+	s.subscribers[sub] = struct{}{}
+	bookStreamSubscribers.Inc()
+
+	return sub
+}
+
+// HandleBookEvent passes a book event to the subscribers. An event published again is passed once.
+func (s *BookStreamService) HandleBookEvent(ctx context.Context, event core.Event) error {
+	var book core.Book
+	if err := json.Unmarshal(event.Data, &book); err != nil {
+		return err
+	}
+
+	data, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, kept := range s.replay {
+		if kept.ID == event.ID {
+			return nil
+		}
+	}
+
+	bookEvent := BookEvent{
+		ID:   event.ID,
+		Type: event.Type,
+		Book: book,
+		Data: data,
+	}
+
+	if len(s.replay) == s.policy.ReplaySize {
+		copy(s.replay, s.replay[1:])
+		s.replay = s.replay[:len(s.replay)-1]
+	}
+
+	s.replay = append(s.replay, bookEvent)
+
+	for sub := range s.subscribers {
+		if !sub.filter.Match(book) {
+			continue
+		}
+
+		// A subscriber that doesn't keep up is dropped rather than holding up the others.
+		select {
+		case sub.events <- bookEvent:
+		default:
+			s.remove(sub)
+			bookStreamDroppedTotal.Inc()
+		}
+	}
+
+	return nil
+}
+
+// Close ends the streams of all subscribers and refuses new ones, the subscribers reconnect to another instance.
+func (s *BookStreamService) Close() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.closed = true
+
+	for sub := range s.subscribers {
+		s.remove(sub)
+	}
+}
+
+func (s *BookStreamService) unsubscribe(sub *BookSubscription) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.subscribers[sub]; ok {
+		s.remove(sub)
+	}
+}
+
+// remove must be called with mu held.
+func (s *BookStreamService) remove(sub *BookSubscription) {
+	delete(s.subscribers, sub)
+	close(sub.events)
+	bookStreamSubscribers.Dec()
+}
@@ -0,0 +1,125 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/google/uuid"
+
+	"github.com/ernur-eskermes/crud-app/internal/core"
+)
+
+// fakeFeed passes the latest recent of its events to handle, then stops the broadcaster.
+type fakeFeed struct {
+	events []core.Event
+	cancel context.CancelFunc
+}
+
+func (f fakeFeed) Listen(ctx context.Context, _ []string, recent int,
+	handle func(ctx context.Context, event core.Event) error,
+) error {
+	events := f.events
+	if len(events) > recent {
+		events = events[len(events)-recent:]
+	}
+
+	for _, event := range events {
+		if err := handle(ctx, event); err != nil {
+			return err
+		}
+	}
+
+	f.cancel()
+
+	return nil
+}
+
+func newBookEvent(t *testing.T, id int64, book core.Book) core.Event {
+	t.Helper()
+
+	data, err := json.Marshal(book)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return core.Event{ID: id, Type: core.EventBookUpdated, AggregateID: book.ID, Data: data}
+}
+
+// runStream returns a stream of book events of an instance, fed the events by an EventBroadcaster.
+func runStream(t *testing.T, replaySize int, events []core.Event) *BookStreamService {
+	t.Helper()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	stream := NewBookStreamService(BookStreamPolicy{ReplaySize: replaySize, BufferSize: 10})
+	broadcaster := NewEventBroadcaster(fakeFeed{events: events, cancel: cancel}, replaySize)
+	broadcaster.Subscribe(stream.HandleBookEvent, core.EventBookUpdated)
+
+	if err := broadcaster.Run(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	return stream
+}
+
+func TestBookStreamResumesOnAnyInstance(t *testing.T) {
+	book := core.Book{ID: uuid.New(), Author: uuid.New()}
+
+	events := []core.Event{
+		newBookEvent(t, 1, book),
+		newBookEvent(t, 2, book),
+		newBookEvent(t, 3, book),
+		newBookEvent(t, 4, book),
+	}
+
+	// The instance started after the first events were published keeps the same latest ones.
+	first := runStream(t, 3, events)
+	second := runStream(t, 3, events[2:])
+
+	tests := []struct {
+		name        string
+		stream      *BookStreamService
+		lastEventID *int64
+		wantMissed  bool
+		wantIDs     []int64
+	}{
+		{name: "new subscriber", stream: second},
+		{name: "resumes on the instance it was on", stream: first, lastEventID: int64Ptr(2), wantIDs: []int64{3, 4}},
+		{name: "resumes on another instance", stream: second, lastEventID: int64Ptr(3), wantIDs: []int64{4}},
+		{name: "up to date", stream: second, lastEventID: int64Ptr(4)},
+		{name: "event no longer kept", stream: first, lastEventID: int64Ptr(1), wantMissed: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sub := tt.stream.Subscribe(core.BookStreamFilter{}, tt.lastEventID)
+			defer sub.Close()
+
+			if sub.Missed != tt.wantMissed {
+				t.Errorf("Missed = %v, want %v", sub.Missed, tt.wantMissed)
+			}
+
+			var ids []int64
+
+			for len(sub.Events()) > 0 {
+				ids = append(ids, (<-sub.Events()).ID)
+			}
+
+			if len(ids) != len(tt.wantIDs) {
+				t.Fatalf("replayed %v, want %v", ids, tt.wantIDs)
+			}
+
+			for i := range ids {
+				if ids[i] != tt.wantIDs[i] {
+					t.Fatalf("replayed %v, want %v", ids, tt.wantIDs)
+				}
+			}
+		})
+	}
+}
+
+func int64Ptr(v int64) *int64 {
+	return &v
+}
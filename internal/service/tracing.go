@@ -0,0 +1,484 @@
+package service
+
+import (
+	"context"
+	"io"
+	"time"
+
+	"github.com/duo-labs/webauthn/protocol"
+	"github.com/ernur-eskermes/crud-app/internal/core"
+	"github.com/ernur-eskermes/crud-app/pkg/auth"
+	"github.com/google/uuid"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const tracerName = "github.com/ernur-eskermes/crud-app/internal/service"
+
+// booksTracing records a span for every Books method.
+type booksTracing struct {
+	next   Books
+	tracer trace.Tracer
+}
+
+func newBooksTracing(next Books) *booksTracing {
+	return &booksTracing{
+		next:   next,
+		tracer: otel.Tracer(tracerName),
+	}
+}
+
+func (b *booksTracing) Create(ctx context.Context, book core.CreateBookInput, userID uuid.UUID) (err error) {
+	ctx, span := b.tracer.Start(ctx, "BooksService.Create")
+	defer func() { endSpan(span, err) }()
+
+	return b.next.Create(ctx, book, userID)
+}
+
+func (b *booksTracing) GetByID(ctx context.Context, id uuid.UUID) (_ core.Book, err error) {
+	ctx, span := b.tracer.Start(ctx, "BooksService.GetByID")
+	defer func() { endSpan(span, err) }()
+
+	return b.next.GetByID(ctx, id)
+}
+
+func (b *booksTracing) GetAll(ctx context.Context) (_ []core.Book, err error) {
+	ctx, span := b.tracer.Start(ctx, "BooksService.GetAll")
+	defer func() { endSpan(span, err) }()
+
+	return b.next.GetAll(ctx)
+}
+
+func (b *booksTracing) Search(ctx context.Context, query core.SearchBooksQuery) (_ []core.Book, err error) {
+	ctx, span := b.tracer.Start(ctx, "BooksService.Search")
+	defer func() { endSpan(span, err) }()
+
+	return b.next.Search(ctx, query)
+}
+
+func (b *booksTracing) Delete(ctx context.Context, id, userID uuid.UUID) (err error) {
+	ctx, span := b.tracer.Start(ctx, "BooksService.Delete")
+	defer func() { endSpan(span, err) }()
+
+	return b.next.Delete(ctx, id, userID)
+}
+
+func (b *booksTracing) Update(ctx context.Context, id, userID uuid.UUID, inp core.UpdateBookInput) (err error) {
+	ctx, span := b.tracer.Start(ctx, "BooksService.Update")
+	defer func() { endSpan(span, err) }()
+
+	return b.next.Update(ctx, id, userID, inp)
+}
+
+func (b *booksTracing) LastModified(ctx context.Context) (_ time.Time, err error) {
+	ctx, span := b.tracer.Start(ctx, "BooksService.LastModified")
+	defer func() { endSpan(span, err) }()
+
+	return b.next.LastModified(ctx)
+}
+
+// usersTracing records a span for every Users method.
+type usersTracing struct {
+	next   Users
+	tracer trace.Tracer
+}
+
+func newUsersTracing(next Users) *usersTracing {
+	return &usersTracing{
+		next:   next,
+		tracer: otel.Tracer(tracerName),
+	}
+}
+
+func (u *usersTracing) SignUp(ctx context.Context, input UserSignUpInput) (err error) {
+	ctx, span := u.tracer.Start(ctx, "UsersService.SignUp")
+	defer func() { endSpan(span, err) }()
+
+	return u.next.SignUp(ctx, input)
+}
+
+func (u *usersTracing) SignIn(ctx context.Context, input UserSignInInput) (_ Tokens, err error) {
+	ctx, span := u.tracer.Start(ctx, "UsersService.SignIn")
+	defer func() { endSpan(span, err) }()
+
+	return u.next.SignIn(ctx, input)
+}
+
+func (u *usersTracing) GetByID(ctx context.Context, id uuid.UUID) (_ core.User, err error) {
+	ctx, span := u.tracer.Start(ctx, "UsersService.GetByID")
+	defer func() { endSpan(span, err) }()
+
+	return u.next.GetByID(ctx, id)
+}
+
+func (u *usersTracing) GetByIDs(ctx context.Context, ids []uuid.UUID) (_ []core.User, err error) {
+	ctx, span := u.tracer.Start(ctx, "UsersService.GetByIDs")
+	defer func() { endSpan(span, err) }()
+
+	return u.next.GetByIDs(ctx, ids)
+}
+
+func (u *usersTracing) GetByUsername(ctx context.Context, username string) (_ core.User, err error) {
+	ctx, span := u.tracer.Start(ctx, "UsersService.GetByUsername")
+	defer func() { endSpan(span, err) }()
+
+	return u.next.GetByUsername(ctx, username)
+}
+
+func (u *usersTracing) Create(ctx context.Context, input UserCreateInput) (_ core.User, err error) {
+	ctx, span := u.tracer.Start(ctx, "UsersService.Create")
+	defer func() { endSpan(span, err) }()
+
+	return u.next.Create(ctx, input)
+}
+
+func (u *usersTracing) MarkVerified(ctx context.Context, username string) (err error) {
+	ctx, span := u.tracer.Start(ctx, "UsersService.MarkVerified")
+	defer func() { endSpan(span, err) }()
+
+	return u.next.MarkVerified(ctx, username)
+}
+
+func (u *usersTracing) Promote(ctx context.Context, username string) (err error) {
+	ctx, span := u.tracer.Start(ctx, "UsersService.Promote")
+	defer func() { endSpan(span, err) }()
+
+	return u.next.Promote(ctx, username)
+}
+
+func (u *usersTracing) Unlock(ctx context.Context, username string) (err error) {
+	ctx, span := u.tracer.Start(ctx, "UsersService.Unlock")
+	defer func() { endSpan(span, err) }()
+
+	return u.next.Unlock(ctx, username)
+}
+
+func (u *usersTracing) Verify(ctx context.Context, username, code string) (err error) {
+	ctx, span := u.tracer.Start(ctx, "UsersService.Verify")
+	defer func() { endSpan(span, err) }()
+
+	return u.next.Verify(ctx, username, code)
+}
+
+// apiKeysTracing records a span for every APIKeys method.
+type apiKeysTracing struct {
+	next   APIKeys
+	tracer trace.Tracer
+}
+
+func newAPIKeysTracing(next APIKeys) *apiKeysTracing {
+	return &apiKeysTracing{
+		next:   next,
+		tracer: otel.Tracer(tracerName),
+	}
+}
+
+func (a *apiKeysTracing) Create(ctx context.Context, userID uuid.UUID, input core.CreateAPIKeyInput) (_ core.APIKey, _ string, err error) {
+	ctx, span := a.tracer.Start(ctx, "APIKeysService.Create")
+	defer func() { endSpan(span, err) }()
+
+	return a.next.Create(ctx, userID, input)
+}
+
+func (a *apiKeysTracing) List(ctx context.Context, userID uuid.UUID) (_ []core.APIKey, err error) {
+	ctx, span := a.tracer.Start(ctx, "APIKeysService.List")
+	defer func() { endSpan(span, err) }()
+
+	return a.next.List(ctx, userID)
+}
+
+func (a *apiKeysTracing) Revoke(ctx context.Context, userID, id uuid.UUID) (err error) {
+	ctx, span := a.tracer.Start(ctx, "APIKeysService.Revoke")
+	defer func() { endSpan(span, err) }()
+
+	return a.next.Revoke(ctx, userID, id)
+}
+
+func (a *apiKeysTracing) Authenticate(ctx context.Context, rawKey string) (_ auth.Claims, err error) {
+	ctx, span := a.tracer.Start(ctx, "APIKeysService.Authenticate")
+	defer func() { endSpan(span, err) }()
+
+	return a.next.Authenticate(ctx, rawKey)
+}
+
+// oidcTracing records a span for the OIDC login methods.
+type oidcTracing struct {
+	next   OIDC
+	tracer trace.Tracer
+}
+
+func newOIDCTracing(next OIDC) *oidcTracing {
+	return &oidcTracing{
+		next:   next,
+		tracer: otel.Tracer(tracerName),
+	}
+}
+
+func (o *oidcTracing) Providers() []string {
+	return o.next.Providers()
+}
+
+func (o *oidcTracing) AuthURL(ctx context.Context, provider string, linkTo uuid.UUID) (_ OIDCLogin, err error) {
+	ctx, span := o.tracer.Start(ctx, "OIDCService.AuthURL", trace.WithAttributes(attribute.String("provider", provider)))
+	defer func() { endSpan(span, err) }()
+
+	return o.next.AuthURL(ctx, provider, linkTo)
+}
+
+func (o *oidcTracing) Callback(ctx context.Context, provider, state, browserKey, code string) (_ Tokens, err error) {
+	ctx, span := o.tracer.Start(ctx, "OIDCService.Callback", trace.WithAttributes(attribute.String("provider", provider)))
+	defer func() { endSpan(span, err) }()
+
+	return o.next.Callback(ctx, provider, state, browserKey, code)
+}
+
+// webAuthnTracing records a span for every WebAuthn method.
+type webAuthnTracing struct {
+	next   WebAuthn
+	tracer trace.Tracer
+}
+
+func newWebAuthnTracing(next WebAuthn) *webAuthnTracing {
+	return &webAuthnTracing{
+		next:   next,
+		tracer: otel.Tracer(tracerName),
+	}
+}
+
+func (w *webAuthnTracing) BeginRegistration(ctx context.Context, userID uuid.UUID) (_ *protocol.CredentialCreation, err error) {
+	ctx, span := w.tracer.Start(ctx, "WebAuthnService.BeginRegistration")
+	defer func() { endSpan(span, err) }()
+
+	return w.next.BeginRegistration(ctx, userID)
+}
+
+func (w *webAuthnTracing) FinishRegistration(ctx context.Context, userID uuid.UUID, name string, response io.Reader,
+) (_ core.WebAuthnCredential, err error) {
+	ctx, span := w.tracer.Start(ctx, "WebAuthnService.FinishRegistration")
+	defer func() { endSpan(span, err) }()
+
+	return w.next.FinishRegistration(ctx, userID, name, response)
+}
+
+func (w *webAuthnTracing) BeginLogin(ctx context.Context, username, secondFactorToken string,
+) (_ string, _ *protocol.CredentialAssertion, err error) {
+	ctx, span := w.tracer.Start(ctx, "WebAuthnService.BeginLogin",
+		trace.WithAttributes(attribute.Bool("second_factor", secondFactorToken != "")))
+	defer func() { endSpan(span, err) }()
+
+	return w.next.BeginLogin(ctx, username, secondFactorToken)
+}
+
+func (w *webAuthnTracing) FinishLogin(ctx context.Context, loginID string, response io.Reader) (_ Tokens, err error) {
+	ctx, span := w.tracer.Start(ctx, "WebAuthnService.FinishLogin")
+	defer func() { endSpan(span, err) }()
+
+	return w.next.FinishLogin(ctx, loginID, response)
+}
+
+func (w *webAuthnTracing) GetCredentials(ctx context.Context, userID uuid.UUID) (_ []core.WebAuthnCredential, err error) {
+	ctx, span := w.tracer.Start(ctx, "WebAuthnService.GetCredentials")
+	defer func() { endSpan(span, err) }()
+
+	return w.next.GetCredentials(ctx, userID)
+}
+
+func (w *webAuthnTracing) DeleteCredential(ctx context.Context, userID, id uuid.UUID) (err error) {
+	ctx, span := w.tracer.Start(ctx, "WebAuthnService.DeleteCredential")
+	defer func() { endSpan(span, err) }()
+
+	return w.next.DeleteCredential(ctx, userID, id)
+}
+
+// oauthTracing records a span for every OAuth method.
+type oauthTracing struct {
+	next   OAuth
+	tracer trace.Tracer
+}
+
+func newOAuthTracing(next OAuth) *oauthTracing {
+	return &oauthTracing{
+		next:   next,
+		tracer: otel.Tracer(tracerName),
+	}
+}
+
+func (o *oauthTracing) CreateClient(ctx context.Context, input core.CreateOAuthClientInput) (_ core.OAuthClient, _ string, err error) {
+	ctx, span := o.tracer.Start(ctx, "OAuthService.CreateClient")
+	defer func() { endSpan(span, err) }()
+
+	return o.next.CreateClient(ctx, input)
+}
+
+func (o *oauthTracing) GetClients(ctx context.Context) (_ []core.OAuthClient, err error) {
+	ctx, span := o.tracer.Start(ctx, "OAuthService.GetClients")
+	defer func() { endSpan(span, err) }()
+
+	return o.next.GetClients(ctx)
+}
+
+func (o *oauthTracing) DeleteClient(ctx context.Context, id uuid.UUID) (err error) {
+	ctx, span := o.tracer.Start(ctx, "OAuthService.DeleteClient")
+	defer func() { endSpan(span, err) }()
+
+	return o.next.DeleteClient(ctx, id)
+}
+
+func (o *oauthTracing) Authorize(ctx context.Context, userID uuid.UUID, req AuthorizeRequest) (_ string, err error) {
+	ctx, span := o.tracer.Start(ctx, "OAuthService.Authorize")
+	defer func() { endSpan(span, err) }()
+
+	return o.next.Authorize(ctx, userID, req)
+}
+
+func (o *oauthTracing) Token(ctx context.Context, creds ClientCredentials, req TokenRequest) (_ TokenResponse, err error) {
+	ctx, span := o.tracer.Start(ctx, "OAuthService.Token")
+	defer func() { endSpan(span, err) }()
+
+	return o.next.Token(ctx, creds, req)
+}
+
+func (o *oauthTracing) Introspect(ctx context.Context, creds ClientCredentials, token string) (_ Introspection, err error) {
+	ctx, span := o.tracer.Start(ctx, "OAuthService.Introspect")
+	defer func() { endSpan(span, err) }()
+
+	return o.next.Introspect(ctx, creds, token)
+}
+
+func (o *oauthTracing) Revoke(ctx context.Context, creds ClientCredentials, token string) (err error) {
+	ctx, span := o.tracer.Start(ctx, "OAuthService.Revoke")
+	defer func() { endSpan(span, err) }()
+
+	return o.next.Revoke(ctx, creds, token)
+}
+
+func (o *oauthTracing) CheckGrant(ctx context.Context, claims auth.Claims) (err error) {
+	ctx, span := o.tracer.Start(ctx, "OAuthService.CheckGrant")
+	defer func() { endSpan(span, err) }()
+
+	return o.next.CheckGrant(ctx, claims)
+}
+
+func (o *oauthTracing) GetConsents(ctx context.Context, userID uuid.UUID) (_ []core.OAuthConsent, err error) {
+	ctx, span := o.tracer.Start(ctx, "OAuthService.GetConsents")
+	defer func() { endSpan(span, err) }()
+
+	return o.next.GetConsents(ctx, userID)
+}
+
+func (o *oauthTracing) RevokeConsent(ctx context.Context, userID, clientID uuid.UUID) (err error) {
+	ctx, span := o.tracer.Start(ctx, "OAuthService.RevokeConsent")
+	defer func() { endSpan(span, err) }()
+
+	return o.next.RevokeConsent(ctx, userID, clientID)
+}
+
+// webhooksTracing records a span for every Webhooks method.
+type webhooksTracing struct {
+	next   Webhooks
+	tracer trace.Tracer
+}
+
+func newWebhooksTracing(next Webhooks) *webhooksTracing {
+	return &webhooksTracing{
+		next:   next,
+		tracer: otel.Tracer(tracerName),
+	}
+}
+
+func (w *webhooksTracing) Create(ctx context.Context, userID uuid.UUID, input core.CreateWebhookInput,
+) (_ core.Webhook, _ string, err error) {
+	ctx, span := w.tracer.Start(ctx, "WebhooksService.Create")
+	defer func() { endSpan(span, err) }()
+
+	return w.next.Create(ctx, userID, input)
+}
+
+func (w *webhooksTracing) List(ctx context.Context, userID uuid.UUID) (_ []core.Webhook, err error) {
+	ctx, span := w.tracer.Start(ctx, "WebhooksService.List")
+	defer func() { endSpan(span, err) }()
+
+	return w.next.List(ctx, userID)
+}
+
+func (w *webhooksTracing) Update(ctx context.Context, userID, id uuid.UUID, input core.UpdateWebhookInput,
+) (_ core.Webhook, err error) {
+	ctx, span := w.tracer.Start(ctx, "WebhooksService.Update")
+	defer func() { endSpan(span, err) }()
+
+	return w.next.Update(ctx, userID, id, input)
+}
+
+func (w *webhooksTracing) Delete(ctx context.Context, userID, id uuid.UUID) (err error) {
+	ctx, span := w.tracer.Start(ctx, "WebhooksService.Delete")
+	defer func() { endSpan(span, err) }()
+
+	return w.next.Delete(ctx, userID, id)
+}
+
+func (w *webhooksTracing) GetDeliveries(ctx context.Context, userID, id uuid.UUID) (_ []core.WebhookDelivery, err error) {
+	ctx, span := w.tracer.Start(ctx, "WebhooksService.GetDeliveries")
+	defer func() { endSpan(span, err) }()
+
+	return w.next.GetDeliveries(ctx, userID, id)
+}
+
+func (w *webhooksTracing) Replay(ctx context.Context, userID, id, deliveryID uuid.UUID) (_ core.WebhookDelivery, err error) {
+	ctx, span := w.tracer.Start(ctx, "WebhooksService.Replay")
+	defer func() { endSpan(span, err) }()
+
+	return w.next.Replay(ctx, userID, id, deliveryID)
+}
+
+func endSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+
+	span.End()
+}
+
+// jobsTracing records a span for every Jobs method.
+type jobsTracing struct {
+	next   Jobs
+	tracer trace.Tracer
+}
+
+func newJobsTracing(next Jobs) *jobsTracing {
+	return &jobsTracing{
+		next:   next,
+		tracer: otel.Tracer(tracerName),
+	}
+}
+
+func (j *jobsTracing) Enqueue(ctx context.Context, jobType string, payload interface{}, runAt time.Time,
+) (_ core.Job, err error) {
+	ctx, span := j.tracer.Start(ctx, "JobsService.Enqueue", trace.WithAttributes(attribute.String("job.type", jobType)))
+	defer func() { endSpan(span, err) }()
+
+	return j.next.Enqueue(ctx, jobType, payload, runAt)
+}
+
+func (j *jobsTracing) Get(ctx context.Context, id uuid.UUID) (_ core.Job, err error) {
+	ctx, span := j.tracer.Start(ctx, "JobsService.Get")
+	defer func() { endSpan(span, err) }()
+
+	return j.next.Get(ctx, id)
+}
+
+func (j *jobsTracing) List(ctx context.Context, query core.JobsQuery) (_ []core.Job, err error) {
+	ctx, span := j.tracer.Start(ctx, "JobsService.List")
+	defer func() { endSpan(span, err) }()
+
+	return j.next.List(ctx, query)
+}
+
+func (j *jobsTracing) Retry(ctx context.Context, id uuid.UUID) (_ core.Job, err error) {
+	ctx, span := j.tracer.Start(ctx, "JobsService.Retry")
+	defer func() { endSpan(span, err) }()
+
+	return j.next.Retry(ctx, id)
+}
@@ -3,7 +3,7 @@ package service
 import (
 	"context"
 	"errors"
-	"fmt"
+	"sync/atomic"
 	"time"
 
 	"github.com/ernur-eskermes/crud-app/pkg/otp"
@@ -14,41 +14,63 @@ import (
 	"github.com/ernur-eskermes/crud-app/internal/core"
 	"github.com/ernur-eskermes/crud-app/pkg/auth"
 	"github.com/ernur-eskermes/crud-app/pkg/hash"
+	"github.com/ernur-eskermes/crud-app/pkg/logging"
 )
 
 type UsersRepository interface {
 	Create(ctx context.Context, user *core.User) error
 	GetByCredentials(ctx context.Context, email, password string) (core.User, error)
 	GetByID(ctx context.Context, id uuid.UUID) (core.User, error)
+	GetByIDs(ctx context.Context, ids []uuid.UUID) ([]core.User, error)
+	GetByUsername(ctx context.Context, username string) (core.User, error)
 	Verify(ctx context.Context, username string) error
+	SetRole(ctx context.Context, username, role string) error
 }
 
 type UsersService struct {
+	// accessTokenTTL is a time.Duration changed at runtime, accessed atomically.
+	// It is the first field to keep it 64-bit aligned.
+	accessTokenTTL int64
+
 	repo         UsersRepository
 	hasher       hash.PasswordHasher
 	tokenManager auth.TokenManager
 	cache        cache.Cache
 	otpGenerator otp.Generator
-
-	accessTokenTTL time.Duration
+	lockout      *Lockout
+	// secondFactor confirms password sign ins of users with passkeys, set by NewServices.
+	secondFactor *WebAuthnService
+	// logCodes logs the verification codes, which are not sent otherwise, outside of production.
+	// Set by NewServices.
+	logCodes bool
 
 	domain string
 }
 
 func NewUsersService(repo UsersRepository, hasher hash.PasswordHasher, tokenManager auth.TokenManager,
-	accessTTL time.Duration, domain string, cache cache.Cache, otpGenerator otp.Generator,
+	accessTTL time.Duration, domain string, cache cache.Cache, otpGenerator otp.Generator, lockout *Lockout,
 ) *UsersService {
 	return &UsersService{
 		repo:           repo,
 		hasher:         hasher,
 		tokenManager:   tokenManager,
-		accessTokenTTL: accessTTL,
+		accessTokenTTL: int64(accessTTL),
 		domain:         domain,
 		cache:          cache,
 		otpGenerator:   otpGenerator,
+		lockout:        lockout,
 	}
 }
 
+// SetAccessTokenTTL changes the lifetime of access tokens issued from now on.
+func (s *UsersService) SetAccessTokenTTL(ttl time.Duration) {
+	atomic.StoreInt64(&s.accessTokenTTL, int64(ttl))
+}
+
+func (s *UsersService) accessTTL() time.Duration {
+	return time.Duration(atomic.LoadInt64(&s.accessTokenTTL))
+}
+
 func (s *UsersService) SignUp(ctx context.Context, input UserSignUpInput) error {
 	passwordHash, err := s.hasher.Hash(input.Password)
 	if err != nil {
@@ -57,7 +79,10 @@ func (s *UsersService) SignUp(ctx context.Context, input UserSignUpInput) error
 
 	code := s.otpGenerator.RandomSecret(6)
 	s.cache.Set(input.Username, code, 10*time.Minute)
-	fmt.Println(code)
+
+	if s.logCodes {
+		logging.FromContext(ctx).WithField("username", input.Username).Debugf("verification code: %s", code)
+	}
 
 	return s.repo.Create(ctx, &core.User{
 		Username: input.Username,
@@ -66,6 +91,10 @@ func (s *UsersService) SignUp(ctx context.Context, input UserSignUpInput) error
 }
 
 func (s *UsersService) Verify(ctx context.Context, username, code string) error {
+	if err := s.lockout.Check(ctx, username); err != nil {
+		return err
+	}
+
 	c, err := s.cache.Get(username)
 	if err != nil {
 		return core.ErrUserCodeExpired
@@ -77,15 +106,23 @@ func (s *UsersService) Verify(ctx context.Context, username, code string) error
 	}
 
 	if v != code {
-		return core.ErrUserCodeIncorrect
+		return s.failAttempt(ctx, username, core.ErrUserCodeIncorrect)
 	}
 
 	s.cache.Delete(username)
 
-	return s.repo.Verify(ctx, username)
+	if err = s.repo.Verify(ctx, username); err != nil {
+		return err
+	}
+
+	return s.lockout.Succeed(ctx, username)
 }
 
 func (s *UsersService) SignIn(ctx context.Context, input UserSignInInput) (Tokens, error) {
+	if err := s.lockout.Check(ctx, input.Username); err != nil {
+		return Tokens{}, err
+	}
+
 	passwordHash, err := s.hasher.Hash(input.Password)
 	if err != nil {
 		return Tokens{}, err
@@ -94,26 +131,94 @@ func (s *UsersService) SignIn(ctx context.Context, input UserSignInInput) (Token
 	user, err := s.repo.GetByCredentials(ctx, input.Username, passwordHash)
 	if err != nil {
 		if errors.Is(err, core.ErrUserNotFound) {
-			return Tokens{}, err
+			return Tokens{}, s.failAttempt(ctx, input.Username, err)
 		}
 
 		return Tokens{}, err
 	}
 
-	return s.createSession(user.ID.String())
+	if err = s.lockout.Succeed(ctx, input.Username); err != nil {
+		return Tokens{}, err
+	}
+
+	if s.secondFactor != nil {
+		if err = s.secondFactor.requireSecondFactor(ctx, user); err != nil {
+			return Tokens{}, err
+		}
+	}
+
+	return s.createSession(user)
+}
+
+// Unlock clears the failed attempts of the user, lifting a lockout.
+func (s *UsersService) Unlock(ctx context.Context, username string) error {
+	return s.lockout.Unlock(ctx, username)
+}
+
+// failAttempt counts a failed sign in or verification and returns err.
+func (s *UsersService) failAttempt(ctx context.Context, username string, err error) error {
+	if lockErr := s.lockout.Fail(ctx, username); lockErr != nil {
+		logging.FromContext(ctx).Errorf("failed to count failed attempt: %v", lockErr)
+	}
+
+	return err
 }
 
 func (s *UsersService) GetByID(ctx context.Context, id uuid.UUID) (core.User, error) {
 	return s.repo.GetByID(ctx, id)
 }
 
-func (s *UsersService) createSession(userID string) (Tokens, error) {
+func (s *UsersService) GetByIDs(ctx context.Context, ids []uuid.UUID) ([]core.User, error) {
+	return s.repo.GetByIDs(ctx, ids)
+}
+
+func (s *UsersService) GetByUsername(ctx context.Context, username string) (core.User, error) {
+	return s.repo.GetByUsername(ctx, username)
+}
+
+func (s *UsersService) Create(ctx context.Context, input UserCreateInput) (core.User, error) {
+	passwordHash, err := s.hasher.Hash(input.Password)
+	if err != nil {
+		return core.User{}, err
+	}
+
+	user := core.User{
+		Username: input.Username,
+		Password: passwordHash,
+		Role:     input.Role,
+		IsActive: input.Verified,
+	}
+
+	if err = s.repo.Create(ctx, &user); err != nil {
+		return core.User{}, err
+	}
+
+	return user, nil
+}
+
+// MarkVerified activates the user without checking a verification code.
+func (s *UsersService) MarkVerified(ctx context.Context, username string) error {
+	s.cache.Delete(username)
+
+	return s.repo.Verify(ctx, username)
+}
+
+// Promote grants the admin role to the user.
+func (s *UsersService) Promote(ctx context.Context, username string) error {
+	return s.repo.SetRole(ctx, username, core.RoleAdmin)
+}
+
+func (s *UsersService) createSession(user core.User) (Tokens, error) {
 	var (
 		res Tokens
 		err error
 	)
 
-	res.AccessToken, err = s.tokenManager.NewJWT(userID, s.accessTokenTTL)
+	res.AccessToken, err = s.tokenManager.NewJWT(auth.Claims{
+		Subject:   user.ID.String(),
+		SessionID: uuid.NewString(),
+		Roles:     []string{user.Role},
+	}, s.accessTTL())
 	if err != nil {
 		return res, err
 	}
@@ -0,0 +1,405 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"time"
+
+	"github.com/duo-labs/webauthn/protocol"
+	"github.com/duo-labs/webauthn/webauthn"
+	"github.com/google/uuid"
+
+	"github.com/ernur-eskermes/crud-app/internal/core"
+	"github.com/ernur-eskermes/crud-app/pkg/hash"
+	"github.com/ernur-eskermes/crud-app/pkg/logging"
+)
+
+// webAuthnLoginIDBytes is the length of the ids of passkey logins and second factor tokens.
+const webAuthnLoginIDBytes = 32
+
+type WebAuthnRepository interface {
+	Create(ctx context.Context, credential *core.WebAuthnCredential) error
+	GetByUser(ctx context.Context, userID uuid.UUID) ([]core.WebAuthnCredential, error)
+	Touch(ctx context.Context, id uuid.UUID, signCount uint32) error
+	Delete(ctx context.Context, id, userID uuid.UUID) error
+	CreateSession(ctx context.Context, session core.WebAuthnSession) error
+	ConsumeSession(ctx context.Context, keyHash string) (core.WebAuthnSession, error)
+}
+
+// WebAuthnService registers passkeys and signs users in with them, either instead of
+// the password or as the second factor after it. The challenges and the second factor tokens
+// are stored next to the passkeys, so a ceremony may end on any instance; they are single use
+// and expire after challengeTTL.
+type WebAuthnService struct {
+	webauthn     *webauthn.WebAuthn
+	repo         WebAuthnRepository
+	users        *UsersService
+	hasher       hash.PasswordHasher
+	challengeTTL time.Duration
+}
+
+func NewWebAuthnService(relyingParty *webauthn.WebAuthn, repo WebAuthnRepository, users *UsersService,
+	hasher hash.PasswordHasher, challengeTTL time.Duration,
+) *WebAuthnService {
+	return &WebAuthnService{
+		webauthn:     relyingParty,
+		repo:         repo,
+		users:        users,
+		hasher:       hasher,
+		challengeTTL: challengeTTL,
+	}
+}
+
+// BeginRegistration returns the options for navigator.credentials.create() to register a passkey of the user.
+func (s *WebAuthnService) BeginRegistration(ctx context.Context, userID uuid.UUID) (*protocol.CredentialCreation, error) {
+	user, err := s.webAuthnUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	exclusions := make([]protocol.CredentialDescriptor, 0, len(user.credentials))
+	for _, c := range user.credentials {
+		exclusions = append(exclusions, protocol.CredentialDescriptor{
+			Type:         protocol.PublicKeyCredentialType,
+			CredentialID: c.CredentialID,
+		})
+	}
+
+	options, session, err := s.webauthn.BeginRegistration(user,
+		webauthn.WithExclusions(exclusions),
+		webauthn.WithResidentKeyRequirement(protocol.ResidentKeyRequirementPreferred))
+	if err != nil {
+		return nil, err
+	}
+
+	if err = s.saveSession(ctx, registrationKey(userID), userID, session); err != nil {
+		return nil, err
+	}
+
+	return options, nil
+}
+
+// FinishRegistration verifies the response of navigator.credentials.create() and stores the passkey.
+func (s *WebAuthnService) FinishRegistration(ctx context.Context, userID uuid.UUID, name string, response io.Reader,
+) (core.WebAuthnCredential, error) {
+	sessionUserID, session, err := s.consumeSession(ctx, registrationKey(userID))
+	if err != nil {
+		return core.WebAuthnCredential{}, err
+	}
+
+	if sessionUserID != userID || session == nil {
+		return core.WebAuthnCredential{}, core.ErrWebAuthnSessionInvalid
+	}
+
+	user, err := s.webAuthnUser(ctx, userID)
+	if err != nil {
+		return core.WebAuthnCredential{}, err
+	}
+
+	parsed, err := protocol.ParseCredentialCreationResponseBody(response)
+	if err != nil {
+		return core.WebAuthnCredential{}, webAuthnFailed(ctx, err)
+	}
+
+	created, err := s.webauthn.CreateCredential(user, *session, parsed)
+	if err != nil {
+		return core.WebAuthnCredential{}, webAuthnFailed(ctx, err)
+	}
+
+	credential := core.WebAuthnCredential{
+		UserID:          userID,
+		Name:            name,
+		CredentialID:    created.ID,
+		PublicKey:       created.PublicKey,
+		AttestationType: created.AttestationType,
+		AAGUID:          created.Authenticator.AAGUID,
+		SignCount:       created.Authenticator.SignCount,
+	}
+	if err = s.repo.Create(ctx, &credential); err != nil {
+		return core.WebAuthnCredential{}, err
+	}
+
+	return credential, nil
+}
+
+// BeginLogin starts a passkey login and returns its id along with the options for navigator.credentials.get().
+// The user is identified by the username for a passwordless sign in, which requires user verification,
+// or by the token of core.SecondFactorRequiredError to complete a password sign in.
+func (s *WebAuthnService) BeginLogin(ctx context.Context, username, secondFactorToken string,
+) (string, *protocol.CredentialAssertion, error) {
+	var (
+		user             core.User
+		err              error
+		userVerification = protocol.VerificationPreferred
+	)
+
+	if secondFactorToken != "" {
+		userID, _, consumeErr := s.consumeSession(ctx, secondFactorKey(secondFactorToken))
+		if consumeErr != nil {
+			return "", nil, consumeErr
+		}
+
+		user, err = s.users.GetByID(ctx, userID)
+	} else {
+		if err = s.users.lockout.Check(ctx, username); err != nil {
+			return "", nil, err
+		}
+
+		user, err = s.users.GetByUsername(ctx, username)
+		userVerification = protocol.VerificationRequired
+	}
+
+	if err != nil {
+		if errors.Is(err, core.ErrUserNotFound) {
+			return "", nil, core.ErrCredentialNotFound
+		}
+
+		return "", nil, err
+	}
+
+	webAuthnUser, err := s.webAuthnUser(ctx, user.ID)
+	if err != nil {
+		return "", nil, err
+	}
+
+	if len(webAuthnUser.credentials) == 0 {
+		return "", nil, core.ErrCredentialNotFound
+	}
+
+	options, session, err := s.webauthn.BeginLogin(webAuthnUser, webauthn.WithUserVerification(userVerification))
+	if err != nil {
+		return "", nil, err
+	}
+
+	id, err := randomHex(webAuthnLoginIDBytes)
+	if err != nil {
+		return "", nil, err
+	}
+
+	if err = s.saveSession(ctx, loginKey(id), user.ID, session); err != nil {
+		return "", nil, err
+	}
+
+	return id, options, nil
+}
+
+// FinishLogin verifies the response of navigator.credentials.get() and signs the user in.
+// Failed assertions count towards the lockout of the account.
+func (s *WebAuthnService) FinishLogin(ctx context.Context, loginID string, response io.Reader) (Tokens, error) {
+	// The login is single use, a replayed assertion is rejected.
+	userID, session, err := s.consumeSession(ctx, loginKey(loginID))
+	if err != nil {
+		return Tokens{}, err
+	}
+
+	if session == nil {
+		return Tokens{}, core.ErrWebAuthnSessionInvalid
+	}
+
+	user, err := s.webAuthnUser(ctx, userID)
+	if err != nil {
+		return Tokens{}, err
+	}
+
+	username := user.user.Username
+
+	parsed, err := protocol.ParseCredentialRequestResponseBody(response)
+	if err != nil {
+		return Tokens{}, s.users.failAttempt(ctx, username, webAuthnFailed(ctx, err))
+	}
+
+	validated, err := s.webauthn.ValidateLogin(user, *session, parsed)
+	if err != nil {
+		return Tokens{}, s.users.failAttempt(ctx, username, webAuthnFailed(ctx, err))
+	}
+
+	// A counter that went backwards means the authenticator may have been cloned.
+	if validated.Authenticator.CloneWarning {
+		logging.FromContext(ctx).WithField("user_id", userID).Warn("passkey sign count went backwards")
+
+		return Tokens{}, s.users.failAttempt(ctx, username, core.ErrWebAuthnFailed)
+	}
+
+	for _, c := range user.credentials {
+		if bytes.Equal(c.CredentialID, validated.ID) {
+			if err = s.repo.Touch(ctx, c.ID, validated.Authenticator.SignCount); err != nil {
+				return Tokens{}, err
+			}
+		}
+	}
+
+	if err = s.users.lockout.Succeed(ctx, username); err != nil {
+		return Tokens{}, err
+	}
+
+	return s.users.createSession(user.user)
+}
+
+func (s *WebAuthnService) GetCredentials(ctx context.Context, userID uuid.UUID) ([]core.WebAuthnCredential, error) {
+	return s.repo.GetByUser(ctx, userID)
+}
+
+func (s *WebAuthnService) DeleteCredential(ctx context.Context, userID, id uuid.UUID) error {
+	return s.repo.Delete(ctx, id, userID)
+}
+
+// requireSecondFactor returns core.SecondFactorRequiredError when the user has a passkey,
+// nil otherwise. Passkeys are the only second factor, so they are asked whenever registered.
+func (s *WebAuthnService) requireSecondFactor(ctx context.Context, user core.User) error {
+	credentials, err := s.repo.GetByUser(ctx, user.ID)
+	if err != nil {
+		return err
+	}
+
+	if len(credentials) == 0 {
+		return nil
+	}
+
+	token, err := randomHex(webAuthnLoginIDBytes)
+	if err != nil {
+		return err
+	}
+
+	if err = s.saveSession(ctx, secondFactorKey(token), user.ID, nil); err != nil {
+		return err
+	}
+
+	return &core.SecondFactorRequiredError{Token: token}
+}
+
+// saveSession stores the challenge of a ceremony of the user, or a second factor token when session is nil,
+// under the hash of the key.
+func (s *WebAuthnService) saveSession(ctx context.Context, key string, userID uuid.UUID,
+	session *webauthn.SessionData,
+) error {
+	keyHash, err := s.hasher.Hash(key)
+	if err != nil {
+		return err
+	}
+
+	stored := core.WebAuthnSession{
+		KeyHash:   keyHash,
+		UserID:    userID,
+		ExpiresAt: time.Now().Add(s.challengeTTL),
+	}
+
+	if session != nil {
+		if stored.Data, err = json.Marshal(session); err != nil {
+			return err
+		}
+	}
+
+	return s.repo.CreateSession(ctx, stored)
+}
+
+// consumeSession deletes the session of the key and returns its user and challenge, nil for a second
+// factor token. Unknown and expired sessions are core.ErrWebAuthnSessionInvalid.
+func (s *WebAuthnService) consumeSession(ctx context.Context, key string) (uuid.UUID, *webauthn.SessionData, error) {
+	keyHash, err := s.hasher.Hash(key)
+	if err != nil {
+		return uuid.Nil, nil, err
+	}
+
+	stored, err := s.repo.ConsumeSession(ctx, keyHash)
+	if err != nil {
+		return uuid.Nil, nil, err
+	}
+
+	if time.Now().After(stored.ExpiresAt) {
+		return uuid.Nil, nil, core.ErrWebAuthnSessionInvalid
+	}
+
+	if stored.Data == nil {
+		return stored.UserID, nil, nil
+	}
+
+	var session webauthn.SessionData
+	if err = json.Unmarshal(stored.Data, &session); err != nil {
+		return uuid.Nil, nil, err
+	}
+
+	return stored.UserID, &session, nil
+}
+
+func (s *WebAuthnService) webAuthnUser(ctx context.Context, userID uuid.UUID) (webAuthnUser, error) {
+	user, err := s.users.GetByID(ctx, userID)
+	if err != nil {
+		return webAuthnUser{}, err
+	}
+
+	credentials, err := s.repo.GetByUser(ctx, userID)
+	if err != nil {
+		return webAuthnUser{}, err
+	}
+
+	return webAuthnUser{
+		user:        user,
+		credentials: credentials,
+	}, nil
+}
+
+// webAuthnFailed hides the details of a rejected ceremony from the client, they are logged instead.
+func webAuthnFailed(ctx context.Context, err error) error {
+	var protocolErr *protocol.Error
+	if errors.As(err, &protocolErr) {
+		logging.FromContext(ctx).WithField("details", protocolErr.DevInfo).Info(protocolErr.Details)
+	}
+
+	return core.ErrWebAuthnFailed
+}
+
+// webAuthnUser adapts a user and their passkeys to webauthn.User.
+type webAuthnUser struct {
+	user        core.User
+	credentials []core.WebAuthnCredential
+}
+
+func (u webAuthnUser) WebAuthnID() []byte {
+	id := u.user.ID
+
+	return id[:]
+}
+
+func (u webAuthnUser) WebAuthnName() string {
+	return u.user.Username
+}
+
+func (u webAuthnUser) WebAuthnDisplayName() string {
+	return u.user.Username
+}
+
+func (u webAuthnUser) WebAuthnIcon() string {
+	return ""
+}
+
+func (u webAuthnUser) WebAuthnCredentials() []webauthn.Credential {
+	credentials := make([]webauthn.Credential, 0, len(u.credentials))
+	for _, c := range u.credentials {
+		credentials = append(credentials, webauthn.Credential{
+			ID:              c.CredentialID,
+			PublicKey:       c.PublicKey,
+			AttestationType: c.AttestationType,
+			Authenticator: webauthn.Authenticator{
+				AAGUID:    c.AAGUID,
+				SignCount: c.SignCount,
+			},
+		})
+	}
+
+	return credentials
+}
+
+func registrationKey(userID uuid.UUID) string {
+	return "webauthn:register:" + userID.String()
+}
+
+func loginKey(id string) string {
+	return "webauthn:login:" + id
+}
+
+func secondFactorKey(token string) string {
+	return "webauthn:2fa:" + token
+}
@@ -0,0 +1,109 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/duo-labs/webauthn/webauthn"
+	"github.com/google/uuid"
+
+	"github.com/ernur-eskermes/crud-app/internal/core"
+	"github.com/ernur-eskermes/crud-app/pkg/hash"
+)
+
+type fakeWebAuthnRepo struct {
+	WebAuthnRepository
+	sessions map[string]core.WebAuthnSession
+}
+
+func (r *fakeWebAuthnRepo) CreateSession(_ context.Context, session core.WebAuthnSession) error {
+	r.sessions[session.KeyHash] = session
+
+	return nil
+}
+
+func (r *fakeWebAuthnRepo) ConsumeSession(_ context.Context, keyHash string) (core.WebAuthnSession, error) {
+	session, ok := r.sessions[keyHash]
+	if !ok {
+		return core.WebAuthnSession{}, core.ErrWebAuthnSessionInvalid
+	}
+
+	delete(r.sessions, keyHash)
+
+	return session, nil
+}
+
+func TestWebAuthnServiceSessions(t *testing.T) {
+	userID := uuid.New()
+	challenge := &webauthn.SessionData{Challenge: "challenge", UserID: userID[:]}
+
+	tests := []struct {
+		name    string
+		session *webauthn.SessionData
+		ttl     time.Duration
+		// consume is the key consumed after the session is saved under "key".
+		consume     []string
+		wantErr     error
+		wantSession bool
+	}{
+		{name: "challenge", session: challenge, ttl: time.Minute, consume: []string{"key"}, wantSession: true},
+		{name: "second factor token", ttl: time.Minute, consume: []string{"key"}},
+		{name: "other key", session: challenge, ttl: time.Minute, consume: []string{"other"}, wantErr: core.ErrWebAuthnSessionInvalid},
+		{name: "expired", session: challenge, ttl: -time.Second, consume: []string{"key"}, wantErr: core.ErrWebAuthnSessionInvalid},
+		{
+			name:    "single use",
+			session: challenge,
+			ttl:     time.Minute,
+			consume: []string{"key", "key"},
+			wantErr: core.ErrWebAuthnSessionInvalid,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := &fakeWebAuthnRepo{sessions: make(map[string]core.WebAuthnSession)}
+			s := NewWebAuthnService(nil, repo, nil, hash.NewSHA256Hasher("salt"), tt.ttl)
+			ctx := context.Background()
+
+			if err := s.saveSession(ctx, "key", userID, tt.session); err != nil {
+				t.Fatal(err)
+			}
+
+			if _, ok := repo.sessions["key"]; ok {
+				t.Fatal("the session is stored under the plain key")
+			}
+
+			var (
+				gotUserID  uuid.UUID
+				gotSession *webauthn.SessionData
+				err        error
+			)
+
+			for _, key := range tt.consume {
+				gotUserID, gotSession, err = s.consumeSession(ctx, key)
+			}
+
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("consumeSession() error = %v, want %v", err, tt.wantErr)
+			}
+
+			if err != nil {
+				return
+			}
+
+			if gotUserID != userID {
+				t.Errorf("consumeSession() user = %s, want %s", gotUserID, userID)
+			}
+
+			if (gotSession != nil) != tt.wantSession {
+				t.Fatalf("consumeSession() session = %+v, want one: %v", gotSession, tt.wantSession)
+			}
+
+			if gotSession != nil && gotSession.Challenge != challenge.Challenge {
+				t.Errorf("consumeSession() challenge = %q, want %q", gotSession.Challenge, challenge.Challenge)
+			}
+		})
+	}
+}
@@ -0,0 +1,354 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/ernur-eskermes/crud-app/internal/core"
+	"github.com/ernur-eskermes/crud-app/pkg/logging"
+	"github.com/ernur-eskermes/crud-app/pkg/repeatable"
+)
+
+const (
+	// Headers of webhook requests. The signature is "t=<unix time>,v1=<hex HMAC-SHA256 of "<unix time>.<body>">".
+	WebhookSignatureHeader = "X-Webhook-Signature"
+	WebhookEventHeader     = "X-Webhook-Event"
+	WebhookDeliveryHeader  = "X-Webhook-Delivery"
+
+	webhookSecretBytes = 24
+	// webhookDeliveriesLimit is the number of the latest deliveries kept in the delivery log response.
+	webhookDeliveriesLimit = 100
+	// webhookErrorBodyLimit caps the part of an error response kept in the delivery log.
+	webhookErrorBodyLimit = 512
+)
+
+type WebhooksRepository interface {
+	Create(ctx context.Context, webhook *core.Webhook) error
+	Get(ctx context.Context, id, userID uuid.UUID) (core.Webhook, error)
+	GetByUser(ctx context.Context, userID uuid.UUID) ([]core.Webhook, error)
+	Update(ctx context.Context, webhook core.Webhook) error
+	Delete(ctx context.Context, id, userID uuid.UUID) error
+	CreateDeliveries(ctx context.Context, event core.Event, authorID uuid.UUID, payload []byte) (int, error)
+	ClaimDeliveries(ctx context.Context, limit int, lease time.Duration) ([]core.WebhookDelivery, error)
+	SaveAttempt(ctx context.Context, delivery core.WebhookDelivery, disableAfter int) (bool, error)
+	CreateDelivery(ctx context.Context, delivery *core.WebhookDelivery) error
+	GetDelivery(ctx context.Context, id, webhookID uuid.UUID) (core.WebhookDelivery, error)
+	GetDeliveries(ctx context.Context, webhookID uuid.UUID, limit int) ([]core.WebhookDelivery, error)
+}
+
+// WebhookPolicy sets how deliveries are sent. A failed delivery is retried up to MaxAttempts times,
+// RetryDelay after the first failure doubling up to MaxRetryDelay; a webhook is disabled after
+// DisableAfter failed attempts in a row. Due deliveries are polled every PollInterval, BatchSize at once.
+// Webhooks may only reach public addresses unless AllowPrivateNetworks is set, e.g. for local development.
+type WebhookPolicy struct {
+	Timeout       time.Duration
+	MaxAttempts   int
+	RetryDelay    time.Duration
+	MaxRetryDelay time.Duration
+	DisableAfter  int
+	PollInterval  time.Duration
+	BatchSize     int
+
+	AllowPrivateNetworks bool
+}
+
+// WebhooksService manages the webhooks of users and delivers the book events to them.
+type WebhooksService struct {
+	repo   WebhooksRepository
+	client *http.Client
+	policy WebhookPolicy
+}
+
+func NewWebhooksService(repo WebhooksRepository, policy WebhookPolicy) *WebhooksService {
+	return &WebhooksService{
+		repo:   repo,
+		client: newWebhookClient(policy),
+		policy: policy,
+	}
+}
+
+// newWebhookClient returns the client of the deliveries. It doesn't follow redirects, which count as failures,
+// nor use the proxy of the environment, so that every address it connects to is checked by dialPublicOnly.
+func newWebhookClient(policy WebhookPolicy) *http.Client {
+	dialer := &net.Dialer{
+		Timeout:   policy.Timeout,
+		KeepAlive: 30 * time.Second,
+	}
+
+	if !policy.AllowPrivateNetworks {
+		dialer.Control = dialPublicOnly
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone() //nolint:forcetypeassert
+	transport.Proxy = nil
+	transport.DialContext = dialer.DialContext
+
+	return &http.Client{
+		Timeout:   policy.Timeout,
+		Transport: transport,
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+}
+
+// Create registers a webhook of the user. The returned secret signs the payloads,
+// it is the one of the input or a generated one.
+func (s *WebhooksService) Create(ctx context.Context, userID uuid.UUID, input core.CreateWebhookInput) (core.Webhook, string, error) {
+	if err := checkWebhookURL(input.URL, s.policy.AllowPrivateNetworks); err != nil {
+		return core.Webhook{}, "", err
+	}
+
+	secret := input.Secret
+	if secret == "" {
+		var err error
+		if secret, err = randomHex(webhookSecretBytes); err != nil {
+			return core.Webhook{}, "", err
+		}
+	}
+
+	webhook := core.Webhook{
+		UserID: userID,
+		URL:    input.URL,
+		Events: input.Events,
+		Secret: secret,
+	}
+	if err := s.repo.Create(ctx, &webhook); err != nil {
+		return core.Webhook{}, "", err
+	}
+
+	return webhook, secret, nil
+}
+
+func (s *WebhooksService) List(ctx context.Context, userID uuid.UUID) ([]core.Webhook, error) {
+	return s.repo.GetByUser(ctx, userID)
+}
+
+func (s *WebhooksService) Update(ctx context.Context, userID, id uuid.UUID, input core.UpdateWebhookInput) (core.Webhook, error) {
+	if err := checkWebhookURL(input.URL, s.policy.AllowPrivateNetworks); err != nil {
+		return core.Webhook{}, err
+	}
+
+	webhook, err := s.repo.Get(ctx, id, userID)
+	if err != nil {
+		return core.Webhook{}, err
+	}
+
+	webhook.URL = input.URL
+	webhook.Events = input.Events
+
+	switch {
+	case input.Enabled && webhook.DisabledAt != nil:
+		webhook.DisabledAt = nil
+		webhook.Failures = 0
+	case !input.Enabled && webhook.DisabledAt == nil:
+		now := time.Now()
+		webhook.DisabledAt = &now
+	}
+
+	if err = s.repo.Update(ctx, webhook); err != nil {
+		return core.Webhook{}, err
+	}
+
+	return webhook, nil
+}
+
+func (s *WebhooksService) Delete(ctx context.Context, userID, id uuid.UUID) error {
+	return s.repo.Delete(ctx, id, userID)
+}
+
+// GetDeliveries returns the delivery log of the webhook of the user.
+func (s *WebhooksService) GetDeliveries(ctx context.Context, userID, id uuid.UUID) ([]core.WebhookDelivery, error) {
+	if _, err := s.repo.Get(ctx, id, userID); err != nil {
+		return nil, err
+	}
+
+	return s.repo.GetDeliveries(ctx, id, webhookDeliveriesLimit)
+}
+
+// Replay sends the payload of a logged delivery again, as a new delivery.
+func (s *WebhooksService) Replay(ctx context.Context, userID, id, deliveryID uuid.UUID) (core.WebhookDelivery, error) {
+	if _, err := s.repo.Get(ctx, id, userID); err != nil {
+		return core.WebhookDelivery{}, err
+	}
+
+	delivery, err := s.repo.GetDelivery(ctx, deliveryID, id)
+	if err != nil {
+		return core.WebhookDelivery{}, err
+	}
+
+	replay := core.WebhookDelivery{
+		WebhookID: delivery.WebhookID,
+		Event:     delivery.Event,
+		Payload:   delivery.Payload,
+	}
+	if err = s.repo.CreateDelivery(ctx, &replay); err != nil {
+		return core.WebhookDelivery{}, err
+	}
+
+	return replay, nil
+}
+
+// HandleBookEvent creates the deliveries of a book event. The payload is the event itself.
+func (s *WebhooksService) HandleBookEvent(ctx context.Context, event core.Event) error {
+	var book core.Book
+	if err := json.Unmarshal(event.Data, &book); err != nil {
+		return err
+	}
+
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	_, err = s.repo.CreateDeliveries(ctx, event, book.Author, payload)
+
+	return err
+}
+
+// Run sends the due deliveries until ctx is done. The deliveries being sent are finished before it returns.
+func (s *WebhooksService) Run(ctx context.Context) error {
+	ticker := time.NewTicker(s.policy.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		s.poll(ctx)
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+func (s *WebhooksService) poll(ctx context.Context) {
+	// A claimed delivery is not sent again by anyone before its request times out.
+	deliveries, err := s.repo.ClaimDeliveries(ctx, s.policy.BatchSize, 2*s.policy.Timeout)
+	if err != nil {
+		if ctx.Err() == nil {
+			logging.FromContext(ctx).Errorf("failed to claim webhook deliveries: %v", err)
+		}
+
+		return
+	}
+
+	// The attempts are recorded even when ctx is done meanwhile, the requests themselves are bounded by the timeout.
+	deliverCtx := context.Background()
+
+	var wg sync.WaitGroup
+
+	for _, delivery := range deliveries {
+		wg.Add(1)
+
+		go func(delivery core.WebhookDelivery) {
+			defer wg.Done()
+
+			s.deliver(deliverCtx, delivery)
+		}(delivery)
+	}
+
+	wg.Wait()
+}
+
+// deliver sends the delivery once and records the outcome, scheduling the next attempt on failure.
+func (s *WebhooksService) deliver(ctx context.Context, delivery core.WebhookDelivery) {
+	logger := logging.FromContext(ctx).WithFields(map[string]interface{}{
+		"webhook_id":  delivery.WebhookID,
+		"delivery_id": delivery.ID,
+	})
+
+	now := time.Now()
+	status, err := s.send(ctx, delivery, now)
+
+	delivery.Attempts++
+	delivery.ResponseStatus = status
+
+	if err == nil {
+		delivery.Status = core.DeliverySucceeded
+		delivery.LastError = ""
+		delivery.DeliveredAt = &now
+	} else {
+		delivery.LastError = err.Error()
+		delivery.NextAttemptAt = now.Add(repeatable.Backoff(s.policy.RetryDelay, s.policy.MaxRetryDelay, delivery.Attempts))
+
+		if delivery.Attempts >= s.policy.MaxAttempts {
+			delivery.Status = core.DeliveryFailed
+		}
+	}
+
+	webhookDeliveriesTotal.WithLabelValues(delivery.Event, deliveryOutcome(err)).Inc()
+
+	disabled, err := s.repo.SaveAttempt(ctx, delivery, s.policy.DisableAfter)
+	if err != nil {
+		logger.Errorf("failed to save webhook delivery attempt: %v", err)
+
+		return
+	}
+
+	if disabled {
+		logger.Warnf("webhook disabled after %d failed deliveries in a row", s.policy.DisableAfter)
+	}
+}
+
+// send posts the payload to the webhook, any response other than 2xx is a failure.
+func (s *WebhooksService) send(ctx context.Context, delivery core.WebhookDelivery, now time.Time) (*int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, delivery.URL, bytes.NewReader(delivery.Payload))
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(WebhookEventHeader, delivery.Event)
+	req.Header.Set(WebhookDeliveryHeader, delivery.ID.String())
+	req.Header.Set(WebhookSignatureHeader, SignWebhookPayload(delivery.Secret, now, delivery.Payload))
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	status := resp.StatusCode
+
+	if status < http.StatusOK || status >= http.StatusMultipleChoices {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, webhookErrorBodyLimit))
+
+		return &status, fmt.Errorf("unexpected response status %d: %s", status, body)
+	}
+
+	return &status, nil
+}
+
+// SignWebhookPayload returns the signature header of a payload sent at the time.
+// Receivers recompute the HMAC from the timestamp and the raw body and compare it to v1.
+func SignWebhookPayload(secret string, at time.Time, payload []byte) string {
+	timestamp := strconv.FormatInt(at.Unix(), 10)
+
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp + "."))
+	mac.Write(payload)
+
+	return "t=" + timestamp + ",v1=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+func deliveryOutcome(err error) string {
+	if err != nil {
+		return core.DeliveryFailed
+	}
+
+	return core.DeliverySucceeded
+}
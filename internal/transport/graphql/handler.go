@@ -0,0 +1,99 @@
+package graphql
+
+import (
+	"context"
+	_ "embed"
+	"errors"
+	"strings"
+
+	"github.com/ernur-eskermes/crud-app/internal/service"
+	"github.com/ernur-eskermes/crud-app/internal/transport/rest/problem"
+	"github.com/ernur-eskermes/crud-app/pkg/auth"
+	"github.com/ernur-eskermes/crud-app/pkg/logging"
+	"github.com/go-playground/validator/v10"
+	"github.com/gofiber/fiber/v2"
+	gographql "github.com/graph-gophers/graphql-go"
+)
+
+const (
+	authorizationHeader = "Authorization"
+
+	maxQueryDepth = 10
+)
+
+//go:embed schema.graphql
+var schema string
+
+type claimsCtxKey struct{}
+
+type Handler struct {
+	schema   *gographql.Schema
+	services *service.Services
+}
+
+func NewHandler(services *service.Services, validate *validator.Validate) *Handler {
+	resolver := &Resolver{
+		services: services,
+		validate: validate,
+	}
+
+	return &Handler{
+		schema:   gographql.MustParseSchema(schema, resolver, gographql.MaxDepth(maxQueryDepth)),
+		services: services,
+	}
+}
+
+func (h *Handler) Init(router fiber.Router) {
+	router.Post("/graphql", h.serve)
+}
+
+type request struct {
+	Query         string                 `json:"query"`
+	OperationName string                 `json:"operationName"`
+	Variables     map[string]interface{} `json:"variables"`
+}
+
+func (h *Handler) serve(c *fiber.Ctx) error {
+	var req request
+	if err := c.BodyParser(&req); err != nil {
+		return problem.ErrInvalidBody
+	}
+
+	ctx := withLoaders(c.UserContext(), h.services)
+
+	if header := c.Get(authorizationHeader); header != "" {
+		claims, err := h.authenticate(ctx, header)
+		if err != nil {
+			var authErr *service.AuthenticationError
+			if errors.As(err, &authErr) {
+				return problem.TokenError(authErr.Err)
+			}
+
+			return err
+		}
+
+		ctx = context.WithValue(ctx, claimsCtxKey{}, claims)
+		ctx = logging.WithField(ctx, "user_id", claims.Subject)
+	}
+
+	return c.JSON(h.schema.Exec(ctx, req.Query, req.OperationName, req.Variables))
+}
+
+// authenticate checks the bearer token of the header like the other transports do, see service.Services.Authenticate.
+func (h *Handler) authenticate(ctx context.Context, header string) (auth.Claims, error) {
+	headerParts := strings.Split(header, " ")
+	if len(headerParts) != 2 || headerParts[0] != "Bearer" || len(headerParts[1]) == 0 {
+		return auth.Claims{}, problem.ErrInvalidAuthHeader
+	}
+
+	return h.services.Authenticate(ctx, headerParts[1])
+}
+
+func getClaims(ctx context.Context) (auth.Claims, error) {
+	claims, ok := ctx.Value(claimsCtxKey{}).(auth.Claims)
+	if !ok {
+		return auth.Claims{}, errUnauthorized
+	}
+
+	return claims, nil
+}
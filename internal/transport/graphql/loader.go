@@ -0,0 +1,130 @@
+package graphql
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/ernur-eskermes/crud-app/internal/core"
+	"github.com/ernur-eskermes/crud-app/internal/service"
+	"github.com/google/uuid"
+)
+
+const (
+	loaderWait     = 5 * time.Millisecond
+	loaderMaxBatch = 100
+)
+
+type loadersCtxKey struct{}
+
+type loaders struct {
+	users *userLoader
+}
+
+// withLoaders attaches a fresh set of loaders to ctx. Loaders cache results,
+// so they must be created per request.
+func withLoaders(ctx context.Context, services *service.Services) context.Context {
+	return context.WithValue(ctx, loadersCtxKey{}, &loaders{
+		users: newUserLoader(services.Users),
+	})
+}
+
+func getLoaders(ctx context.Context) *loaders {
+	return ctx.Value(loadersCtxKey{}).(*loaders) //nolint:forcetypeassert
+}
+
+// userLoader collects the user ids requested while a query is resolved and
+// fetches them with a single UsersService.GetByIDs call per batch.
+type userLoader struct {
+	users service.Users
+
+	mu    sync.Mutex
+	cache map[uuid.UUID]*userBatch
+	batch *userBatch
+}
+
+type userBatch struct {
+	ids        []uuid.UUID
+	dispatched bool
+	done       chan struct{}
+
+	users map[uuid.UUID]core.User
+	err   error
+}
+
+func newUserLoader(users service.Users) *userLoader {
+	return &userLoader{
+		users: users,
+		cache: make(map[uuid.UUID]*userBatch),
+	}
+}
+
+func (l *userLoader) Load(ctx context.Context, id uuid.UUID) (core.User, error) {
+	l.mu.Lock()
+
+	b, ok := l.cache[id]
+	if !ok {
+		if l.batch == nil {
+			l.batch = &userBatch{done: make(chan struct{})}
+
+			batch := l.batch
+			time.AfterFunc(loaderWait, func() { l.dispatch(ctx, batch) })
+		}
+
+		b = l.batch
+		b.ids = append(b.ids, id)
+		l.cache[id] = b
+
+		if len(b.ids) >= loaderMaxBatch {
+			go l.dispatch(ctx, b)
+		}
+	}
+
+	l.mu.Unlock()
+
+	select {
+	case <-b.done:
+	case <-ctx.Done():
+		return core.User{}, ctx.Err()
+	}
+
+	if b.err != nil {
+		return core.User{}, b.err
+	}
+
+	user, ok := b.users[id]
+	if !ok {
+		return core.User{}, core.ErrUserNotFound
+	}
+
+	return user, nil
+}
+
+func (l *userLoader) dispatch(ctx context.Context, b *userBatch) {
+	l.mu.Lock()
+	if b.dispatched {
+		l.mu.Unlock()
+
+		return
+	}
+
+	b.dispatched = true
+	if l.batch == b {
+		l.batch = nil
+	}
+	l.mu.Unlock()
+
+	defer close(b.done)
+
+	users, err := l.users.GetByIDs(ctx, b.ids)
+	if err != nil {
+		b.err = err
+
+		return
+	}
+
+	b.users = make(map[uuid.UUID]core.User, len(users))
+	for _, user := range users {
+		b.users[user.ID] = user
+	}
+}
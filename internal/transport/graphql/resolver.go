@@ -0,0 +1,255 @@
+package graphql
+
+import (
+	"context"
+	"errors"
+
+	"github.com/ernur-eskermes/crud-app/internal/core"
+	"github.com/ernur-eskermes/crud-app/internal/service"
+	"github.com/ernur-eskermes/crud-app/pkg/logging"
+	"github.com/go-playground/validator/v10"
+	"github.com/google/uuid"
+	gographql "github.com/graph-gophers/graphql-go"
+)
+
+var (
+	errUnauthorized = errors.New("unauthorized")
+	errInvalidID    = errors.New("id value of incorrect type")
+	errInternal     = errors.New("internal error")
+)
+
+type Resolver struct {
+	services *service.Services
+	validate *validator.Validate
+}
+
+func (r *Resolver) Book(ctx context.Context, args struct{ ID gographql.ID }) (*bookResolver, error) {
+	id, err := uuid.Parse(string(args.ID))
+	if err != nil {
+		return nil, errInvalidID
+	}
+
+	book, err := r.services.Books.GetByID(ctx, id)
+	if err != nil {
+		if errors.Is(err, core.ErrBookNotFound) {
+			return nil, nil
+		}
+
+		return nil, r.error(ctx, err)
+	}
+
+	return &bookResolver{book}, nil
+}
+
+type booksArgs struct {
+	Title  *string
+	Author *gographql.ID
+	Limit  int32
+	Offset int32
+}
+
+func (r *Resolver) Books(ctx context.Context, args booksArgs) ([]*bookResolver, error) {
+	query := core.SearchBooksQuery{
+		Limit:  int(args.Limit),
+		Offset: int(args.Offset),
+	}
+
+	if args.Title != nil {
+		query.Title = *args.Title
+	}
+
+	if args.Author != nil {
+		author, err := uuid.Parse(string(*args.Author))
+		if err != nil {
+			return nil, errInvalidID
+		}
+
+		query.Author = &author
+	}
+
+	if err := r.validate.Struct(query); err != nil {
+		return nil, err
+	}
+
+	books, err := r.services.Books.Search(ctx, query)
+	if err != nil {
+		return nil, r.error(ctx, err)
+	}
+
+	res := make([]*bookResolver, 0, len(books))
+	for _, book := range books {
+		res = append(res, &bookResolver{book})
+	}
+
+	return res, nil
+}
+
+func (r *Resolver) User(ctx context.Context, args struct{ ID gographql.ID }) (*userResolver, error) {
+	id, err := uuid.Parse(string(args.ID))
+	if err != nil {
+		return nil, errInvalidID
+	}
+
+	user, err := getLoaders(ctx).users.Load(ctx, id)
+	if err != nil {
+		if errors.Is(err, core.ErrUserNotFound) {
+			return nil, nil
+		}
+
+		return nil, r.error(ctx, err)
+	}
+
+	return &userResolver{user}, nil
+}
+
+type bookInput struct {
+	Title       string
+	PublishDate gographql.Time
+	Rating      int32
+}
+
+func (r *Resolver) CreateBook(ctx context.Context, args struct{ Input bookInput }) (bool, error) {
+	userID, err := r.authorizedUserID(ctx, core.ScopeBooksWrite)
+	if err != nil {
+		return false, err
+	}
+
+	inp := core.CreateBookInput{
+		Title:       args.Input.Title,
+		PublishDate: args.Input.PublishDate.Time,
+		Rating:      int(args.Input.Rating),
+	}
+
+	if err = r.validate.Struct(inp); err != nil {
+		return false, err
+	}
+
+	if err = r.services.Books.Create(ctx, inp, userID); err != nil {
+		return false, r.error(ctx, err)
+	}
+
+	return true, nil
+}
+
+type updateBookArgs struct {
+	ID    gographql.ID
+	Input bookInput
+}
+
+func (r *Resolver) UpdateBook(ctx context.Context, args updateBookArgs) (bool, error) {
+	userID, err := r.authorizedUserID(ctx, core.ScopeBooksWrite)
+	if err != nil {
+		return false, err
+	}
+
+	id, err := uuid.Parse(string(args.ID))
+	if err != nil {
+		return false, errInvalidID
+	}
+
+	inp := core.UpdateBookInput{
+		Title:       args.Input.Title,
+		PublishDate: args.Input.PublishDate.Time,
+		Rating:      int(args.Input.Rating),
+	}
+
+	if err = r.validate.Struct(inp); err != nil {
+		return false, err
+	}
+
+	if err = r.services.Books.Update(ctx, id, userID, inp); err != nil {
+		return false, r.error(ctx, err)
+	}
+
+	return true, nil
+}
+
+func (r *Resolver) DeleteBook(ctx context.Context, args struct{ ID gographql.ID }) (bool, error) {
+	userID, err := r.authorizedUserID(ctx, core.ScopeBooksWrite)
+	if err != nil {
+		return false, err
+	}
+
+	id, err := uuid.Parse(string(args.ID))
+	if err != nil {
+		return false, errInvalidID
+	}
+
+	if err = r.services.Books.Delete(ctx, id, userID); err != nil {
+		return false, r.error(ctx, err)
+	}
+
+	return true, nil
+}
+
+// authorizedUserID returns the id of the authenticated user, making sure the credentials
+// allow the scope and the user still exists.
+func (r *Resolver) authorizedUserID(ctx context.Context, scope string) (uuid.UUID, error) {
+	claims, err := getClaims(ctx)
+	if err != nil {
+		return uuid.UUID{}, err
+	}
+
+	if err = service.Authorize(claims, scope); err != nil {
+		return uuid.UUID{}, err
+	}
+
+	userID := service.UserID(claims)
+	if _, err = r.services.Users.GetByID(ctx, userID); err != nil {
+		return uuid.UUID{}, r.error(ctx, err)
+	}
+
+	return userID, nil
+}
+
+// error hides unexpected errors from clients, domain errors are returned as is.
+func (r *Resolver) error(ctx context.Context, err error) error {
+	if errors.Is(err, core.ErrBookNotFound) || errors.Is(err, core.ErrUserNotFound) {
+		return err
+	}
+
+	logging.FromContext(ctx).Error(err)
+
+	return errInternal
+}
+
+type bookResolver struct {
+	book core.Book
+}
+
+func (b *bookResolver) ID() gographql.ID {
+	return gographql.ID(b.book.ID.String())
+}
+
+func (b *bookResolver) Title() string {
+	return b.book.Title
+}
+
+func (b *bookResolver) Author(ctx context.Context) (*userResolver, error) {
+	user, err := getLoaders(ctx).users.Load(ctx, b.book.Author)
+	if err != nil {
+		return nil, err
+	}
+
+	return &userResolver{user}, nil
+}
+
+func (b *bookResolver) PublishDate() gographql.Time {
+	return gographql.Time{Time: b.book.PublishDate}
+}
+
+func (b *bookResolver) Rating() int32 {
+	return int32(b.book.Rating)
+}
+
+type userResolver struct {
+	user core.User
+}
+
+func (u *userResolver) ID() gographql.ID {
+	return gographql.ID(u.user.ID.String())
+}
+
+func (u *userResolver) Username() string {
+	return u.user.Username
+}
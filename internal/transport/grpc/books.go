@@ -0,0 +1,149 @@
+package grpc
+
+import (
+	"context"
+
+	"github.com/ernur-eskermes/crud-app/internal/core"
+	"github.com/ernur-eskermes/crud-app/internal/service"
+	"github.com/ernur-eskermes/crud-app/pkg/api"
+	"github.com/go-playground/validator/v10"
+	"github.com/google/uuid"
+	"google.golang.org/protobuf/types/known/emptypb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+type BooksHandler struct {
+	api.UnimplementedBooksServiceServer
+
+	services *service.Services
+	validate *validator.Validate
+}
+
+func NewBooksHandler(services *service.Services, validate *validator.Validate) *BooksHandler {
+	return &BooksHandler{
+		services: services,
+		validate: validate,
+	}
+}
+
+func (h *BooksHandler) Create(ctx context.Context, req *api.CreateBookRequest) (*emptypb.Empty, error) {
+	userID, err := h.authorizedUserID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	inp := core.CreateBookInput{
+		Title:       req.GetTitle(),
+		PublishDate: req.GetPublishDate().AsTime(),
+		Rating:      int(req.GetRating()),
+	}
+
+	if err = h.validate.Struct(inp); err != nil {
+		return nil, toStatus(ctx, err)
+	}
+
+	if err = h.services.Books.Create(ctx, inp, userID); err != nil {
+		return nil, toStatus(ctx, err)
+	}
+
+	return &emptypb.Empty{}, nil
+}
+
+func (h *BooksHandler) GetByID(ctx context.Context, req *api.GetBookByIDRequest) (*api.Book, error) {
+	id, err := uuid.Parse(req.GetId())
+	if err != nil {
+		return nil, errInvalidID
+	}
+
+	book, err := h.services.Books.GetByID(ctx, id)
+	if err != nil {
+		return nil, toStatus(ctx, err)
+	}
+
+	return toBook(book), nil
+}
+
+func (h *BooksHandler) GetAll(ctx context.Context, _ *emptypb.Empty) (*api.GetAllBooksResponse, error) {
+	books, err := h.services.Books.GetAll(ctx)
+	if err != nil {
+		return nil, toStatus(ctx, err)
+	}
+
+	res := &api.GetAllBooksResponse{Books: make([]*api.Book, 0, len(books))}
+	for _, book := range books {
+		res.Books = append(res.Books, toBook(book))
+	}
+
+	return res, nil
+}
+
+func (h *BooksHandler) Update(ctx context.Context, req *api.UpdateBookRequest) (*emptypb.Empty, error) {
+	userID, err := h.authorizedUserID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	id, err := uuid.Parse(req.GetId())
+	if err != nil {
+		return nil, errInvalidID
+	}
+
+	inp := core.UpdateBookInput{
+		Title:       req.GetTitle(),
+		PublishDate: req.GetPublishDate().AsTime(),
+		Rating:      int(req.GetRating()),
+	}
+
+	if err = h.validate.Struct(inp); err != nil {
+		return nil, toStatus(ctx, err)
+	}
+
+	if err = h.services.Books.Update(ctx, id, userID, inp); err != nil {
+		return nil, toStatus(ctx, err)
+	}
+
+	return &emptypb.Empty{}, nil
+}
+
+func (h *BooksHandler) Delete(ctx context.Context, req *api.DeleteBookRequest) (*emptypb.Empty, error) {
+	userID, err := h.authorizedUserID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	id, err := uuid.Parse(req.GetId())
+	if err != nil {
+		return nil, errInvalidID
+	}
+
+	if err = h.services.Books.Delete(ctx, id, userID); err != nil {
+		return nil, toStatus(ctx, err)
+	}
+
+	return &emptypb.Empty{}, nil
+}
+
+// authorizedUserID returns the id of the authenticated user, making sure
+// the user still exists.
+func (h *BooksHandler) authorizedUserID(ctx context.Context) (uuid.UUID, error) {
+	userID, err := getUserID(ctx)
+	if err != nil {
+		return uuid.UUID{}, err
+	}
+
+	if _, err = h.services.Users.GetByID(ctx, userID); err != nil {
+		return uuid.UUID{}, toStatus(ctx, err)
+	}
+
+	return userID, nil
+}
+
+func toBook(book core.Book) *api.Book {
+	return &api.Book{
+		Id:          book.ID.String(),
+		Title:       book.Title,
+		Author:      book.Author.String(),
+		PublishDate: timestamppb.New(book.PublishDate),
+		Rating:      int32(book.Rating),
+	}
+}
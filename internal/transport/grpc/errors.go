@@ -0,0 +1,69 @@
+package grpc
+
+import (
+	"context"
+	"errors"
+
+	"github.com/ernur-eskermes/crud-app/internal/core"
+	"github.com/ernur-eskermes/crud-app/pkg/logging"
+	"github.com/go-playground/validator/v10"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/durationpb"
+)
+
+var (
+	errEmptyAuthHeader   = errors.New("empty auth header")
+	errInvalidAuthHeader = errors.New("invalid auth header")
+	errEmptyToken        = errors.New("token is empty")
+	errInvalidID         = status.Error(codes.InvalidArgument, "id value of incorrect type")
+)
+
+// toStatus converts domain errors returned by services into gRPC status errors.
+// Unknown errors are logged and hidden behind codes.Internal.
+func toStatus(ctx context.Context, err error) error {
+	var (
+		validationErrors validator.ValidationErrors
+		tooManyAttempts  *core.TooManyAttemptsError
+		secondFactor     *core.SecondFactorRequiredError
+	)
+
+	switch {
+	case errors.Is(err, core.ErrBookNotFound),
+		errors.Is(err, core.ErrUserNotFound):
+		return status.Error(codes.NotFound, err.Error())
+	case errors.Is(err, core.ErrUserAlreadyExists):
+		return status.Error(codes.AlreadyExists, err.Error())
+	case errors.Is(err, core.ErrUserCodeExpired),
+		errors.Is(err, core.ErrUserCodeUnknownType),
+		errors.Is(err, core.ErrUserCodeIncorrect):
+		return status.Error(codes.InvalidArgument, err.Error())
+	case errors.As(err, &tooManyAttempts):
+		st, detailsErr := status.New(codes.ResourceExhausted, err.Error()).WithDetails(&errdetails.RetryInfo{
+			RetryDelay: durationpb.New(tooManyAttempts.RetryAfter),
+		})
+		if detailsErr != nil {
+			return status.Error(codes.ResourceExhausted, err.Error())
+		}
+
+		return st.Err()
+	case errors.As(err, &secondFactor):
+		// The passkey login is completed over REST with the token.
+		st, detailsErr := status.New(codes.Unauthenticated, err.Error()).WithDetails(&errdetails.ErrorInfo{
+			Reason:   "SECOND_FACTOR_REQUIRED",
+			Metadata: map[string]string{"second_factor_token": secondFactor.Token},
+		})
+		if detailsErr != nil {
+			return status.Error(codes.Unauthenticated, err.Error())
+		}
+
+		return st.Err()
+	case errors.As(err, &validationErrors):
+		return status.Error(codes.InvalidArgument, validationErrors.Error())
+	}
+
+	logging.FromContext(ctx).Error(err)
+
+	return status.Error(codes.Internal, "internal error")
+}
@@ -0,0 +1,137 @@
+package grpc
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strings"
+
+	"github.com/ernur-eskermes/crud-app/internal/core"
+	"github.com/ernur-eskermes/crud-app/internal/service"
+	"github.com/ernur-eskermes/crud-app/pkg/auth"
+	"github.com/ernur-eskermes/crud-app/pkg/database/postgresql"
+	"github.com/ernur-eskermes/crud-app/pkg/logging"
+	"github.com/google/uuid"
+	gogrpc "google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	authorizationHeader = "authorization"
+	requestIDHeader     = "x-request-id"
+)
+
+type claimsCtxKey struct{}
+
+// authenticatedMethods lists the RPCs that require valid credentials, with the scope they require
+// from API keys and OAuth clients, if any.
+var authenticatedMethods = map[string]string{
+	"/crud.v1.BooksService/Create":  core.ScopeBooksWrite,
+	"/crud.v1.BooksService/Update":  core.ScopeBooksWrite,
+	"/crud.v1.BooksService/Delete":  core.ScopeBooksWrite,
+	"/crud.v1.UsersService/GetByID": "",
+}
+
+// requestContextInterceptor tags the logger of each call with the request id,
+// taken from the x-request-id metadata or generated.
+func requestContextInterceptor(logger *logging.Logger) gogrpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *gogrpc.UnaryServerInfo, handler gogrpc.UnaryHandler) (interface{}, error) {
+		var requestID string
+		if md, ok := metadata.FromIncomingContext(ctx); ok {
+			if values := md.Get(requestIDHeader); len(values) > 0 {
+				requestID = values[0]
+			}
+		}
+
+		if requestID == "" {
+			requestID = uuid.NewString()
+		}
+
+		if err := gogrpc.SetHeader(ctx, metadata.Pairs(requestIDHeader, requestID)); err != nil {
+			return nil, err
+		}
+
+		if p, ok := peer.FromContext(ctx); ok {
+			if host, _, err := net.SplitHostPort(p.Addr.String()); err == nil {
+				ctx = service.WithClientIP(ctx, host)
+			}
+		}
+
+		l := logger.GetLoggerWithField("request_id", requestID)
+
+		return handler(logging.ContextWithLogger(ctx, &l), req)
+	}
+}
+
+func authInterceptor(services *service.Services) gogrpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *gogrpc.UnaryServerInfo, handler gogrpc.UnaryHandler) (interface{}, error) {
+		scope, ok := authenticatedMethods[info.FullMethod]
+		if !ok {
+			return handler(ctx, req)
+		}
+
+		token, err := parseAuthMetadata(ctx)
+		if err != nil {
+			return nil, status.Error(codes.Unauthenticated, err.Error())
+		}
+
+		claims, err := services.Authenticate(ctx, token)
+		if err != nil {
+			var authErr *service.AuthenticationError
+			if errors.As(err, &authErr) {
+				return nil, status.Error(codes.Unauthenticated, authErr.Error())
+			}
+
+			return nil, toStatus(ctx, err)
+		}
+
+		if scope != "" {
+			if err = service.Authorize(claims, scope); err != nil {
+				return nil, status.Error(codes.PermissionDenied, err.Error())
+			}
+		}
+
+		userID := service.UserID(claims)
+
+		ctx = context.WithValue(ctx, claimsCtxKey{}, claims)
+		ctx = postgresql.WithSession(ctx, userID.String())
+
+		return handler(logging.WithField(ctx, "user_id", userID), req)
+	}
+}
+
+// parseAuthMetadata returns the bearer token of the authorization metadata.
+func parseAuthMetadata(ctx context.Context) (string, error) {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return "", errEmptyAuthHeader
+	}
+
+	values := md.Get(authorizationHeader)
+	if len(values) == 0 || values[0] == "" {
+		return "", errEmptyAuthHeader
+	}
+
+	headerParts := strings.Split(values[0], " ")
+	if len(headerParts) != 2 || headerParts[0] != "Bearer" {
+		return "", errInvalidAuthHeader
+	}
+
+	if len(headerParts[1]) == 0 {
+		return "", errEmptyToken
+	}
+
+	return headerParts[1], nil
+}
+
+func getUserID(ctx context.Context) (uuid.UUID, error) {
+	claims, ok := ctx.Value(claimsCtxKey{}).(auth.Claims)
+	if !ok {
+		return uuid.UUID{}, status.Error(codes.Unauthenticated, "user is not authenticated")
+	}
+
+	return service.UserID(claims), nil
+}
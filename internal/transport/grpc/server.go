@@ -0,0 +1,47 @@
+package grpc
+
+import (
+	"net"
+
+	"github.com/ernur-eskermes/crud-app/internal/service"
+	"github.com/ernur-eskermes/crud-app/pkg/api"
+	"github.com/ernur-eskermes/crud-app/pkg/logging"
+	"github.com/go-playground/validator/v10"
+	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc"
+	gogrpc "google.golang.org/grpc"
+	"google.golang.org/grpc/reflection"
+)
+
+type Server struct {
+	srv *gogrpc.Server
+}
+
+func NewServer(services *service.Services, validate *validator.Validate, logger *logging.Logger) *Server {
+	srv := gogrpc.NewServer(
+		gogrpc.ChainUnaryInterceptor(
+			otelgrpc.UnaryServerInterceptor(),
+			requestContextInterceptor(logger),
+			authInterceptor(services),
+		),
+	)
+
+	api.RegisterBooksServiceServer(srv, NewBooksHandler(services, validate))
+	api.RegisterUsersServiceServer(srv, NewUsersHandler(services, validate))
+	reflection.Register(srv)
+
+	return &Server{srv: srv}
+}
+
+func (s *Server) ListenAndServe(port string) error {
+	lis, err := net.Listen("tcp", ":"+port)
+	if err != nil {
+		return err
+	}
+
+	return s.srv.Serve(lis)
+}
+
+// Stop stops the server, waiting for pending RPCs to finish.
+func (s *Server) Stop() {
+	s.srv.GracefulStop()
+}
@@ -0,0 +1,94 @@
+package grpc
+
+import (
+	"context"
+	"errors"
+
+	"github.com/ernur-eskermes/crud-app/internal/core"
+	"github.com/ernur-eskermes/crud-app/internal/service"
+	"github.com/ernur-eskermes/crud-app/pkg/api"
+	"github.com/go-playground/validator/v10"
+	"github.com/google/uuid"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/emptypb"
+)
+
+type UsersHandler struct {
+	api.UnimplementedUsersServiceServer
+
+	services *service.Services
+	validate *validator.Validate
+}
+
+func NewUsersHandler(services *service.Services, validate *validator.Validate) *UsersHandler {
+	return &UsersHandler{
+		services: services,
+		validate: validate,
+	}
+}
+
+type credentialsInput struct {
+	Username string `validate:"required,max=64"`
+	Password string `validate:"required,min=8,max=64"`
+}
+
+func (h *UsersHandler) SignUp(ctx context.Context, req *api.SignUpRequest) (*emptypb.Empty, error) {
+	if err := h.validate.Struct(credentialsInput{Username: req.GetUsername(), Password: req.GetPassword()}); err != nil {
+		return nil, toStatus(ctx, err)
+	}
+
+	if err := h.services.Users.SignUp(ctx, service.UserSignUpInput{
+		Username: req.GetUsername(),
+		Password: req.GetPassword(),
+	}); err != nil {
+		return nil, toStatus(ctx, err)
+	}
+
+	return &emptypb.Empty{}, nil
+}
+
+func (h *UsersHandler) SignIn(ctx context.Context, req *api.SignInRequest) (*api.TokenResponse, error) {
+	if err := h.validate.Struct(credentialsInput{Username: req.GetUsername(), Password: req.GetPassword()}); err != nil {
+		return nil, toStatus(ctx, err)
+	}
+
+	res, err := h.services.Users.SignIn(ctx, service.UserSignInInput{
+		Username: req.GetUsername(),
+		Password: req.GetPassword(),
+	})
+	if err != nil {
+		if errors.Is(err, core.ErrUserNotFound) {
+			return nil, status.Error(codes.Unauthenticated, err.Error())
+		}
+
+		return nil, toStatus(ctx, err)
+	}
+
+	return &api.TokenResponse{AccessToken: res.AccessToken}, nil
+}
+
+func (h *UsersHandler) Verify(ctx context.Context, req *api.VerifyRequest) (*emptypb.Empty, error) {
+	if err := h.services.Users.Verify(ctx, req.GetUsername(), req.GetCode()); err != nil {
+		return nil, toStatus(ctx, err)
+	}
+
+	return &emptypb.Empty{}, nil
+}
+
+func (h *UsersHandler) GetByID(ctx context.Context, req *api.GetUserByIDRequest) (*api.User, error) {
+	id, err := uuid.Parse(req.GetId())
+	if err != nil {
+		return nil, errInvalidID
+	}
+
+	user, err := h.services.Users.GetByID(ctx, id)
+	if err != nil {
+		return nil, toStatus(ctx, err)
+	}
+
+	return &api.User{
+		Id:       user.ID.String(),
+		Username: user.Username,
+	}, nil
+}
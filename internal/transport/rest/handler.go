@@ -1,19 +1,29 @@
 package rest
 
 import (
+	"encoding/json"
+	"errors"
+	"strings"
 	"time"
 
 	"github.com/ernur-eskermes/crud-app/internal/config"
+	"github.com/ernur-eskermes/crud-app/internal/core"
 	"github.com/ernur-eskermes/crud-app/internal/service"
+	"github.com/ernur-eskermes/crud-app/internal/transport/graphql"
+	"github.com/ernur-eskermes/crud-app/internal/transport/rest/problem"
 	v1 "github.com/ernur-eskermes/crud-app/internal/transport/rest/v1"
 	"github.com/ernur-eskermes/crud-app/pkg/auth"
+	"github.com/ernur-eskermes/crud-app/pkg/health"
 	"github.com/ernur-eskermes/crud-app/pkg/logging"
+	"github.com/ernur-eskermes/crud-app/pkg/ratelimit"
 	"github.com/go-playground/validator/v10"
 	"github.com/gofiber/fiber/v2"
 	"github.com/gofiber/fiber/v2/middleware/cors"
-	"github.com/gofiber/fiber/v2/middleware/limiter"
 	"github.com/gofiber/fiber/v2/middleware/logger"
 	"github.com/gofiber/fiber/v2/middleware/monitor"
+	"github.com/gofiber/fiber/v2/middleware/requestid"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/valyala/fasthttp/fasthttpadaptor"
 
 	swagger "github.com/arsmn/fiber-swagger/v2"
 )
@@ -22,35 +32,104 @@ type Handler struct {
 	services     *service.Services
 	tokenManager auth.TokenManager
 	validate     *validator.Validate
+	health       *health.Checker
 	logger       *logging.Logger
+
+	cors    swappable
+	limiter *rateLimiter
 }
 
-func NewHandler(services *service.Services, tokenManager auth.TokenManager, validate *validator.Validate, logger *logging.Logger) *Handler {
+func NewHandler(services *service.Services, tokenManager auth.TokenManager, validate *validator.Validate,
+	health *health.Checker, limitStore ratelimit.Store, logger *logging.Logger,
+) *Handler {
 	return &Handler{
 		services:     services,
 		validate:     validate,
 		tokenManager: tokenManager,
+		health:       health,
 		logger:       logger,
+		limiter:      newRateLimiter(limitStore, tokenManager, services.APIKeys),
 	}
 }
 
 func (h *Handler) InitRouter(app *fiber.App, cfg *config.Config) {
-	app.Use(cors.New())
+	app.Use(requestid.New())
+	app.Use(h.requestContext(cfg.HTTP.WriteTimeout))
+	app.Use(requestTracing())
+	app.Use(requestMetrics)
+	h.Reconfigure(cfg)
+
+	app.Use(h.cors.handle)
 	app.Use(logger.New(logger.Config{
+		Format:     "[${time}] ${locals:requestid} ${status} - ${latency} ${method} ${path}\n",
 		TimeFormat: time.RFC3339,
 		TimeZone:   "Asia/Almaty",
 	}))
-	app.Use(limiter.New(limiter.Config{
-		Next: func(c *fiber.Ctx) bool {
-			return c.IP() == "127.0.0.1"
-		},
-		Max:        20,
-		Expiration: 30 * time.Second,
-	}))
+	app.Use(h.limiter.handle)
 
 	app.Get("/dashboard", monitor.New())
+	app.Get("/metrics", metricsHandler())
+	h.initHealthRoutes(app)
+	app.Get(jwksPath, h.jwks)
+	h.initOAuthRoutes(app)
 	app.Get("/swagger/*", swagger.HandlerDefault)
 	h.initAPI(app)
+	h.initGraphQL(app)
+
+	// fiber writes 404 for unmatched routes itself, route it through ErrorHandler instead.
+	app.Use(func(c *fiber.Ctx) error {
+		return fiber.ErrNotFound
+	})
+}
+
+// Reconfigure applies the reloadable HTTP settings: CORS origins and rate limits.
+func (h *Handler) Reconfigure(cfg *config.Config) {
+	h.cors.set(cors.New(cors.Config{
+		AllowOrigins:  strings.Join(cfg.HTTP.CORSOrigins, ","),
+		ExposeHeaders: strings.Join(rateLimitHeaders, ","),
+	}))
+	h.limiter.configure(cfg.Limiter)
+}
+
+// ErrorHandler renders errors returned by handlers as application/problem+json.
+func (h *Handler) ErrorHandler(c *fiber.Ctx, err error) error {
+	p, ok := problem.From(err)
+	if !ok {
+		logging.FromContext(c.UserContext()).Error(err)
+	}
+
+	var tooManyAttempts *core.TooManyAttemptsError
+	if errors.As(err, &tooManyAttempts) {
+		c.Set(fiber.HeaderRetryAfter, seconds(tooManyAttempts.RetryAfter))
+	}
+
+	var secondFactor *core.SecondFactorRequiredError
+	if errors.As(err, &secondFactor) {
+		p.SecondFactorToken = secondFactor.Token
+	}
+
+	p.Instance = c.Path()
+	p.RequestID, _ = c.Locals(requestid.ConfigDefault.ContextKey).(string)
+
+	body, err := json.Marshal(p)
+	if err != nil {
+		return err
+	}
+
+	c.Set(fiber.HeaderContentType, problem.ContentType)
+
+	return c.Status(p.Status).Send(body)
+}
+
+// metricsHandler serves the metrics of the default prometheus registry.
+func metricsHandler() fiber.Handler {
+	handler := fasthttpadaptor.NewFastHTTPHandler(promhttp.Handler())
+
+	return func(c *fiber.Ctx) error {
+		handler(c.Context())
+
+		return nil
+	}
 }
 
 func (h *Handler) initAPI(app fiber.Router) {
@@ -60,3 +139,7 @@ func (h *Handler) initAPI(app fiber.Router) {
 		handlerV1.Init(api)
 	}
 }
+
+func (h *Handler) initGraphQL(app fiber.Router) {
+	graphql.NewHandler(h.services, h.validate).Init(app)
+}
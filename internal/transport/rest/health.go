@@ -0,0 +1,35 @@
+package rest
+
+import (
+	"github.com/ernur-eskermes/crud-app/pkg/health"
+	"github.com/gofiber/fiber/v2"
+)
+
+const (
+	livenessPath  = "/healthz"
+	readinessPath = "/readyz"
+)
+
+func (h *Handler) initHealthRoutes(app fiber.Router) {
+	app.Get(livenessPath, h.liveness)
+	app.Get(readinessPath, h.readiness)
+}
+
+// liveness reports that the process is up and serving requests.
+func (h *Handler) liveness(c *fiber.Ctx) error {
+	return c.JSON(health.Report{Status: health.StatusOK})
+}
+
+// readiness reports whether the application dependencies are usable.
+func (h *Handler) readiness(c *fiber.Ctx) error {
+	report := h.health.Ready(c.UserContext())
+	if report.Status != health.StatusOK {
+		c.Status(fiber.StatusServiceUnavailable)
+	}
+
+	return c.JSON(report)
+}
+
+func isHealthCheck(c *fiber.Ctx) bool {
+	return c.Path() == livenessPath || c.Path() == readinessPath
+}
@@ -0,0 +1,20 @@
+package rest
+
+import (
+	"github.com/gofiber/fiber/v2"
+)
+
+const (
+	jwksPath = "/.well-known/jwks.json"
+
+	// jwksMaxAge lets verifiers cache the keys. Keys are published before they start
+	// signing tokens, so caches pick up a rotation in time.
+	jwksMaxAge = "public, max-age=300"
+)
+
+// jwks serves the public keys that verify the access tokens.
+func (h *Handler) jwks(c *fiber.Ctx) error {
+	c.Set(fiber.HeaderCacheControl, jwksMaxAge)
+
+	return c.JSON(h.tokenManager.JWKS())
+}
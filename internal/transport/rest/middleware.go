@@ -0,0 +1,138 @@
+package rest
+
+import (
+	"context"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/ernur-eskermes/crud-app/internal/service"
+	"github.com/ernur-eskermes/crud-app/pkg/logging"
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/middleware/requestid"
+	"github.com/gofiber/fiber/v2/utils"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	semconv "go.opentelemetry.io/otel/semconv/v1.7.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	requestIDField = "request_id"
+	traceIDField   = "trace_id"
+
+	tracerName = "github.com/ernur-eskermes/crud-app/internal/transport/rest"
+)
+
+var (
+	httpRequestsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "http_requests_total",
+		Help: "Number of HTTP requests by route and status code.",
+	}, []string{"method", "route", "status"})
+	httpRequestDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "http_request_duration_seconds",
+		Help:    "Duration of HTTP requests by route.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"method", "route"})
+)
+
+// requestContext sets up the request context passed down to services: it is bounded
+// by timeout and carries a logger tagged with the request id. fasthttp doesn't report
+// client disconnects, so the timeout is what cancels abandoned database queries.
+func (h *Handler) requestContext(timeout time.Duration) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		requestID, _ := c.Locals(requestid.ConfigDefault.ContextKey).(string)
+		logger := h.logger.GetLoggerWithField(requestIDField, requestID)
+
+		ctx, cancel := context.WithTimeout(c.UserContext(), timeout)
+		defer cancel()
+
+		ctx = service.WithClientIP(ctx, utils.CopyString(c.IP()))
+
+		c.SetUserContext(logging.ContextWithLogger(ctx, &logger))
+
+		return c.Next()
+	}
+}
+
+// requestTracing starts a server span for every request, continuing the trace propagated
+// by the client. It must run before the logger middleware, which resolves handler
+// errors into responses, so that the span sees the final status code.
+func requestTracing() fiber.Handler {
+	tracer := otel.Tracer(tracerName)
+
+	return func(c *fiber.Ctx) error {
+		carrier := propagation.HeaderCarrier{}
+		c.Request().Header.VisitAll(func(k, v []byte) {
+			carrier.Set(string(k), string(v))
+		})
+
+		// Spans outlive the request, so values backed by fasthttp buffers are copied.
+		method := utils.CopyString(c.Method())
+
+		ctx := otel.GetTextMapPropagator().Extract(c.UserContext(), carrier)
+		ctx, span := tracer.Start(ctx, method+" "+utils.CopyString(c.Path()),
+			trace.WithSpanKind(trace.SpanKindServer),
+			trace.WithAttributes(
+				semconv.HTTPMethodKey.String(method),
+				semconv.HTTPTargetKey.String(utils.CopyString(c.OriginalURL())),
+				semconv.HTTPClientIPKey.String(utils.CopyString(c.IP())),
+				semconv.HTTPUserAgentKey.String(utils.CopyString(c.Get(fiber.HeaderUserAgent))),
+			),
+		)
+		defer span.End()
+
+		if sc := span.SpanContext(); sc.HasTraceID() {
+			ctx = logging.WithField(ctx, traceIDField, sc.TraceID().String())
+		}
+
+		c.SetUserContext(ctx)
+
+		err := c.Next()
+
+		status := c.Response().StatusCode()
+		span.SetName(method + " " + c.Route().Path)
+		span.SetAttributes(
+			semconv.HTTPRouteKey.String(c.Route().Path),
+			semconv.HTTPStatusCodeKey.Int(status),
+		)
+		span.SetStatus(semconv.SpanStatusFromHTTPStatusCodeAndSpanKind(status, trace.SpanKindServer))
+
+		if err != nil {
+			span.RecordError(err)
+		}
+
+		return err
+	}
+}
+
+// requestMetrics records the count and latency of requests per route. Like
+// requestTracing it must run before the logger middleware.
+func requestMetrics(c *fiber.Ctx) error {
+	start := time.Now()
+
+	err := c.Next()
+
+	method := utils.CopyString(c.Method())
+	route := c.Route().Path
+
+	httpRequestsTotal.WithLabelValues(method, route, strconv.Itoa(c.Response().StatusCode())).Inc()
+	httpRequestDuration.WithLabelValues(method, route).Observe(time.Since(start).Seconds())
+
+	return err
+}
+
+// swappable is a middleware that can be replaced while the server is running.
+type swappable struct {
+	handler atomic.Value
+}
+
+func (s *swappable) set(handler fiber.Handler) {
+	s.handler.Store(handler)
+}
+
+func (s *swappable) handle(c *fiber.Ctx) error {
+	return s.handler.Load().(fiber.Handler)(c)
+}
@@ -0,0 +1,153 @@
+package rest
+
+import (
+	"encoding/base64"
+	"errors"
+	"net/url"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/ernur-eskermes/crud-app/internal/core"
+	"github.com/ernur-eskermes/crud-app/internal/service"
+)
+
+// initOAuthRoutes serves the token endpoints of the OAuth 2.0 authorization server.
+// The authorization endpoint needs a signed in user and lives in the v1 API.
+func (h *Handler) initOAuthRoutes(app fiber.Router) {
+	oauth := app.Group("/oauth")
+	{
+		oauth.Post("/token", h.oauthToken)
+		oauth.Post("/introspect", h.oauthIntrospect)
+		oauth.Post("/revoke", h.oauthRevoke)
+	}
+}
+
+type oauthErrorResponse struct {
+	Error            string `json:"error"`
+	ErrorDescription string `json:"error_description,omitempty"`
+}
+
+// oauthToken is the token endpoint of RFC 6749 section 3.2.
+func (h *Handler) oauthToken(c *fiber.Ctx) error {
+	creds, err := clientCredentials(c)
+	if err != nil {
+		return oauthError(c, err)
+	}
+
+	res, err := h.services.OAuth.Token(c.UserContext(), creds, service.TokenRequest{
+		GrantType:    c.FormValue("grant_type"),
+		Code:         c.FormValue("code"),
+		RedirectURI:  c.FormValue("redirect_uri"),
+		CodeVerifier: c.FormValue("code_verifier"),
+		RefreshToken: c.FormValue("refresh_token"),
+		Scope:        c.FormValue("scope"),
+	})
+	if err != nil {
+		return oauthError(c, err)
+	}
+
+	noStore(c)
+
+	return c.JSON(res)
+}
+
+// oauthIntrospect is the introspection endpoint of RFC 7662.
+func (h *Handler) oauthIntrospect(c *fiber.Ctx) error {
+	creds, err := clientCredentials(c)
+	if err != nil {
+		return oauthError(c, err)
+	}
+
+	res, err := h.services.OAuth.Introspect(c.UserContext(), creds, c.FormValue("token"))
+	if err != nil {
+		return oauthError(c, err)
+	}
+
+	noStore(c)
+
+	return c.JSON(res)
+}
+
+// oauthRevoke is the revocation endpoint of RFC 7009.
+func (h *Handler) oauthRevoke(c *fiber.Ctx) error {
+	creds, err := clientCredentials(c)
+	if err != nil {
+		return oauthError(c, err)
+	}
+
+	if err = h.services.OAuth.Revoke(c.UserContext(), creds, c.FormValue("token")); err != nil {
+		return oauthError(c, err)
+	}
+
+	return c.SendStatus(fiber.StatusOK)
+}
+
+// clientCredentials reads the client credentials from the Basic authorization header
+// or the client_id and client_secret form parameters (RFC 6749 section 2.3.1).
+func clientCredentials(c *fiber.Ctx) (service.ClientCredentials, error) {
+	invalidClient := core.NewOAuthError(core.OAuthInvalidClient, "malformed client credentials")
+
+	header := c.Get(fiber.HeaderAuthorization)
+	if header == "" {
+		return service.ClientCredentials{
+			ID:     c.FormValue("client_id"),
+			Secret: c.FormValue("client_secret"),
+		}, nil
+	}
+
+	encoded := strings.TrimPrefix(header, "Basic ")
+	if encoded == header {
+		return service.ClientCredentials{}, invalidClient
+	}
+
+	decoded, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return service.ClientCredentials{}, invalidClient
+	}
+
+	parts := strings.SplitN(string(decoded), ":", 2)
+	if len(parts) != 2 {
+		return service.ClientCredentials{}, invalidClient
+	}
+
+	id, secret := parts[0], parts[1]
+
+	// The credentials are form encoded before they are joined.
+	if id, err = url.QueryUnescape(id); err != nil {
+		return service.ClientCredentials{}, invalidClient
+	}
+
+	if secret, err = url.QueryUnescape(secret); err != nil {
+		return service.ClientCredentials{}, invalidClient
+	}
+
+	return service.ClientCredentials{ID: id, Secret: secret}, nil
+}
+
+// oauthError renders the protocol errors as RFC 6749 section 5.2 error responses,
+// other errors go to the error handler.
+func oauthError(c *fiber.Ctx, err error) error {
+	var oauthErr *core.OAuthError
+	if !errors.As(err, &oauthErr) {
+		return err
+	}
+
+	status := fiber.StatusBadRequest
+	if oauthErr.Code == core.OAuthInvalidClient {
+		status = fiber.StatusUnauthorized
+		c.Set(fiber.HeaderWWWAuthenticate, `Basic realm="oauth"`)
+	}
+
+	noStore(c)
+
+	return c.Status(status).JSON(oauthErrorResponse{
+		Error:            oauthErr.Code,
+		ErrorDescription: oauthErr.Description,
+	})
+}
+
+func noStore(c *fiber.Ctx) {
+	c.Set(fiber.HeaderCacheControl, "no-store")
+	c.Set(fiber.HeaderPragma, "no-cache")
+}
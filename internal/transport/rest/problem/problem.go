@@ -0,0 +1,177 @@
+package problem
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/ernur-eskermes/crud-app/internal/core"
+	"github.com/ernur-eskermes/crud-app/pkg/auth"
+	"github.com/go-playground/validator/v10"
+	"github.com/gofiber/fiber/v2"
+)
+
+const ContentType = "application/problem+json"
+
+// Error is an error with a stable machine-readable code.
+// It is rendered as application/problem+json by the REST error handler.
+type Error struct {
+	Status int
+	Code   string
+	Detail string
+}
+
+func New(status int, code, detail string) *Error {
+	return &Error{
+		Status: status,
+		Code:   code,
+		Detail: detail,
+	}
+}
+
+func (e *Error) Error() string {
+	return e.Detail
+}
+
+var (
+	ErrInvalidBody        = New(fiber.StatusBadRequest, "invalid_body", "request body is malformed")
+	ErrInvalidID          = New(fiber.StatusBadRequest, "invalid_id", "id value of incorrect type")
+	ErrInvalidQuery       = New(fiber.StatusBadRequest, "invalid_query", "query string is malformed")
+	ErrValidation         = New(fiber.StatusBadRequest, "validation_failed", "request validation failed")
+	ErrUnauthorized       = New(fiber.StatusUnauthorized, "unauthorized", "authentication is required")
+	ErrInvalidCredentials = New(fiber.StatusUnauthorized, "invalid_credentials", "username or password is incorrect")
+	ErrInternal           = New(fiber.StatusInternalServerError, "internal_error", "internal server error")
+	ErrInvalidAuthHeader  = New(fiber.StatusBadRequest, "invalid_auth_header", "authorization header must be \"Bearer <token>\"")
+	ErrInvalidToken       = New(fiber.StatusUnauthorized, "invalid_token", "access token is invalid")
+	ErrInvalidAPIKey      = New(fiber.StatusUnauthorized, "invalid_api_key", core.ErrAPIKeyInvalid.Error())
+	ErrInsufficientScope  = New(fiber.StatusForbidden, "insufficient_scope", "api key lacks the scope required by the request")
+	ErrSessionRequired    = New(fiber.StatusForbidden, "session_required", "request must be authenticated by the user, not an api key or an oauth client")
+	ErrForbidden          = New(fiber.StatusForbidden, "forbidden", "user is not allowed to perform the request")
+)
+
+// tokenErrors maps access token errors to problems with distinct codes.
+var tokenErrors = map[error]*Error{
+	auth.ErrTokenExpired:          New(fiber.StatusUnauthorized, "token_expired", auth.ErrTokenExpired.Error()),
+	auth.ErrTokenMalformed:        New(fiber.StatusUnauthorized, "token_malformed", auth.ErrTokenMalformed.Error()),
+	auth.ErrTokenSignatureInvalid: New(fiber.StatusUnauthorized, "token_signature_invalid", auth.ErrTokenSignatureInvalid.Error()),
+	auth.ErrTokenInvalidClaims:    New(fiber.StatusUnauthorized, "token_claims_invalid", auth.ErrTokenInvalidClaims.Error()),
+	core.ErrTokenNotUser:          New(fiber.StatusUnauthorized, "token_not_user", core.ErrTokenNotUser.Error()),
+}
+
+// TokenError returns the problem for an error wrapping one of the auth.ErrToken* errors,
+// ErrInvalidToken for others.
+func TokenError(err error) *Error {
+	for target, p := range tokenErrors {
+		if errors.Is(err, target) {
+			return p
+		}
+	}
+
+	return ErrInvalidToken
+}
+
+// domainErrors maps errors returned by services to problems.
+var domainErrors = []struct {
+	target  error
+	problem *Error
+}{
+	{core.ErrBookNotFound, New(fiber.StatusNotFound, "book_not_found", core.ErrBookNotFound.Error())},
+	{core.ErrUserNotFound, New(fiber.StatusNotFound, "user_not_found", core.ErrUserNotFound.Error())},
+	{core.ErrUserAlreadyExists, New(fiber.StatusConflict, "user_already_exists", core.ErrUserAlreadyExists.Error())},
+	{core.ErrUserCodeExpired, New(fiber.StatusBadRequest, "verification_code_expired", core.ErrUserCodeExpired.Error())},
+	{core.ErrUserCodeUnknownType, New(fiber.StatusBadRequest, "verification_code_invalid", core.ErrUserCodeUnknownType.Error())},
+	{core.ErrUserCodeIncorrect, New(fiber.StatusBadRequest, "verification_code_incorrect", core.ErrUserCodeIncorrect.Error())},
+	{core.ErrAPIKeyNotFound, New(fiber.StatusNotFound, "api_key_not_found", core.ErrAPIKeyNotFound.Error())},
+	{core.ErrScopeForbidden, New(fiber.StatusForbidden, "scope_forbidden", core.ErrScopeForbidden.Error())},
+	{core.ErrProviderNotFound, New(fiber.StatusNotFound, "provider_not_found", core.ErrProviderNotFound.Error())},
+	{core.ErrIdentityAlreadyLinked, New(fiber.StatusConflict, "identity_already_linked", core.ErrIdentityAlreadyLinked.Error())},
+	{core.ErrIdentityUsernameTaken, New(fiber.StatusConflict, "username_taken", core.ErrIdentityUsernameTaken.Error())},
+	{core.ErrLoginStateInvalid, New(fiber.StatusBadRequest, "login_state_invalid", core.ErrLoginStateInvalid.Error())},
+	{core.ErrLoginFailed, New(fiber.StatusUnauthorized, "login_failed", core.ErrLoginFailed.Error())},
+	{core.ErrOAuthClientNotFound, New(fiber.StatusNotFound, "oauth_client_not_found", core.ErrOAuthClientNotFound.Error())},
+	{core.ErrRedirectURIMismatch, New(fiber.StatusBadRequest, "redirect_uri_mismatch", core.ErrRedirectURIMismatch.Error())},
+	{core.ErrConsentRequired, New(fiber.StatusForbidden, "consent_required", core.ErrConsentRequired.Error())},
+	{core.ErrConsentNotFound, New(fiber.StatusNotFound, "consent_not_found", core.ErrConsentNotFound.Error())},
+	{core.ErrCredentialNotFound, New(fiber.StatusNotFound, "passkey_not_found", core.ErrCredentialNotFound.Error())},
+	{core.ErrCredentialAlreadyRegistered, New(fiber.StatusConflict, "passkey_already_registered", core.ErrCredentialAlreadyRegistered.Error())},
+	{core.ErrWebAuthnSessionInvalid, New(fiber.StatusBadRequest, "webauthn_session_invalid", core.ErrWebAuthnSessionInvalid.Error())},
+	{core.ErrWebAuthnFailed, New(fiber.StatusUnauthorized, "webauthn_failed", core.ErrWebAuthnFailed.Error())},
+	{core.ErrSecondFactorRequired, New(fiber.StatusUnauthorized, "second_factor_required", core.ErrSecondFactorRequired.Error())},
+	{core.ErrWebhookNotFound, New(fiber.StatusNotFound, "webhook_not_found", core.ErrWebhookNotFound.Error())},
+	{core.ErrWebhookURLInvalid, New(fiber.StatusBadRequest, "webhook_url_invalid", core.ErrWebhookURLInvalid.Error())},
+	{core.ErrDeliveryNotFound, New(fiber.StatusNotFound, "delivery_not_found", core.ErrDeliveryNotFound.Error())},
+	{core.ErrJobNotFound, New(fiber.StatusNotFound, "job_not_found", core.ErrJobNotFound.Error())},
+	{core.ErrJobNotRetryable, New(fiber.StatusConflict, "job_not_retryable", core.ErrJobNotRetryable.Error())},
+	{core.ErrTooManyAttempts, New(fiber.StatusTooManyRequests, "too_many_attempts", core.ErrTooManyAttempts.Error())},
+}
+
+// Problem is a RFC 7807 problem details object.
+type Problem struct {
+	Type      string       `json:"type"`
+	Title     string       `json:"title"`
+	Status    int          `json:"status"`
+	Detail    string       `json:"detail,omitempty"`
+	Instance  string       `json:"instance,omitempty"`
+	Code      string       `json:"code"`
+	RequestID string       `json:"request_id,omitempty"`
+	Errors    []FieldError `json:"errors,omitempty"`
+	// SecondFactorToken is set with the second_factor_required code, it starts the passkey login.
+	SecondFactorToken string `json:"second_factor_token,omitempty"`
+}
+
+type FieldError struct {
+	Field string `json:"field"`
+	Tag   string `json:"tag"`
+	Param string `json:"param,omitempty"`
+}
+
+// From describes err as a Problem. The second return value is false
+// if err is not known, in that case the problem hides the error details.
+func From(err error) (Problem, bool) {
+	var (
+		problemErr       *Error
+		fiberErr         *fiber.Error
+		validationErrors validator.ValidationErrors
+	)
+
+	switch {
+	case errors.As(err, &problemErr):
+		return newProblem(problemErr), true
+	case errors.As(err, &validationErrors):
+		p := newProblem(ErrValidation)
+		for _, fe := range validationErrors {
+			p.Errors = append(p.Errors, FieldError{
+				Field: fe.Namespace(),
+				Tag:   fe.Tag(),
+				Param: fe.Param(),
+			})
+		}
+
+		return p, true
+	case errors.As(err, &fiberErr):
+		return newProblem(New(fiberErr.Code, statusCode(fiberErr.Code), fiberErr.Message)), true
+	}
+
+	for _, de := range domainErrors {
+		if errors.Is(err, de.target) {
+			return newProblem(de.problem), true
+		}
+	}
+
+	return newProblem(ErrInternal), false
+}
+
+func newProblem(err *Error) Problem {
+	return Problem{
+		Type:   "about:blank",
+		Title:  http.StatusText(err.Status),
+		Status: err.Status,
+		Detail: err.Detail,
+		Code:   err.Code,
+	}
+}
+
+// statusCode derives a code from the HTTP status text, e.g. "too_many_requests".
+func statusCode(status int) string {
+	return strings.ReplaceAll(strings.ToLower(http.StatusText(status)), " ", "_")
+}
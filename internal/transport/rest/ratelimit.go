@@ -0,0 +1,190 @@
+package rest
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"math"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/ernur-eskermes/crud-app/internal/config"
+	"github.com/ernur-eskermes/crud-app/internal/core"
+	"github.com/ernur-eskermes/crud-app/internal/service"
+	"github.com/ernur-eskermes/crud-app/pkg/auth"
+	"github.com/ernur-eskermes/crud-app/pkg/logging"
+	"github.com/ernur-eskermes/crud-app/pkg/lru"
+	"github.com/ernur-eskermes/crud-app/pkg/ratelimit"
+	"github.com/gofiber/fiber/v2"
+)
+
+const (
+	defaultLimitRule = "default"
+
+	// The owners of the API keys recently used are remembered, so a key is looked up once a minute at most.
+	apiKeyOwnersSize = 10000
+	apiKeyOwnersTTL  = time.Minute
+
+	headerAPIKey = "X-API-Key"
+
+	headerRateLimitLimit     = "RateLimit-Limit"
+	headerRateLimitRemaining = "RateLimit-Remaining"
+	headerRateLimitReset     = "RateLimit-Reset"
+)
+
+var rateLimitHeaders = []string{
+	headerRateLimitLimit, headerRateLimitRemaining, headerRateLimitReset, fiber.HeaderRetryAfter,
+}
+
+type limitRule struct {
+	name   string
+	method string
+	path   string
+	limit  ratelimit.Limit
+}
+
+func (r limitRule) matches(method, path string) bool {
+	if r.method != "" && r.method != method {
+		return false
+	}
+
+	return path == r.path || strings.HasPrefix(path, strings.TrimSuffix(r.path, "/")+"/")
+}
+
+// rateLimiter limits requests per route rule, keyed by the user ID of the requests with a valid
+// access token or API key and by the client IP otherwise.
+type rateLimiter struct {
+	store        ratelimit.Store
+	tokenManager auth.TokenManager
+	apiKeys      service.APIKeys
+	// keyOwners maps the SHA-256 of the valid API keys to the user ID of their owner.
+	keyOwners *lru.Cache
+	rules     atomic.Value
+}
+
+func newRateLimiter(store ratelimit.Store, tokenManager auth.TokenManager, apiKeys service.APIKeys) *rateLimiter {
+	return &rateLimiter{
+		store:        store,
+		tokenManager: tokenManager,
+		apiKeys:      apiKeys,
+		keyOwners:    lru.New(apiKeyOwnersSize, apiKeyOwnersTTL),
+	}
+}
+
+// configure replaces the rules. Buckets are kept, so the new limits apply
+// to the tokens already taken.
+func (l *rateLimiter) configure(cfg config.LimiterConfig) {
+	rules := make([]limitRule, 0, len(cfg.Rules)+1)
+
+	for _, rule := range cfg.Rules {
+		rules = append(rules, limitRule{
+			name:   rule.Name,
+			method: rule.Method,
+			path:   rule.Path,
+			limit:  ratelimit.Limit{Rate: rule.RPS, Burst: rule.Burst},
+		})
+	}
+
+	rules = append(rules, limitRule{
+		name:  defaultLimitRule,
+		path:  "/",
+		limit: ratelimit.Limit{Rate: cfg.RPS, Burst: cfg.Burst},
+	})
+
+	l.rules.Store(rules)
+}
+
+func (l *rateLimiter) handle(c *fiber.Ctx) error {
+	if isHealthCheck(c) {
+		return c.Next()
+	}
+
+	rule := l.match(c.Method(), c.Path())
+
+	res, err := l.store.Take(c.UserContext(), rule.name+":"+l.identity(c), rule.limit)
+	if err != nil {
+		// An unavailable store must not take the API down with it.
+		logging.FromContext(c.UserContext()).Errorf("rate limiter: %v", err)
+
+		return c.Next()
+	}
+
+	c.Set(headerRateLimitLimit, strconv.Itoa(res.Limit))
+	c.Set(headerRateLimitRemaining, strconv.Itoa(res.Remaining))
+	c.Set(headerRateLimitReset, seconds(res.Reset))
+
+	if !res.Allowed {
+		c.Set(fiber.HeaderRetryAfter, seconds(res.RetryAfter))
+
+		return fiber.ErrTooManyRequests
+	}
+
+	return c.Next()
+}
+
+// match returns the first rule matching the request, the default rule matches everything.
+func (l *rateLimiter) match(method, path string) limitRule {
+	rules := l.rules.Load().([]limitRule)
+
+	for _, rule := range rules {
+		if rule.matches(method, path) {
+			return rule
+		}
+	}
+
+	return rules[len(rules)-1]
+}
+
+// identity returns the key of the buckets of the request. Invalid credentials don't get buckets of their own,
+// such requests are limited by the IP like the anonymous ones.
+func (l *rateLimiter) identity(c *fiber.Ctx) string {
+	token := c.Get(headerAPIKey)
+	if token == "" {
+		token = strings.TrimPrefix(c.Get(fiber.HeaderAuthorization), "Bearer ")
+	}
+
+	switch {
+	case token == "":
+	case service.IsAPIKey(token):
+		if owner, ok := l.apiKeyOwner(c.UserContext(), token); ok {
+			return "user:" + owner
+		}
+	default:
+		if claims, err := l.tokenManager.Parse(token); err == nil {
+			return "user:" + claims.Subject
+		}
+	}
+
+	return "ip:" + c.IP()
+}
+
+// apiKeyOwner returns the user ID of the owner of a valid API key.
+func (l *rateLimiter) apiKeyOwner(ctx context.Context, rawKey string) (string, bool) {
+	sum := sha256.Sum256([]byte(rawKey))
+	keyHash := hex.EncodeToString(sum[:])
+
+	if owner, ok := l.keyOwners.Get(keyHash); ok {
+		return owner.(string), true //nolint:forcetypeassert
+	}
+
+	claims, err := l.apiKeys.Authenticate(ctx, rawKey)
+	if err != nil {
+		if !errors.Is(err, core.ErrAPIKeyInvalid) {
+			logging.FromContext(ctx).Errorf("rate limiter: %v", err)
+		}
+
+		return "", false
+	}
+
+	l.keyOwners.Set(keyHash, claims.Subject)
+
+	return claims.Subject, true
+}
+
+// seconds formats d as whole seconds, rounded up so clients never retry too early.
+func seconds(d time.Duration) string {
+	return strconv.Itoa(int(math.Ceil(d.Seconds())))
+}
@@ -0,0 +1,162 @@
+package rest
+
+import (
+	"context"
+	"io"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/ernur-eskermes/crud-app/internal/config"
+	"github.com/ernur-eskermes/crud-app/internal/core"
+	"github.com/ernur-eskermes/crud-app/internal/service"
+	"github.com/ernur-eskermes/crud-app/pkg/auth"
+	"github.com/ernur-eskermes/crud-app/pkg/ratelimit"
+)
+
+const testAPIKey = service.APIKeyPrefix + "abc_secret"
+
+type fakeTokenManager struct {
+	auth.TokenManager
+}
+
+func (fakeTokenManager) Parse(token string) (auth.Claims, error) {
+	if token != "jwt" {
+		return auth.Claims{}, auth.ErrTokenMalformed
+	}
+
+	return auth.Claims{Subject: "jwt-user"}, nil
+}
+
+type fakeAPIKeys struct {
+	service.APIKeys
+	lookups int
+}
+
+func (k *fakeAPIKeys) Authenticate(_ context.Context, rawKey string) (auth.Claims, error) {
+	k.lookups++
+
+	if rawKey != testAPIKey {
+		return auth.Claims{}, core.ErrAPIKeyInvalid
+	}
+
+	return auth.Claims{Subject: "key-owner", TokenType: auth.TokenTypeAPIKey}, nil
+}
+
+func TestRateLimiterIdentity(t *testing.T) {
+	tests := []struct {
+		name    string
+		headers map[string]string
+		want    string
+	}{
+		{name: "anonymous", want: "ip:0.0.0.0"},
+		{name: "access token", headers: map[string]string{"Authorization": "Bearer jwt"}, want: "user:jwt-user"},
+		{name: "invalid access token", headers: map[string]string{"Authorization": "Bearer forged"}, want: "ip:0.0.0.0"},
+		{name: "api key header", headers: map[string]string{"X-API-Key": testAPIKey}, want: "user:key-owner"},
+		{name: "bearer api key", headers: map[string]string{"Authorization": "Bearer " + testAPIKey}, want: "user:key-owner"},
+		{
+			name:    "invalid api key",
+			headers: map[string]string{"X-API-Key": service.APIKeyPrefix + "abc_guess"},
+			want:    "ip:0.0.0.0",
+		},
+		{
+			name:    "api key header first",
+			headers: map[string]string{"X-API-Key": testAPIKey, "Authorization": "Bearer jwt"},
+			want:    "user:key-owner",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := newRateLimiter(nil, fakeTokenManager{}, &fakeAPIKeys{})
+
+			if got := identityOf(t, l, tt.headers); got != tt.want {
+				t.Errorf("identity() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRateLimiterRemembersAPIKeyOwners(t *testing.T) {
+	apiKeys := &fakeAPIKeys{}
+	l := newRateLimiter(nil, fakeTokenManager{}, apiKeys)
+
+	for i := 0; i < 3; i++ {
+		identityOf(t, l, map[string]string{"X-API-Key": testAPIKey})
+		identityOf(t, l, map[string]string{"X-API-Key": service.APIKeyPrefix + "abc_guess"})
+	}
+
+	// The valid key is looked up once, the invalid one every time.
+	if apiKeys.lookups != 4 {
+		t.Errorf("api key lookups = %d, want 4", apiKeys.lookups)
+	}
+}
+
+func TestRateLimiterHandle(t *testing.T) {
+	store := ratelimit.NewMemoryStore(time.Minute)
+	l := newRateLimiter(store, fakeTokenManager{}, &fakeAPIKeys{})
+	l.configure(config.LimiterConfig{RPS: 0.001, Burst: 1})
+
+	app := fiber.New()
+	app.Use(l.handle)
+	app.Get("/", func(c *fiber.Ctx) error {
+		return c.SendStatus(fiber.StatusNoContent)
+	})
+
+	// Another key gets buckets of its own only when it is valid: the guesses share those of the IP.
+	tests := []struct {
+		apiKey string
+		want   int
+	}{
+		{apiKey: testAPIKey, want: fiber.StatusNoContent},
+		{apiKey: testAPIKey, want: fiber.StatusTooManyRequests},
+		{apiKey: service.APIKeyPrefix + "abc_guess1", want: fiber.StatusNoContent},
+		{apiKey: service.APIKeyPrefix + "abc_guess2", want: fiber.StatusTooManyRequests},
+	}
+
+	for i, tt := range tests {
+		req := httptest.NewRequest(fiber.MethodGet, "/", nil)
+		req.Header.Set("X-API-Key", tt.apiKey)
+
+		resp, err := app.Test(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		_, _ = io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+
+		if resp.StatusCode != tt.want {
+			t.Errorf("request #%d status = %d, want %d", i+1, resp.StatusCode, tt.want)
+		}
+	}
+}
+
+func identityOf(t *testing.T, l *rateLimiter, headers map[string]string) string {
+	t.Helper()
+
+	var identity string
+
+	app := fiber.New()
+	app.Get("/", func(c *fiber.Ctx) error {
+		identity = l.identity(c)
+
+		return nil
+	})
+
+	req := httptest.NewRequest(fiber.MethodGet, "/", nil)
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+
+	resp, err := app.Test(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	resp.Body.Close()
+
+	return identity
+}
@@ -0,0 +1,114 @@
+package v1
+
+import (
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/ernur-eskermes/crud-app/internal/core"
+)
+
+func (h *Handler) initAPIKeysRoutes(api fiber.Router) {
+	keys := api.Group("/api-keys", h.userIdentity, requireSession)
+	{
+		keys.Post("", h.createAPIKey)
+		keys.Get("", h.getAPIKeys)
+		keys.Delete("/:id", h.revokeAPIKey)
+	}
+}
+
+type apiKeyResponse struct {
+	core.APIKey
+	// Key is the secret, returned only once on creation.
+	Key string `json:"key"`
+}
+
+// @Summary Create API Key
+// @Tags api-keys
+// @Description create a personal api key, the key is shown only in this response
+// @ModuleID createAPIKey
+// @Security UsersAuth
+// @Accept  json
+// @Produce  json
+// @Param input body core.CreateAPIKeyInput true "api key"
+// @Success 201 {object} apiKeyResponse
+// @Failure 400 {object} problem.Problem
+// @Failure 401 {object} problem.Problem
+// @Failure 403 {object} problem.Problem
+// @Router /api-keys [post]
+func (h *Handler) createAPIKey(c *fiber.Ctx) error {
+	userID, err := h.authorizedUserID(c)
+	if err != nil {
+		return err
+	}
+
+	var inp core.CreateAPIKeyInput
+	if err = h.parseBody(c, &inp); err != nil {
+		return err
+	}
+
+	key, secret, err := h.services.APIKeys.Create(c.UserContext(), userID, inp)
+	if err != nil {
+		return err
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(apiKeyResponse{
+		APIKey: key,
+		Key:    secret,
+	})
+}
+
+// @Summary Get API Keys
+// @Tags api-keys
+// @Description get api keys of the user, without the secrets
+// @ModuleID getAPIKeys
+// @Security UsersAuth
+// @Accept  json
+// @Produce  json
+// @Success 200 {object} []core.APIKey
+// @Failure 401 {object} problem.Problem
+// @Failure 403 {object} problem.Problem
+// @Router /api-keys [get]
+func (h *Handler) getAPIKeys(c *fiber.Ctx) error {
+	userID, err := h.authorizedUserID(c)
+	if err != nil {
+		return err
+	}
+
+	keys, err := h.services.APIKeys.List(c.UserContext(), userID)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(keys)
+}
+
+// @Summary Revoke API Key
+// @Tags api-keys
+// @Description revoke api key by id
+// @ModuleID revokeAPIKey
+// @Security UsersAuth
+// @Accept  json
+// @Produce  json
+// @Param id path string true "api key id"
+// @Success 204 {string} string "No Content"
+// @Failure 400 {object} problem.Problem
+// @Failure 401 {object} problem.Problem
+// @Failure 403 {object} problem.Problem
+// @Failure 404 {object} problem.Problem
+// @Router /api-keys/{id} [delete]
+func (h *Handler) revokeAPIKey(c *fiber.Ctx) error {
+	userID, err := h.authorizedUserID(c)
+	if err != nil {
+		return err
+	}
+
+	id, err := parseID(c)
+	if err != nil {
+		return err
+	}
+
+	if err = h.services.APIKeys.Revoke(c.UserContext(), userID, id); err != nil {
+		return err
+	}
+
+	return c.SendStatus(fiber.StatusNoContent)
+}
@@ -7,6 +7,7 @@ import (
 
 	"github.com/ernur-eskermes/crud-app/internal/core"
 	"github.com/ernur-eskermes/crud-app/internal/service"
+	"github.com/ernur-eskermes/crud-app/internal/transport/rest/problem"
 )
 
 func (h *Handler) initAuthRoutes(api fiber.Router) {
@@ -40,29 +41,20 @@ type tokenResponse struct {
 // @Produce  json
 // @Param input body userSignUpInput true "sign up info"
 // @Success 201 {string} string "Created"
-// @Failure 400 {object} response
+// @Failure 400 {object} problem.Problem
+// @Failure 409 {object} problem.Problem
 // @Router /auth/sign-up [post]
 func (h *Handler) userSignUp(c *fiber.Ctx) error {
 	var inp userSignUpInput
-	if err := c.BodyParser(&inp); err != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(response{err.Error()})
+	if err := h.parseBody(c, &inp); err != nil {
+		return err
 	}
 
-	if validationError := h.validateStruct(inp); validationError != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(validationError)
-	}
-
-	if err := h.services.Users.SignUp(c.Context(), service.UserSignUpInput{
+	if err := h.services.Users.SignUp(c.UserContext(), service.UserSignUpInput{
 		Username: inp.Username,
 		Password: inp.Password,
 	}); err != nil {
-		if errors.Is(err, core.ErrUserAlreadyExists) {
-			return c.Status(fiber.StatusBadRequest).JSON(response{err.Error()})
-		}
-
-		h.logger.Error(err)
-
-		return c.SendStatus(fiber.StatusInternalServerError)
+		return err
 	}
 
 	return c.SendStatus(fiber.StatusCreated)
@@ -70,37 +62,33 @@ func (h *Handler) userSignUp(c *fiber.Ctx) error {
 
 // @Summary User SignIn
 // @Tags users-auth
-// @Description user sign in
+// @Description user sign in. Users with passkeys get 401 second_factor_required with a second_factor_token,
+// @Description the sign in is completed with /auth/webauthn/login/begin.
 // @ModuleID userSignIn
 // @Accept  json
 // @Produce  json
 // @Param input body signInInput true "sign up info"
 // @Success 200 {object} tokenResponse
-// @Failure 400 {object} response
+// @Failure 400 {object} problem.Problem
+// @Failure 401 {object} problem.Problem
+// @Failure 429 {object} problem.Problem
 // @Router /auth/sign-in [post]
 func (h *Handler) userSignIn(c *fiber.Ctx) error {
 	var inp signInInput
-
-	if err := c.BodyParser(&inp); err != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(response{err.Error()})
-	}
-
-	if validationError := h.validateStruct(inp); validationError != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(validationError)
+	if err := h.parseBody(c, &inp); err != nil {
+		return err
 	}
 
-	res, err := h.services.Users.SignIn(c.Context(), service.UserSignInInput{
+	res, err := h.services.Users.SignIn(c.UserContext(), service.UserSignInInput{
 		Username: inp.Username,
 		Password: inp.Password,
 	})
 	if err != nil {
 		if errors.Is(err, core.ErrUserNotFound) {
-			return c.Status(fiber.StatusBadRequest).JSON(response{err.Error()})
+			return problem.ErrInvalidCredentials
 		}
 
-		h.logger.Error(err)
-
-		return c.SendStatus(fiber.StatusInternalServerError)
+		return err
 	}
 
 	return c.JSON(tokenResponse{
@@ -121,30 +109,18 @@ type verifyInput struct {
 // @Produce  json
 // @Param input body verifyInput true "verify"
 // @Success 200
-// @Failure 400 {object} response
+// @Failure 400 {object} problem.Problem
+// @Failure 404 {object} problem.Problem
+// @Failure 429 {object} problem.Problem
 // @Router /auth/verify [post]
 func (h *Handler) verify(c *fiber.Ctx) error {
 	var inp verifyInput
-
-	if err := c.BodyParser(&inp); err != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(response{err.Error()})
-	}
-
-	if validationError := h.validateStruct(inp); validationError != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(validationError)
+	if err := h.parseBody(c, &inp); err != nil {
+		return err
 	}
 
-	if err := h.services.Users.Verify(c.Context(), inp.Username, inp.Code); err != nil {
-		if errors.Is(err, core.ErrUserNotFound) ||
-			errors.Is(err, core.ErrUserCodeExpired) ||
-			errors.Is(err, core.ErrUserCodeUnknownType) ||
-			errors.Is(err, core.ErrUserCodeIncorrect) {
-			return c.Status(fiber.StatusBadRequest).JSON(response{err.Error()})
-		}
-
-		h.logger.Error(err)
-
-		return c.SendStatus(fiber.StatusInternalServerError)
+	if err := h.services.Users.Verify(c.UserContext(), inp.Username, inp.Code); err != nil {
+		return err
 	}
 
 	return c.SendStatus(fiber.StatusOK)
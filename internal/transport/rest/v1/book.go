@@ -1,13 +1,25 @@
 package v1
 
 import (
-	"context"
-	"errors"
-
-	"github.com/google/uuid"
+	"bufio"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
 
 	"github.com/ernur-eskermes/crud-app/internal/core"
+	"github.com/ernur-eskermes/crud-app/internal/service"
+	"github.com/ernur-eskermes/crud-app/internal/transport/rest/problem"
 	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/valyala/fasthttp"
+)
+
+const (
+	lastEventIDHeader = "Last-Event-ID"
+	// streamRetry is how long clients wait before reconnecting to a closed stream.
+	streamRetry = 3 * time.Second
 )
 
 /*
@@ -31,10 +43,11 @@ func NewBookHandler(service BookService) *BookHandler {
 func (h *Handler) initBooksRoutes(api fiber.Router) {
 	books := api.Group("/books")
 	{
-		books.Get("", h.getAllBooks)
-		books.Get("/:id", h.getBookByID)
+		books.Get("", h.readSession, h.getAllBooks)
+		books.Get("/stream", h.streamBooks)
+		books.Get("/:id", h.readSession, h.getBookByID)
 
-		authenticated := books.Group("", h.userIdentity)
+		authenticated := books.Group("", h.userIdentity, requireScope(core.ScopeBooksWrite))
 		{
 			authenticated.Post("", h.createBook)
 			authenticated.Delete("/:id", h.deleteBook)
@@ -50,24 +63,26 @@ func (h *Handler) initBooksRoutes(api fiber.Router) {
 // @Accept  json
 // @Produce  json
 // @Param id path string true "book id"
+// @Param If-Modified-Since header string false "Last-Modified of the cached book"
 // @Success 200 {object} core.Book
-// @Failure 404 {object} response
+// @Header 200 {string} Last-Modified "when the book was last updated"
+// @Success 304 {string} string "Not Modified"
+// @Failure 400 {object} problem.Problem
+// @Failure 404 {object} problem.Problem
 // @Router /books/{id} [get]
 func (h *Handler) getBookByID(c *fiber.Ctx) error {
-	id, err := uuid.Parse(c.Params("id"))
+	id, err := parseID(c)
 	if err != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(response{"id value of incorrect type"})
+		return err
 	}
 
-	book, err := h.services.Books.GetByID(context.TODO(), id)
+	book, err := h.services.Books.GetByID(c.UserContext(), id)
 	if err != nil {
-		if errors.Is(err, core.ErrBookNotFound) {
-			return c.SendStatus(fiber.StatusNotFound)
-		}
-
-		h.logger.Error(err)
+		return err
+	}
 
-		return c.SendStatus(fiber.StatusInternalServerError)
+	if notModified(c, book.UpdatedAt) {
+		return c.SendStatus(fiber.StatusNotModified)
 	}
 
 	return c.JSON(book)
@@ -78,43 +93,28 @@ func (h *Handler) getBookByID(c *fiber.Ctx) error {
 // @Description create book
 // @ModuleID createBook
 // @Security UsersAuth
+// @Security APIKeyAuth
 // @Accept  json
 // @Produce  json
 // @Param input body core.CreateBookInput true "create book"
 // @Success 201 {string} string "Created"
-// @Failure 400 {object} response
+// @Failure 400 {object} problem.Problem
+// @Failure 401 {object} problem.Problem
+// @Failure 403 {object} problem.Problem
 // @Router /books [post]
 func (h *Handler) createBook(c *fiber.Ctx) error {
-	userID, err := getUserID(c)
+	userID, err := h.authorizedUserID(c)
 	if err != nil {
-		h.logger.Warning(err)
-
-		return c.SendStatus(fiber.StatusUnauthorized)
-	}
-
-	if _, err = h.services.Users.GetByID(context.TODO(), userID); err != nil {
-		if errors.Is(err, core.ErrUserNotFound) {
-			return c.Status(fiber.StatusBadRequest).JSON(response{"user not found"})
-		}
-
-		return c.SendStatus(fiber.StatusInternalServerError)
+		return err
 	}
 
 	var inp core.CreateBookInput
-
-	if err = c.BodyParser(&inp); err != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(response{err.Error()})
+	if err = h.parseBody(c, &inp); err != nil {
+		return err
 	}
 
-	if validationError := h.validateStruct(inp); validationError != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(validationError)
-	}
-
-	err = h.services.Books.Create(context.TODO(), inp, userID)
-	if err != nil {
-		h.logger.Error(err)
-
-		return c.SendStatus(fiber.StatusInternalServerError)
+	if err = h.services.Books.Create(c.UserContext(), inp, userID); err != nil {
+		return err
 	}
 
 	return c.SendStatus(fiber.StatusCreated)
@@ -125,41 +125,29 @@ func (h *Handler) createBook(c *fiber.Ctx) error {
 // @Description delete book by id
 // @ModuleID deleteBook
 // @Security UsersAuth
+// @Security APIKeyAuth
 // @Accept  json
 // @Produce  json
 // @Param id path string true "book id"
 // @Success 204 {string} string "No Content"
-// @Failure 400,404 {object} response
+// @Failure 400 {object} problem.Problem
+// @Failure 401 {object} problem.Problem
+// @Failure 403 {object} problem.Problem
+// @Failure 404 {object} problem.Problem
 // @Router /books/{id} [delete]
 func (h *Handler) deleteBook(c *fiber.Ctx) error {
-	userID, err := getUserID(c)
+	userID, err := h.authorizedUserID(c)
 	if err != nil {
-		h.logger.Warning(err)
-
-		return c.SendStatus(fiber.StatusUnauthorized)
+		return err
 	}
 
-	id, err := uuid.Parse(c.Params("id"))
+	id, err := parseID(c)
 	if err != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(response{"id value of incorrect type"})
-	}
-
-	if _, err = h.services.Users.GetByID(context.TODO(), userID); err != nil {
-		if errors.Is(err, core.ErrUserNotFound) {
-			return c.Status(fiber.StatusBadRequest).JSON(response{"user not found"})
-		}
-
-		return c.SendStatus(fiber.StatusInternalServerError)
+		return err
 	}
 
-	if err = h.services.Books.Delete(context.TODO(), id, userID); err != nil {
-		if errors.Is(err, core.ErrBookNotFound) {
-			return c.SendStatus(fiber.StatusForbidden)
-		}
-
-		h.logger.Error(err)
-
-		return c.SendStatus(fiber.StatusInternalServerError)
+	if err = h.services.Books.Delete(c.UserContext(), id, userID); err != nil {
+		return err
 	}
 
 	return c.SendStatus(fiber.StatusNoContent)
@@ -171,14 +159,26 @@ func (h *Handler) deleteBook(c *fiber.Ctx) error {
 // @ModuleID getAllBooks
 // @Accept  json
 // @Produce  json
+// @Param If-Modified-Since header string false "Last-Modified of the cached books"
 // @Success 200 {object} []core.Book
+// @Header 200 {string} Last-Modified "when a book was last created, updated or deleted"
+// @Success 304 {string} string "Not Modified"
+// @Failure 500 {object} problem.Problem
 // @Router /books [get]
 func (h *Handler) getAllBooks(c *fiber.Ctx) error {
-	books, err := h.services.Books.GetAll(context.TODO())
+	// Read before the books, so a change made meanwhile is newer than the Last-Modified of the response.
+	modified, err := h.services.Books.LastModified(c.UserContext())
 	if err != nil {
-		h.logger.Error(err)
+		return err
+	}
+
+	if notModified(c, modified) {
+		return c.SendStatus(fiber.StatusNotModified)
+	}
 
-		return c.SendStatus(fiber.StatusInternalServerError)
+	books, err := h.services.Books.GetAll(c.UserContext())
+	if err != nil {
+		return err
 	}
 
 	return c.JSON(books)
@@ -189,52 +189,160 @@ func (h *Handler) getAllBooks(c *fiber.Ctx) error {
 // @Description update book
 // @ModuleID updateBook
 // @Security UsersAuth
+// @Security APIKeyAuth
 // @Accept  json
 // @Produce  json
 // @Param id path string true "book id"
 // @Param input body core.UpdateBookInput true "update book"
 // @Success 200 {string} string "OK"
-// @Failure 400 {object} response
+// @Failure 400 {object} problem.Problem
+// @Failure 401 {object} problem.Problem
+// @Failure 403 {object} problem.Problem
+// @Failure 404 {object} problem.Problem
 // @Router /books/{id} [put]
 func (h *Handler) updateBook(c *fiber.Ctx) error {
-	userID, err := getUserID(c)
+	userID, err := h.authorizedUserID(c)
 	if err != nil {
-		h.logger.Warning(err)
-
-		return c.SendStatus(fiber.StatusUnauthorized)
+		return err
 	}
 
-	id, err := uuid.Parse(c.Params("id"))
+	id, err := parseID(c)
 	if err != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(response{"id value of incorrect type"})
+		return err
 	}
 
-	if _, err = h.services.Users.GetByID(context.TODO(), userID); err != nil {
-		if errors.Is(err, core.ErrUserNotFound) {
-			return c.Status(fiber.StatusBadRequest).JSON(response{"user not found"})
-		}
+	var inp core.UpdateBookInput
+	if err = h.parseBody(c, &inp); err != nil {
+		return err
+	}
 
-		return c.SendStatus(fiber.StatusInternalServerError)
+	if err = h.services.Books.Update(c.UserContext(), id, userID, inp); err != nil {
+		return err
 	}
 
-	var inp core.UpdateBookInput
-	if err = c.BodyParser(&inp); err != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(response{err.Error()})
+	return c.SendStatus(fiber.StatusOK)
+}
+
+// @Summary Stream Books
+// @Tags books
+// @Description stream the created, updated and deleted books as Server-Sent Events, of an author or of a single book.
+// @Description Every event has the id of the domain event and its JSON as data; a client reconnecting with
+// @Description the Last-Event-ID header gets the events it missed, or a "reset" event when they are no longer kept
+// @Description and the books have to be reloaded. Clients falling behind are disconnected and resume the same way.
+// @ModuleID streamBooks
+// @Produce  text/event-stream
+// @Param author query string false "author id"
+// @Param book_id query string false "book id"
+// @Param Last-Event-ID header string false "id of the last event received"
+// @Success 200 {string} string "event stream"
+// @Failure 400 {object} problem.Problem
+// @Router /books/stream [get]
+func (h *Handler) streamBooks(c *fiber.Ctx) error {
+	var (
+		filter core.BookStreamFilter
+		err    error
+	)
+
+	if filter.Author, err = parseUUIDQuery(c, "author"); err != nil {
+		return err
+	}
+
+	if filter.BookID, err = parseUUIDQuery(c, "book_id"); err != nil {
+		return err
 	}
 
-	if validationError := h.validateStruct(inp); validationError != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(validationError)
+	var lastEventID *int64
+
+	if raw := c.Get(lastEventIDHeader); raw != "" {
+		// A malformed id is not kept either, the client gets a reset.
+		id, _ := strconv.ParseInt(raw, 10, 64)
+		lastEventID = &id
 	}
 
-	if err = h.services.Books.Update(context.TODO(), id, userID, inp); err != nil {
-		if errors.Is(err, core.ErrBookNotFound) {
-			return c.SendStatus(fiber.StatusForbidden)
+	sub := h.services.BookStream.Subscribe(filter, lastEventID)
+	conn := c.Context().Conn()
+
+	c.Set(fiber.HeaderContentType, "text/event-stream")
+	c.Set(fiber.HeaderCacheControl, "no-cache")
+	c.Set(fiber.HeaderConnection, "keep-alive")
+	c.Set("X-Accel-Buffering", "no")
+
+	c.Context().SetBodyStreamWriter(writeBookStream(sub, conn))
+
+	return nil
+}
+
+// writeBookStream writes the events of the subscription until it is closed or the client is gone.
+func writeBookStream(sub *service.BookSubscription, conn net.Conn) fasthttp.StreamWriter {
+	return func(w *bufio.Writer) {
+		defer sub.Close()
+
+		heartbeat := time.NewTicker(sub.Heartbeat)
+		defer heartbeat.Stop()
+
+		fmt.Fprintf(w, "retry: %d\n\n", streamRetry.Milliseconds())
+
+		if sub.Missed {
+			fmt.Fprint(w, "event: reset\ndata: {}\n\n")
 		}
 
-		h.logger.Error(err)
+		for {
+			// A client that can't take a write within a heartbeat is disconnected.
+			if err := conn.SetWriteDeadline(time.Now().Add(sub.Heartbeat)); err != nil {
+				return
+			}
+
+			if err := w.Flush(); err != nil {
+				return
+			}
+
+			select {
+			case event, ok := <-sub.Events():
+				if !ok {
+					return
+				}
+
+				writeBookEvent(w, event)
+			case <-heartbeat.C:
+				fmt.Fprint(w, ": heartbeat\n\n")
+			}
+		}
+	}
+}
+
+func writeBookEvent(w *bufio.Writer, event service.BookEvent) {
+	fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", event.ID, event.Type, event.Data)
+}
+
+// notModified sets the caching headers of a resource last modified at the time and reports
+// whether the client has it already. Clients have to revalidate the resource on every use.
+func notModified(c *fiber.Ctx, modified time.Time) bool {
+	c.Set(fiber.HeaderCacheControl, "no-cache")
 
-		return c.SendStatus(fiber.StatusInternalServerError)
+	// Last-Modified has a precision of a second, the resource may still change within the current one.
+	if modified.IsZero() || time.Since(modified) < time.Second {
+		return false
 	}
 
-	return c.SendStatus(fiber.StatusOK)
+	modified = modified.UTC().Truncate(time.Second)
+	c.Set(fiber.HeaderLastModified, modified.Format(http.TimeFormat))
+
+	since, err := http.ParseTime(c.Get(fiber.HeaderIfModifiedSince))
+
+	return err == nil && !modified.After(since)
+}
+
+// parseUUIDQuery returns the id in the query parameter, nil when it is not set.
+func parseUUIDQuery(c *fiber.Ctx, name string) (*uuid.UUID, error) {
+	raw := c.Query(name)
+	if raw == "" {
+		return nil, nil
+	}
+
+	id, err := uuid.Parse(raw)
+	if err != nil {
+		return nil, problem.ErrInvalidQuery
+	}
+
+	return &id, nil
 }
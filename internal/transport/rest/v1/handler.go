@@ -1,13 +1,13 @@
 package v1
 
 import (
-	"errors"
-
 	"github.com/ernur-eskermes/crud-app/internal/service"
+	"github.com/ernur-eskermes/crud-app/internal/transport/rest/problem"
 	"github.com/ernur-eskermes/crud-app/pkg/auth"
 	"github.com/ernur-eskermes/crud-app/pkg/logging"
 	"github.com/go-playground/validator/v10"
 	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
 )
 
 type Handler struct {
@@ -30,35 +30,43 @@ func (h *Handler) Init(api fiber.Router) {
 	v1 := api.Group("/v1")
 	{
 		h.initAuthRoutes(v1)
+		h.initOIDCRoutes(v1)
 		h.initBooksRoutes(v1)
+		h.initAPIKeysRoutes(v1)
+		h.initOAuthRoutes(v1)
+		h.initWebAuthnRoutes(v1)
+		h.initWebhooksRoutes(v1)
+		h.initJobsRoutes(v1)
 	}
 }
 
-type response struct {
-	Message string `json:"message"`
+// parseBody decodes the request body into inp and validates it.
+func (h *Handler) parseBody(c *fiber.Ctx, inp interface{}) error {
+	if err := c.BodyParser(inp); err != nil {
+		return problem.ErrInvalidBody
+	}
+
+	return h.validate.Struct(inp)
 }
 
-type ErrorResponse struct {
-	FailedField string
-	Tag         string
-	Value       string
+// parseQuery decodes the query string into inp and validates it.
+func (h *Handler) parseQuery(c *fiber.Ctx, inp interface{}) error {
+	if err := c.QueryParser(inp); err != nil {
+		return problem.ErrInvalidQuery
+	}
+
+	return h.validate.Struct(inp)
 }
 
-func (h *Handler) validateStruct(st interface{}) []*ErrorResponse {
-	var res []*ErrorResponse
+func parseID(c *fiber.Ctx) (uuid.UUID, error) {
+	return parseUUIDParam(c, "id")
+}
 
-	if err := h.validate.Struct(st); err != nil {
-		var validationErrors validator.ValidationErrors
-		if errors.As(err, &validationErrors) {
-			for _, err := range validationErrors {
-				var element ErrorResponse
-				element.FailedField = err.StructNamespace()
-				element.Tag = err.Tag()
-				element.Value = err.Param()
-				res = append(res, &element)
-			}
-		}
+func parseUUIDParam(c *fiber.Ctx, name string) (uuid.UUID, error) {
+	id, err := uuid.Parse(c.Params(name))
+	if err != nil {
+		return uuid.UUID{}, problem.ErrInvalidID
 	}
 
-	return res
+	return id, nil
 }
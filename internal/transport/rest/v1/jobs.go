@@ -0,0 +1,102 @@
+package v1
+
+import (
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/ernur-eskermes/crud-app/internal/core"
+)
+
+const defaultJobsLimit = 50
+
+func (h *Handler) initJobsRoutes(api fiber.Router) {
+	jobs := api.Group("/jobs", h.userIdentity, requireSession, requireRole(core.RoleAdmin))
+	{
+		jobs.Get("", h.getJobs)
+		jobs.Get("/:id", h.getJob)
+		jobs.Post("/:id/retry", h.retryJob)
+	}
+}
+
+// @Summary Get Jobs
+// @Tags jobs
+// @Description get background jobs, newest first, e.g. the dead ones with status=dead. Admins only.
+// @ModuleID getJobs
+// @Security UsersAuth
+// @Produce  json
+// @Param status query string false "job status" Enums(queued, running, succeeded, dead)
+// @Param type query string false "job type"
+// @Param limit query int false "page size" default(50)
+// @Param offset query int false "page offset"
+// @Success 200 {object} []core.Job
+// @Failure 400 {object} problem.Problem
+// @Failure 401 {object} problem.Problem
+// @Failure 403 {object} problem.Problem
+// @Router /jobs [get]
+func (h *Handler) getJobs(c *fiber.Ctx) error {
+	query := core.JobsQuery{Limit: defaultJobsLimit}
+	if err := h.parseQuery(c, &query); err != nil {
+		return err
+	}
+
+	jobs, err := h.services.Jobs.List(c.UserContext(), query)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(jobs)
+}
+
+// @Summary Get Job
+// @Tags jobs
+// @Description get background job by id. Admins only.
+// @ModuleID getJob
+// @Security UsersAuth
+// @Produce  json
+// @Param id path string true "job id"
+// @Success 200 {object} core.Job
+// @Failure 400 {object} problem.Problem
+// @Failure 401 {object} problem.Problem
+// @Failure 403 {object} problem.Problem
+// @Failure 404 {object} problem.Problem
+// @Router /jobs/{id} [get]
+func (h *Handler) getJob(c *fiber.Ctx) error {
+	id, err := parseID(c)
+	if err != nil {
+		return err
+	}
+
+	job, err := h.services.Jobs.Get(c.UserContext(), id)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(job)
+}
+
+// @Summary Retry Job
+// @Tags jobs
+// @Description queue a dead job again with all of its attempts. Admins only.
+// @ModuleID retryJob
+// @Security UsersAuth
+// @Produce  json
+// @Param id path string true "job id"
+// @Success 202 {object} core.Job
+// @Failure 400 {object} problem.Problem
+// @Failure 401 {object} problem.Problem
+// @Failure 403 {object} problem.Problem
+// @Failure 404 {object} problem.Problem
+// @Failure 409 {object} problem.Problem
+// @Router /jobs/{id}/retry [post]
+func (h *Handler) retryJob(c *fiber.Ctx) error {
+	id, err := parseID(c)
+	if err != nil {
+		return err
+	}
+
+	job, err := h.services.Jobs.Retry(c.UserContext(), id)
+	if err != nil {
+		return err
+	}
+
+	return c.Status(fiber.StatusAccepted).JSON(job)
+}
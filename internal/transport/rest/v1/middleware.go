@@ -2,50 +2,170 @@ package v1
 
 import (
 	"errors"
+	"fmt"
 	"strings"
 
 	"github.com/google/uuid"
 
+	"github.com/ernur-eskermes/crud-app/internal/core"
+	"github.com/ernur-eskermes/crud-app/internal/service"
+	"github.com/ernur-eskermes/crud-app/internal/transport/rest/problem"
+	"github.com/ernur-eskermes/crud-app/pkg/auth"
+	"github.com/ernur-eskermes/crud-app/pkg/database/postgresql"
+	"github.com/ernur-eskermes/crud-app/pkg/logging"
 	"github.com/gofiber/fiber/v2"
 )
 
 const (
 	authorizationHeader = "Authorization"
+	apiKeyHeader        = "X-API-Key"
 
-	userCtx = "userID"
+	userCtx   = "userID"
+	claimsCtx = "claims"
+
+	userIDField = "user_id"
+
+	authRealm = "crud-app"
+)
+
+var (
+	errEmptyAuthHeader   = errors.New("empty auth header")
+	errInvalidAuthHeader = errors.New("invalid auth header")
 )
 
 func (h *Handler) userIdentity(c *fiber.Ctx) error {
-	id, err := h.parseAuthHeader(c.Get(authorizationHeader))
+	claims, err := h.authenticate(c)
 	if err != nil {
-		return c.SendStatus(fiber.StatusUnauthorized)
+		return err
 	}
 
-	userID, err := uuid.Parse(id)
-	if err != nil {
-		return c.SendStatus(fiber.StatusUnauthorized)
-	}
+	userID := service.UserID(claims)
 
 	c.Locals(userCtx, userID)
+	c.Locals(claimsCtx, claims)
+
+	ctx := postgresql.WithSession(c.UserContext(), userID.String())
+	c.SetUserContext(logging.WithField(ctx, userIDField, userID))
+
+	return c.Next()
+}
+
+// readSession lets a signed in user read their own writes on a public route: a valid access token
+// sets the database session, anything else leaves the request anonymous.
+func (h *Handler) readSession(c *fiber.Ctx) error {
+	token, err := parseAuthHeader(c.Get(authorizationHeader))
+	if err != nil || service.IsAPIKey(token) {
+		return c.Next()
+	}
+
+	if claims, err := h.tokenManager.Parse(token); err == nil {
+		c.SetUserContext(postgresql.WithSession(c.UserContext(), claims.Subject))
+	}
 
 	return c.Next()
 }
 
-func (h *Handler) parseAuthHeader(header string) (string, error) {
+// authenticate reads the claims from the X-API-Key header or the bearer token,
+// which is either an access token or an API key.
+func (h *Handler) authenticate(c *fiber.Ctx) (auth.Claims, error) {
+	token := c.Get(apiKeyHeader)
+	if token == "" {
+		var err error
+		if token, err = parseAuthHeader(c.Get(authorizationHeader)); err != nil {
+			return auth.Claims{}, authenticationFailed(c, err)
+		}
+	}
+
+	claims, err := h.services.Authenticate(c.UserContext(), token)
+
+	var authErr *service.AuthenticationError
+	if errors.As(err, &authErr) {
+		return auth.Claims{}, authenticationFailed(c, authErr.Err)
+	}
+
+	return claims, err
+}
+
+func parseAuthHeader(header string) (string, error) {
 	if header == "" {
-		return "", errors.New("empty auth header")
+		return "", errEmptyAuthHeader
 	}
 
 	headerParts := strings.Split(header, " ")
-	if len(headerParts) != 2 || headerParts[0] != "Bearer" {
-		return "", errors.New("invalid auth header")
+	if len(headerParts) != 2 || headerParts[0] != "Bearer" || len(headerParts[1]) == 0 {
+		return "", errInvalidAuthHeader
 	}
 
-	if len(headerParts[1]) == 0 {
-		return "", errors.New("token is empty")
+	return headerParts[1], nil
+}
+
+// requireScope rejects requests authenticated with an API key or a token of an OAuth client
+// lacking the scope. Access tokens of user sessions are not limited by scopes.
+func requireScope(scope string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if err := service.Authorize(getClaims(c), scope); err != nil {
+			c.Set(fiber.HeaderWWWAuthenticate,
+				fmt.Sprintf("Bearer realm=%q, error=\"insufficient_scope\", scope=%q", authRealm, scope))
+
+			return problem.ErrInsufficientScope
+		}
+
+		return c.Next()
+	}
+}
+
+// requireSession rejects requests authenticated with an API key or a token of an OAuth client,
+// so that a leaked key or a third party can't manage credentials of the user.
+func requireSession(c *fiber.Ctx) error {
+	if service.Delegated(getClaims(c)) {
+		return problem.ErrSessionRequired
 	}
 
-	return h.tokenManager.Parse(headerParts[1])
+	return c.Next()
+}
+
+// requireRole rejects users without the role. Use it after requireSession,
+// only session tokens carry the roles of the user.
+func requireRole(role string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if !getClaims(c).HasRole(role) {
+			return problem.ErrForbidden
+		}
+
+		return c.Next()
+	}
+}
+
+// authenticationFailed sets the RFC 6750 WWW-Authenticate challenge describing err
+// and returns the matching problem.
+func authenticationFailed(c *fiber.Ctx, err error) error {
+	challenge := fmt.Sprintf("Bearer realm=%q", authRealm)
+
+	switch {
+	case errors.Is(err, errEmptyAuthHeader):
+		c.Set(fiber.HeaderWWWAuthenticate, challenge)
+
+		return problem.ErrUnauthorized
+	case errors.Is(err, errInvalidAuthHeader):
+		c.Set(fiber.HeaderWWWAuthenticate, challenge+`, error="invalid_request", error_description="malformed authorization header"`)
+
+		return problem.ErrInvalidAuthHeader
+	}
+
+	p := problem.TokenError(err)
+	if errors.Is(err, core.ErrAPIKeyInvalid) {
+		p = problem.ErrInvalidAPIKey
+	}
+
+	c.Set(fiber.HeaderWWWAuthenticate, fmt.Sprintf("%s, error=\"invalid_token\", error_description=%q", challenge, p.Detail))
+
+	return p
+}
+
+func getClaims(c *fiber.Ctx) auth.Claims {
+	claims, _ := c.Locals(claimsCtx).(auth.Claims)
+
+	return claims
 }
 
 func getUserID(c *fiber.Ctx) (uuid.UUID, error) {
@@ -61,3 +181,24 @@ func getUserID(c *fiber.Ctx) (uuid.UUID, error) {
 
 	return idStr, nil
 }
+
+// authorizedUserID returns the id of the authenticated user, making sure
+// the user still exists.
+func (h *Handler) authorizedUserID(c *fiber.Ctx) (uuid.UUID, error) {
+	userID, err := getUserID(c)
+	if err != nil {
+		logging.FromContext(c.UserContext()).Warning(err)
+
+		return uuid.UUID{}, problem.ErrUnauthorized
+	}
+
+	if _, err = h.services.Users.GetByID(c.UserContext(), userID); err != nil {
+		if errors.Is(err, core.ErrUserNotFound) {
+			return uuid.UUID{}, problem.ErrUnauthorized
+		}
+
+		return uuid.UUID{}, err
+	}
+
+	return userID, nil
+}
@@ -0,0 +1,229 @@
+package v1
+
+import (
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/ernur-eskermes/crud-app/internal/core"
+	"github.com/ernur-eskermes/crud-app/internal/service"
+)
+
+func (h *Handler) initOAuthRoutes(api fiber.Router) {
+	oauth := api.Group("/oauth", h.userIdentity, requireSession)
+	{
+		oauth.Post("/authorize", h.oauthAuthorize)
+
+		consents := oauth.Group("/consents")
+		{
+			consents.Get("", h.getOAuthConsents)
+			consents.Delete("/:id", h.revokeOAuthConsent)
+		}
+
+		clients := oauth.Group("/clients", requireRole(core.RoleAdmin))
+		{
+			clients.Post("", h.createOAuthClient)
+			clients.Get("", h.getOAuthClients)
+			clients.Delete("/:id", h.deleteOAuthClient)
+		}
+	}
+}
+
+type authorizeInput struct {
+	ResponseType        string `json:"response_type"`
+	ClientID            string `json:"client_id"`
+	RedirectURI         string `json:"redirect_uri"`
+	Scope               string `json:"scope"`
+	State               string `json:"state"`
+	CodeChallenge       string `json:"code_challenge"`
+	CodeChallengeMethod string `json:"code_challenge_method"`
+	// Approve is set by the consent screen: true grants the scopes, false denies the request.
+	Approve *bool `json:"approve"`
+}
+
+type authorizeResponse struct {
+	RedirectTo string `json:"redirect_to"`
+}
+
+type oauthClientResponse struct {
+	core.OAuthClient
+	// ClientSecret is returned only once on creation, and only to confidential clients.
+	ClientSecret string `json:"client_secret,omitempty"`
+}
+
+// @Summary OAuth Authorize
+// @Tags oauth
+// @Description authorization endpoint for the login page of the app, returns the redirect URI of the client
+// @Description with the authorization code or the error. Fails with consent_required until the user approves the scopes.
+// @ModuleID oauthAuthorize
+// @Security UsersAuth
+// @Accept  json
+// @Produce  json
+// @Param input body authorizeInput true "authorization request"
+// @Success 200 {object} authorizeResponse
+// @Failure 400 {object} problem.Problem
+// @Failure 401 {object} problem.Problem
+// @Failure 403 {object} problem.Problem
+// @Failure 404 {object} problem.Problem
+// @Router /oauth/authorize [post]
+func (h *Handler) oauthAuthorize(c *fiber.Ctx) error {
+	userID, err := h.authorizedUserID(c)
+	if err != nil {
+		return err
+	}
+
+	var inp authorizeInput
+	if err = h.parseBody(c, &inp); err != nil {
+		return err
+	}
+
+	url, err := h.services.OAuth.Authorize(c.UserContext(), userID, service.AuthorizeRequest{
+		ResponseType:        inp.ResponseType,
+		ClientID:            inp.ClientID,
+		RedirectURI:         inp.RedirectURI,
+		Scope:               inp.Scope,
+		State:               inp.State,
+		CodeChallenge:       inp.CodeChallenge,
+		CodeChallengeMethod: inp.CodeChallengeMethod,
+		Approve:             inp.Approve,
+	})
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(authorizeResponse{
+		RedirectTo: url,
+	})
+}
+
+// @Summary Get OAuth Consents
+// @Tags oauth
+// @Description get the apps the user has granted access to
+// @ModuleID getOAuthConsents
+// @Security UsersAuth
+// @Accept  json
+// @Produce  json
+// @Success 200 {object} []core.OAuthConsent
+// @Failure 401 {object} problem.Problem
+// @Failure 403 {object} problem.Problem
+// @Router /oauth/consents [get]
+func (h *Handler) getOAuthConsents(c *fiber.Ctx) error {
+	userID, err := h.authorizedUserID(c)
+	if err != nil {
+		return err
+	}
+
+	consents, err := h.services.OAuth.GetConsents(c.UserContext(), userID)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(consents)
+}
+
+// @Summary Revoke OAuth Consent
+// @Tags oauth
+// @Description revoke the access of the app, invalidating the tokens issued to it for the user
+// @ModuleID revokeOAuthConsent
+// @Security UsersAuth
+// @Accept  json
+// @Produce  json
+// @Param id path string true "client id"
+// @Success 204 {string} string "No Content"
+// @Failure 400 {object} problem.Problem
+// @Failure 401 {object} problem.Problem
+// @Failure 403 {object} problem.Problem
+// @Failure 404 {object} problem.Problem
+// @Router /oauth/consents/{id} [delete]
+func (h *Handler) revokeOAuthConsent(c *fiber.Ctx) error {
+	userID, err := h.authorizedUserID(c)
+	if err != nil {
+		return err
+	}
+
+	clientID, err := parseID(c)
+	if err != nil {
+		return err
+	}
+
+	if err = h.services.OAuth.RevokeConsent(c.UserContext(), userID, clientID); err != nil {
+		return err
+	}
+
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+// @Summary Create OAuth Client
+// @Tags oauth
+// @Description register an app, the secret of a confidential client is shown only in this response
+// @ModuleID createOAuthClient
+// @Security UsersAuth
+// @Accept  json
+// @Produce  json
+// @Param input body core.CreateOAuthClientInput true "client"
+// @Success 201 {object} oauthClientResponse
+// @Failure 400 {object} problem.Problem
+// @Failure 401 {object} problem.Problem
+// @Failure 403 {object} problem.Problem
+// @Router /oauth/clients [post]
+func (h *Handler) createOAuthClient(c *fiber.Ctx) error {
+	var inp core.CreateOAuthClientInput
+	if err := h.parseBody(c, &inp); err != nil {
+		return err
+	}
+
+	client, secret, err := h.services.OAuth.CreateClient(c.UserContext(), inp)
+	if err != nil {
+		return err
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(oauthClientResponse{
+		OAuthClient:  client,
+		ClientSecret: secret,
+	})
+}
+
+// @Summary Get OAuth Clients
+// @Tags oauth
+// @Description get the registered apps
+// @ModuleID getOAuthClients
+// @Security UsersAuth
+// @Accept  json
+// @Produce  json
+// @Success 200 {object} []core.OAuthClient
+// @Failure 401 {object} problem.Problem
+// @Failure 403 {object} problem.Problem
+// @Router /oauth/clients [get]
+func (h *Handler) getOAuthClients(c *fiber.Ctx) error {
+	clients, err := h.services.OAuth.GetClients(c.UserContext())
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(clients)
+}
+
+// @Summary Delete OAuth Client
+// @Tags oauth
+// @Description delete the app along with its consents and tokens
+// @ModuleID deleteOAuthClient
+// @Security UsersAuth
+// @Accept  json
+// @Produce  json
+// @Param id path string true "client id"
+// @Success 204 {string} string "No Content"
+// @Failure 400 {object} problem.Problem
+// @Failure 401 {object} problem.Problem
+// @Failure 403 {object} problem.Problem
+// @Failure 404 {object} problem.Problem
+// @Router /oauth/clients/{id} [delete]
+func (h *Handler) deleteOAuthClient(c *fiber.Ctx) error {
+	id, err := parseID(c)
+	if err != nil {
+		return err
+	}
+
+	if err = h.services.OAuth.DeleteClient(c.UserContext(), id); err != nil {
+		return err
+	}
+
+	return c.SendStatus(fiber.StatusNoContent)
+}
@@ -0,0 +1,154 @@
+package v1
+
+import (
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+
+	"github.com/ernur-eskermes/crud-app/internal/core"
+	"github.com/ernur-eskermes/crud-app/internal/service"
+)
+
+// oidcLoginCookie binds a login with a provider to the browser that started it.
+const oidcLoginCookie = "oidc_login"
+
+func (h *Handler) initOIDCRoutes(api fiber.Router) {
+	oidc := api.Group("/auth/oidc")
+	{
+		oidc.Get("", h.getIdentityProviders)
+		oidc.Get("/:provider", h.oidcLogin)
+		oidc.Get("/:provider/callback", h.oidcCallback)
+		oidc.Post("/:provider/link", h.userIdentity, requireSession, h.oidcLink)
+	}
+}
+
+type identityProvidersResponse struct {
+	Providers []string `json:"providers"`
+}
+
+type authURLResponse struct {
+	URL string `json:"url"`
+}
+
+// @Summary Identity Providers
+// @Tags users-auth
+// @Description list the OpenID Connect providers users can sign in with
+// @ModuleID getIdentityProviders
+// @Produce  json
+// @Success 200 {object} identityProvidersResponse
+// @Router /auth/oidc [get]
+func (h *Handler) getIdentityProviders(c *fiber.Ctx) error {
+	return c.JSON(identityProvidersResponse{
+		Providers: h.services.OIDC.Providers(),
+	})
+}
+
+// @Summary OIDC Login
+// @Tags users-auth
+// @Description redirect to the login page of the provider
+// @ModuleID oidcLogin
+// @Param provider path string true "provider name"
+// @Success 302
+// @Header 302 {string} Set-Cookie "oidc_login cookie required by the callback"
+// @Failure 404 {object} problem.Problem
+// @Router /auth/oidc/{provider} [get]
+func (h *Handler) oidcLogin(c *fiber.Ctx) error {
+	login, err := h.services.OIDC.AuthURL(c.UserContext(), c.Params("provider"), uuid.Nil)
+	if err != nil {
+		return err
+	}
+
+	setOIDCLoginCookie(c, login)
+
+	return c.Redirect(login.URL, fiber.StatusFound)
+}
+
+// @Summary OIDC Callback
+// @Tags users-auth
+// @Description complete the login at the provider, creating or linking the user; requires the oidc_login cookie set when the login started
+// @ModuleID oidcCallback
+// @Produce  json
+// @Param provider path string true "provider name"
+// @Param state query string true "state"
+// @Param code query string true "authorization code"
+// @Success 200 {object} tokenResponse
+// @Failure 400 {object} problem.Problem
+// @Failure 401 {object} problem.Problem
+// @Failure 404 {object} problem.Problem
+// @Failure 409 {object} problem.Problem
+// @Router /auth/oidc/{provider}/callback [get]
+func (h *Handler) oidcCallback(c *fiber.Ctx) error {
+	// The provider redirects with an error when the user denies the login.
+	if c.Query("error") != "" {
+		return core.ErrLoginFailed
+	}
+
+	browserKey := c.Cookies(oidcLoginCookie)
+	clearOIDCLoginCookie(c)
+
+	res, err := h.services.OIDC.Callback(c.UserContext(), c.Params("provider"), c.Query("state"), browserKey,
+		c.Query("code"))
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(tokenResponse{
+		AccessToken: res.AccessToken,
+	})
+}
+
+// @Summary OIDC Link
+// @Tags users-auth
+// @Description start linking an identity of the provider to the signed in user
+// @ModuleID oidcLink
+// @Security UsersAuth
+// @Produce  json
+// @Param provider path string true "provider name"
+// @Success 200 {object} authURLResponse
+// @Failure 401 {object} problem.Problem
+// @Failure 403 {object} problem.Problem
+// @Failure 404 {object} problem.Problem
+// @Router /auth/oidc/{provider}/link [post]
+func (h *Handler) oidcLink(c *fiber.Ctx) error {
+	userID, err := h.authorizedUserID(c)
+	if err != nil {
+		return err
+	}
+
+	login, err := h.services.OIDC.AuthURL(c.UserContext(), c.Params("provider"), userID)
+	if err != nil {
+		return err
+	}
+
+	setOIDCLoginCookie(c, login)
+
+	return c.JSON(authURLResponse{
+		URL: login.URL,
+	})
+}
+
+// setOIDCLoginCookie keeps the browser key of the login until the callback. The cookie is sent
+// along the redirect back from the provider, a top-level navigation, but not to other sites.
+func setOIDCLoginCookie(c *fiber.Ctx, login service.OIDCLogin) {
+	c.Cookie(&fiber.Cookie{
+		Name:     oidcLoginCookie,
+		Value:    login.BrowserKey,
+		Path:     "/api/v1/auth/oidc",
+		Expires:  login.ExpiresAt,
+		Secure:   c.Protocol() == "https",
+		HTTPOnly: true,
+		SameSite: fiber.CookieSameSiteLaxMode,
+	})
+}
+
+func clearOIDCLoginCookie(c *fiber.Ctx) {
+	c.Cookie(&fiber.Cookie{
+		Name:     oidcLoginCookie,
+		Path:     "/api/v1/auth/oidc",
+		Expires:  time.Unix(0, 0),
+		Secure:   c.Protocol() == "https",
+		HTTPOnly: true,
+		SameSite: fiber.CookieSameSiteLaxMode,
+	})
+}
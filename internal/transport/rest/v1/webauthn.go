@@ -0,0 +1,220 @@
+package v1
+
+import (
+	"bytes"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func (h *Handler) initWebAuthnRoutes(api fiber.Router) {
+	webauthn := api.Group("/auth/webauthn")
+	{
+		webauthn.Post("/register/begin", h.userIdentity, requireSession, h.webAuthnBeginRegistration)
+		webauthn.Post("/register/finish", h.userIdentity, requireSession, h.webAuthnFinishRegistration)
+		webauthn.Post("/login/begin", h.webAuthnBeginLogin)
+		webauthn.Post("/login/finish", h.webAuthnFinishLogin)
+		webauthn.Get("/credentials", h.userIdentity, requireSession, h.getWebAuthnCredentials)
+		webauthn.Delete("/credentials/:id", h.userIdentity, requireSession, h.deleteWebAuthnCredential)
+	}
+}
+
+type webAuthnRegistrationQuery struct {
+	Name string `query:"name" validate:"required,max=64"`
+}
+
+type webAuthnLoginInput struct {
+	// Username starts a passwordless sign in.
+	Username string `json:"username" validate:"required_without=SecondFactorToken,max=64"`
+	// SecondFactorToken completes a password sign in that failed with second_factor_required.
+	SecondFactorToken string `json:"second_factor_token"`
+}
+
+type webAuthnLoginQuery struct {
+	LoginID string `query:"login_id" validate:"required"`
+}
+
+// webAuthnOptionsResponse holds the options to pass to navigator.credentials.create() or get().
+type webAuthnOptionsResponse struct {
+	PublicKey interface{} `json:"publicKey"`
+}
+
+type webAuthnLoginResponse struct {
+	// LoginID is passed to /auth/webauthn/login/finish.
+	LoginID   string      `json:"login_id"`
+	PublicKey interface{} `json:"publicKey"`
+}
+
+// @Summary WebAuthn Begin Registration
+// @Tags webauthn
+// @Description start registering a passkey, pass the options to navigator.credentials.create()
+// @ModuleID webAuthnBeginRegistration
+// @Security UsersAuth
+// @Produce  json
+// @Success 200 {object} webAuthnOptionsResponse
+// @Failure 401 {object} problem.Problem
+// @Failure 403 {object} problem.Problem
+// @Router /auth/webauthn/register/begin [post]
+func (h *Handler) webAuthnBeginRegistration(c *fiber.Ctx) error {
+	userID, err := h.authorizedUserID(c)
+	if err != nil {
+		return err
+	}
+
+	options, err := h.services.WebAuthn.BeginRegistration(c.UserContext(), userID)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(webAuthnOptionsResponse{
+		PublicKey: options.Response,
+	})
+}
+
+// @Summary WebAuthn Finish Registration
+// @Tags webauthn
+// @Description store the passkey, the body is the credential returned by navigator.credentials.create()
+// @ModuleID webAuthnFinishRegistration
+// @Security UsersAuth
+// @Accept  json
+// @Produce  json
+// @Param name query string true "passkey name"
+// @Param input body object true "public key credential"
+// @Success 201 {object} core.WebAuthnCredential
+// @Failure 400 {object} problem.Problem
+// @Failure 401 {object} problem.Problem
+// @Failure 403 {object} problem.Problem
+// @Failure 409 {object} problem.Problem
+// @Router /auth/webauthn/register/finish [post]
+func (h *Handler) webAuthnFinishRegistration(c *fiber.Ctx) error {
+	userID, err := h.authorizedUserID(c)
+	if err != nil {
+		return err
+	}
+
+	var query webAuthnRegistrationQuery
+	if err = h.parseQuery(c, &query); err != nil {
+		return err
+	}
+
+	credential, err := h.services.WebAuthn.FinishRegistration(c.UserContext(), userID, query.Name,
+		bytes.NewReader(c.Body()))
+	if err != nil {
+		return err
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(credential)
+}
+
+// @Summary WebAuthn Begin Login
+// @Tags webauthn
+// @Description start signing in with a passkey, pass the options to navigator.credentials.get().
+// @Description Send the username to sign in without a password, or the second_factor_token
+// @Description of a sign in that failed with second_factor_required to complete it.
+// @ModuleID webAuthnBeginLogin
+// @Accept  json
+// @Produce  json
+// @Param input body webAuthnLoginInput true "user"
+// @Success 200 {object} webAuthnLoginResponse
+// @Failure 400 {object} problem.Problem
+// @Failure 404 {object} problem.Problem
+// @Failure 429 {object} problem.Problem
+// @Router /auth/webauthn/login/begin [post]
+func (h *Handler) webAuthnBeginLogin(c *fiber.Ctx) error {
+	var inp webAuthnLoginInput
+	if err := h.parseBody(c, &inp); err != nil {
+		return err
+	}
+
+	loginID, options, err := h.services.WebAuthn.BeginLogin(c.UserContext(), inp.Username, inp.SecondFactorToken)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(webAuthnLoginResponse{
+		LoginID:   loginID,
+		PublicKey: options.Response,
+	})
+}
+
+// @Summary WebAuthn Finish Login
+// @Tags webauthn
+// @Description sign in, the body is the credential returned by navigator.credentials.get()
+// @ModuleID webAuthnFinishLogin
+// @Accept  json
+// @Produce  json
+// @Param login_id query string true "login id"
+// @Param input body object true "public key credential"
+// @Success 200 {object} tokenResponse
+// @Failure 400 {object} problem.Problem
+// @Failure 401 {object} problem.Problem
+// @Failure 429 {object} problem.Problem
+// @Router /auth/webauthn/login/finish [post]
+func (h *Handler) webAuthnFinishLogin(c *fiber.Ctx) error {
+	var query webAuthnLoginQuery
+	if err := h.parseQuery(c, &query); err != nil {
+		return err
+	}
+
+	res, err := h.services.WebAuthn.FinishLogin(c.UserContext(), query.LoginID, bytes.NewReader(c.Body()))
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(tokenResponse{
+		AccessToken: res.AccessToken,
+	})
+}
+
+// @Summary Get WebAuthn Credentials
+// @Tags webauthn
+// @Description get passkeys of the user
+// @ModuleID getWebAuthnCredentials
+// @Security UsersAuth
+// @Produce  json
+// @Success 200 {object} []core.WebAuthnCredential
+// @Failure 401 {object} problem.Problem
+// @Failure 403 {object} problem.Problem
+// @Router /auth/webauthn/credentials [get]
+func (h *Handler) getWebAuthnCredentials(c *fiber.Ctx) error {
+	userID, err := h.authorizedUserID(c)
+	if err != nil {
+		return err
+	}
+
+	credentials, err := h.services.WebAuthn.GetCredentials(c.UserContext(), userID)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(credentials)
+}
+
+// @Summary Delete WebAuthn Credential
+// @Tags webauthn
+// @Description delete passkey by id
+// @ModuleID deleteWebAuthnCredential
+// @Security UsersAuth
+// @Param id path string true "passkey id"
+// @Success 204 {string} string "No Content"
+// @Failure 400 {object} problem.Problem
+// @Failure 401 {object} problem.Problem
+// @Failure 403 {object} problem.Problem
+// @Failure 404 {object} problem.Problem
+// @Router /auth/webauthn/credentials/{id} [delete]
+func (h *Handler) deleteWebAuthnCredential(c *fiber.Ctx) error {
+	userID, err := h.authorizedUserID(c)
+	if err != nil {
+		return err
+	}
+
+	id, err := parseID(c)
+	if err != nil {
+		return err
+	}
+
+	if err = h.services.WebAuthn.DeleteCredential(c.UserContext(), userID, id); err != nil {
+		return err
+	}
+
+	return c.SendStatus(fiber.StatusNoContent)
+}
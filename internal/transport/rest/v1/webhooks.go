@@ -0,0 +1,224 @@
+package v1
+
+import (
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/ernur-eskermes/crud-app/internal/core"
+)
+
+func (h *Handler) initWebhooksRoutes(api fiber.Router) {
+	webhooks := api.Group("/webhooks", h.userIdentity, requireSession)
+	{
+		webhooks.Post("", h.createWebhook)
+		webhooks.Get("", h.getWebhooks)
+		webhooks.Put("/:id", h.updateWebhook)
+		webhooks.Delete("/:id", h.deleteWebhook)
+		webhooks.Get("/:id/deliveries", h.getWebhookDeliveries)
+		webhooks.Post("/:id/deliveries/:deliveryID/replay", h.replayWebhookDelivery)
+	}
+}
+
+type webhookResponse struct {
+	core.Webhook
+	// Secret signs the payloads, returned only once on creation.
+	Secret string `json:"secret"`
+}
+
+// @Summary Create Webhook
+// @Tags webhooks
+// @Description register an endpoint notified of the book events, of the books of the user or of all books for admins.
+// @Description Payloads are signed with the secret, shown only in this response, in the X-Webhook-Signature header.
+// @ModuleID createWebhook
+// @Security UsersAuth
+// @Accept  json
+// @Produce  json
+// @Param input body core.CreateWebhookInput true "webhook"
+// @Success 201 {object} webhookResponse
+// @Failure 400 {object} problem.Problem
+// @Failure 401 {object} problem.Problem
+// @Failure 403 {object} problem.Problem
+// @Router /webhooks [post]
+func (h *Handler) createWebhook(c *fiber.Ctx) error {
+	userID, err := h.authorizedUserID(c)
+	if err != nil {
+		return err
+	}
+
+	var inp core.CreateWebhookInput
+	if err = h.parseBody(c, &inp); err != nil {
+		return err
+	}
+
+	webhook, secret, err := h.services.Webhooks.Create(c.UserContext(), userID, inp)
+	if err != nil {
+		return err
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(webhookResponse{
+		Webhook: webhook,
+		Secret:  secret,
+	})
+}
+
+// @Summary Get Webhooks
+// @Tags webhooks
+// @Description get webhooks of the user, without the secrets
+// @ModuleID getWebhooks
+// @Security UsersAuth
+// @Produce  json
+// @Success 200 {object} []core.Webhook
+// @Failure 401 {object} problem.Problem
+// @Failure 403 {object} problem.Problem
+// @Router /webhooks [get]
+func (h *Handler) getWebhooks(c *fiber.Ctx) error {
+	userID, err := h.authorizedUserID(c)
+	if err != nil {
+		return err
+	}
+
+	webhooks, err := h.services.Webhooks.List(c.UserContext(), userID)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(webhooks)
+}
+
+// @Summary Update Webhook
+// @Tags webhooks
+// @Description update webhook by id, enabling a disabled webhook resumes its pending deliveries
+// @ModuleID updateWebhook
+// @Security UsersAuth
+// @Accept  json
+// @Produce  json
+// @Param id path string true "webhook id"
+// @Param input body core.UpdateWebhookInput true "webhook"
+// @Success 200 {object} core.Webhook
+// @Failure 400 {object} problem.Problem
+// @Failure 401 {object} problem.Problem
+// @Failure 403 {object} problem.Problem
+// @Failure 404 {object} problem.Problem
+// @Router /webhooks/{id} [put]
+func (h *Handler) updateWebhook(c *fiber.Ctx) error {
+	userID, err := h.authorizedUserID(c)
+	if err != nil {
+		return err
+	}
+
+	id, err := parseID(c)
+	if err != nil {
+		return err
+	}
+
+	var inp core.UpdateWebhookInput
+	if err = h.parseBody(c, &inp); err != nil {
+		return err
+	}
+
+	webhook, err := h.services.Webhooks.Update(c.UserContext(), userID, id, inp)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(webhook)
+}
+
+// @Summary Delete Webhook
+// @Tags webhooks
+// @Description delete webhook by id along with its delivery log
+// @ModuleID deleteWebhook
+// @Security UsersAuth
+// @Param id path string true "webhook id"
+// @Success 204 {string} string "No Content"
+// @Failure 400 {object} problem.Problem
+// @Failure 401 {object} problem.Problem
+// @Failure 403 {object} problem.Problem
+// @Failure 404 {object} problem.Problem
+// @Router /webhooks/{id} [delete]
+func (h *Handler) deleteWebhook(c *fiber.Ctx) error {
+	userID, err := h.authorizedUserID(c)
+	if err != nil {
+		return err
+	}
+
+	id, err := parseID(c)
+	if err != nil {
+		return err
+	}
+
+	if err = h.services.Webhooks.Delete(c.UserContext(), userID, id); err != nil {
+		return err
+	}
+
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+// @Summary Get Webhook Deliveries
+// @Tags webhooks
+// @Description get the latest deliveries of the webhook, newest first
+// @ModuleID getWebhookDeliveries
+// @Security UsersAuth
+// @Produce  json
+// @Param id path string true "webhook id"
+// @Success 200 {object} []core.WebhookDelivery
+// @Failure 400 {object} problem.Problem
+// @Failure 401 {object} problem.Problem
+// @Failure 403 {object} problem.Problem
+// @Failure 404 {object} problem.Problem
+// @Router /webhooks/{id}/deliveries [get]
+func (h *Handler) getWebhookDeliveries(c *fiber.Ctx) error {
+	userID, err := h.authorizedUserID(c)
+	if err != nil {
+		return err
+	}
+
+	id, err := parseID(c)
+	if err != nil {
+		return err
+	}
+
+	deliveries, err := h.services.Webhooks.GetDeliveries(c.UserContext(), userID, id)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(deliveries)
+}
+
+// @Summary Replay Webhook Delivery
+// @Tags webhooks
+// @Description send the payload of a delivery again, as a new delivery
+// @ModuleID replayWebhookDelivery
+// @Security UsersAuth
+// @Produce  json
+// @Param id path string true "webhook id"
+// @Param deliveryID path string true "delivery id"
+// @Success 202 {object} core.WebhookDelivery
+// @Failure 400 {object} problem.Problem
+// @Failure 401 {object} problem.Problem
+// @Failure 403 {object} problem.Problem
+// @Failure 404 {object} problem.Problem
+// @Router /webhooks/{id}/deliveries/{deliveryID}/replay [post]
+func (h *Handler) replayWebhookDelivery(c *fiber.Ctx) error {
+	userID, err := h.authorizedUserID(c)
+	if err != nil {
+		return err
+	}
+
+	id, err := parseID(c)
+	if err != nil {
+		return err
+	}
+
+	deliveryID, err := parseUUIDParam(c, "deliveryID")
+	if err != nil {
+		return err
+	}
+
+	delivery, err := h.services.Webhooks.Replay(c.UserContext(), userID, id, deliveryID)
+	if err != nil {
+		return err
+	}
+
+	return c.Status(fiber.StatusAccepted).JSON(delivery)
+}
@@ -0,0 +1,2 @@
+alter table users
+    add column if not exists role varchar(16) not null default 'user';
@@ -0,0 +1,9 @@
+create table if not exists rate_limit_buckets
+(
+    key        varchar(255) primary key,
+    tokens     double precision not null,
+    allowed    boolean          not null,
+    updated_at timestamptz      not null
+);
+
+create index if not exists rate_limit_buckets_updated_at_idx on rate_limit_buckets (updated_at);
@@ -0,0 +1,7 @@
+create table if not exists auth_failures
+(
+    key          varchar(255) primary key,
+    failures     int          not null,
+    locked_until timestamptz  not null,
+    failed_at    timestamptz  not null
+);
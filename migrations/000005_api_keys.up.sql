@@ -0,0 +1,14 @@
+create table if not exists api_keys
+(
+    id           UUID PRIMARY KEY      DEFAULT gen_random_uuid(),
+    user_id      UUID         not null REFERENCES users (id) ON DELETE CASCADE,
+    name         varchar(64)  not null,
+    prefix       varchar(16)  not null unique,
+    key_hash     varchar(255) not null,
+    scopes       text[]       not null,
+    expires_at   timestamptz,
+    last_used_at timestamptz,
+    created_at   timestamptz  not null default now()
+);
+
+create index if not exists api_keys_user_id_idx on api_keys (user_id);
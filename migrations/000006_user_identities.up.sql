@@ -0,0 +1,12 @@
+create table if not exists user_identities
+(
+    provider   varchar(64)  not null,
+    subject    varchar(255) not null,
+    user_id    UUID         not null REFERENCES users (id) ON DELETE CASCADE,
+    email      varchar(255) not null default '',
+    created_at timestamptz  not null default now(),
+
+    PRIMARY KEY (provider, subject)
+);
+
+create index if not exists user_identities_user_id_idx on user_identities (user_id);
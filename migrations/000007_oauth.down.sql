@@ -0,0 +1,4 @@
+drop table if exists oauth_grants;
+drop table if exists oauth_codes;
+drop table if exists oauth_consents;
+drop table if exists oauth_clients;
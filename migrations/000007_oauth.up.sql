@@ -0,0 +1,44 @@
+create table if not exists oauth_clients
+(
+    id            UUID PRIMARY KEY      DEFAULT gen_random_uuid(),
+    name          varchar(64)  not null,
+    secret_hash   varchar(255) not null default '',
+    confidential  boolean      not null,
+    redirect_uris text[]       not null,
+    scopes        text[]       not null,
+    created_at    timestamptz  not null default now()
+);
+
+create table if not exists oauth_consents
+(
+    user_id    UUID        not null REFERENCES users (id) ON DELETE CASCADE,
+    client_id  UUID        not null REFERENCES oauth_clients (id) ON DELETE CASCADE,
+    scopes     text[]      not null,
+    granted_at timestamptz not null default now(),
+
+    PRIMARY KEY (user_id, client_id)
+);
+
+create table if not exists oauth_codes
+(
+    code_hash      varchar(255) PRIMARY KEY,
+    client_id      UUID         not null REFERENCES oauth_clients (id) ON DELETE CASCADE,
+    user_id        UUID         not null REFERENCES users (id) ON DELETE CASCADE,
+    redirect_uri   text         not null,
+    scopes         text[]       not null,
+    code_challenge varchar(128) not null,
+    expires_at     timestamptz  not null
+);
+
+create table if not exists oauth_grants
+(
+    id         UUID PRIMARY KEY     DEFAULT gen_random_uuid(),
+    client_id  UUID        not null REFERENCES oauth_clients (id) ON DELETE CASCADE,
+    user_id    UUID REFERENCES users (id) ON DELETE CASCADE,
+    scopes     text[]      not null,
+    expires_at timestamptz not null,
+    revoked_at timestamptz
+);
+
+create index if not exists oauth_grants_user_client_idx on oauth_grants (user_id, client_id);
+create index if not exists oauth_grants_expires_at_idx on oauth_grants (expires_at);
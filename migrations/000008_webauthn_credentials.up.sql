@@ -0,0 +1,15 @@
+create table if not exists webauthn_credentials
+(
+    id               UUID PRIMARY KEY     DEFAULT gen_random_uuid(),
+    user_id          UUID        not null REFERENCES users (id) ON DELETE CASCADE,
+    name             varchar(64) not null,
+    credential_id    bytea       not null unique,
+    public_key       bytea       not null,
+    attestation_type varchar(32) not null default '',
+    aaguid           bytea,
+    sign_count       bigint      not null default 0,
+    last_used_at     timestamptz,
+    created_at       timestamptz not null default now()
+);
+
+create index if not exists webauthn_credentials_user_id_idx on webauthn_credentials (user_id);
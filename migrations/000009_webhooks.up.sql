@@ -0,0 +1,44 @@
+create table if not exists book_events
+(
+    id            bigserial PRIMARY KEY,
+    type          varchar(32) not null,
+    book_id       UUID        not null,
+    author_id     UUID        not null,
+    data          jsonb       not null,
+    created_at    timestamptz not null default now(),
+    dispatched_at timestamptz
+);
+
+create index if not exists book_events_pending_idx on book_events (id) where dispatched_at is null;
+
+create table if not exists webhooks
+(
+    id          UUID PRIMARY KEY      DEFAULT gen_random_uuid(),
+    user_id     UUID          not null REFERENCES users (id) ON DELETE CASCADE,
+    url         varchar(2048) not null,
+    events      text[]        not null,
+    secret      varchar(128)  not null,
+    failures    int           not null default 0,
+    disabled_at timestamptz,
+    created_at  timestamptz   not null default now()
+);
+
+create index if not exists webhooks_user_id_idx on webhooks (user_id);
+
+create table if not exists webhook_deliveries
+(
+    id              UUID PRIMARY KEY     DEFAULT gen_random_uuid(),
+    webhook_id      UUID        not null REFERENCES webhooks (id) ON DELETE CASCADE,
+    event           varchar(32) not null,
+    payload         jsonb       not null,
+    status          varchar(16) not null default 'pending',
+    attempts        int         not null default 0,
+    response_status int,
+    last_error      text        not null default '',
+    next_attempt_at timestamptz not null default now(),
+    delivered_at    timestamptz,
+    created_at      timestamptz not null default now()
+);
+
+create index if not exists webhook_deliveries_webhook_id_idx on webhook_deliveries (webhook_id, created_at);
+create index if not exists webhook_deliveries_pending_idx on webhook_deliveries (next_attempt_at) where status = 'pending';
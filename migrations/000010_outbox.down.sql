@@ -0,0 +1,26 @@
+drop index if exists webhook_deliveries_event_idx;
+
+alter table webhook_deliveries
+    drop column if exists event_id;
+
+create table if not exists book_events
+(
+    id            bigserial PRIMARY KEY,
+    type          varchar(32) not null,
+    book_id       UUID        not null,
+    author_id     UUID        not null,
+    data          jsonb       not null,
+    created_at    timestamptz not null default now(),
+    dispatched_at timestamptz
+);
+
+create index if not exists book_events_pending_idx on book_events (id) where dispatched_at is null;
+
+insert into book_events (type, book_id, author_id, data, created_at)
+select type, aggregate_id, (data ->> 'author')::uuid, data, created_at
+from outbox
+where published_at is null
+  and type like 'book.%'
+order by id;
+
+drop table if exists outbox;
@@ -0,0 +1,24 @@
+create table if not exists outbox
+(
+    id           bigserial PRIMARY KEY,
+    type         varchar(64) not null,
+    aggregate_id UUID        not null,
+    data         jsonb       not null,
+    created_at   timestamptz not null default now(),
+    published_at timestamptz
+);
+
+create index if not exists outbox_pending_idx on outbox (id) where published_at is null;
+
+insert into outbox (type, aggregate_id, data, created_at)
+select type, book_id, data, created_at
+from book_events
+where dispatched_at is null
+order by id;
+
+drop table if exists book_events;
+
+alter table webhook_deliveries
+    add column if not exists event_id bigint;
+
+create unique index if not exists webhook_deliveries_event_idx on webhook_deliveries (webhook_id, event_id);
@@ -0,0 +1,19 @@
+create table if not exists jobs
+(
+    id           UUID PRIMARY KEY     DEFAULT gen_random_uuid(),
+    type         varchar(64) not null,
+    payload      jsonb       not null,
+    status       varchar(16) not null default 'queued',
+    attempts     int         not null default 0,
+    max_attempts int         not null,
+    run_at       timestamptz not null default now(),
+    locked_until timestamptz,
+    last_error   text        not null default '',
+    key          varchar(255),
+    created_at   timestamptz not null default now(),
+    finished_at  timestamptz
+);
+
+create index if not exists jobs_due_idx on jobs (run_at) where status in ('queued', 'running');
+create index if not exists jobs_status_idx on jobs (status, created_at);
+create unique index if not exists jobs_key_idx on jobs (key) where key is not null;
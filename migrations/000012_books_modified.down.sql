@@ -0,0 +1,4 @@
+drop table if exists books_modified;
+
+alter table book
+    drop column if exists updated_at;
@@ -0,0 +1,11 @@
+alter table book
+    add column if not exists updated_at timestamptz not null default now();
+
+create table if not exists books_modified
+(
+    id          bool PRIMARY KEY     DEFAULT true CHECK (id),
+    modified_at timestamptz not null default now()
+);
+
+insert into books_modified default values
+on conflict do nothing;
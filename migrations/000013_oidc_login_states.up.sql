@@ -0,0 +1,10 @@
+create table if not exists oidc_login_states
+(
+    state_hash   varchar(255) PRIMARY KEY,
+    browser_hash varchar(255) not null,
+    provider     varchar(64)  not null,
+    nonce        varchar(255) not null,
+    verifier     varchar(255) not null,
+    link_to      UUID REFERENCES users (id) ON DELETE CASCADE,
+    expires_at   timestamptz  not null
+);
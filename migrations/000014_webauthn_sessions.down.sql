@@ -0,0 +1 @@
+drop table if exists webauthn_sessions;
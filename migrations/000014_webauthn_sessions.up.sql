@@ -0,0 +1,7 @@
+create table if not exists webauthn_sessions
+(
+    key_hash   varchar(255) PRIMARY KEY,
+    user_id    UUID         not null REFERENCES users (id) ON DELETE CASCADE,
+    data       jsonb,
+    expires_at timestamptz  not null
+);
@@ -0,0 +1,184 @@
+// Package migrations embeds the SQL migrations of the application and applies them with golang-migrate.
+package migrations
+
+import (
+	"database/sql"
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+
+	"github.com/golang-migrate/migrate/v4"
+	migratepgx "github.com/golang-migrate/migrate/v4/database/pgx"
+	"github.com/golang-migrate/migrate/v4/source"
+	"github.com/golang-migrate/migrate/v4/source/iofs"
+	"github.com/jackc/pgx/v4"
+	"github.com/jackc/pgx/v4/stdlib"
+)
+
+//go:embed *.sql
+var files embed.FS
+
+// Logger is implemented by the application logger.
+type Logger interface {
+	Printf(format string, args ...interface{})
+}
+
+// Migrator applies the embedded migrations. The database driver takes a Postgres
+// advisory lock for every run, so replicas migrating concurrently wait for each other.
+type Migrator struct {
+	m  *migrate.Migrate
+	db *sql.DB
+}
+
+func NewMigrator(connStr string, logger Logger) (*Migrator, error) {
+	connConfig, err := pgx.ParseConfig(connStr)
+	if err != nil {
+		return nil, err
+	}
+
+	db := stdlib.OpenDB(*connConfig)
+
+	driver, err := migratepgx.WithInstance(db, &migratepgx.Config{})
+	if err != nil {
+		db.Close()
+
+		return nil, err
+	}
+
+	src, err := iofs.New(files, ".")
+	if err != nil {
+		db.Close()
+
+		return nil, err
+	}
+
+	m, err := migrate.NewWithInstance("iofs", src, "pgx", driver)
+	if err != nil {
+		db.Close()
+
+		return nil, err
+	}
+
+	m.Log = migrateLogger{logger}
+
+	return &Migrator{m: m, db: db}, nil
+}
+
+// Up applies all pending migrations.
+func (m *Migrator) Up() error {
+	return ignoreNoChange(m.m.Up())
+}
+
+// Down rolls back n migrations.
+func (m *Migrator) Down(n int) error {
+	return ignoreNoChange(m.m.Steps(-n))
+}
+
+// DownAll rolls back all migrations.
+func (m *Migrator) DownAll() error {
+	return ignoreNoChange(m.m.Down())
+}
+
+// To migrates up or down to the given version.
+func (m *Migrator) To(version uint) error {
+	return ignoreNoChange(m.m.Migrate(version))
+}
+
+// Force sets the schema version without running migrations and clears the dirty flag.
+// Version -1 means no migrations are applied.
+func (m *Migrator) Force(version int) error {
+	return m.m.Force(version)
+}
+
+// Version returns the current schema version. It is 0 if no migrations are applied.
+func (m *Migrator) Version() (version uint, dirty bool, err error) {
+	version, dirty, err = m.m.Version()
+	if errors.Is(err, migrate.ErrNilVersion) {
+		return 0, false, nil
+	}
+
+	return version, dirty, err
+}
+
+func (m *Migrator) Close() error {
+	srcErr, dbErr := m.m.Close()
+	if srcErr != nil {
+		return srcErr
+	}
+
+	return dbErr
+}
+
+type Migration struct {
+	Version uint
+	Name    string
+}
+
+// List returns the embedded migrations in the order they are applied.
+func List() ([]Migration, error) {
+	src, err := iofs.New(files, ".")
+	if err != nil {
+		return nil, err
+	}
+	defer src.Close()
+
+	var res []Migration
+
+	version, err := src.First()
+	for err == nil {
+		var name string
+		if name, err = migrationName(src, version); err != nil {
+			return nil, err
+		}
+
+		res = append(res, Migration{Version: version, Name: name})
+		version, err = src.Next(version)
+	}
+
+	if !errors.Is(err, os.ErrNotExist) && !errors.Is(err, fs.ErrNotExist) {
+		return nil, err
+	}
+
+	return res, nil
+}
+
+// Latest returns the version of the last embedded migration.
+func Latest() (uint, error) {
+	list, err := List()
+	if err != nil {
+		return 0, err
+	}
+
+	if len(list) == 0 {
+		return 0, errors.New("no migrations found")
+	}
+
+	return list[len(list)-1].Version, nil
+}
+
+func migrationName(src source.Driver, version uint) (string, error) {
+	r, name, err := src.ReadUp(version)
+	if err != nil {
+		return "", fmt.Errorf("read migration %d: %w", version, err)
+	}
+
+	return name, r.Close()
+}
+
+func ignoreNoChange(err error) error {
+	if errors.Is(err, migrate.ErrNoChange) {
+		return nil
+	}
+
+	return err
+}
+
+type migrateLogger struct {
+	Logger
+}
+
+func (l migrateLogger) Verbose() bool {
+	return false
+}
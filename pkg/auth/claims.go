@@ -0,0 +1,104 @@
+package auth
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+)
+
+const (
+	TokenTypeAccess  = "access"
+	TokenTypeRefresh = "refresh"
+	// TokenTypeAPIKey marks the claims of a request authenticated with an API key,
+	// such claims are never issued as a JWT.
+	TokenTypeAPIKey = "api_key"
+)
+
+// Errors returned by Manager.Parse, wrapping the details of the failure.
+var (
+	ErrTokenMalformed        = errors.New("token is malformed")
+	ErrTokenSignatureInvalid = errors.New("token signature is invalid")
+	ErrTokenExpired          = errors.New("token is expired")
+	ErrTokenInvalidClaims    = errors.New("token claims are invalid")
+)
+
+// Claims are the claims of the tokens issued by the Manager.
+type Claims struct {
+	Subject   string
+	SessionID string
+	Roles     []string
+	Scopes    []string
+	// ClientID is the OAuth client the token was issued to, empty for user sessions.
+	ClientID string
+	// TokenType is TokenTypeAccess, TokenTypeRefresh or TokenTypeAPIKey.
+	TokenType string
+	IssuedAt  time.Time
+	ExpiresAt time.Time
+}
+
+func (c Claims) HasRole(role string) bool {
+	return contains(c.Roles, role)
+}
+
+func (c Claims) HasScope(scope string) bool {
+	return contains(c.Scopes, scope)
+}
+
+// tokenClaims is the JWT representation of Claims.
+type tokenClaims struct {
+	jwt.RegisteredClaims
+	SessionID string   `json:"sid,omitempty"`
+	Roles     []string `json:"roles,omitempty"`
+	// Scope is a space separated list, as in OAuth 2.0.
+	Scope     string `json:"scope,omitempty"`
+	ClientID  string `json:"client_id,omitempty"`
+	TokenType string `json:"token_type"`
+}
+
+func newTokenClaims(c Claims) tokenClaims {
+	return tokenClaims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			Subject:   c.Subject,
+			IssuedAt:  jwt.NewNumericDate(c.IssuedAt),
+			ExpiresAt: jwt.NewNumericDate(c.ExpiresAt),
+		},
+		SessionID: c.SessionID,
+		Roles:     c.Roles,
+		Scope:     strings.Join(c.Scopes, " "),
+		ClientID:  c.ClientID,
+		TokenType: c.TokenType,
+	}
+}
+
+func (c tokenClaims) claims() Claims {
+	claims := Claims{
+		Subject:   c.Subject,
+		SessionID: c.SessionID,
+		Roles:     c.Roles,
+		Scopes:    strings.Fields(c.Scope),
+		ClientID:  c.ClientID,
+		TokenType: c.TokenType,
+	}
+
+	if c.IssuedAt != nil {
+		claims.IssuedAt = c.IssuedAt.Time
+	}
+
+	if c.ExpiresAt != nil {
+		claims.ExpiresAt = c.ExpiresAt.Time
+	}
+
+	return claims
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,116 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+)
+
+const (
+	AlgorithmHS256 = "HS256"
+	AlgorithmRS256 = "RS256"
+	AlgorithmEdDSA = "EdDSA"
+)
+
+// Key is a token signing key. Tokens are signed with the most recently activated key,
+// every key that has not expired is accepted for verification, so tokens issued with
+// a previous key stay valid after a rotation.
+type Key struct {
+	// ID is put into the kid header of the tokens signed with the key.
+	ID        string
+	Algorithm string
+	// Secret is the HS256 key, Signer the private RS256 or EdDSA key.
+	Secret []byte
+	Signer crypto.Signer
+	// ActiveFrom is when the key starts signing tokens. Keys are published
+	// in the JWKS before that, so verifiers can fetch them in advance.
+	ActiveFrom time.Time
+	// ExpiresAt is when tokens signed with the key stop being accepted, zero for never.
+	ExpiresAt time.Time
+}
+
+// NewSecretKey creates an HS256 key. It can only be verified by holders of the secret
+// and is never published.
+func NewSecretKey(id, secret string) (Key, error) {
+	if secret == "" {
+		return Key{}, errors.New("empty signing key")
+	}
+
+	return Key{ID: id, Algorithm: AlgorithmHS256, Secret: []byte(secret)}, nil
+}
+
+// LoadKey reads a PEM encoded RSA or Ed25519 private key, the algorithm follows the key type.
+func LoadKey(id, path string) (Key, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return Key{}, err
+	}
+
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return Key{}, fmt.Errorf("%s: no PEM data", path)
+	}
+
+	var private interface{}
+
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		private, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "PRIVATE KEY":
+		private, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	default:
+		return Key{}, fmt.Errorf("%s: unsupported PEM block %q", path, block.Type)
+	}
+
+	if err != nil {
+		return Key{}, fmt.Errorf("%s: %w", path, err)
+	}
+
+	switch private := private.(type) {
+	case *rsa.PrivateKey:
+		return Key{ID: id, Algorithm: AlgorithmRS256, Signer: private}, nil
+	case ed25519.PrivateKey:
+		return Key{ID: id, Algorithm: AlgorithmEdDSA, Signer: private}, nil
+	default:
+		return Key{}, fmt.Errorf("%s: unsupported key type %T", path, private)
+	}
+}
+
+func (k Key) signingMethod() jwt.SigningMethod {
+	switch k.Algorithm {
+	case AlgorithmRS256:
+		return jwt.SigningMethodRS256
+	case AlgorithmEdDSA:
+		return jwt.SigningMethodEdDSA
+	default:
+		return jwt.SigningMethodHS256
+	}
+}
+
+func (k Key) signingKey() interface{} {
+	if k.Signer != nil {
+		return k.Signer
+	}
+
+	return k.Secret
+}
+
+func (k Key) verificationKey() interface{} {
+	if k.Signer != nil {
+		return k.Signer.Public()
+	}
+
+	return k.Secret
+}
+
+func (k Key) expired(now time.Time) bool {
+	return !k.ExpiresAt.IsZero() && !now.Before(k.ExpiresAt)
+}
@@ -1,68 +1,231 @@
 package auth
 
 import (
-	"crypto/rand"
 	"errors"
 	"fmt"
-	"math/big"
+	"sort"
 	"time"
 
-	"github.com/dgrijalva/jwt-go"
+	"github.com/golang-jwt/jwt/v4"
 )
 
+var errNoActiveKey = errors.New("no active signing key")
+
 // TokenManager provides logic for JWT & Refresh tokens generation and parsing.
 type TokenManager interface {
-	NewJWT(userID string, ttl time.Duration) (string, error)
-	Parse(accessToken string) (string, error)
-	NewRefreshToken() (string, error)
+	NewJWT(claims Claims, ttl time.Duration) (string, error)
+	NewRefreshToken(claims Claims, ttl time.Duration) (string, error)
+	// Parse validates an access token. Refresh tokens are rejected.
+	Parse(accessToken string) (Claims, error)
+	ParseRefreshToken(refreshToken string) (Claims, error)
+	JWKS() JWKS
+}
+
+type ManagerConfig struct {
+	Issuer   string
+	Audience []string
+	Keys     []Key
+	// Leeway tolerates clock skew between the issuer and the verifiers
+	// when checking the expiration and issue times.
+	Leeway time.Duration
 }
 
 type Manager struct {
-	signingKey string
+	issuer   string
+	audience []string
+	leeway   time.Duration
+	// keys are sorted by ActiveFrom, the last active one signs tokens.
+	keys []Key
+	now  func() time.Time
 }
 
-func NewManager(signingKey string) (*Manager, error) {
-	if signingKey == "" {
-		return nil, errors.New("empty signing key")
+// NewManager creates a manager issuing tokens for the issuer and audience, and accepting only such tokens.
+func NewManager(cfg ManagerConfig) (*Manager, error) {
+	if len(cfg.Keys) == 0 {
+		return nil, errors.New("no signing keys")
 	}
 
-	return &Manager{signingKey: signingKey}, nil
-}
+	if cfg.Issuer == "" || len(cfg.Audience) == 0 {
+		return nil, errors.New("issuer and audience are required")
+	}
+
+	ids := make(map[string]bool, len(cfg.Keys))
+	for _, key := range cfg.Keys {
+		if ids[key.ID] {
+			return nil, fmt.Errorf("duplicate key id %q", key.ID)
+		}
+
+		ids[key.ID] = true
+	}
 
-func (m *Manager) NewJWT(userID string, ttl time.Duration) (string, error) {
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.StandardClaims{
-		ExpiresAt: time.Now().Add(ttl).Unix(),
-		Subject:   userID,
+	keys := append([]Key(nil), cfg.Keys...)
+	sort.SliceStable(keys, func(i, j int) bool {
+		return keys[i].ActiveFrom.Before(keys[j].ActiveFrom)
 	})
 
-	return token.SignedString([]byte(m.signingKey))
+	return &Manager{
+		issuer:   cfg.Issuer,
+		audience: cfg.Audience,
+		leeway:   cfg.Leeway,
+		keys:     keys,
+		now:      time.Now,
+	}, nil
 }
 
-func (m *Manager) Parse(accessToken string) (string, error) {
-	token, err := jwt.Parse(accessToken, func(token *jwt.Token) (i interface{}, err error) {
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+// NewJWT issues an access token for the claims.
+func (m *Manager) NewJWT(claims Claims, ttl time.Duration) (string, error) {
+	claims.TokenType = TokenTypeAccess
+
+	return m.sign(claims, ttl)
+}
+
+// NewRefreshToken issues a refresh token for the claims.
+func (m *Manager) NewRefreshToken(claims Claims, ttl time.Duration) (string, error) {
+	claims.TokenType = TokenTypeRefresh
+
+	return m.sign(claims, ttl)
+}
+
+func (m *Manager) Parse(accessToken string) (Claims, error) {
+	return m.parse(accessToken, TokenTypeAccess)
+}
+
+func (m *Manager) ParseRefreshToken(refreshToken string) (Claims, error) {
+	return m.parse(refreshToken, TokenTypeRefresh)
+}
+
+// JWKS returns the public keys that have not expired, including the ones not active yet.
+func (m *Manager) JWKS() JWKS {
+	now := m.now()
+	set := JWKS{Keys: []JWK{}}
+
+	for _, key := range m.keys {
+		if key.expired(now) {
+			continue
 		}
 
-		return []byte(m.signingKey), nil
-	})
+		if jwk, ok := newJWK(key); ok {
+			set.Keys = append(set.Keys, jwk)
+		}
+	}
+
+	return set
+}
+
+func (m *Manager) sign(claims Claims, ttl time.Duration) (string, error) {
+	now := m.now()
+
+	key, err := m.signingKey(now)
 	if err != nil {
 		return "", err
 	}
 
-	claims, ok := token.Claims.(jwt.MapClaims)
-	if !ok {
-		return "", fmt.Errorf("error get user claims from token")
+	claims.IssuedAt = now
+	claims.ExpiresAt = now.Add(ttl)
+
+	tc := newTokenClaims(claims)
+	tc.Issuer = m.issuer
+	tc.Audience = m.audience
+
+	token := jwt.NewWithClaims(key.signingMethod(), tc)
+	if key.ID != "" {
+		token.Header["kid"] = key.ID
 	}
 
-	return claims["sub"].(string), nil
+	return token.SignedString(key.signingKey())
 }
 
-func (m *Manager) NewRefreshToken() (string, error) {
-	n, err := rand.Int(rand.Reader, big.NewInt(32))
+func (m *Manager) parse(tokenString, tokenType string) (Claims, error) {
+	var tc tokenClaims
+
+	// Time based claims are checked below, with the leeway.
+	parser := jwt.Parser{SkipClaimsValidation: true}
+
+	_, err := parser.ParseWithClaims(tokenString, &tc, func(token *jwt.Token) (interface{}, error) {
+		kid, _ := token.Header["kid"].(string)
+
+		key, ok := m.verificationKey(kid)
+		if !ok {
+			return nil, fmt.Errorf("unknown key id %q", kid)
+		}
+
+		if token.Method.Alg() != key.Algorithm {
+			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+		}
+
+		return key.verificationKey(), nil
+	})
 	if err != nil {
-		return "", err
+		return Claims{}, classify(err)
+	}
+
+	now := m.now()
+
+	switch {
+	case tc.ExpiresAt == nil || !tc.VerifyExpiresAt(now.Add(-m.leeway), true):
+		return Claims{}, ErrTokenExpired
+	case !tc.VerifyIssuedAt(now.Add(m.leeway), false), !tc.VerifyNotBefore(now.Add(m.leeway), false):
+		return Claims{}, fmt.Errorf("%w: token used before issued", ErrTokenInvalidClaims)
+	case !tc.VerifyIssuer(m.issuer, true):
+		return Claims{}, fmt.Errorf("%w: issuer", ErrTokenInvalidClaims)
+	case !m.verifyAudience(tc.RegisteredClaims):
+		return Claims{}, fmt.Errorf("%w: audience", ErrTokenInvalidClaims)
+	case tc.Subject == "":
+		return Claims{}, fmt.Errorf("%w: missing subject", ErrTokenInvalidClaims)
+	case tc.TokenType != tokenType:
+		return Claims{}, fmt.Errorf("%w: %q token used as %s token", ErrTokenInvalidClaims, tc.TokenType, tokenType)
+	}
+
+	return tc.claims(), nil
+}
+
+// classify converts the errors of the jwt package to the errors of this package.
+func classify(err error) error {
+	var validationErr *jwt.ValidationError
+	if !errors.As(err, &validationErr) {
+		return fmt.Errorf("%w: %v", ErrTokenMalformed, err)
+	}
+
+	switch {
+	case validationErr.Errors&jwt.ValidationErrorMalformed != 0:
+		return fmt.Errorf("%w: %v", ErrTokenMalformed, err)
+	case validationErr.Errors&(jwt.ValidationErrorSignatureInvalid|jwt.ValidationErrorUnverifiable) != 0:
+		return fmt.Errorf("%w: %v", ErrTokenSignatureInvalid, err)
+	default:
+		return fmt.Errorf("%w: %v", ErrTokenInvalidClaims, err)
+	}
+}
+
+func (m *Manager) signingKey(now time.Time) (Key, error) {
+	for i := len(m.keys) - 1; i >= 0; i-- {
+		key := m.keys[i]
+		if !key.ActiveFrom.After(now) && !key.expired(now) {
+			return key, nil
+		}
+	}
+
+	return Key{}, errNoActiveKey
+}
+
+func (m *Manager) verificationKey(kid string) (Key, bool) {
+	now := m.now()
+
+	for _, key := range m.keys {
+		if key.ID == kid && !key.expired(now) {
+			return key, true
+		}
+	}
+
+	return Key{}, false
+}
+
+// verifyAudience accepts tokens issued for any of our audiences.
+func (m *Manager) verifyAudience(claims jwt.RegisteredClaims) bool {
+	for _, aud := range m.audience {
+		if claims.VerifyAudience(aud, true) {
+			return true
+		}
 	}
 
-	return n.String(), nil
+	return false
 }
@@ -0,0 +1,172 @@
+package cron
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// maxYears bounds the search for the next time of a schedule that never matches, e.g. "0 0 30 2 *".
+const maxYears = 5
+
+// Schedule is a parsed cron expression.
+type Schedule struct {
+	minute, hour, dom, month, dow uint64
+	// domAny and dowAny are set when the field is "*", a day then matches when both fields match,
+	// otherwise when either of them does.
+	domAny, dowAny bool
+}
+
+var descriptors = map[string]string{
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+	"@monthly":  "0 0 1 * *",
+	"@weekly":   "0 0 * * 0",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@hourly":   "0 * * * *",
+}
+
+type bounds struct {
+	min, max int
+}
+
+var fields = []bounds{{0, 59}, {0, 23}, {1, 31}, {1, 12}, {0, 7}}
+
+// Parse parses a standard five field expression "minute hour day-of-month month day-of-week"
+// with lists, ranges and steps, or one of the descriptors like @daily. Sunday is 0 or 7.
+func Parse(spec string) (Schedule, error) {
+	if expr, ok := descriptors[strings.TrimSpace(spec)]; ok {
+		spec = expr
+	}
+
+	parts := strings.Fields(spec)
+	if len(parts) != len(fields) {
+		return Schedule{}, fmt.Errorf("cron: %q must have %d fields", spec, len(fields))
+	}
+
+	bits := make([]uint64, len(fields))
+
+	for i, part := range parts {
+		var err error
+		if bits[i], err = parseField(part, fields[i]); err != nil {
+			return Schedule{}, fmt.Errorf("cron: %q: %w", spec, err)
+		}
+	}
+
+	// Sunday is both 0 and 7.
+	if bits[4]&(1<<7) != 0 {
+		bits[4] |= 1
+	}
+
+	return Schedule{
+		minute: bits[0],
+		hour:   bits[1],
+		dom:    bits[2],
+		month:  bits[3],
+		dow:    bits[4],
+		domAny: parts[2] == "*",
+		dowAny: parts[4] == "*",
+	}, nil
+}
+
+// Next returns the first time of the schedule after t, in the location of t,
+// or the zero time when there is none in the next years.
+func (s Schedule) Next(t time.Time) time.Time {
+	t = t.Truncate(time.Minute).Add(time.Minute)
+	limit := t.AddDate(maxYears, 0, 0)
+
+	for t.Before(limit) {
+		switch {
+		case !has(s.month, int(t.Month())):
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, t.Location())
+		case !s.matchDay(t):
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, t.Location())
+		case !has(s.hour, t.Hour()):
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, t.Location())
+		case !has(s.minute, t.Minute()):
+			t = t.Add(time.Minute)
+		default:
+			return t
+		}
+	}
+
+	return time.Time{}
+}
+
+func (s Schedule) matchDay(t time.Time) bool {
+	dom := has(s.dom, t.Day())
+	dow := has(s.dow, int(t.Weekday()))
+
+	if s.domAny || s.dowAny {
+		return dom && dow
+	}
+
+	return dom || dow
+}
+
+// parseField parses a comma separated list of "*", "n" or "a-b", each optionally followed by "/step".
+func parseField(field string, b bounds) (uint64, error) {
+	var bits uint64
+
+	for _, item := range strings.Split(field, ",") {
+		rng, step := item, 1
+
+		if i := strings.IndexByte(item, '/'); i >= 0 {
+			var err error
+			if step, err = strconv.Atoi(item[i+1:]); err != nil || step < 1 {
+				return 0, fmt.Errorf("invalid step in %q", item)
+			}
+
+			rng = item[:i]
+		}
+
+		lo, hi, err := parseRange(rng, b)
+		if err != nil {
+			return 0, err
+		}
+
+		// "n/step" means from n to the end of the field.
+		if step > 1 && !strings.ContainsAny(rng, "*-") {
+			hi = b.max
+		}
+
+		for v := lo; v <= hi; v += step {
+			bits |= 1 << uint(v)
+		}
+	}
+
+	return bits, nil
+}
+
+func parseRange(rng string, b bounds) (int, int, error) {
+	if rng == "*" {
+		return b.min, b.max, nil
+	}
+
+	loStr, hiStr := rng, rng
+	if i := strings.IndexByte(rng, '-'); i >= 0 {
+		loStr, hiStr = rng[:i], rng[i+1:]
+	}
+
+	lo, err := strconv.Atoi(loStr)
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid value %q", rng)
+	}
+
+	hi, err := strconv.Atoi(hiStr)
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid value %q", rng)
+	}
+
+	if lo < b.min || hi > b.max || lo > hi {
+		return 0, 0, fmt.Errorf("%q is out of range %d-%d", rng, b.min, b.max)
+	}
+
+	return lo, hi, nil
+}
+
+func has(bits uint64, v int) bool {
+	return bits&(1<<uint(v)) != 0
+}
@@ -0,0 +1,102 @@
+package cron
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		spec    string
+		wantErr bool
+	}{
+		{spec: "* * * * *"},
+		{spec: "0 3 * * *"},
+		{spec: "*/15 * * * *"},
+		{spec: "0 9-17/2 * * 1-5"},
+		{spec: "0,30 * 1,15 * *"},
+		{spec: "5/10 * * * *"},
+		{spec: "0 0 * * 7"},
+		{spec: "@daily"},
+		{spec: " @hourly "},
+		{spec: "", wantErr: true},
+		{spec: "* * * *", wantErr: true},
+		{spec: "* * * * * *", wantErr: true},
+		{spec: "60 * * * *", wantErr: true},
+		{spec: "* 24 * * *", wantErr: true},
+		{spec: "* * 0 * *", wantErr: true},
+		{spec: "* * * 13 *", wantErr: true},
+		{spec: "* * * * 8", wantErr: true},
+		{spec: "5-1 * * * *", wantErr: true},
+		{spec: "*/0 * * * *", wantErr: true},
+		{spec: "a * * * *", wantErr: true},
+		{spec: "@every 5m", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		if _, err := Parse(tt.spec); (err != nil) != tt.wantErr {
+			t.Errorf("Parse(%q) error = %v, want error: %v", tt.spec, err, tt.wantErr)
+		}
+	}
+}
+
+func TestScheduleNext(t *testing.T) {
+	// A Wednesday.
+	from := time.Date(2024, time.January, 10, 10, 17, 42, 0, time.UTC)
+
+	tests := []struct {
+		spec string
+		from time.Time
+		want time.Time
+	}{
+		{spec: "* * * * *", from: from, want: time.Date(2024, 1, 10, 10, 18, 0, 0, time.UTC)},
+		{spec: "*/15 * * * *", from: from, want: time.Date(2024, 1, 10, 10, 30, 0, 0, time.UTC)},
+		{spec: "5/20 * * * *", from: from, want: time.Date(2024, 1, 10, 10, 25, 0, 0, time.UTC)},
+		{spec: "0 3 * * *", from: from, want: time.Date(2024, 1, 11, 3, 0, 0, 0, time.UTC)},
+		{spec: "@hourly", from: from, want: time.Date(2024, 1, 10, 11, 0, 0, 0, time.UTC)},
+		{spec: "@monthly", from: from, want: time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC)},
+		{spec: "@yearly", from: from, want: time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)},
+		{spec: "0 9-17/2 * * 1-5", from: from, want: time.Date(2024, 1, 10, 11, 0, 0, 0, time.UTC)},
+		{
+			spec: "0 9 * * 1-5",
+			from: time.Date(2024, 1, 12, 10, 0, 0, 0, time.UTC), // Friday after 9
+			want: time.Date(2024, 1, 15, 9, 0, 0, 0, time.UTC),
+		},
+		{spec: "0 0 * * 0", from: from, want: time.Date(2024, 1, 14, 0, 0, 0, 0, time.UTC)},
+		{spec: "0 0 * * 7", from: from, want: time.Date(2024, 1, 14, 0, 0, 0, 0, time.UTC)},
+		// Day of month and day of week both restricted: either matches.
+		{spec: "0 0 13 * 5", from: from, want: time.Date(2024, 1, 12, 0, 0, 0, 0, time.UTC)},
+		{spec: "0 0 29 2 *", from: from, want: time.Date(2024, 2, 29, 0, 0, 0, 0, time.UTC)},
+		{spec: "0 0 31 * *", from: time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC), want: time.Date(2024, 3, 31, 0, 0, 0, 0, time.UTC)},
+		// An exact match of from is not returned, the next time is.
+		{spec: "0 3 * * *", from: time.Date(2024, 1, 10, 3, 0, 0, 0, time.UTC), want: time.Date(2024, 1, 11, 3, 0, 0, 0, time.UTC)},
+		{spec: "0 0 31 12 *", from: time.Date(2024, 12, 31, 23, 59, 0, 0, time.UTC), want: time.Date(2025, 12, 31, 0, 0, 0, 0, time.UTC)},
+		// Never matches.
+		{spec: "0 0 30 2 *", from: from, want: time.Time{}},
+	}
+
+	for _, tt := range tests {
+		schedule, err := Parse(tt.spec)
+		if err != nil {
+			t.Fatalf("Parse(%q) error = %v", tt.spec, err)
+		}
+
+		if got := schedule.Next(tt.from); !got.Equal(tt.want) {
+			t.Errorf("Parse(%q).Next(%s) = %s, want %s", tt.spec, tt.from, got, tt.want)
+		}
+	}
+}
+
+func TestScheduleNextKeepsLocation(t *testing.T) {
+	loc := time.FixedZone("UTC+6", 6*60*60)
+
+	schedule, err := Parse("0 3 * * *")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got := schedule.Next(time.Date(2024, 1, 10, 10, 0, 0, 0, loc))
+	if want := time.Date(2024, 1, 11, 3, 0, 0, 0, loc); !got.Equal(want) || got.Location() != loc {
+		t.Errorf("Next() = %s, want %s", got, want)
+	}
+}
@@ -0,0 +1,340 @@
+package postgresql
+
+import (
+	"context"
+	"regexp"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/jackc/pgconn"
+	"github.com/jackc/pgx/v4"
+	"github.com/jackc/pgx/v4/pgxpool"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	PrimaryPool = "primary"
+
+	defaultHealthCheckPeriod = 5 * time.Second
+
+	poolKey = attribute.Key("db.pool")
+)
+
+// writingSelect matches the clauses making a SELECT lock or create rows.
+var writingSelect = regexp.MustCompile(`(?i)\b(FOR\s+(NO\s+KEY\s+)?UPDATE|FOR\s+(KEY\s+)?SHARE|INTO)\b`)
+
+type (
+	readOnlyCtxKey struct{}
+	sessionCtxKey  struct{}
+)
+
+// ReadOnly marks the queries run with the context as reads that may be served by a replica.
+// Only the reads that tolerate the replication lag should be marked, e.g. listings.
+func ReadOnly(ctx context.Context) context.Context {
+	return context.WithValue(ctx, readOnlyCtxKey{}, true)
+}
+
+// WithSession sets the session, e.g. the user id, the queries run with the context belong to.
+// After a write of the session its reads are served by the primary for the read-your-writes window.
+func WithSession(ctx context.Context, session string) context.Context {
+	return context.WithValue(ctx, sessionCtxKey{}, session)
+}
+
+// Pool is a connection pool of the cluster, labeled "primary" or "replica-N" in logs, metrics and spans.
+type Pool struct {
+	*pgxpool.Pool
+	Name string
+
+	healthy int32
+	queries uint64
+}
+
+func (p *Pool) Healthy() bool {
+	return atomic.LoadInt32(&p.healthy) == 1
+}
+
+// Queries returns the number of queries the pool has served.
+func (p *Pool) Queries() uint64 {
+	return atomic.LoadUint64(&p.queries)
+}
+
+func (p *Pool) setHealthy(healthy bool) bool {
+	var v int32
+	if healthy {
+		v = 1
+	}
+
+	return atomic.SwapInt32(&p.healthy, v) != v
+}
+
+// Cluster is a Client sending the writes and the transactions to the primary and the ReadOnly queries
+// to the healthy replicas in turn. It falls back to the primary when no replica is healthy.
+type Cluster struct {
+	primary  *Pool
+	replicas []*Pool
+	next     uint32
+
+	logger         pgx.Logger
+	healthCheck    time.Duration
+	readYourWrites time.Duration
+
+	mu     sync.Mutex
+	writes map[string]time.Time
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+func newCluster(primary *Pool, replicas []*Pool, sc StorageConfig) *Cluster {
+	c := &Cluster{
+		primary:        primary,
+		replicas:       replicas,
+		logger:         sc.Logger,
+		healthCheck:    sc.HealthCheckPeriod,
+		readYourWrites: sc.ReadYourWrites,
+		writes:         make(map[string]time.Time),
+		stop:           make(chan struct{}),
+		done:           make(chan struct{}),
+	}
+
+	if c.healthCheck <= 0 {
+		c.healthCheck = defaultHealthCheckPeriod
+	}
+
+	if len(replicas) == 0 {
+		close(c.done)
+
+		return c
+	}
+
+	c.checkReplicas()
+
+	go c.run()
+
+	return c
+}
+
+// Primary returns the pool of the primary, e.g. for the migrations and health checks.
+func (c *Cluster) Primary() *pgxpool.Pool {
+	return c.primary.Pool
+}
+
+// PrimaryOnly returns a Client running the ReadOnly queries on the primary as well,
+// e.g. for the reads that must never lag behind the writes.
+func (c *Cluster) PrimaryOnly() Client {
+	return primaryOnly{c}
+}
+
+// Pools returns the pool of the primary followed by those of the replicas.
+func (c *Cluster) Pools() []*Pool {
+	return append([]*Pool{c.primary}, c.replicas...)
+}
+
+func (c *Cluster) Exec(ctx context.Context, sql string, arguments ...interface{}) (pgconn.CommandTag, error) {
+	c.wrote(ctx)
+
+	return c.use(ctx, c.primary).Exec(ctx, sql, arguments...)
+}
+
+func (c *Cluster) Query(ctx context.Context, sql string, args ...interface{}) (pgx.Rows, error) {
+	return c.use(ctx, c.route(ctx, sql)).Query(ctx, sql, args...)
+}
+
+func (c *Cluster) QueryRow(ctx context.Context, sql string, args ...interface{}) pgx.Row {
+	return c.use(ctx, c.route(ctx, sql)).QueryRow(ctx, sql, args...)
+}
+
+// Begin starts a transaction on the primary, all of its queries run there.
+func (c *Cluster) Begin(ctx context.Context) (pgx.Tx, error) {
+	c.wrote(ctx)
+
+	return c.use(ctx, c.primary).Begin(ctx)
+}
+
+// Close stops the health checks and closes the pools.
+func (c *Cluster) Close() {
+	select {
+	case <-c.stop:
+	default:
+		close(c.stop)
+	}
+
+	<-c.done
+
+	for _, p := range c.Pools() {
+		p.Close()
+	}
+}
+
+// route picks the pool of a query outside of a transaction. Anything but a plain SELECT is a write
+// and goes to the primary, even when marked ReadOnly.
+func (c *Cluster) route(ctx context.Context, sql string) *Pool {
+	if !isRead(sql) {
+		c.wrote(ctx)
+
+		return c.primary
+	}
+
+	if readOnly, _ := ctx.Value(readOnlyCtxKey{}).(bool); !readOnly {
+		return c.primary
+	}
+
+	if len(c.replicas) == 0 || c.recentlyWrote(ctx) {
+		return c.primary
+	}
+
+	start := atomic.AddUint32(&c.next, 1)
+
+	for i := range c.replicas {
+		if p := c.replicas[(int(start)+i)%len(c.replicas)]; p.Healthy() {
+			return p
+		}
+	}
+
+	return c.primary
+}
+
+type primaryOnly struct {
+	*Cluster
+}
+
+func (p primaryOnly) Query(ctx context.Context, sql string, args ...interface{}) (pgx.Rows, error) {
+	return p.Cluster.Query(onPrimary(ctx), sql, args...)
+}
+
+func (p primaryOnly) QueryRow(ctx context.Context, sql string, args ...interface{}) pgx.Row {
+	return p.Cluster.QueryRow(onPrimary(ctx), sql, args...)
+}
+
+// onPrimary undoes ReadOnly.
+func onPrimary(ctx context.Context) context.Context {
+	return context.WithValue(ctx, readOnlyCtxKey{}, false)
+}
+
+// use counts the query of the pool and labels its span.
+func (c *Cluster) use(ctx context.Context, p *Pool) *Pool {
+	atomic.AddUint64(&p.queries, 1)
+	trace.SpanFromContext(ctx).SetAttributes(poolKey.String(p.Name))
+
+	return p
+}
+
+// wrote records a write of the session of the context, if any.
+func (c *Cluster) wrote(ctx context.Context) {
+	session, _ := ctx.Value(sessionCtxKey{}).(string)
+	if session == "" || c.readYourWrites <= 0 || len(c.replicas) == 0 {
+		return
+	}
+
+	c.mu.Lock()
+	c.writes[session] = time.Now()
+	c.mu.Unlock()
+}
+
+// recentlyWrote reports whether the session of the context wrote within the read-your-writes window.
+func (c *Cluster) recentlyWrote(ctx context.Context) bool {
+	session, _ := ctx.Value(sessionCtxKey{}).(string)
+	if session == "" {
+		return false
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	at, ok := c.writes[session]
+
+	return ok && time.Since(at) < c.readYourWrites
+}
+
+// run checks the replicas and forgets the writes out of the window until Close.
+func (c *Cluster) run() {
+	defer close(c.done)
+
+	ticker := time.NewTicker(c.healthCheck)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-c.stop:
+			return
+		case <-ticker.C:
+		}
+
+		c.checkReplicas()
+		c.forgetWrites()
+	}
+}
+
+// checkReplicas pings every replica, a replica is used only while its pings succeed.
+func (c *Cluster) checkReplicas() {
+	var wg sync.WaitGroup
+
+	for _, p := range c.replicas {
+		wg.Add(1)
+
+		go func(p *Pool) {
+			defer wg.Done()
+
+			ctx, cancel := context.WithTimeout(context.Background(), c.healthCheck)
+			defer cancel()
+
+			err := p.Ping(ctx)
+			if !p.setHealthy(err == nil) || c.logger == nil {
+				return
+			}
+
+			if err != nil {
+				c.logger.Log(ctx, pgx.LogLevelWarn, "replica is unhealthy", map[string]interface{}{"pool": p.Name, "err": err})
+			} else {
+				c.logger.Log(ctx, pgx.LogLevelInfo, "replica is healthy", map[string]interface{}{"pool": p.Name})
+			}
+		}(p)
+	}
+
+	wg.Wait()
+}
+
+func (c *Cluster) forgetWrites() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for session, at := range c.writes {
+		if time.Since(at) >= c.readYourWrites {
+			delete(c.writes, session)
+		}
+	}
+}
+
+// poolLogger adds the name of the pool to the log lines of its queries.
+type poolLogger struct {
+	logger pgx.Logger
+	pool   string
+}
+
+func (l poolLogger) Log(ctx context.Context, level pgx.LogLevel, msg string, data map[string]interface{}) {
+	if data == nil {
+		data = make(map[string]interface{}, 1)
+	}
+
+	data["pool"] = l.pool
+
+	l.logger.Log(ctx, level, msg, data)
+}
+
+// operation returns the first keyword of the statement, e.g. SELECT.
+func operation(sql string) string {
+	sql = strings.TrimSpace(sql)
+	if i := strings.IndexAny(sql, " \n\t"); i > 0 {
+		sql = sql[:i]
+	}
+
+	return strings.ToUpper(sql)
+}
+
+// isRead reports whether the statement is a plain SELECT, e.g. not WITH ... UPDATE or SELECT ... FOR UPDATE.
+func isRead(sql string) bool {
+	return operation(sql) == "SELECT" && !writingSelect.MatchString(sql)
+}
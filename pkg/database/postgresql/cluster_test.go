@@ -0,0 +1,218 @@
+package postgresql
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+func newTestCluster(healthy ...bool) *Cluster {
+	c := &Cluster{
+		primary:        &Pool{Name: PrimaryPool},
+		readYourWrites: time.Minute,
+		writes:         make(map[string]time.Time),
+	}
+
+	for i, h := range healthy {
+		p := &Pool{Name: fmt.Sprintf("replica-%d", i+1)}
+		p.setHealthy(h)
+		c.replicas = append(c.replicas, p)
+	}
+
+	return c
+}
+
+func TestClusterRoute(t *testing.T) {
+	readOnly := ReadOnly(context.Background())
+
+	tests := []struct {
+		name    string
+		cluster *Cluster
+		ctx     context.Context
+		sql     string
+		// wrote is the session that wrote before the query, if any.
+		wrote string
+		want  []string
+	}{
+		{
+			name:    "reads not marked read only go to the primary",
+			cluster: newTestCluster(true),
+			ctx:     context.Background(),
+			sql:     "SELECT 1",
+			want:    []string{PrimaryPool},
+		},
+		{
+			name:    "read only queries take turns on the replicas",
+			cluster: newTestCluster(true, true),
+			ctx:     readOnly,
+			sql:     "SELECT 1",
+			want:    []string{"replica-2", "replica-1", "replica-2"},
+		},
+		{
+			name:    "unhealthy replicas are skipped",
+			cluster: newTestCluster(false, true),
+			ctx:     readOnly,
+			sql:     "SELECT 1",
+			want:    []string{"replica-2", "replica-2"},
+		},
+		{
+			name:    "no healthy replica falls back to the primary",
+			cluster: newTestCluster(false, false),
+			ctx:     readOnly,
+			sql:     "SELECT 1",
+			want:    []string{PrimaryPool},
+		},
+		{
+			name:    "no replicas",
+			cluster: newTestCluster(),
+			ctx:     readOnly,
+			sql:     "SELECT 1",
+			want:    []string{PrimaryPool},
+		},
+		{
+			name:    "a session reads its own writes from the primary",
+			cluster: newTestCluster(true),
+			ctx:     WithSession(readOnly, "alice"),
+			sql:     "SELECT 1",
+			wrote:   "alice",
+			want:    []string{PrimaryPool},
+		},
+		{
+			name:    "writes marked read only go to the primary",
+			cluster: newTestCluster(true),
+			ctx:     readOnly,
+			sql:     "WITH b AS (UPDATE book SET rating=$1 RETURNING id) SELECT id FROM b",
+			want:    []string{PrimaryPool},
+		},
+		{
+			name:    "locking reads go to the primary",
+			cluster: newTestCluster(true),
+			ctx:     readOnly,
+			sql:     "SELECT id FROM book WHERE id=$1 FOR UPDATE",
+			want:    []string{PrimaryPool},
+		},
+		{
+			name:    "the writes of other sessions don't matter",
+			cluster: newTestCluster(true),
+			ctx:     WithSession(readOnly, "bob"),
+			sql:     "SELECT 1",
+			wrote:   "alice",
+			want:    []string{"replica-1"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.wrote != "" {
+				tt.cluster.wrote(WithSession(context.Background(), tt.wrote))
+			}
+
+			for i, want := range tt.want {
+				if got := tt.cluster.route(tt.ctx, tt.sql).Name; got != want {
+					t.Errorf("route() #%d = %s, want %s", i+1, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestClusterRouteRecordsWrites(t *testing.T) {
+	c := newTestCluster(true)
+	ctx := WithSession(context.Background(), "alice")
+
+	c.route(ctx, "UPDATE books SET title=$1")
+
+	if got := c.route(ReadOnly(ctx), "SELECT 1").Name; got != PrimaryPool {
+		t.Errorf("route() after a write outside of a transaction = %s, want %s", got, PrimaryPool)
+	}
+}
+
+func TestClusterRouteRecordsWritesInSelects(t *testing.T) {
+	for _, sql := range []string{
+		"WITH b AS (DELETE FROM book WHERE id=$1 RETURNING id) SELECT count(*) FROM b",
+		"SELECT id FROM book WHERE id=$1 FOR NO KEY UPDATE",
+	} {
+		c := newTestCluster(true)
+		ctx := WithSession(context.Background(), "alice")
+
+		c.route(ctx, sql)
+
+		if got := c.route(ReadOnly(ctx), "SELECT 1").Name; got != PrimaryPool {
+			t.Errorf("route() after %q = %s, want %s", sql, got, PrimaryPool)
+		}
+	}
+}
+
+// The book cache is shared by the sessions, so a fill racing a write of another session
+// must not be served by a lagging replica.
+func TestPrimaryOnlyRouteAfterWrite(t *testing.T) {
+	c := newTestCluster(true, true)
+
+	c.wrote(WithSession(context.Background(), "alice"))
+
+	var wg sync.WaitGroup
+
+	got := make([]string, 8)
+
+	for i := range got {
+		wg.Add(1)
+
+		go func(i int) {
+			defer wg.Done()
+
+			ctx := ReadOnly(WithSession(context.Background(), fmt.Sprintf("reader-%d", i)))
+			got[i] = c.route(onPrimary(ctx), "SELECT id FROM book WHERE id=$1").Name
+		}(i)
+	}
+
+	wg.Wait()
+
+	for i, name := range got {
+		if name != PrimaryPool {
+			t.Errorf("primary only load #%d routed to %s, want %s", i+1, name, PrimaryPool)
+		}
+	}
+
+	if name := c.route(ReadOnly(context.Background()), "SELECT 1").Name; name == PrimaryPool {
+		t.Errorf("read only load routed to %s, want a replica", name)
+	}
+}
+
+func TestIsRead(t *testing.T) {
+	tests := map[string]bool{
+		"SELECT 1": true,
+		"select id from book where title='update'": true,
+		"SELECT id FROM book FOR UPDATE":           false,
+		"SELECT id FROM book FOR NO KEY UPDATE":    false,
+		"SELECT id FROM book\nFOR SHARE":           false,
+		"SELECT id FROM book FOR KEY SHARE":        false,
+		"SELECT * INTO archive FROM book":          false,
+		"WITH x AS (SELECT 1) SELECT * FROM x":     false,
+		"WITH x AS (INSERT INTO book) SELECT 1":    false,
+		"UPDATE book SET rating=1":                 false,
+	}
+
+	for sql, want := range tests {
+		if got := isRead(sql); got != want {
+			t.Errorf("isRead(%q) = %v, want %v", sql, got, want)
+		}
+	}
+}
+
+func TestOperation(t *testing.T) {
+	tests := map[string]string{
+		"SELECT 1":                        "SELECT",
+		"  select * from books":           "SELECT",
+		"\n\tWITH x AS (SELECT 1) SELECT": "WITH",
+		"INSERT INTO books":               "INSERT",
+		"VACUUM":                          "VACUUM",
+	}
+
+	for sql, want := range tests {
+		if got := operation(sql); got != want {
+			t.Errorf("operation(%q) = %s, want %s", sql, got, want)
+		}
+	}
+}
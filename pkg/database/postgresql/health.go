@@ -0,0 +1,39 @@
+package postgresql
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/ernur-eskermes/crud-app/pkg/health"
+	"github.com/jackc/pgx/v4/pgxpool"
+)
+
+// PingCheck checks that a connection can be acquired from the pool and used.
+func PingCheck(pool *pgxpool.Pool) health.Check {
+	return pool.Ping
+}
+
+// SchemaVersionCheck checks that the migrations applied to the database,
+// as recorded by golang-migrate, are at the expected version.
+func SchemaVersionCheck(db Client, expected uint) health.Check {
+	return func(ctx context.Context) error {
+		var (
+			version uint
+			dirty   bool
+		)
+
+		if err := db.QueryRow(ctx, "SELECT version, dirty FROM schema_migrations LIMIT 1").Scan(&version, &dirty); err != nil {
+			return err
+		}
+
+		if dirty {
+			return fmt.Errorf("schema version %d is dirty", version)
+		}
+
+		if version != expected {
+			return fmt.Errorf("schema version is %d, expected %d", version, expected)
+		}
+
+		return nil
+	}
+}
@@ -0,0 +1,45 @@
+package postgresql
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v4"
+	"github.com/jackc/pgx/v4/pgxpool"
+)
+
+// Listen receives the notifications of the channel on a connection of its own, opened with the config
+// of the pool. ready is called once listening, e.g. to catch up with the changes made before, then notify
+// for every notification. Listen returns nil when ctx is done, or the error of the connection or of the callbacks.
+func Listen(ctx context.Context, pool *pgxpool.Pool, channel string,
+	ready func(ctx context.Context) error, notify func(ctx context.Context, payload string) error,
+) error {
+	conn, err := pgx.ConnectConfig(ctx, pool.Config().ConnConfig.Copy())
+	if err != nil {
+		return err
+	}
+
+	defer conn.Close(context.Background()) //nolint:errcheck
+
+	if _, err = conn.Exec(ctx, "LISTEN "+pgx.Identifier{channel}.Sanitize()); err != nil {
+		return err
+	}
+
+	if err = ready(ctx); err != nil {
+		return err
+	}
+
+	for {
+		notification, err := conn.WaitForNotification(ctx)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+
+			return err
+		}
+
+		if err = notify(ctx, notification.Payload); err != nil {
+			return err
+		}
+	}
+}
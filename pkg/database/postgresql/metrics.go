@@ -0,0 +1,77 @@
+package postgresql
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// StatsCollector exports pgxpool statistics of the pools of a cluster as prometheus metrics, labeled by pool.
+type StatsCollector struct {
+	cluster *Cluster
+
+	acquiredConns        *prometheus.Desc
+	idleConns            *prometheus.Desc
+	totalConns           *prometheus.Desc
+	maxConns             *prometheus.Desc
+	acquireCount         *prometheus.Desc
+	acquireDuration      *prometheus.Desc
+	emptyAcquireCount    *prometheus.Desc
+	canceledAcquireCount *prometheus.Desc
+	queries              *prometheus.Desc
+	healthy              *prometheus.Desc
+}
+
+func NewStatsCollector(cluster *Cluster) *StatsCollector {
+	labels := []string{"pool"}
+
+	return &StatsCollector{
+		cluster: cluster,
+
+		acquiredConns: prometheus.NewDesc("pgxpool_acquired_conns",
+			"Number of currently acquired connections in the pool.", labels, nil),
+		idleConns: prometheus.NewDesc("pgxpool_idle_conns",
+			"Number of currently idle connections in the pool.", labels, nil),
+		totalConns: prometheus.NewDesc("pgxpool_total_conns",
+			"Total number of connections currently in the pool.", labels, nil),
+		maxConns: prometheus.NewDesc("pgxpool_max_conns",
+			"Maximum size of the pool.", labels, nil),
+		acquireCount: prometheus.NewDesc("pgxpool_acquire_count_total",
+			"Cumulative count of successful acquires from the pool.", labels, nil),
+		acquireDuration: prometheus.NewDesc("pgxpool_acquire_duration_seconds_total",
+			"Total time spent waiting for a connection to be acquired from the pool.", labels, nil),
+		emptyAcquireCount: prometheus.NewDesc("pgxpool_empty_acquire_count_total",
+			"Cumulative count of acquires that waited for a connection because the pool was empty.", labels, nil),
+		canceledAcquireCount: prometheus.NewDesc("pgxpool_canceled_acquire_count_total",
+			"Cumulative count of acquires canceled by a context.", labels, nil),
+		queries: prometheus.NewDesc("pgxpool_queries_total",
+			"Cumulative count of queries served by the pool outside of transactions.", labels, nil),
+		healthy: prometheus.NewDesc("pgxpool_healthy",
+			"Whether the pool passes its health checks and serves queries.", labels, nil),
+	}
+}
+
+func (c *StatsCollector) Describe(ch chan<- *prometheus.Desc) {
+	prometheus.DescribeByCollect(c, ch)
+}
+
+func (c *StatsCollector) Collect(ch chan<- prometheus.Metric) {
+	for _, p := range c.cluster.Pools() {
+		stat := p.Stat()
+
+		healthy := 0.0
+		if p.Healthy() {
+			healthy = 1
+		}
+
+		ch <- prometheus.MustNewConstMetric(c.acquiredConns, prometheus.GaugeValue, float64(stat.AcquiredConns()), p.Name)
+		ch <- prometheus.MustNewConstMetric(c.idleConns, prometheus.GaugeValue, float64(stat.IdleConns()), p.Name)
+		ch <- prometheus.MustNewConstMetric(c.totalConns, prometheus.GaugeValue, float64(stat.TotalConns()), p.Name)
+		ch <- prometheus.MustNewConstMetric(c.maxConns, prometheus.GaugeValue, float64(stat.MaxConns()), p.Name)
+		ch <- prometheus.MustNewConstMetric(c.acquireCount, prometheus.CounterValue, float64(stat.AcquireCount()), p.Name)
+		ch <- prometheus.MustNewConstMetric(c.acquireDuration, prometheus.CounterValue, stat.AcquireDuration().Seconds(), p.Name)
+		ch <- prometheus.MustNewConstMetric(c.emptyAcquireCount, prometheus.CounterValue, float64(stat.EmptyAcquireCount()), p.Name)
+		ch <- prometheus.MustNewConstMetric(c.canceledAcquireCount, prometheus.CounterValue,
+			float64(stat.CanceledAcquireCount()), p.Name)
+		ch <- prometheus.MustNewConstMetric(c.queries, prometheus.CounterValue, float64(p.Queries()), p.Name)
+		ch <- prometheus.MustNewConstMetric(c.healthy, prometheus.GaugeValue, healthy, p.Name)
+	}
+}
@@ -2,6 +2,7 @@ package postgresql
 
 import (
 	"context"
+	"fmt"
 	"log"
 	"time"
 
@@ -11,9 +12,14 @@ import (
 	"github.com/jackc/pgx/v4/pgxpool"
 )
 
+// StorageConfig describes the primary and the optional read replicas. The replicas are pinged every
+// HealthCheckPeriod, and a session reads from the primary for ReadYourWrites after its writes.
 type StorageConfig struct {
-	ConnStr string
-	Logger  pgx.Logger
+	ConnStr           string
+	ReplicaConnStrs   []string
+	HealthCheckPeriod time.Duration
+	ReadYourWrites    time.Duration
+	Logger            pgx.Logger
 }
 
 type Client interface {
@@ -23,15 +29,19 @@ type Client interface {
 	Begin(ctx context.Context) (pgx.Tx, error)
 }
 
-func NewClient(ctx context.Context, maxAttempts int, sc StorageConfig) (pool *pgxpool.Pool, err error) {
+// NewClient connects to the primary, retrying up to maxAttempts times, and to the replicas.
+// The replicas connect lazily, one that is down is skipped until its health check succeeds.
+func NewClient(ctx context.Context, maxAttempts int, sc StorageConfig) (*Cluster, error) {
 	config, err := pgxpool.ParseConfig(sc.ConnStr)
 	if err != nil {
 		log.Fatalf("Unable to parse config: %v\n", err)
 	}
 
-	config.ConnConfig.Logger = sc.Logger
+	setLogger(config, sc.Logger, PrimaryPool)
 
-	err = repeatable.DoWithTries(func() error {
+	var pool *pgxpool.Pool
+
+	err = repeatable.DoWithTries(ctx, func() error {
 		ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
 		defer cancel()
 
@@ -47,5 +57,44 @@ func NewClient(ctx context.Context, maxAttempts int, sc StorageConfig) (pool *pg
 		log.Fatal("error do with tries postgresql")
 	}
 
-	return pool, nil
+	replicas := make([]*Pool, 0, len(sc.ReplicaConnStrs))
+
+	for i, connStr := range sc.ReplicaConnStrs {
+		name := fmt.Sprintf("replica-%d", i+1)
+
+		config, err := pgxpool.ParseConfig(connStr)
+		if err != nil {
+			closePools(replicas)
+			pool.Close()
+
+			return nil, fmt.Errorf("unable to parse config of %s: %w", name, err)
+		}
+
+		config.LazyConnect = true
+		setLogger(config, sc.Logger, name)
+
+		replica, err := pgxpool.ConnectConfig(ctx, config)
+		if err != nil {
+			closePools(replicas)
+			pool.Close()
+
+			return nil, fmt.Errorf("unable to connect to %s: %w", name, err)
+		}
+
+		replicas = append(replicas, &Pool{Pool: replica, Name: name})
+	}
+
+	return newCluster(&Pool{Pool: pool, Name: PrimaryPool, healthy: 1}, replicas, sc), nil
+}
+
+func setLogger(config *pgxpool.Config, logger pgx.Logger, pool string) {
+	if logger != nil {
+		config.ConnConfig.Logger = poolLogger{logger: logger, pool: pool}
+	}
+}
+
+func closePools(pools []*Pool) {
+	for _, p := range pools {
+		p.Close()
+	}
 }
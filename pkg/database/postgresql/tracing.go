@@ -0,0 +1,206 @@
+package postgresql
+
+import (
+	"context"
+	"errors"
+
+	"github.com/jackc/pgconn"
+	"github.com/jackc/pgx/v4"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.7.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	tracerName = "github.com/ernur-eskermes/crud-app/pkg/database/postgresql"
+
+	rowsAffectedKey = attribute.Key("db.rows_affected")
+)
+
+// TracedClient is a Client recording a span with the SQL statement for every query.
+type TracedClient struct {
+	client Client
+	tracer trace.Tracer
+}
+
+func NewTracedClient(client Client) *TracedClient {
+	return &TracedClient{
+		client: client,
+		tracer: otel.Tracer(tracerName),
+	}
+}
+
+func (c *TracedClient) Exec(ctx context.Context, sql string, arguments ...interface{}) (pgconn.CommandTag, error) {
+	return traceExec(ctx, c.tracer, c.client.Exec, sql, arguments...)
+}
+
+func (c *TracedClient) Query(ctx context.Context, sql string, args ...interface{}) (pgx.Rows, error) {
+	return traceQuery(ctx, c.tracer, c.client.Query, sql, args...)
+}
+
+func (c *TracedClient) QueryRow(ctx context.Context, sql string, args ...interface{}) pgx.Row {
+	return traceQueryRow(ctx, c.tracer, c.client.QueryRow, sql, args...)
+}
+
+func (c *TracedClient) Begin(ctx context.Context) (pgx.Tx, error) {
+	ctx, span := startSpan(ctx, c.tracer, "BEGIN")
+
+	tx, err := c.client.Begin(ctx)
+	endSpan(span, err)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &tracedTx{Tx: tx, tracer: c.tracer}, nil
+}
+
+// tracedTx traces the queries run inside a transaction.
+type tracedTx struct {
+	pgx.Tx
+	tracer trace.Tracer
+}
+
+func (t *tracedTx) Exec(ctx context.Context, sql string, arguments ...interface{}) (pgconn.CommandTag, error) {
+	return traceExec(ctx, t.tracer, t.Tx.Exec, sql, arguments...)
+}
+
+func (t *tracedTx) Query(ctx context.Context, sql string, args ...interface{}) (pgx.Rows, error) {
+	return traceQuery(ctx, t.tracer, t.Tx.Query, sql, args...)
+}
+
+func (t *tracedTx) QueryRow(ctx context.Context, sql string, args ...interface{}) pgx.Row {
+	return traceQueryRow(ctx, t.tracer, t.Tx.QueryRow, sql, args...)
+}
+
+func (t *tracedTx) Commit(ctx context.Context) error {
+	ctx, span := startSpan(ctx, t.tracer, "COMMIT")
+
+	err := t.Tx.Commit(ctx)
+	endSpan(span, err)
+
+	return err
+}
+
+func (t *tracedTx) Rollback(ctx context.Context) error {
+	ctx, span := startSpan(ctx, t.tracer, "ROLLBACK")
+
+	err := t.Tx.Rollback(ctx)
+	if errors.Is(err, pgx.ErrTxClosed) {
+		endSpan(span, nil)
+	} else {
+		endSpan(span, err)
+	}
+
+	return err
+}
+
+type (
+	execFunc     func(ctx context.Context, sql string, arguments ...interface{}) (pgconn.CommandTag, error)
+	queryFunc    func(ctx context.Context, sql string, args ...interface{}) (pgx.Rows, error)
+	queryRowFunc func(ctx context.Context, sql string, args ...interface{}) pgx.Row
+)
+
+func traceExec(ctx context.Context, tracer trace.Tracer, exec execFunc, sql string, args ...interface{}) (pgconn.CommandTag, error) {
+	ctx, span := startSpan(ctx, tracer, sql)
+
+	tag, err := exec(ctx, sql, args...)
+	if err == nil {
+		span.SetAttributes(rowsAffectedKey.Int64(tag.RowsAffected()))
+	}
+
+	endSpan(span, err)
+
+	return tag, err
+}
+
+func traceQuery(ctx context.Context, tracer trace.Tracer, query queryFunc, sql string, args ...interface{}) (pgx.Rows, error) {
+	ctx, span := startSpan(ctx, tracer, sql)
+
+	rows, err := query(ctx, sql, args...)
+	if err != nil {
+		endSpan(span, err)
+
+		return nil, err
+	}
+
+	return &tracedRows{Rows: rows, span: span}, nil
+}
+
+func traceQueryRow(ctx context.Context, tracer trace.Tracer, queryRow queryRowFunc, sql string, args ...interface{}) pgx.Row {
+	ctx, span := startSpan(ctx, tracer, sql)
+
+	return &tracedRow{row: queryRow(ctx, sql, args...), span: span}
+}
+
+// tracedRows ends the query span once the rows are read or closed.
+type tracedRows struct {
+	pgx.Rows
+	span  trace.Span
+	ended bool
+}
+
+func (r *tracedRows) Next() bool {
+	if r.Rows.Next() {
+		return true
+	}
+
+	r.end()
+
+	return false
+}
+
+func (r *tracedRows) Close() {
+	r.Rows.Close()
+	r.end()
+}
+
+func (r *tracedRows) end() {
+	if r.ended {
+		return
+	}
+
+	r.ended = true
+	endSpan(r.span, r.Rows.Err())
+}
+
+// tracedRow ends the query span when the row is scanned.
+type tracedRow struct {
+	row  pgx.Row
+	span trace.Span
+}
+
+func (r *tracedRow) Scan(dest ...interface{}) error {
+	err := r.row.Scan(dest...)
+	if errors.Is(err, pgx.ErrNoRows) {
+		endSpan(r.span, nil)
+	} else {
+		endSpan(r.span, err)
+	}
+
+	return err
+}
+
+func startSpan(ctx context.Context, tracer trace.Tracer, sql string) (context.Context, trace.Span) {
+	operation := operation(sql)
+
+	return tracer.Start(ctx, operation,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			semconv.DBSystemPostgreSQL,
+			semconv.DBOperationKey.String(operation),
+			semconv.DBStatementKey.String(sql),
+		),
+	)
+}
+
+func endSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+
+	span.End()
+}
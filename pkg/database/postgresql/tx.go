@@ -0,0 +1,23 @@
+package postgresql
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v4"
+)
+
+// WithTx runs fn in a transaction, committed when fn succeeds and rolled back otherwise.
+func WithTx(ctx context.Context, db Client, fn func(tx pgx.Tx) error) error {
+	tx, err := db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+
+	defer tx.Rollback(ctx) //nolint:errcheck
+
+	if err = fn(tx); err != nil {
+		return err
+	}
+
+	return tx.Commit(ctx)
+}
@@ -0,0 +1,103 @@
+package health
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	StatusOK          = "ok"
+	StatusUnavailable = "unavailable"
+	StatusShutdown    = "shutting down"
+)
+
+// Check reports whether a dependency is usable.
+type Check func(ctx context.Context) error
+
+type Report struct {
+	Status string                 `json:"status"`
+	Checks map[string]CheckResult `json:"checks,omitempty"`
+}
+
+type CheckResult struct {
+	Status   string `json:"status"`
+	Error    string `json:"error,omitempty"`
+	Duration string `json:"duration"`
+}
+
+// Checker runs the readiness checks of the application dependencies.
+type Checker struct {
+	timeout      time.Duration
+	checks       map[string]Check
+	shuttingDown int32
+}
+
+func NewChecker(timeout time.Duration) *Checker {
+	return &Checker{
+		timeout: timeout,
+		checks:  make(map[string]Check),
+	}
+}
+
+// Add registers a check. It must not be called once the checker is in use.
+func (c *Checker) Add(name string, check Check) {
+	c.checks[name] = check
+}
+
+// Shutdown makes the application report itself as not ready, so that
+// load balancers stop routing traffic to it before the servers are stopped.
+func (c *Checker) Shutdown() {
+	atomic.StoreInt32(&c.shuttingDown, 1)
+}
+
+// Ready runs all checks concurrently.
+func (c *Checker) Ready(ctx context.Context) Report {
+	if atomic.LoadInt32(&c.shuttingDown) == 1 {
+		return Report{Status: StatusShutdown}
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+
+	report := Report{
+		Status: StatusOK,
+		Checks: make(map[string]CheckResult, len(c.checks)),
+	}
+
+	var (
+		mu sync.Mutex
+		wg sync.WaitGroup
+	)
+
+	for name, check := range c.checks {
+		wg.Add(1)
+
+		go func(name string, check Check) {
+			defer wg.Done()
+
+			start := time.Now()
+			err := check(ctx)
+			res := CheckResult{
+				Status:   StatusOK,
+				Duration: time.Since(start).String(),
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+
+			if err != nil {
+				res.Status = StatusUnavailable
+				res.Error = err.Error()
+				report.Status = StatusUnavailable
+			}
+
+			report.Checks[name] = res
+		}(name, check)
+	}
+
+	wg.Wait()
+
+	return report
+}
@@ -0,0 +1,26 @@
+package logging
+
+import "context"
+
+type loggerCtxKey struct{}
+
+// ContextWithLogger returns a copy of ctx carrying l.
+func ContextWithLogger(ctx context.Context, l *Logger) context.Context {
+	return context.WithValue(ctx, loggerCtxKey{}, l)
+}
+
+// FromContext returns the logger stored in ctx, or the global logger if there is none.
+func FromContext(ctx context.Context) *Logger {
+	if l, ok := ctx.Value(loggerCtxKey{}).(*Logger); ok {
+		return l
+	}
+
+	return GetLogger()
+}
+
+// WithField returns a copy of ctx carrying the logger from ctx with the field added.
+func WithField(ctx context.Context, k string, v interface{}) context.Context {
+	l := FromContext(ctx).GetLoggerWithField(k, v)
+
+	return ContextWithLogger(ctx, &l)
+}
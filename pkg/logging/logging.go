@@ -82,3 +82,15 @@ func init() {
 
 	e = logrus.NewEntry(l)
 }
+
+// SetLevel changes the level of the application logger, e.g. "info" or "debug".
+func SetLevel(level string) error {
+	lvl, err := logrus.ParseLevel(level)
+	if err != nil {
+		return err
+	}
+
+	e.Logger.SetLevel(lvl)
+
+	return nil
+}
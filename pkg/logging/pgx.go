@@ -0,0 +1,42 @@
+package logging
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v4"
+	"github.com/sirupsen/logrus"
+)
+
+// PgxLogger is a pgx.Logger that writes to the logger stored in the query context,
+// so database log lines carry the fields of the request they belong to.
+type PgxLogger struct {
+	logger *Logger
+}
+
+func NewPgxLogger(l *Logger) *PgxLogger {
+	return &PgxLogger{logger: l}
+}
+
+func (l *PgxLogger) Log(ctx context.Context, level pgx.LogLevel, msg string, data map[string]interface{}) {
+	logger := l.logger
+	if ctxLogger, ok := ctx.Value(loggerCtxKey{}).(*Logger); ok {
+		logger = ctxLogger
+	}
+
+	entry := logger.WithFields(logrus.Fields(data))
+
+	switch level {
+	case pgx.LogLevelTrace:
+		entry.WithField("PGX_LOG_LEVEL", level).Debug(msg)
+	case pgx.LogLevelDebug:
+		entry.Debug(msg)
+	case pgx.LogLevelInfo:
+		entry.Info(msg)
+	case pgx.LogLevelWarn:
+		entry.Warn(msg)
+	case pgx.LogLevelError:
+		entry.Error(msg)
+	default:
+		entry.WithField("INVALID_PGX_LOG_LEVEL", level).Error(msg)
+	}
+}
@@ -0,0 +1,128 @@
+package ratelimit
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestMemoryStoreTake(t *testing.T) {
+	limit := Limit{Rate: 2, Burst: 3}
+
+	type take struct {
+		// after is the time since the previous take.
+		after         time.Duration
+		wantAllowed   bool
+		wantRemaining int
+		wantRetry     time.Duration
+	}
+
+	tests := []struct {
+		name  string
+		takes []take
+	}{
+		{
+			name: "burst then rejected",
+			takes: []take{
+				{wantAllowed: true, wantRemaining: 2},
+				{wantAllowed: true, wantRemaining: 1},
+				{wantAllowed: true, wantRemaining: 0},
+				{wantAllowed: false, wantRemaining: 0, wantRetry: 500 * time.Millisecond},
+			},
+		},
+		{
+			name: "refilled at the rate",
+			takes: []take{
+				{wantAllowed: true, wantRemaining: 2},
+				{wantAllowed: true, wantRemaining: 1},
+				{wantAllowed: true, wantRemaining: 0},
+				{after: 250 * time.Millisecond, wantAllowed: false, wantRetry: 250 * time.Millisecond},
+				{after: 250 * time.Millisecond, wantAllowed: true, wantRemaining: 0},
+			},
+		},
+		{
+			name: "refill capped at the burst",
+			takes: []take{
+				{wantAllowed: true, wantRemaining: 2},
+				{after: time.Hour, wantAllowed: true, wantRemaining: 2},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			now := time.Unix(1700000000, 0)
+			s := NewMemoryStore(24 * time.Hour)
+			s.now = func() time.Time { return now }
+
+			for i, tk := range tt.takes {
+				now = now.Add(tk.after)
+
+				res, err := s.Take(context.Background(), "key", limit)
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				if res.Allowed != tk.wantAllowed || res.Remaining != tk.wantRemaining || res.RetryAfter != tk.wantRetry {
+					t.Errorf("take #%d = %+v, want allowed %v, remaining %d, retry after %s",
+						i+1, res, tk.wantAllowed, tk.wantRemaining, tk.wantRetry)
+				}
+
+				if res.Limit != limit.Burst {
+					t.Errorf("take #%d limit = %d, want %d", i+1, res.Limit, limit.Burst)
+				}
+			}
+		})
+	}
+}
+
+func TestMemoryStoreKeysAreIndependent(t *testing.T) {
+	s := NewMemoryStore(time.Minute)
+	limit := Limit{Rate: 1, Burst: 1}
+
+	for _, key := range []string{"user:a", "user:b"} {
+		res, err := s.Take(context.Background(), key, limit)
+		if err != nil || !res.Allowed {
+			t.Errorf("Take(%s) = %+v, %v, want allowed", key, res, err)
+		}
+	}
+}
+
+func TestMemoryStoreForgetsIdleBuckets(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	s := NewMemoryStore(time.Minute)
+	s.now = func() time.Time { return now }
+
+	if _, err := s.Take(context.Background(), "key", Limit{Rate: 1, Burst: 1}); err != nil {
+		t.Fatal(err)
+	}
+
+	now = now.Add(2 * time.Minute)
+
+	if _, err := s.Take(context.Background(), "other", Limit{Rate: 1, Burst: 1}); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, ok := s.buckets["key"]; ok {
+		t.Error("idle bucket is kept")
+	}
+}
+
+func TestResultReset(t *testing.T) {
+	tests := []struct {
+		limit  Limit
+		tokens float64
+		want   time.Duration
+	}{
+		{limit: Limit{Rate: 1, Burst: 5}, tokens: 5, want: 0},
+		{limit: Limit{Rate: 1, Burst: 5}, tokens: 3, want: 2 * time.Second},
+		{limit: Limit{Rate: 10, Burst: 5}, tokens: 0, want: 500 * time.Millisecond},
+		{limit: Limit{Rate: 0, Burst: 5}, tokens: 0, want: 0},
+	}
+
+	for _, tt := range tests {
+		if got := result(tt.limit, tt.tokens, true).Reset; got != tt.want {
+			t.Errorf("result(%+v, %v).Reset = %s, want %s", tt.limit, tt.tokens, got, tt.want)
+		}
+	}
+}
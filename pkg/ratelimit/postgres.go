@@ -0,0 +1,79 @@
+package ratelimit
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/ernur-eskermes/crud-app/pkg/database/postgresql"
+)
+
+// takeQuery refills the bucket for the time elapsed since its last update and takes a token
+// if there is one, in a single statement so concurrent replicas cannot overdraw it.
+// The database clock is used, so replicas with skewed clocks agree on the refill.
+const takeQuery = `
+INSERT INTO rate_limit_buckets AS b (key, tokens, allowed, updated_at)
+VALUES ($1, $2::float8 - 1, true, now())
+ON CONFLICT (key) DO UPDATE SET
+    allowed    = LEAST($2::float8, b.tokens + EXTRACT(EPOCH FROM now() - b.updated_at) * $3::float8) >= 1,
+    tokens     = LEAST($2::float8, b.tokens + EXTRACT(EPOCH FROM now() - b.updated_at) * $3::float8)
+                 - CASE
+                       WHEN LEAST($2::float8, b.tokens + EXTRACT(EPOCH FROM now() - b.updated_at) * $3::float8) >= 1
+                           THEN 1
+                       ELSE 0
+                   END,
+    updated_at = now()
+RETURNING tokens, allowed`
+
+// PostgresStore keeps buckets in the rate_limit_buckets table, shared by all replicas.
+type PostgresStore struct {
+	db  postgresql.Client
+	ttl time.Duration
+
+	mu        sync.Mutex
+	lastSweep time.Time
+}
+
+// NewPostgresStore creates a store that deletes buckets not used for ttl.
+func NewPostgresStore(db postgresql.Client, ttl time.Duration) *PostgresStore {
+	return &PostgresStore{
+		db:  db,
+		ttl: ttl,
+	}
+}
+
+func (s *PostgresStore) Take(ctx context.Context, key string, limit Limit) (Result, error) {
+	if err := s.sweep(ctx); err != nil {
+		return Result{}, err
+	}
+
+	var (
+		tokens  float64
+		allowed bool
+	)
+
+	if err := s.db.QueryRow(ctx, takeQuery, key, float64(limit.Burst), limit.Rate).Scan(&tokens, &allowed); err != nil {
+		return Result{}, err
+	}
+
+	return result(limit, tokens, allowed), nil
+}
+
+// sweep deletes idle buckets, at most once per ttl from each replica.
+func (s *PostgresStore) sweep(ctx context.Context) error {
+	s.mu.Lock()
+
+	if time.Since(s.lastSweep) < s.ttl {
+		s.mu.Unlock()
+
+		return nil
+	}
+
+	s.lastSweep = time.Now()
+	s.mu.Unlock()
+
+	_, err := s.db.Exec(ctx, "DELETE FROM rate_limit_buckets WHERE updated_at < now() - make_interval(secs => $1)",
+		s.ttl.Seconds())
+
+	return err
+}
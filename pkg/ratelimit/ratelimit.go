@@ -0,0 +1,59 @@
+// Package ratelimit implements token bucket rate limiting with pluggable bucket storage.
+package ratelimit
+
+import (
+	"context"
+	"math"
+	"time"
+)
+
+// Limit describes a token bucket: it holds up to Burst tokens and is refilled at Rate tokens per second.
+type Limit struct {
+	Rate  float64
+	Burst int
+}
+
+// Result is the state of a bucket after a request took a token from it.
+type Result struct {
+	Allowed bool
+	// Limit is the capacity of the bucket.
+	Limit int
+	// Remaining is the number of whole tokens left in the bucket.
+	Remaining int
+	// Reset is the time until the bucket is full again.
+	Reset time.Duration
+	// RetryAfter is the time until the next token is available, zero when a request was allowed.
+	RetryAfter time.Duration
+}
+
+// Store keeps token buckets. Implementations must take tokens atomically,
+// so a store shared between replicas enforces a single limit for all of them.
+type Store interface {
+	// Take removes one token from the bucket identified by key, if there is one.
+	Take(ctx context.Context, key string, limit Limit) (Result, error)
+}
+
+// result builds the Result for a bucket that has tokens left after the request.
+func result(limit Limit, tokens float64, allowed bool) Result {
+	res := Result{
+		Allowed:   allowed,
+		Limit:     limit.Burst,
+		Remaining: int(math.Floor(tokens)),
+		Reset:     refillTime(limit, float64(limit.Burst)-tokens),
+	}
+
+	if !allowed {
+		res.RetryAfter = refillTime(limit, 1-tokens)
+	}
+
+	return res
+}
+
+// refillTime is the time needed to refill the given number of tokens.
+func refillTime(limit Limit, tokens float64) time.Duration {
+	if tokens <= 0 || limit.Rate <= 0 {
+		return 0
+	}
+
+	return time.Duration(tokens / limit.Rate * float64(time.Second))
+}
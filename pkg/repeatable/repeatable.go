@@ -1,18 +1,55 @@
 package repeatable
 
-import "time"
+import (
+	"context"
+	"time"
+)
 
-func DoWithTries(fn func() error, attempts int, delay time.Duration) (err error) {
+// DoWithTries calls fn until it succeeds, at most attempts times, waiting delay between the calls.
+// It stops early with the context error when ctx is done.
+func DoWithTries(ctx context.Context, fn func() error, attempts int, delay time.Duration) (err error) {
 	for attempts > 0 {
-		if err = fn(); err != nil {
-			time.Sleep(delay)
-			attempts--
+		if err = fn(); err == nil {
+			return nil
+		}
 
-			continue
+		attempts--
+		if attempts == 0 {
+			break
 		}
 
-		return nil
+		timer := time.NewTimer(delay)
+
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+
+			return ctx.Err()
+		case <-timer.C:
+		}
 	}
 
 	return
 }
+
+// Backoff returns the delay before the retry following the given number of failed attempts:
+// delay doubled for every failure after the first, capped at maxDelay.
+func Backoff(delay, maxDelay time.Duration, failures int) time.Duration {
+	if failures < 1 {
+		return 0
+	}
+
+	for i := 1; i < failures; i++ {
+		if delay >= maxDelay/2 {
+			return maxDelay
+		}
+
+		delay *= 2
+	}
+
+	if delay > maxDelay {
+		return maxDelay
+	}
+
+	return delay
+}
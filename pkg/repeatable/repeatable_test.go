@@ -0,0 +1,79 @@
+package repeatable
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestBackoff(t *testing.T) {
+	tests := []struct {
+		name     string
+		delay    time.Duration
+		maxDelay time.Duration
+		failures int
+		want     time.Duration
+	}{
+		{name: "no failure", delay: time.Second, maxDelay: time.Minute, failures: 0, want: 0},
+		{name: "first failure", delay: time.Second, maxDelay: time.Minute, failures: 1, want: time.Second},
+		{name: "doubled", delay: time.Second, maxDelay: time.Minute, failures: 2, want: 2 * time.Second},
+		{name: "doubled again", delay: time.Second, maxDelay: time.Minute, failures: 4, want: 8 * time.Second},
+		{name: "capped", delay: time.Second, maxDelay: time.Minute, failures: 7, want: time.Minute},
+		{name: "no overflow", delay: time.Second, maxDelay: time.Hour, failures: 1000, want: time.Hour},
+		{name: "delay above the cap", delay: time.Hour, maxDelay: time.Minute, failures: 1, want: time.Minute},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Backoff(tt.delay, tt.maxDelay, tt.failures); got != tt.want {
+				t.Errorf("Backoff(%s, %s, %d) = %s, want %s", tt.delay, tt.maxDelay, tt.failures, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDoWithTries(t *testing.T) {
+	errFailed := errors.New("failed")
+
+	tests := []struct {
+		name      string
+		failures  int
+		attempts  int
+		wantCalls int
+		wantErr   error
+	}{
+		{name: "first call succeeds", failures: 0, attempts: 3, wantCalls: 1},
+		{name: "succeeds on retry", failures: 2, attempts: 3, wantCalls: 3},
+		{name: "out of attempts", failures: 5, attempts: 3, wantCalls: 3, wantErr: errFailed},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calls := 0
+
+			err := DoWithTries(context.Background(), func() error {
+				calls++
+				if calls <= tt.failures {
+					return errFailed
+				}
+
+				return nil
+			}, tt.attempts, time.Millisecond)
+
+			if !errors.Is(err, tt.wantErr) || calls != tt.wantCalls {
+				t.Errorf("DoWithTries() = %v after %d calls, want %v after %d", err, calls, tt.wantErr, tt.wantCalls)
+			}
+		})
+	}
+}
+
+func TestDoWithTriesStopsWhenDone(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	err := DoWithTries(ctx, func() error { return errors.New("failed") }, 3, time.Hour)
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("DoWithTries() = %v, want %v", err, context.Canceled)
+	}
+}
@@ -0,0 +1,54 @@
+package singleflight
+
+import (
+	"errors"
+	"sync"
+)
+
+// errPanicked is returned to the callers waiting for a call that panicked.
+var errPanicked = errors.New("singleflight: call panicked")
+
+type call struct {
+	wg    sync.WaitGroup
+	value interface{}
+	err   error
+}
+
+// Group collapses concurrent calls with the same key into one.
+type Group struct {
+	mu    sync.Mutex
+	calls map[string]*call
+}
+
+// Do calls fn and returns its results, unless a call with the key is in flight already:
+// then it waits for that call and returns its results instead. Shared reports the latter.
+func (g *Group) Do(key string, fn func() (interface{}, error)) (value interface{}, err error, shared bool) {
+	g.mu.Lock()
+	if g.calls == nil {
+		g.calls = make(map[string]*call)
+	}
+
+	if c, ok := g.calls[key]; ok {
+		g.mu.Unlock()
+		c.wg.Wait()
+
+		return c.value, c.err, true
+	}
+
+	c := &call{err: errPanicked}
+	c.wg.Add(1)
+	g.calls[key] = c
+	g.mu.Unlock()
+
+	defer func() {
+		g.mu.Lock()
+		delete(g.calls, key)
+		g.mu.Unlock()
+
+		c.wg.Done()
+	}()
+
+	c.value, c.err = fn()
+
+	return c.value, c.err, false
+}